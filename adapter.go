@@ -0,0 +1,308 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+type (
+	// AdapterBuilder builds an adapter from functions instead of methods named by the conventions
+	// (ForAssignXxxx, ForKindXxxx, ...), for generated code and closures. The functions have the
+	// same signatures as the binding methods without the receiver (v1 or v2). The builder itself
+	// is accepted by NewTraveller as the adapter, and the first error of registrations is returned
+	// there. Bindings are matched in the order of their registrations.
+	AdapterBuilder struct {
+		methods []adapterMethod
+		err     error
+	}
+
+	// adapterMethod is a binding function of an adapter, Method is used for the name and the
+	// signature (with receiver), fn is the function to be called (without receiver).
+	adapterMethod struct {
+		reflect.Method
+		fn      reflect.Value
+		strict  bool         // invalid signature is an error instead of being ignored
+		checked bool         // the signature is known to be a valid v1 binding, no need to check
+		want    reflect.Type // type of the property expected by OnType, nil for others
+		// predicate of the binding registered by OnWhen, nil for others
+		when func(reflect.Value) bool
+		// predicate of the binding registered by OnNode, nil for others
+		match NodePredicate
+	}
+)
+
+var _typeOfAdapterBuilderPtr = reflect.TypeOf((*AdapterBuilder)(nil))
+
+func NewAdapterBuilder() *AdapterBuilder {
+	return &AdapterBuilder{}
+}
+
+// OnType binds fn to the values of the type of sample like ForAssignXxxx, or to the values
+// implementing the interface if sample is a pointer to interface (e.g. (*fmt.Stringer)(nil)) like
+// ForImplXxxx.
+func (b *AdapterBuilder) OnType(sample interface{}, fn interface{}) *AdapterBuilder {
+	typ := reflect.TypeOf(sample)
+	if typ == nil {
+		return b.fail(fmt.Errorf("nil sample for %T", fn))
+	}
+	if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface {
+		typ = typ.Elem()
+	}
+	return b.bindType(typ, fn, false)
+}
+
+// bindType binds fn to the values of typ, or implementing typ if it's an interface
+func (b *AdapterBuilder) bindType(typ reflect.Type, fn interface{}, checked bool) *AdapterBuilder {
+	name := AssignPrefix + typ.String()
+	if typ.Kind() == reflect.Interface {
+		name = ImplPrefix + typ.String()
+	}
+	if b.bind(name, fn, checked).err == nil {
+		b.methods[len(b.methods)-1].want = typ
+	}
+	return b
+}
+
+// OnKind binds fn to the values of the non-container kind like ForKindXxxx
+func (b *AdapterBuilder) OnKind(kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	name, ok := kindName(kind)
+	if _, isContainer := _containers[kind]; !ok || isContainer {
+		return b.fail(fmt.Errorf("kind %s can not be bound by OnKind", kind))
+	}
+	return b.bind(KindPrefix+name, fn, false)
+}
+
+// OnPlaceholder binds fn to the placeholders of structs given by StructPropertier like ForPlaceholder
+func (b *AdapterBuilder) OnPlaceholder(fn interface{}) *AdapterBuilder {
+	return b.bind(PlaceholderName, fn, false)
+}
+
+// OnMapEntry binds fn to the entries of maps like ForMapEntry
+func (b *AdapterBuilder) OnMapEntry(fn interface{}) *AdapterBuilder {
+	return b.bind(MapEntryName, fn, false)
+}
+
+// OnNamed binds fn to the values of named types of the non-container kind like ForNamedXxxx
+func (b *AdapterBuilder) OnNamed(kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	return b.bindKindGroup(NamedPrefix, kind, fn)
+}
+
+// OnMapKey binds fn to the keys of map entries of the non-container kind like ForMapKeyXxxx
+func (b *AdapterBuilder) OnMapKey(kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	return b.bindKindGroup(MapKeyPrefix, kind, fn)
+}
+
+// OnMapValue binds fn to the values of map entries of the non-container kind like ForMapValueXxxx
+func (b *AdapterBuilder) OnMapValue(kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	return b.bindKindGroup(MapValuePrefix, kind, fn)
+}
+
+func (b *AdapterBuilder) bindKindGroup(prefix string, kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	name, ok := kindName(kind)
+	if _, isContainer := _containers[kind]; !ok || isContainer {
+		return b.fail(fmt.Errorf("kind %s can not be bound by %s", kind, prefix))
+	}
+	return b.bind(prefix+name, fn, false)
+}
+
+// OnContainer binds fn to the containers of the kind like ForContainerXxxx
+func (b *AdapterBuilder) OnContainer(kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	name, ok := kindName(kind)
+	if _, isContainer := _containers[kind]; !ok || !isContainer {
+		return b.fail(fmt.Errorf("kind %s is not a container", kind))
+	}
+	return b.bind(ContainerPrefix+name, fn, false)
+}
+
+// OnTag binds fn to the struct fields with the tag option (e.g. "secret" for `dfpt:"secret"`) like
+// ForTagXxxx, fn should have the v2 signature
+func (b *AdapterBuilder) OnTag(option string, fn interface{}) *AdapterBuilder {
+	if option == "" {
+		return b.fail(errors.New("empty tag option"))
+	}
+	return b.bind(TagPrefix+option, fn, false)
+}
+
+// OnDefault binds fn to the values no other binding matches like ForDefault
+func (b *AdapterBuilder) OnDefault(fn interface{}) *AdapterBuilder {
+	return b.bind(DefaultName, fn, false)
+}
+
+// OnNilPtr binds fn to nil pointers like ForNilPtr
+func (b *AdapterBuilder) OnNilPtr(fn interface{}) *AdapterBuilder {
+	return b.bind(NilPtrName, fn, false)
+}
+
+// OnNil binds fn to the nil values of kind (Ptr, Slice, Map or Interface) like ForNilPtr,
+// ForNilSlice, ForNilMap and ForNilInterface
+func (b *AdapterBuilder) OnNil(kind reflect.Kind, fn interface{}) *AdapterBuilder {
+	switch kind {
+	case reflect.Ptr:
+		return b.bind(NilPtrName, fn, false)
+	case reflect.Slice:
+		return b.bind(NilSliceName, fn, false)
+	case reflect.Map:
+		return b.bind(NilMapName, fn, false)
+	case reflect.Interface:
+		return b.bind(NilInterfaceName, fn, false)
+	default:
+		return b.fail(fmt.Errorf("kind %s can not be bound by OnNil", kind))
+	}
+}
+
+// OnNumber binds fn to the numbers not bound by more specific bindings like ForNumber
+func (b *AdapterBuilder) OnNumber(fn interface{}) *AdapterBuilder {
+	return b.bind(NumberName, fn, false)
+}
+
+// OnZero binds fn to the zero values like ForZero
+func (b *AdapterBuilder) OnZero(fn interface{}) *AdapterBuilder {
+	return b.bind(ZeroName, fn, false)
+}
+
+// OnBytes binds fn to byte slices (and byte arrays with TraverseConf.BytesArrays) like ForBytes
+func (b *AdapterBuilder) OnBytes(fn interface{}) *AdapterBuilder {
+	return b.bind(BytesName, fn, false)
+}
+
+// OnWhen binds fn to the values when returns true, fn has the same signature as ForAllKinds. The
+// predicates are evaluated in the order of registrations after ForNilPtr and before the bindings of
+// types and kinds, the value matched is handled as a leaf, e.g. strings looking like URLs:
+//
+//	b.OnWhen(func(v reflect.Value) bool {
+//		return v.Kind() == reflect.String && strings.HasPrefix(v.String(), "https://")
+//	}, handleURL)
+func (b *AdapterBuilder) OnWhen(when func(reflect.Value) bool, fn interface{}) *AdapterBuilder {
+	if when == nil {
+		return b.fail(errors.New("nil predicate"))
+	}
+	if b.bind(AllKindsName, fn, false).err == nil {
+		b.methods[len(b.methods)-1].when = when
+	}
+	return b
+}
+
+// OnNode binds fn to the values whose NodeInfo match returns true, like OnWhen but with the
+// position of the values, e.g. the strings under Users at depth 3:
+//
+//	b.OnNode(And(UnderPath("Users"), ByKind(reflect.String), AtDepth(3)), handleName)
+func (b *AdapterBuilder) OnNode(match NodePredicate, fn interface{}) *AdapterBuilder {
+	if match == nil {
+		return b.fail(errors.New("nil predicate"))
+	}
+	if b.bind(AllKindsName, fn, false).err == nil {
+		b.methods[len(b.methods)-1].match = match
+	}
+	return b
+}
+
+func (b *AdapterBuilder) fail(err error) *AdapterBuilder {
+	if b.err == nil {
+		b.err = err
+	}
+	return b
+}
+
+// bind adds fn as the binding named name, checked is true if fn is known to be a valid v1 binding
+func (b *AdapterBuilder) bind(name string, fn interface{}, checked bool) *AdapterBuilder {
+	fVal := reflect.ValueOf(fn)
+	if !fVal.IsValid() || fVal.Kind() != reflect.Func || fVal.IsNil() {
+		return b.fail(fmt.Errorf("binding %s should be a function, but %T", name, fn))
+	}
+	// the signature with a receiver, so that it can be checked like methods
+	fType := fVal.Type()
+	ins := make([]reflect.Type, 0, fType.NumIn()+1)
+	ins = append(ins, _typeOfAdapterBuilderPtr)
+	for i := 0; i < fType.NumIn(); i++ {
+		ins = append(ins, fType.In(i))
+	}
+	outs := make([]reflect.Type, fType.NumOut())
+	for i := range outs {
+		outs[i] = fType.Out(i)
+	}
+	sig := reflect.FuncOf(ins, outs, fType.IsVariadic())
+	b.methods = append(b.methods, adapterMethod{
+		Method:  reflect.Method{Name: name, Type: sig, Func: reflect.Zero(sig), Index: len(b.methods)},
+		fn:      fVal,
+		strict:  true,
+		checked: checked,
+	})
+	return b
+}
+
+// goInAll is embedded in the adapters of this package to go into all arrays, maps, pointers,
+// slices and structs, unless the adapters bind them with their own methods.
+type goInAll struct{}
+
+func (goInAll) ForContainerArray(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (goInAll) ForContainerMap(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (goInAll) ForContainerPtr(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (goInAll) ForContainerSlice(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (goInAll) ForContainerStruct(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+// kindName returns the name of kind in the binding names
+func kindName(kind reflect.Kind) (string, bool) {
+	for name, k := range _kindMap {
+		if k == kind && name != "Pointer" {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// adapterMethods returns the candidate binding functions of the adapter
+func adapterMethods(aptVal reflect.Value) ([]adapterMethod, error) {
+	if b, ok := aptVal.Interface().(*AdapterBuilder); ok {
+		if b == nil {
+			return nil, ErrInvalidAdapter
+		}
+		if b.err != nil {
+			return nil, b.err
+		}
+		return b.methods, nil
+	}
+	if s, ok := aptVal.Interface().(*Sandbox); ok {
+		if s == nil {
+			return nil, ErrInvalidAdapter
+		}
+		return s.methods, nil
+	}
+	aptType := aptVal.Type()
+	methods := make([]adapterMethod, aptType.NumMethod())
+	for i := range methods {
+		methods[i] = adapterMethod{Method: aptType.Method(i), fn: aptVal.Method(i)}
+	}
+	return methods, nil
+}
@@ -0,0 +1,164 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdapterBuilder(t *testing.T) {
+	type item struct {
+		Name    string
+		Count   int
+		At      time.Duration
+		Stamp   fmt.Stringer
+		Next    *item
+		Enabled bool
+	}
+	var logs []string
+	log := func(node *NodeInfo, format string, args ...interface{}) {
+		logs = append(logs, node.Path.String()+":"+fmt.Sprintf(format, args...))
+	}
+	b := NewAdapterBuilder().
+		OnType(time.Duration(0), func(_ *TravContext, node *NodeInfo, d time.Duration) error {
+			log(node, "duration %s", d)
+			return nil
+		}).
+		OnType((*fmt.Stringer)(nil), func(_ *TravContext, node *NodeInfo, s fmt.Stringer) error {
+			log(node, "stringer %s", s)
+			return nil
+		}).
+		OnKind(reflect.String, func(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+			log(node, "string %s", val.String())
+			return nil
+		}).
+		OnKind(reflect.Int, func(_ *TravContext, _ int, _ int, name string, val int) error {
+			logs = append(logs, fmt.Sprintf("v1 %s=%d", name, val))
+			return nil
+		}).
+		OnContainer(reflect.Struct, func(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnContainer(reflect.Ptr, func(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnNilPtr(func(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+			log(node, "nil")
+			return nil
+		})
+	tr, err := NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &item{Name: "a", Count: 1, At: time.Second, Stamp: time.Minute, Next: &item{Name: "b"}}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	want := "Name:string a|v1 Count=1|At:duration 1s|Stamp:stringer 1m0s|Next.Name:string b|v1 Count=0|" +
+		"Next.At:duration 0s|Next.Stamp:stringer %!s(<nil>)|Next.Next:nil"
+	if got := strings.Join(logs, "|"); got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestAdapterBuilderProperty(t *testing.T) {
+	type email string
+	var got []string
+	b := NewAdapterBuilder().
+		OnKind(reflect.String, func(_ *TravContext, _, _ int, _ string, val string) error {
+			got = append(got, val)
+			return nil
+		}).
+		OnContainer(reflect.Struct, func(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+			return true, nil
+		})
+	tr, err := NewTraveller(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct {
+		Name  string
+		Email email
+	}{Name: "a", Email: "b@c"}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "a,b@c" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestAdapterBuilderErrors(t *testing.T) {
+	valid := func(*TravContext, *NodeInfo, reflect.Value) error { return nil }
+	for _, b := range []*AdapterBuilder{
+		NewAdapterBuilder().OnKind(reflect.Struct, valid),
+		NewAdapterBuilder().OnContainer(reflect.String, valid),
+		NewAdapterBuilder().OnNilPtr("not a function"),
+		NewAdapterBuilder().OnNilPtr(func(*TravContext) error { return nil }),
+		NewAdapterBuilder().OnType("", func(*TravContext, *NodeInfo, int) error { return nil }),
+		NewAdapterBuilder().OnKind(reflect.String, valid).OnKind(reflect.String, valid),
+		NewAdapterBuilder().OnKind(reflect.Int, func(*TravContext, int, int, string, string) error { return nil }),
+		NewAdapterBuilder().OnContainer(reflect.Slice, func(*TravContext, int, int, int, bool, string, []int) (bool, error) {
+			return true, nil
+		}),
+		NewAdapterBuilder(),
+	} {
+		if _, err := NewTraveller(b); err == nil {
+			t.Fatalf("expecting error for %+v", b)
+		} else {
+			t.Log(err)
+		}
+	}
+}
+
+func TestAdapterBuilderOnWhen(t *testing.T) {
+	var got []string
+	isURL := func(val reflect.Value) bool {
+		return val.Kind() == reflect.String && strings.HasPrefix(val.String(), "https://")
+	}
+	b := NewAdapterBuilder().
+		OnWhen(isURL, func(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+			got = append(got, node.Name+":url")
+			return nil
+		}).
+		OnKind(reflect.String, func(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+			got = append(got, node.Name+":string")
+			return nil
+		}).
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		})
+	tr, err := NewTraveller(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct{ Home, Name string }{"https://example.com", "x"}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Home:url Name:string]" {
+		t.Fatalf("got %v", got)
+	}
+	if _, err = NewTraveller(NewAdapterBuilder().OnWhen(nil, func() {})); err == nil {
+		t.Fatal("expecting error of nil predicate")
+	}
+}
@@ -0,0 +1,144 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import "reflect"
+
+type (
+	// Alias is a part of an object sharing its storage with a part of another object, so that
+	// changing one of them through the pointer, slice, map or channel changes the other.
+	Alias struct {
+		Path  Path         // path of the part in the object
+		Other Path         // path of the part in the other object
+		Kind  reflect.Kind // Ptr, Slice, Map or Chan
+	}
+
+	// storage is the memory referenced by a pointer, slice, map or channel, [start, end)
+	storage struct {
+		start, end uintptr
+		path       Path
+	}
+
+	// aliasFinder is the adapter recording the storage of the other object, and then finding the
+	// parts of the object sharing them
+	aliasFinder struct {
+		goInAll
+		others  []storage
+		finding bool
+		aliases []Alias
+	}
+)
+
+// storageOf returns the memory referenced by val. The whole capacity of a slice is its storage, as
+// appending to it may write there. Maps and channels are identified by their headers.
+func storageOf(val reflect.Value) (uintptr, uintptr, bool) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() || val.Type().Elem().Size() == 0 {
+			return 0, 0, false
+		}
+		return val.Pointer(), val.Pointer() + val.Type().Elem().Size(), true
+	case reflect.Slice:
+		if val.Cap() == 0 || val.Type().Elem().Size() == 0 {
+			return 0, 0, false
+		}
+		return val.Pointer(), val.Pointer() + uintptr(val.Cap())*val.Type().Elem().Size(), true
+	case reflect.Map, reflect.Chan:
+		if val.IsNil() {
+			return 0, 0, false
+		}
+		return val.Pointer(), val.Pointer() + 1, true
+	default:
+		return 0, 0, false
+	}
+}
+
+// container records the storage of val, or checks it against the recorded ones. Aliased values
+// are not traversed, as all their descendants are shared too.
+func (f *aliasFinder) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return true, nil
+	}
+	start, end, ok := storageOf(val)
+	if !ok {
+		return true, nil
+	}
+	if !f.finding {
+		f.others = append(f.others, storage{start: start, end: end, path: node.Path})
+		return true, nil
+	}
+	for _, other := range f.others {
+		if start < other.end && other.start < end {
+			f.aliases = append(f.aliases, Alias{Path: node.Path, Other: other.path, Kind: val.Kind()})
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (f *aliasFinder) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (f *aliasFinder) ForAllKinds(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (f *aliasFinder) ForContainerChan(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return f.container(node, startOrEnd, val)
+}
+
+func (f *aliasFinder) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return f.container(node, startOrEnd, val)
+}
+
+func (f *aliasFinder) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return f.container(node, startOrEnd, val)
+}
+
+func (f *aliasFinder) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return f.container(node, startOrEnd, val)
+}
+
+// FindAliases returns the parts of obj sharing storage with other in the traversal order of obj,
+// e.g. to detect unintended aliasing after copying other to obj. Pointers pointing into the
+// storage of the other object (including the elements of its slices), slices overlapping its
+// slices (by capacity) and identical maps and channels are aliases. Only the outermost aliases are
+// reported, their descendants are shared too.
+func FindAliases(obj, other interface{}, conf ...*TraverseConf) ([]Alias, error) {
+	c := confOf(conf...)
+	c.ContainerEnd = false
+	c.Addressable = false
+	c.DetectCycles = true
+	c.InterfaceAutoGoIn = true
+	c.ChanLimit = 0
+	c.AsyncLeaves = 0
+	f := &aliasFinder{}
+	tr, err := NewTraveller(f, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), other); err != nil {
+		return nil, err
+	}
+	f.finding = true
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+	return f.aliases, nil
+}
@@ -0,0 +1,90 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindAliases(t *testing.T) {
+	type inner struct {
+		Values []int
+	}
+	type doc struct {
+		Name   string
+		Tags   []string
+		Attrs  map[string]string
+		Inner  *inner
+		Items  []inner
+		Events chan int
+		Any    interface{}
+	}
+	src := &doc{
+		Name:   "a",
+		Tags:   []string{"x", "y", "z"},
+		Attrs:  map[string]string{"k": "v"},
+		Inner:  &inner{Values: []int{1, 2}},
+		Items:  []inner{{Values: []int{3}}, {Values: []int{4}}},
+		Events: make(chan int),
+		Any:    &inner{},
+	}
+
+	// a shallow copy shares everything but the root
+	shallow := *src
+	aliases, err := FindAliases(&shallow, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]reflect.Kind{
+		"Tags": reflect.Slice, "Attrs": reflect.Map, "Inner": reflect.Ptr, "Items": reflect.Slice,
+		"Events": reflect.Chan, "Any": reflect.Ptr,
+	}
+	if len(aliases) != len(want) {
+		t.Fatalf("expecting %d aliases, got %v", len(want), aliases)
+	}
+	for _, a := range aliases {
+		if want[a.Path.String()] != a.Kind || a.Other.String() != a.Path.String() {
+			t.Fatalf("unexpected alias %+v", a)
+		}
+	}
+
+	// deep copy with a subslice and a pointer into the storage of src
+	copied := &doc{
+		Tags:  src.Tags[1:2],
+		Attrs: map[string]string{"k": "v"},
+		Inner: &src.Items[1],
+		Items: []inner{{Values: src.Inner.Values}},
+	}
+	aliases, err = FindAliases(copied, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, a := range aliases {
+		got[a.Path.String()] = a.Other.String()
+	}
+	expected := map[string]string{"Tags": "Tags", "Inner": "Items", "Items[0].Values": "Inner.Values"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expecting %v, got %v", expected, got)
+	}
+
+	if aliases, err = FindAliases(&doc{Tags: []string{"x"}}, src); err != nil || len(aliases) != 0 {
+		t.Fatalf("expecting no aliases, got %v %v", aliases, err)
+	}
+}
@@ -0,0 +1,86 @@
+//go:build go1.23
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"iter"
+	"reflect"
+)
+
+// yielder is the adapter of the traversal of Traveller.All
+type yielder struct {
+	yield func(Path, reflect.Value) bool
+}
+
+func (y yielder) visit(node *NodeInfo, val reflect.Value) error {
+	if !y.yield(node.Path, val) {
+		return ErrStopTraversal
+	}
+	return nil
+}
+
+func (y yielder) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return y.visit(node, val)
+}
+
+func (y yielder) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return y.visit(node, val)
+}
+
+func (y yielder) ForContainerArray(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return true, y.visit(node, val)
+}
+
+func (y yielder) ForContainerMap(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return true, y.visit(node, val)
+}
+
+func (y yielder) ForContainerPtr(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return true, y.visit(node, val)
+}
+
+func (y yielder) ForContainerSlice(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return true, y.visit(node, val)
+}
+
+func (y yielder) ForContainerStruct(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return true, y.visit(node, val)
+}
+
+// All returns an iterator of all values of obj visited in the traversal with the config of t, with
+// their paths, in depth-first order (containers before their children). Like Iterator, the bindings
+// of the adapter of t are not called. The iteration ends silently at the first error of the
+// traversal, use Iterator if the error matters.
+//
+//	for path, val := range t.All(obj) {
+//		...
+//	}
+func (t *Traveller) All(obj interface{}) iter.Seq2[Path, reflect.Value] {
+	return func(yield func(Path, reflect.Value) bool) {
+		c := confOf(t.conf)
+		c.ContainerEnd = false
+		c.AsyncLeaves = 0
+		tr, err := NewTraveller(yielder{yield: yield}, c)
+		if err != nil {
+			return
+		}
+		_ = tr.Traverse(NewContext(), obj)
+	}
+}
@@ -0,0 +1,53 @@
+//go:build go1.23
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	tr, err := NewTraveller(flattener{}, &TraverseConf{PtrAutoGoIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &listNode{V: 1, Next: &listNode{V: 2}}
+	var paths []string
+	for path, val := range tr.All(obj) {
+		if val.Kind() == reflect.Int {
+			paths = append(paths, path.String())
+		}
+	}
+	if len(paths) != 2 || paths[0] != "V" || paths[1] != "Next.V" {
+		t.Fatalf("paths: %v", paths)
+	}
+
+	count := 0
+	for range tr.All([]int{1, 2, 3}) {
+		count++
+		if count == 2 {
+			break
+		}
+	}
+	if count != 2 {
+		t.Fatalf("count: %d", count)
+	}
+}
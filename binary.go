@@ -0,0 +1,360 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// A reference fixed-order binary codec built on the traversal, struct fields are written in slots
+// order given by SlotPropertier, and every placeholder slot is written as a default byte, so that
+// values of the same struct type always have the same layout:
+//
+//	bool: 1 byte
+//	int/int8/int16/int32/int64: zigzag varint
+//	uint/uint8/uint16/uint32/uint64/uintptr: uvarint
+//	float32/float64: 4/8 bytes IEEE 754 big endian
+//	complex64/complex128: real and imaginary parts as float32/float64
+//	string: uvarint length + bytes
+//	slice/array: uvarint length + elements
+//	map: uvarint length + (key, value) pairs in the order of sorted keys
+//	pointer: 0 for nil, 1 + the pointed value for others
+//	struct: uvarint slot count + slots, placeholder slot is one byte of 0
+//
+// Other kinds (interface, chan, func, unsafe pointer) are not supported.
+
+const binaryDefault byte = 0
+
+var ErrBinaryFormat = errors.New("binary format error")
+
+type (
+	binaryEncoder struct {
+		w io.Writer
+	}
+
+	// structSlots tracks the next slot to be written of a struct being encoded
+	structSlots struct {
+		next int
+	}
+
+	// BinaryReader is the input of DecodeBinary
+	BinaryReader interface {
+		io.Reader
+		io.ByteReader
+	}
+
+	// binarySource is the Source for Populate of the reference binary format
+	binarySource struct {
+		r BinaryReader
+	}
+)
+
+type _binaryStackKey struct{}
+
+func (e binaryEncoder) write(bs ...byte) error {
+	_, err := e.w.Write(bs)
+	return err
+}
+
+func (e binaryEncoder) writeUvarint(u uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return e.write(buf[:binary.PutUvarint(buf, u)]...)
+}
+
+func (e binaryEncoder) writeVarint(i int64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return e.write(buf[:binary.PutVarint(buf, i)]...)
+}
+
+func (e binaryEncoder) stack(ctx *TravContext) *[]*structSlots {
+	if v, ok := ctx.GetLocal(_binaryStackKey{}); ok {
+		return v.(*[]*structSlots)
+	}
+	stack := new([]*structSlots)
+	ctx.PutLocal(_binaryStackKey{}, stack)
+	return stack
+}
+
+// fill writes defaults for placeholder slots of the parent struct before slot index
+func (e binaryEncoder) fill(ctx *TravContext, node *NodeInfo, index int) error {
+	if !node.Parent.IsValid() || node.Parent.Kind() != reflect.Struct {
+		return nil
+	}
+	stack := *e.stack(ctx)
+	if len(stack) == 0 {
+		return errors.New("struct slots stack is empty")
+	}
+	top := stack[len(stack)-1]
+	for ; top.next < index; top.next++ {
+		if err := e.write(binaryDefault); err != nil {
+			return err
+		}
+	}
+	top.next = index + 1
+	return nil
+}
+
+func (e binaryEncoder) ForNilPtr(ctx *TravContext, node *NodeInfo, _ reflect.Value) error {
+	if err := e.fill(ctx, node, node.Index); err != nil {
+		return err
+	}
+	return e.write(0)
+}
+
+func (e binaryEncoder) ForAllKinds(ctx *TravContext, node *NodeInfo, val reflect.Value) error {
+	if err := e.fill(ctx, node, node.Index); err != nil {
+		return err
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		if val.Bool() {
+			return e.write(1)
+		}
+		return e.write(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.writeVarint(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.writeUvarint(val.Uint())
+	case reflect.Float32:
+		return e.writeFloat(val.Float(), 32)
+	case reflect.Float64:
+		return e.writeFloat(val.Float(), 64)
+	case reflect.Complex64:
+		if err := e.writeFloat(real(val.Complex()), 32); err != nil {
+			return err
+		}
+		return e.writeFloat(imag(val.Complex()), 32)
+	case reflect.Complex128:
+		if err := e.writeFloat(real(val.Complex()), 64); err != nil {
+			return err
+		}
+		return e.writeFloat(imag(val.Complex()), 64)
+	case reflect.String:
+		if err := e.writeUvarint(uint64(val.Len())); err != nil {
+			return err
+		}
+		return e.write([]byte(val.String())...)
+	default:
+		return fmt.Errorf("binary: unsupported type %s at %s", val.Type(), node.Path)
+	}
+}
+
+func (e binaryEncoder) writeFloat(f float64, bits int) error {
+	if bits == 32 {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, math.Float32bits(float32(f)))
+		return e.write(buf...)
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(f))
+	return e.write(buf...)
+}
+
+func (e binaryEncoder) _sized(ctx *TravContext, node *NodeInfo, startOrEnd bool, length int) (bool, error) {
+	if !startOrEnd {
+		return false, nil
+	}
+	if err := e.fill(ctx, node, node.Index); err != nil {
+		return false, err
+	}
+	return true, e.writeUvarint(uint64(length))
+}
+
+func (e binaryEncoder) ForContainerArray(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return e._sized(ctx, node, startOrEnd, node.Size)
+}
+
+func (e binaryEncoder) ForContainerSlice(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return e._sized(ctx, node, startOrEnd, node.Size)
+}
+
+func (e binaryEncoder) ForContainerMap(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return e._sized(ctx, node, startOrEnd, node.Size>>1)
+}
+
+func (e binaryEncoder) ForContainerPtr(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return false, nil
+	}
+	if err := e.fill(ctx, node, node.Index); err != nil {
+		return false, err
+	}
+	return true, e.write(1)
+}
+
+func (e binaryEncoder) ForContainerStruct(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	stack := e.stack(ctx)
+	if startOrEnd {
+		if err := e.fill(ctx, node, node.Index); err != nil {
+			return false, err
+		}
+		*stack = append(*stack, &structSlots{})
+		return true, e.writeUvarint(uint64(node.Size))
+	}
+	// placeholders at the tail
+	if len(*stack) == 0 {
+		return false, errors.New("struct slots stack is empty")
+	}
+	top := (*stack)[len(*stack)-1]
+	for ; top.next < node.Size; top.next++ {
+		if err := e.write(binaryDefault); err != nil {
+			return false, err
+		}
+	}
+	*stack = (*stack)[:len(*stack)-1]
+	return false, nil
+}
+
+// EncodeBinary writes obj to w in the reference binary format, pointers at root are dereferenced
+// so that the output can be decoded by DecodeBinary into the same type.
+func EncodeBinary(w io.Writer, obj interface{}) error {
+	val := reflect.ValueOf(obj)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return errors.New("binary: encoding nil pointer")
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return errors.New("binary: encoding invalid value")
+	}
+	tr, err := NewTraveller(binaryEncoder{w: w}, &TraverseConf{
+		Propertier:   SlotPropertier{},
+		ContainerEnd: true,
+		SortMapKeys:  true,
+	})
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), val.Interface())
+}
+
+// DecodeBinary reads a value in the reference binary format from r into obj, which must be a
+// non-nil pointer.
+func DecodeBinary(r BinaryReader, obj interface{}) error {
+	return Populate(binarySource{r: r}, obj, &TraverseConf{Propertier: SlotPropertier{}})
+}
+
+func (s binarySource) NextBool(_ *NodeInfo) (bool, error) {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return false, err
+	}
+	return b != 0, nil
+}
+
+func (s binarySource) NextInt(_ *NodeInfo) (int64, error) {
+	return binary.ReadVarint(s.r)
+}
+
+func (s binarySource) NextUint(_ *NodeInfo) (uint64, error) {
+	return binary.ReadUvarint(s.r)
+}
+
+func (s binarySource) NextFloat(node *NodeInfo) (float64, error) {
+	return readBinaryFloat(s.r, node.Value.Type().Bits())
+}
+
+func (s binarySource) NextComplex(node *NodeInfo) (complex128, error) {
+	bits := node.Value.Type().Bits() / 2
+	re, err := readBinaryFloat(s.r, bits)
+	if err != nil {
+		return 0, err
+	}
+	im, err := readBinaryFloat(s.r, bits)
+	if err != nil {
+		return 0, err
+	}
+	return complex(re, im), nil
+}
+
+func (s binarySource) NextString(_ *NodeInfo) (string, error) {
+	n, err := readBinaryLength(s.r)
+	if err != nil {
+		return "", err
+	}
+	// the buffer grows as the bytes arrive, a forged length can't allocate more than the input
+	var sb strings.Builder
+	if _, err = io.CopyN(&sb, s.r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func (s binarySource) BeginContainer(_ *NodeInfo, kind reflect.Kind) (int, error) {
+	if kind == reflect.Ptr {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		return int(b), nil
+	}
+	return readBinaryLength(s.r)
+}
+
+func (s binarySource) EndContainer(_ *NodeInfo, _ reflect.Kind) error {
+	return nil
+}
+
+func (s binarySource) NextPlaceholder(node *NodeInfo) error {
+	b, err := s.r.ReadByte()
+	if err != nil {
+		return err
+	}
+	if b != binaryDefault {
+		return fmt.Errorf("%w: placeholder slot %d of %s is %d", ErrBinaryFormat, node.Index, node.Parent.Type(), b)
+	}
+	return nil
+}
+
+// readBinaryLength reads a length, which is also the least number of bytes following it (each
+// byte of a string, each element of a container takes at least one byte). If r knows the number of
+// its unread bytes (e.g. *bytes.Reader), lengths exceeding it are rejected before any allocation.
+func readBinaryLength(r BinaryReader) (int, error) {
+	u, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if u > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: length %d too large", ErrBinaryFormat, u)
+	}
+	if lr, ok := r.(interface{ Len() int }); ok && u > uint64(lr.Len()) {
+		return 0, fmt.Errorf("%w: length %d exceeds the %d bytes left", ErrBinaryFormat, u, lr.Len())
+	}
+	return int(u), nil
+}
+
+func readBinaryFloat(r BinaryReader, bits int) (float64, error) {
+	buf := make([]byte, bits/8)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, err
+	}
+	if bits == 32 {
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(buf))), nil
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(buf)), nil
+}
@@ -0,0 +1,161 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type (
+	slotInner struct {
+		X int8  `rtlorder:"1"`
+		Y *bool `rtlorder:"3"`
+	}
+
+	slotObj struct {
+		Name   string           `rtlorder:"2"`
+		ID     uint64           // first free slot: 0
+		Skip   int              `rtlorder:"-"`
+		Ratio  float32          // next free slot: 1
+		Inner  slotInner        `rtlorder:"5"`
+		Ptrs   []*slotInner     `rtlorder:"6"`
+		Labels map[string]int16 `rtlorder:"7"`
+		Fixed  [2]complex64     `rtlorder:"9"`
+		hidden int
+	}
+)
+
+func TestSlotPropertier(t *testing.T) {
+	props := SlotPropertier{}.TypeProperties(reflect.TypeOf(slotObj{}))
+	t.Log(props)
+	expected := []Property{
+		{1, "ID", 0}, {3, "Ratio", 1}, {0, "Name", 2}, {-1, "", 3}, {-1, "", 4},
+		{4, "Inner", 5}, {5, "Ptrs", 6}, {6, "Labels", 7}, {-1, "", 8}, {7, "Fixed", 9},
+	}
+	if !reflect.DeepEqual(props, expected) {
+		t.Fatalf("expecting %v", expected)
+	}
+}
+
+func TestBinaryLayout(t *testing.T) {
+	yes := true
+	buf := new(bytes.Buffer)
+	if err := EncodeBinary(buf, &slotInner{X: -1, Y: &yes}); err != nil {
+		t.Fatal(err)
+	}
+	// 4 slots, placeholder, X=-1(zigzag 1), placeholder, Y=present+true
+	expected := []byte{4, 0, 1, 0, 1, 1}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("got %x, expecting %x", buf.Bytes(), expected)
+	}
+	buf.Reset()
+	if err := EncodeBinary(buf, slotInner{}); err != nil {
+		t.Fatal(err)
+	}
+	if expected = []byte{4, 0, 0, 0, 0}; !bytes.Equal(buf.Bytes(), expected) {
+		t.Fatalf("got %x, expecting %x", buf.Bytes(), expected)
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	yes := false
+	objs := []*slotObj{
+		{},
+		{
+			Name:   "slot",
+			ID:     1 << 40,
+			Skip:   100,
+			Ratio:  1.5,
+			Inner:  slotInner{X: 127, Y: &yes},
+			Ptrs:   []*slotInner{nil, {X: -128}},
+			Labels: map[string]int16{"b": -2, "a": 1, "c": 300},
+			Fixed:  [2]complex64{1 + 2i, -3i},
+			hidden: 1,
+		},
+	}
+	for _, obj := range objs {
+		buf := new(bytes.Buffer)
+		if err := EncodeBinary(buf, obj); err != nil {
+			t.Fatal(err)
+		}
+		encoded := buf.Bytes()
+		t.Logf("%x", encoded)
+		decoded := new(slotObj)
+		if err := DecodeBinary(bytes.NewReader(encoded), decoded); err != nil {
+			t.Fatal(err)
+		}
+		expected := *obj
+		expected.Skip, expected.hidden = 0, 0
+		if len(expected.Ptrs) == 0 {
+			expected.Ptrs = []*slotInner{}
+		}
+		if expected.Labels == nil {
+			expected.Labels = map[string]int16{}
+		}
+		if !reflect.DeepEqual(decoded, &expected) {
+			t.Fatalf("decoded %+v, expecting %+v", decoded, &expected)
+		}
+		// encoding is deterministic
+		again := new(bytes.Buffer)
+		if err := EncodeBinary(again, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(again.Bytes(), encoded) {
+			t.Fatalf("re-encoded %x, expecting %x", again.Bytes(), encoded)
+		}
+	}
+}
+
+func TestDecodeBinaryForgedLength(t *testing.T) {
+	// a string claiming math.MaxInt32 bytes followed by a few
+	forged := []byte{0xff, 0xff, 0xff, 0xff, 0x07, 'a', 'b'}
+	var s string
+	if err := DecodeBinary(bytes.NewReader(forged), &s); !errors.Is(err, ErrBinaryFormat) {
+		t.Fatalf("expecting ErrBinaryFormat, got %v", err)
+	}
+	// readers without Len are read as the bytes arrive
+	if err := DecodeBinary(bufio.NewReader(bytes.NewReader(forged)), &s); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expecting io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestBinaryUnknownField(t *testing.T) {
+	type versioned struct {
+		A int
+		X map[string]interface{} `dfpt:"unknown"`
+		B string
+	}
+	obj := &versioned{A: 1, X: map[string]interface{}{"C": true}, B: "b"}
+	buf := new(bytes.Buffer)
+	if err := EncodeBinary(buf, obj); err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(versioned)
+	if err := DecodeBinary(bytes.NewReader(buf.Bytes()), decoded); err != nil {
+		t.Fatal(err)
+	}
+	// the unknown field is not encoded, its slot is kept by a placeholder
+	if !reflect.DeepEqual(decoded, &versioned{A: 1, B: "b"}) {
+		t.Fatalf("got %+v", decoded)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type (
+	// CachePolicy is the invalidation policy of ResultCache
+	CachePolicy struct {
+		// max number of results cached, the least recently used one is evicted if exceeded, 0 for
+		// unlimited
+		MaxEntries int
+		// results expire TTL after they are computed, 0 for never
+		TTL time.Duration
+		// options of the checksums of the objects, nil for default
+		Checksum *ChecksumOptions
+		// current time for TTL, time.Now if nil
+		Now func() time.Time
+	}
+
+	// CacheStats are the statistics of a ResultCache
+	CacheStats struct {
+		Hits    int
+		Misses  int
+		Evicted int // evicted by MaxEntries or expired by TTL
+	}
+
+	// ResultCache caches the results of an expensive read-only pass (e.g. schema extraction, size
+	// estimation, summaries) by the structural checksums of the objects, so that the pass is not
+	// repeated for identical contents. Computing the checksum traverses the object once, so it only
+	// pays off for passes more expensive than that. Errors are not cached.
+	ResultCache struct {
+		lock    sync.Mutex
+		pass    func(obj interface{}) (interface{}, error)
+		policy  CachePolicy
+		entries map[cacheKey]*list.Element
+		lru     *list.List // of *cacheEntry, the most recently used first
+		stats   CacheStats
+	}
+
+	// cacheKey identifies the contents of an object, types are compared by identity as distinct types
+	// may have the same name (e.g. types declared in functions)
+	cacheKey struct {
+		typ reflect.Type
+		sum string
+	}
+
+	cacheEntry struct {
+		key    cacheKey
+		result interface{}
+		at     time.Time
+	}
+)
+
+func NewResultCache(pass func(obj interface{}) (interface{}, error), policy CachePolicy) *ResultCache {
+	return &ResultCache{
+		pass:    pass,
+		policy:  policy,
+		entries: make(map[cacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *ResultCache) now() time.Time {
+	if c.policy.Now != nil {
+		return c.policy.Now()
+	}
+	return time.Now()
+}
+
+// key returns the cache key of obj: its type and structural checksum
+func (c *ResultCache) key(obj interface{}) (cacheKey, error) {
+	sums, err := Checksum(obj, c.policy.Checksum)
+	if err != nil {
+		return cacheKey{}, err
+	}
+	return cacheKey{typ: reflect.TypeOf(obj), sum: string(sums.Root)}, nil
+}
+
+// Get returns the result of the pass over obj, from the cache if an object with the same type and
+// content was passed before and the result is not evicted.
+func (c *ResultCache) Get(obj interface{}) (interface{}, error) {
+	if obj == nil {
+		return c.pass(obj)
+	}
+	key, err := c.key(obj)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.policy.TTL <= 0 || c.now().Sub(entry.at) < c.policy.TTL {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			c.lock.Unlock()
+			return entry.result, nil
+		}
+		c.remove(elem)
+	}
+	c.stats.Misses++
+	c.lock.Unlock()
+
+	// the pass runs without the lock, concurrent misses of the same key may run it repeatedly
+	result, err := c.pass(obj)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, result: result, at: c.now()})
+	for c.policy.MaxEntries > 0 && c.lru.Len() > c.policy.MaxEntries {
+		c.remove(c.lru.Back())
+	}
+	return result, nil
+}
+
+func (c *ResultCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+	c.stats.Evicted++
+}
+
+// Invalidate removes the result of the objects with the same type and content as obj
+func (c *ResultCache) Invalidate(obj interface{}) error {
+	if obj == nil {
+		return nil
+	}
+	key, err := c.key(obj)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+	}
+	return nil
+}
+
+// Purge removes all the results
+func (c *ResultCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// Len returns the number of the results cached
+func (c *ResultCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *ResultCache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats
+}
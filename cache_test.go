@@ -0,0 +1,99 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResultCache(t *testing.T) {
+	type doc struct {
+		Title string
+		Tags  []string
+	}
+	calls := 0
+	now := time.Unix(0, 0)
+	cache := NewResultCache(func(obj interface{}) (interface{}, error) {
+		calls++
+		return Summarize(obj)
+	}, CachePolicy{MaxEntries: 2, TTL: time.Minute, Now: func() time.Time { return now }})
+
+	get := func(obj interface{}, wantCalls int) {
+		t.Helper()
+		if _, err := cache.Get(obj); err != nil {
+			t.Fatal(err)
+		}
+		if calls != wantCalls {
+			t.Fatalf("calls: %d, want %d", calls, wantCalls)
+		}
+	}
+	get(&doc{Title: "a", Tags: []string{"x"}}, 1)
+	// identical content
+	get(&doc{Title: "a", Tags: []string{"x"}}, 1)
+	// same content of another type
+	get(doc{Title: "a", Tags: []string{"x"}}, 2)
+	get(&doc{Title: "b"}, 3)
+	// evicted by MaxEntries
+	get(&doc{Title: "a", Tags: []string{"x"}}, 4)
+	get(&doc{Title: "b"}, 4)
+
+	if err := cache.Invalidate(&doc{Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	get(&doc{Title: "b"}, 5)
+
+	now = now.Add(time.Minute)
+	get(&doc{Title: "b"}, 6)
+
+	if s := cache.Stats(); s.Hits != 2 || s.Misses != 6 || s.Evicted != 3 || cache.Len() != 2 {
+		t.Fatalf("stats: %+v, len %d", s, cache.Len())
+	}
+	cache.Purge()
+	if cache.Len() != 0 {
+		t.Fatal("not purged")
+	}
+}
+
+func TestResultCacheSameTypeNames(t *testing.T) {
+	pass := func(obj interface{}) (interface{}, error) { return fmt.Sprintf("%T", obj), nil }
+	cache := NewResultCache(pass, CachePolicy{})
+	// distinct types with the same name dfpt.doc and the same contents
+	first := func() interface{} {
+		type doc struct{ Title string }
+		return doc{Title: "a"}
+	}()
+	second := func() interface{} {
+		type doc struct{ Title string }
+		return doc{Title: "a"}
+	}()
+	if reflect.TypeOf(first) == reflect.TypeOf(second) ||
+		reflect.TypeOf(first).String() != reflect.TypeOf(second).String() {
+		t.Fatal("expecting distinct types with the same name")
+	}
+	for _, obj := range []interface{}{first, second, first, second} {
+		if _, err := cache.Get(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := cache.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type (
+	// CDCEvent is a change of the object monitored by ChangeCapture, Before and After are parts of
+	// the snapshots of the object, which are not shared with the object.
+	CDCEvent struct {
+		Path      string
+		Op        ChangeKind
+		Before    interface{}
+		After     interface{}
+		Timestamp time.Time
+	}
+
+	// EventSink receives the events of ChangeCapture
+	EventSink interface {
+		Emit(event CDCEvent) error
+	}
+
+	// ChangeCapture monitors an object by comparing it with its snapshot taken last time, and emits
+	// the differences as CDCEvents to the sink.
+	ChangeCapture struct {
+		lock     sync.Mutex
+		obj      interface{}
+		snapshot interface{}
+		sink     EventSink
+		conf     *TraverseConf
+		// time source of the events, time.Now if nil
+		Now func() time.Time
+	}
+)
+
+// NewChangeCapture takes the first snapshot of obj, which should be given by pointer so that its
+// changes can be captured.
+func NewChangeCapture(obj interface{}, sink EventSink, conf ...*TraverseConf) (*ChangeCapture, error) {
+	if sink == nil {
+		return nil, errors.New("cdc: sink should not be nil")
+	}
+	val := reflect.ValueOf(obj)
+	if !val.IsValid() || val.Kind() != reflect.Ptr || val.IsNil() {
+		return nil, errors.New("cdc: object should be a non-nil pointer")
+	}
+	c := &ChangeCapture{obj: obj, sink: sink, snapshot: deepCopy(val, make(map[refKey]reflect.Value)).Interface()}
+	if len(conf) > 0 && conf[0] != nil {
+		c.conf = conf[0].Clone()
+	}
+	return c, nil
+}
+
+// Capture compares the object with the last snapshot, emits the changes to the sink in traversal
+// order, and then takes the snapshot of the current object. Returns the number of emitted events,
+// the snapshot is not replaced if any error occurs, so the changes would be captured again.
+func (c *ChangeCapture) Capture() (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	snapshot := deepCopy(reflect.ValueOf(c.obj), make(map[refKey]reflect.Value)).Interface()
+	changes, err := Diff(c.snapshot, snapshot, c.conf)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	ts := now()
+	for i, change := range changes {
+		event := CDCEvent{
+			Path:      change.Path.String(),
+			Op:        change.Kind,
+			Before:    change.Old,
+			After:     change.New,
+			Timestamp: ts,
+		}
+		if err = c.sink.Emit(event); err != nil {
+			return i, err
+		}
+	}
+	c.snapshot = snapshot
+	return len(changes), nil
+}
@@ -0,0 +1,100 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	events []CDCEvent
+	fail   bool
+}
+
+func (r *eventRecorder) Emit(event CDCEvent) error {
+	if r.fail {
+		return errors.New("sink failed")
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestChangeCapture(t *testing.T) {
+	type item struct {
+		Name  string
+		Count int
+	}
+	obj := &struct {
+		Title string
+		Items []*item
+		Attrs map[string]string
+	}{
+		Title: "a",
+		Items: []*item{{Name: "x", Count: 1}},
+		Attrs: map[string]string{"k": "v"},
+	}
+	sink := &eventRecorder{}
+	cdc, err := NewChangeCapture(obj, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Unix(100, 0)
+	cdc.Now = func() time.Time { return ts }
+	if n, err := cdc.Capture(); err != nil || n != 0 {
+		t.Fatalf("no changes expected: %d %v", n, err)
+	}
+
+	obj.Items[0].Count = 2
+	obj.Attrs["n"] = "w"
+	delete(obj.Attrs, "k")
+	if n, err := cdc.Capture(); err != nil || n != 3 {
+		t.Fatalf("changes: %d %v %v", n, err, sink.events)
+	}
+	var got []string
+	for _, e := range sink.events {
+		if !e.Timestamp.Equal(ts) {
+			t.Fatalf("timestamp: %v", e.Timestamp)
+		}
+		got = append(got, fmt.Sprintf("%s %s %v %v", e.Op, e.Path, e.Before, e.After))
+	}
+	if fmt.Sprint(got) != "[Modified Items[0].Count 1 2 Removed Attrs[k] v <nil> Added Attrs[n] <nil> w]" {
+		t.Fatalf("events: %v", got)
+	}
+
+	sink.events = nil
+	if n, err := cdc.Capture(); err != nil || n != 0 {
+		t.Fatalf("changes should be captured once: %d %v", n, err)
+	}
+
+	obj.Title = "b"
+	sink.fail = true
+	if _, err = cdc.Capture(); err == nil {
+		t.Fatal("expecting error of sink")
+	}
+	sink.fail = false
+	if n, err := cdc.Capture(); err != nil || n != 1 || sink.events[0].Path != "Title" {
+		t.Fatalf("changes should be captured again after failure: %d %v %v", n, err, sink.events)
+	}
+
+	if _, err = NewChangeCapture(*obj, sink); err == nil {
+		t.Fatal("expecting error of non-pointer object")
+	}
+}
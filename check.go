@@ -0,0 +1,68 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+)
+
+type (
+	// leafCheck returns the problems of a leaf value
+	leafCheck func(node *NodeInfo, val reflect.Value) []error
+
+	// leafChecker is the adapter checking all leaves (including map keys and the values held by
+	// interfaces) by check
+	leafChecker struct {
+		goInAll
+		check  leafCheck
+		issues *Diagnostics
+	}
+)
+
+func (c leafChecker) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (c leafChecker) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	for _, err := range c.check(node, val) {
+		*c.issues = append(*c.issues, Diagnostic{Path: node.Path, Err: err})
+	}
+	return nil
+}
+
+// checkLeaves returns the problems of all leaves of obj found by check, with their paths
+func checkLeaves(obj interface{}, check leafCheck, conf ...*TraverseConf) (Diagnostics, error) {
+	c := confOf(conf...)
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	var issues Diagnostics
+	tr, err := NewTraveller(leafChecker{check: check, issues: &issues}, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
@@ -0,0 +1,177 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"reflect"
+)
+
+type (
+	// ChecksumOptions are the options of Checksum
+	ChecksumOptions struct {
+		// config of the traversal, nil for default. Map keys are always sorted.
+		Conf *TraverseConf
+		// hash function of the digests, sha256.New if nil
+		New func() hash.Hash
+		// if true, digests of all containers are recorded by their paths
+		Subtrees bool
+	}
+
+	// Checksums are the digests of an object, the digest of a container is derived from the type of
+	// the container and the digests of its children in order, so that it's also the ETag of the
+	// subtree.
+	Checksums struct {
+		Root []byte
+		// digests of the containers by paths with ChecksumOptions.Subtrees, a pointer and the value
+		// it points to share the same path, and the digest is of the pointer.
+		ByPath map[string][]byte
+	}
+
+	// checksummer is the adapter computing the digests of all values in post-order
+	checksummer struct {
+		newHash func() hash.Hash
+		stack   []hash.Hash // hashes of the containers being traversed
+		sums    *Checksums
+		tree    *MerkleTree   // not nil if building the Merkle tree
+		nodes   []*MerkleNode // Merkle nodes of the containers being traversed
+	}
+)
+
+// digest records the digest of a value into its container, or as the root digest
+func (c *checksummer) digest(node *NodeInfo, sum []byte, container bool) {
+	if container && c.sums.ByPath != nil {
+		c.sums.ByPath[node.Path.String()] = sum
+	}
+	if c.tree != nil {
+		c.merkle(node, sum, container)
+	}
+	if len(c.stack) == 0 {
+		c.sums.Root = sum
+		return
+	}
+	c.stack[len(c.stack)-1].Write(sum)
+}
+
+func (c *checksummer) leaf(node *NodeInfo, format string, args ...interface{}) {
+	h := c.newHash()
+	h.Write([]byte{0})
+	fmt.Fprintf(h, format, args...)
+	c.digest(node, h.Sum(nil), false)
+}
+
+func (c *checksummer) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	c.leaf(node, "%s\x00nil", val.Type())
+	return nil
+}
+
+func (c *checksummer) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if val.CanInterface() {
+		c.leaf(node, "%T\x00%v", val.Interface(), val.Interface())
+	} else {
+		c.leaf(node, "%s\x00%v", val.Type(), val)
+	}
+	return nil
+}
+
+func (c *checksummer) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	c.leaf(node, "%s\x00cycle:%s", val.Type(), ancestor.Path)
+	return nil
+}
+
+func (c *checksummer) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd {
+		header := append([]byte{1}, val.Type().String()...)
+		header = append(header, 0)
+		h := c.newHash()
+		h.Write(header)
+		c.stack = append(c.stack, h)
+		if c.tree != nil {
+			c.nodes = append(c.nodes, &MerkleNode{header: header})
+		}
+		return true, nil
+	}
+	top := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	c.digest(node, top.Sum(nil), true)
+	return true, nil
+}
+
+func (c *checksummer) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return c.container(node, startOrEnd, val)
+}
+
+func (c *checksummer) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return c.container(node, startOrEnd, val)
+}
+
+func (c *checksummer) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return c.container(node, startOrEnd, val)
+}
+
+func (c *checksummer) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return c.container(node, startOrEnd, val)
+}
+
+func (c *checksummer) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return c.container(node, startOrEnd, val)
+}
+
+// Checksum computes the digest of obj, and the digests of all its containers by their paths if
+// ChecksumOptions.Subtrees is set, which can be used as ETags of the parts of obj. Leaves are
+// digested by their types and formatted values, so values of channels and functions are not
+// stable among processes.
+func Checksum(obj interface{}, opts ...*ChecksumOptions) (*Checksums, error) {
+	s, err := checksum(obj, false, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.sums, nil
+}
+
+func checksum(obj interface{}, tree bool, opts ...*ChecksumOptions) (*checksummer, error) {
+	o := &ChecksumOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		o = opts[0]
+	}
+	c := confOf(o.Conf)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	s := &checksummer{newHash: o.New, sums: &Checksums{}}
+	if s.newHash == nil {
+		s.newHash = sha256.New
+	}
+	if o.Subtrees {
+		s.sums.ByPath = make(map[string][]byte)
+	}
+	if tree {
+		s.tree = &MerkleTree{newHash: s.newHash, byPath: make(map[string]*MerkleNode)}
+	}
+	tr, err := NewTraveller(s, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
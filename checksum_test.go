@@ -0,0 +1,85 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"crypto/md5"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	type section struct {
+		Title string
+		Lines []string
+	}
+	type document struct {
+		Name     string
+		Sections []section
+		Meta     map[string]int
+	}
+	newDoc := func() *document {
+		return &document{
+			Name:     "doc",
+			Sections: []section{{Title: "a", Lines: []string{"1", "2"}}, {Title: "b"}},
+			Meta:     map[string]int{"x": 1, "y": 2, "z": 3},
+		}
+	}
+	opts := &ChecksumOptions{Subtrees: true}
+	base, err := Checksum(newDoc(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(base.Root) != 32 || !bytes.Equal(base.Root, base.ByPath[""]) {
+		t.Fatalf("root digest: %x %x", base.Root, base.ByPath[""])
+	}
+	for _, path := range []string{"Sections", "Sections[0]", "Sections[0].Lines", "Sections[1]", "Sections[1].Lines", "Meta"} {
+		if _, ok := base.ByPath[path]; !ok {
+			t.Fatalf("digest of %s not found in %v", path, base.ByPath)
+		}
+	}
+	again, err := Checksum(newDoc(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(base.Root, again.Root) || !bytes.Equal(base.ByPath["Meta"], again.ByPath["Meta"]) {
+		t.Fatal("digests should be stable")
+	}
+
+	doc := newDoc()
+	doc.Sections[1].Title = "c"
+	changed, err := Checksum(doc, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, sum := range base.ByPath {
+		same := bytes.Equal(sum, changed.ByPath[path])
+		affected := path == "" || path == "Sections" || path == "Sections[1]"
+		if same == affected {
+			t.Errorf("digest of %q: same %t", path, same)
+		}
+	}
+
+	sums, err := Checksum(newDoc(), &ChecksumOptions{New: md5.New})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sums.Root) != md5.Size || sums.ByPath != nil {
+		t.Fatalf("md5 digests: %x %v", sums.Root, sums.ByPath)
+	}
+}
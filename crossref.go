@@ -0,0 +1,328 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var ErrUnresolvedReference = errors.New("unresolved reference")
+
+const (
+	refIndexing = iota
+	refLinking
+	refUnlinking
+)
+
+type (
+	// refField is a pointer field referencing the object of kind whose ID is in field id
+	refField struct {
+		ptr  int
+		id   int
+		kind string
+	}
+
+	// refTypeInfo is the cross reference information of a struct type
+	refTypeInfo struct {
+		idField int    // index of the ID field, -1 if none
+		idKind  string // kind of the objects identified by the ID field
+		refs    []refField
+		err     error // illegal tags
+	}
+
+	// refLinker is the adapter of the phases of LinkReferences and UnlinkReferences
+	refLinker struct {
+		goInAll
+		mode  int
+		index map[string]map[interface{}]reflect.Value // kind -> ID -> pointer to the object
+		// whether the values in the nearest map, pointer or slice being traversed are in the memory of
+		// the object, or copies (values of maps) which are not indexed
+		owned []bool
+	}
+)
+
+var _refInfoCache sync.Map // reflect.Type -> *refTypeInfo
+
+// idFieldOf returns the index and the kind of the ID field of struct type typ, -1 if none
+func idFieldOf(typ reflect.Type) (int, string, error) {
+	index, kind := -1, ""
+	for i, opts := range structInfo(typ).options {
+		k, ok := opts.Get(TagID)
+		if !ok {
+			continue
+		}
+		if index >= 0 {
+			return -1, "", fmt.Errorf("%s has more than one ID field: %s, %s", typ,
+				typ.Field(index).Name, typ.Field(i).Name)
+		}
+		f := typ.Field(i)
+		if f.PkgPath != "" || !f.Type.Comparable() {
+			return -1, "", fmt.Errorf("ID field %s of %s should be exported and comparable", f.Name, typ)
+		}
+		if k == "" {
+			k = typ.Name()
+		}
+		index, kind = i, k
+	}
+	return index, kind, nil
+}
+
+func newRefTypeInfo(typ reflect.Type) *refTypeInfo {
+	info := &refTypeInfo{}
+	info.idField, info.idKind, info.err = idFieldOf(typ)
+	if info.err != nil {
+		return info
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		str, ok := f.Tag.Lookup(RefTagName)
+		if !ok {
+			continue
+		}
+		kind, opts := str, tagOptions(nil)
+		if j := strings.IndexByte(str, ','); j >= 0 {
+			kind, opts = str[:j], parseOptionString(str[j+1:])
+		}
+		kind = strings.TrimSpace(kind)
+		if info.err = checkRefField(typ, f, kind); info.err != nil {
+			return info
+		}
+		idName, ok := opts.Get(RefID)
+		if !ok || idName == "" {
+			idName = f.Name + "ID"
+		}
+		idf, ok := typ.FieldByName(idName)
+		if !ok || len(idf.Index) != 1 || idf.PkgPath != "" {
+			info.err = fmt.Errorf("ID field %s of reference %s.%s not found", idName, typ, f.Name)
+			return info
+		}
+		target := f.Type.Elem().Field(mustIDField(f.Type.Elem()))
+		if !idf.Type.ConvertibleTo(target.Type) || !target.Type.ConvertibleTo(idf.Type) {
+			info.err = fmt.Errorf("ID field %s of reference %s.%s can't be converted to %s",
+				idName, typ, f.Name, target.Type)
+			return info
+		}
+		info.refs = append(info.refs, refField{ptr: i, id: idf.Index[0], kind: kind})
+	}
+	return info
+}
+
+// checkRefField checks that reference field f of typ is an exported pointer to the struct type
+// identified by kind
+func checkRefField(typ reflect.Type, f reflect.StructField, kind string) error {
+	if f.PkgPath != "" || f.Type.Kind() != reflect.Ptr || f.Type.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("reference %s.%s should be an exported pointer to struct", typ, f.Name)
+	}
+	index, k, err := idFieldOf(f.Type.Elem())
+	if err != nil {
+		return err
+	}
+	if index < 0 || k != kind {
+		return fmt.Errorf("reference %s.%s: %s is not the ID of kind %q", typ, f.Name, f.Type.Elem(), kind)
+	}
+	return nil
+}
+
+// mustIDField returns the index of the ID field of typ checked by checkRefField
+func mustIDField(typ reflect.Type) int {
+	index, _, _ := idFieldOf(typ)
+	return index
+}
+
+// refInfo returns the cached cross reference information of the struct type
+func refInfo(typ reflect.Type) *refTypeInfo {
+	if v, ok := _refInfoCache.Load(typ); ok {
+		return v.(*refTypeInfo)
+	}
+	v, _ := _refInfoCache.LoadOrStore(typ, newRefTypeInfo(typ))
+	return v.(*refTypeInfo)
+}
+
+// isRef returns whether the value of node is a reference field
+func isRef(node *NodeInfo) bool {
+	index, ok := fieldIndex(node)
+	if !ok {
+		return false
+	}
+	for _, ref := range refInfo(node.Parent.Type()).refs {
+		if ref.ptr == index {
+			return true
+		}
+	}
+	return false
+}
+
+// add indexes the addressable struct val by its ID, zero IDs are not indexed
+func (l *refLinker) add(node *NodeInfo, info *refTypeInfo, val reflect.Value) error {
+	id := val.Field(info.idField)
+	if id.IsZero() || !val.CanAddr() || len(l.owned) == 0 || !l.owned[len(l.owned)-1] {
+		return nil
+	}
+	ids := l.index[info.idKind]
+	if ids == nil {
+		ids = make(map[interface{}]reflect.Value)
+		l.index[info.idKind] = ids
+	}
+	key := id.Interface()
+	if exist, ok := ids[key]; ok && exist.Pointer() != val.Addr().Pointer() {
+		return fmt.Errorf("duplicated %s ID %v at %s", info.idKind, key, node.Path)
+	}
+	ids[key] = val.Addr()
+	return nil
+}
+
+// link sets the references of val to the objects of their IDs, or nil if the IDs are zero
+func (l *refLinker) link(node *NodeInfo, info *refTypeInfo, val reflect.Value) error {
+	for _, ref := range info.refs {
+		ptr, id := val.Field(ref.ptr), val.Field(ref.id)
+		if id.IsZero() {
+			ptr.Set(reflect.Zero(ptr.Type()))
+			continue
+		}
+		target := ptr.Type().Elem().Field(mustIDField(ptr.Type().Elem())).Type
+		key := id.Convert(target).Interface()
+		obj, ok := l.index[ref.kind][key]
+		if !ok {
+			return fmt.Errorf("%w: %s %v of %s at %s", ErrUnresolvedReference, ref.kind, key,
+				val.Type().Field(ref.ptr).Name, node.Path)
+		}
+		if obj.Type() != ptr.Type() {
+			return fmt.Errorf("%s %v at %s is %s, not %s", ref.kind, key, node.Path, obj.Type(), ptr.Type())
+		}
+		ptr.Set(obj)
+	}
+	return nil
+}
+
+// unlink sets the IDs of the references of val to the IDs of the objects they point to, and clears
+// the references
+func (l *refLinker) unlink(info *refTypeInfo, val reflect.Value) {
+	for _, ref := range info.refs {
+		ptr, id := val.Field(ref.ptr), val.Field(ref.id)
+		if ptr.IsNil() {
+			id.Set(reflect.Zero(id.Type()))
+			continue
+		}
+		id.Set(ptr.Elem().Field(mustIDField(ptr.Type().Elem())).Convert(id.Type()))
+		ptr.Set(reflect.Zero(ptr.Type()))
+	}
+}
+
+func (l *refLinker) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (l *refLinker) ForAllKinds(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (l *refLinker) container(startOrEnd, owned bool) (bool, error) {
+	if startOrEnd {
+		l.owned = append(l.owned, owned)
+	} else {
+		l.owned = l.owned[:len(l.owned)-1]
+	}
+	return true, nil
+}
+
+func (l *refLinker) ForContainerMap(_ *TravContext, _ *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return l.container(startOrEnd, false)
+}
+
+func (l *refLinker) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	if startOrEnd && isRef(node) {
+		// referenced objects are traversed where they are owned
+		return false, nil
+	}
+	return l.container(startOrEnd, true)
+}
+
+func (l *refLinker) ForContainerSlice(_ *TravContext, _ *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return l.container(startOrEnd, true)
+}
+
+func (l *refLinker) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return true, nil
+	}
+	info := refInfo(val.Type())
+	if info.err != nil {
+		return false, info.err
+	}
+	switch l.mode {
+	case refIndexing:
+		if info.idField >= 0 {
+			return true, l.add(node, info, val)
+		}
+	case refLinking, refUnlinking:
+		if len(info.refs) == 0 {
+			return true, nil
+		}
+		if !val.CanSet() {
+			return false, fmt.Errorf("references of %s at %s are not settable", val.Type(), node.Path)
+		}
+		if l.mode == refLinking {
+			return true, l.link(node, info, val)
+		}
+		l.unlink(info, val)
+	}
+	return true, nil
+}
+
+// traverse traverses obj with the linker in mode
+func (l *refLinker) traverse(obj interface{}, mode int, conf []*TraverseConf) error {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.Addressable = true
+	c.DetectCycles = true
+	c.InterfaceAutoGoIn = true
+	c.AsyncLeaves = 0
+	l.mode, l.owned = mode, nil
+	tr, err := NewTraveller(l, c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), obj)
+}
+
+// LinkReferences wires the cross references in obj (normally a pointer, so that its values are
+// settable) from IDs to pointers. In the first phase, addressable structs with an ID field tagged
+// `dfpt:"id=Kind"` (or `dfpt:"id"` for the kind of the struct type name) are indexed by kind and
+// ID, zero IDs and the copies of map values are not indexed. In the second phase, each pointer field tagged `ref:"Kind"` is set
+// to the object of Kind whose ID is in the sibling field named by the RefID option (the pointer
+// field name followed by "ID" by default), or nil if the ID is zero. IDs of unknown objects fail
+// with ErrUnresolvedReference. References are not traversed, the referenced objects should be
+// owned by obj elsewhere, e.g. in a slice of them.
+func LinkReferences(obj interface{}, conf ...*TraverseConf) error {
+	l := &refLinker{index: make(map[string]map[interface{}]reflect.Value)}
+	if err := l.traverse(obj, refIndexing, conf); err != nil {
+		return err
+	}
+	return l.traverse(obj, refLinking, conf)
+}
+
+// UnlinkReferences is the reverse of LinkReferences for serialization: the ID field of each
+// reference tagged with `ref:"Kind"` in obj is set to the ID of the object it points to (zero if
+// nil), and the reference is cleared.
+func UnlinkReferences(obj interface{}, conf ...*TraverseConf) error {
+	return (&refLinker{}).traverse(obj, refUnlinking, conf)
+}
@@ -0,0 +1,126 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"testing"
+)
+
+type (
+	refUser struct {
+		ID        string `dfpt:"id=User"`
+		Name      string
+		Manager   *refUser `ref:"User"`
+		ManagerID string
+	}
+
+	refOrder struct {
+		No      int      `dfpt:"id"`
+		Owner   *refUser `ref:"User,id=OwnerID"`
+		OwnerID string
+		Next    *refOrder `ref:"refOrder,id=NextNo"`
+		NextNo  int64
+	}
+
+	refModel struct {
+		Users  []refUser
+		Orders []*refOrder
+	}
+)
+
+func TestLinkReferences(t *testing.T) {
+	m := &refModel{
+		Users: []refUser{{ID: "u1", Name: "alice"}, {ID: "u2", Name: "bob", ManagerID: "u1"}},
+		Orders: []*refOrder{
+			{No: 1, OwnerID: "u2", NextNo: 2},
+			{No: 2, OwnerID: "u1", Owner: &refUser{ID: "stale"}},
+		},
+	}
+	if err := LinkReferences(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Users[0].Manager != nil || m.Users[1].Manager != &m.Users[0] {
+		t.Fatalf("managers not linked: %+v", m.Users)
+	}
+	if m.Orders[0].Owner != &m.Users[1] || m.Orders[1].Owner != &m.Users[0] {
+		t.Fatalf("owners not linked: %+v %+v", m.Orders[0], m.Orders[1])
+	}
+	if m.Orders[0].Next != m.Orders[1] || m.Orders[1].Next != nil {
+		t.Fatalf("orders not linked: %+v %+v", m.Orders[0], m.Orders[1])
+	}
+	// linking again is a no-op
+	if err := LinkReferences(m); err != nil || m.Orders[0].Owner != &m.Users[1] {
+		t.Fatalf("relinking failed: %v", err)
+	}
+
+	m.Users[1].ID = "u3"
+	if err := UnlinkReferences(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Users[1].Manager != nil || m.Users[1].ManagerID != "u1" {
+		t.Fatalf("manager not unlinked: %+v", m.Users[1])
+	}
+	if o := m.Orders[0]; o.Owner != nil || o.OwnerID != "u3" || o.Next != nil || o.NextNo != 2 {
+		t.Fatalf("order not unlinked: %+v", o)
+	}
+	if o := m.Orders[1]; o.OwnerID != "u1" || o.NextNo != 0 {
+		t.Fatalf("order not unlinked: %+v", o)
+	}
+}
+
+func TestLinkReferencesErrors(t *testing.T) {
+	m := &refModel{
+		Users:  []refUser{{ID: "u1"}},
+		Orders: []*refOrder{{No: 1, OwnerID: "u9"}},
+	}
+	if err := LinkReferences(m); !errors.Is(err, ErrUnresolvedReference) {
+		t.Fatalf("expecting ErrUnresolvedReference, got %v", err)
+	}
+
+	m = &refModel{Users: []refUser{{ID: "u1"}, {ID: "u1"}}}
+	if err := LinkReferences(m); err == nil {
+		t.Fatal("expecting duplicated ID error")
+	}
+
+	// referenced type without the ID of the kind
+	bad := &struct {
+		Order   *refOrder `ref:"User"`
+		OrderID int
+	}{}
+	if err := LinkReferences(bad); err == nil {
+		t.Fatal("expecting illegal reference error")
+	}
+
+	// map values are linked, but not indexed as they are copies
+	dir := &struct {
+		Users  []refUser
+		ByName map[string]refUser
+		Orders []refOrder
+	}{
+		Users:  []refUser{{ID: "u1"}},
+		ByName: map[string]refUser{"bob": {ID: "u2", ManagerID: "u1"}},
+	}
+	if err := LinkReferences(dir); err != nil || dir.ByName["bob"].Manager != &dir.Users[0] {
+		t.Fatalf("map value not linked: %v", err)
+	}
+	dir.Orders = []refOrder{{No: 1, OwnerID: "u2"}}
+	if err := LinkReferences(dir); !errors.Is(err, ErrUnresolvedReference) {
+		t.Fatalf("expecting ErrUnresolvedReference, got %v", err)
+	}
+}
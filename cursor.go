@@ -0,0 +1,100 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Cursor is a position in a traversal: the offsets of a value and its ancestors in their parents
+// (the index of the element in an array or slice, of the field in the properties of a struct, 2i
+// for the key and 2i+1 for the value of the ith entry of a map, 0 for the element of a pointer). It
+// is given by PartialError where the traversal stopped, and can be serialized as a text like
+// "1.0.12" for paginated APIs. Map entries should be traversed with SortMapKeys to make positions
+// stable, and the cursor becomes inaccurate if the object is changed between traversals.
+type Cursor []int
+
+func (c Cursor) String() string {
+	strs := make([]string, len(c))
+	for i, offset := range c {
+		strs[i] = strconv.Itoa(offset)
+	}
+	return strings.Join(strs, ".")
+}
+
+// ParseCursor parses the text of Cursor.String, empty text for the beginning of a traversal
+func ParseCursor(text string) (Cursor, error) {
+	if text == "" {
+		return Cursor{}, nil
+	}
+	parts := strings.Split(text, ".")
+	c := make(Cursor, len(parts))
+	for i, part := range parts {
+		offset, err := strconv.Atoi(part)
+		if err != nil || offset < 0 {
+			return nil, fmt.Errorf("illegal cursor %q", text)
+		}
+		c[i] = offset
+	}
+	return c, nil
+}
+
+func (c Cursor) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *Cursor) UnmarshalText(text []byte) error {
+	parsed, err := ParseCursor(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// skipTo returns whether the value at trail is before the resuming cursor and should be skipped.
+// The resuming is over once the value at the cursor (or the first one after it) is reached, the
+// ancestors of the cursor are not skipped.
+func (c *TravContext) skipTo(trail Cursor) bool {
+	for i := 0; i < len(trail) && i < len(c.resume); i++ {
+		if trail[i] != c.resume[i] {
+			if trail[i] < c.resume[i] {
+				return true
+			}
+			c.resume = nil
+			return false
+		}
+	}
+	if len(trail) >= len(c.resume) {
+		c.resume = nil
+	}
+	return false
+}
+
+// TraverseFrom traverses obj like Traverse, but the values before cursor (given by PartialError)
+// are skipped, the ancestors of the value at cursor are still passed to the container bindings,
+// and counted by MaxNodes. So that a large object can be traversed page by page with MaxNodes.
+func (t *Traveller) TraverseFrom(ctx *TravContext, obj interface{}, cursor Cursor) error {
+	if cursor == nil {
+		cursor = Cursor{}
+	}
+	return t.traverseFrom(ctx, reflect.ValueOf(obj), cursor)
+}
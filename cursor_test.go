@@ -0,0 +1,104 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type leafPaths struct {
+	paths *[]string
+}
+
+func (l leafPaths) ForAllKinds(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	*l.paths = append(*l.paths, node.Path.String())
+	return nil
+}
+
+func (l leafPaths) ForContainerMap(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (l leafPaths) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (l leafPaths) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestTraverseFrom(t *testing.T) {
+	type entry struct {
+		Name  string
+		Attrs map[string]int
+	}
+	obj := make([]entry, 4)
+	for i := range obj {
+		obj[i] = entry{Name: fmt.Sprint("e", i), Attrs: map[string]int{"a": i, "b": i}}
+	}
+	var all []string
+	tr, err := NewTraveller(leafPaths{paths: &all}, &TraverseConf{SortMapKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	var paged []string
+	tr, err = NewTraveller(leafPaths{paths: &paged}, &TraverseConf{SortMapKeys: true, MaxNodes: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cursors []string
+	cursor := Cursor{}
+	for pages := 0; ; pages++ {
+		if pages > len(all) {
+			t.Fatalf("too many pages: %v", cursors)
+		}
+		err = tr.TraverseFrom(nil, obj, cursor)
+		if err == nil {
+			break
+		}
+		var partial *PartialError
+		if !errors.As(err, &partial) {
+			t.Fatal(err)
+		}
+		// through the text form like a paginated API
+		text, _ := json.Marshal(partial.Cursor)
+		cursors = append(cursors, string(text))
+		if err = json.Unmarshal(text, &cursor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(paged, " ") != strings.Join(all, " ") {
+		t.Fatalf("got  %v\nwant %v\ncursors %v", paged, all, cursors)
+	}
+	if len(cursors) < 3 || cursors[0] != `"0.1.3"` {
+		t.Fatalf("cursors %v", cursors)
+	}
+
+	if _, err = ParseCursor("1.x"); err == nil {
+		t.Fatal("expecting error of illegal cursor")
+	}
+}
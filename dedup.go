@@ -0,0 +1,300 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type (
+	// DedupOptions are the options of FindDuplicates
+	DedupOptions struct {
+		// config of the traversal, nil for default
+		Conf *TraverseConf
+		// subtrees with less leaves are ignored, 1 if it's not positive
+		MinLeaves int
+		// if true, duplicates are rewritten to share the first instance, which requires obj to be
+		// passed by pointer. Shared instances can only be referenced by pointers, maps or slices,
+		// so duplicated structs and arrays are interned by their pointers, and values in maps
+		// are not interned since they are not settable.
+		Intern bool
+	}
+
+	// Duplicate is a group of subtrees with the same content
+	Duplicate struct {
+		Type      reflect.Type
+		Paths     []string // paths of the subtrees in traversal order
+		Instances int      // number of distinct instances (subtrees may be shared already)
+		Leaves    int      // number of leaves in each subtree
+		Interned  int      // number of subtrees rewritten to the first one, with DedupOptions.Intern
+	}
+
+	// subtree is a container found by dupScanner
+	subtree struct {
+		path   string
+		seq    int
+		val    reflect.Value
+		hash   uint64
+		leaves int
+	}
+
+	dupFrame struct {
+		h      hash.Hash64
+		leaves int
+	}
+
+	// dupScanner hashes all subtrees in post-order
+	dupScanner struct {
+		stack    []*dupFrame
+		subtrees []*subtree
+		pointers map[string]*subtree // innermost pointer of each path
+	}
+)
+
+func (s *dupScanner) leaf(format string, args ...interface{}) {
+	if len(s.stack) == 0 {
+		return
+	}
+	top := s.stack[len(s.stack)-1]
+	fmt.Fprintf(top.h, format, args...)
+	top.leaves++
+}
+
+func (s *dupScanner) ForNilPtr(_ *TravContext, _ *NodeInfo, val reflect.Value) error {
+	s.leaf("%s\x00nil\x00", val.Type())
+	return nil
+}
+
+func (s *dupScanner) ForAllKinds(_ *TravContext, _ *NodeInfo, val reflect.Value) error {
+	if val.CanInterface() {
+		s.leaf("%T\x00%v\x00", val.Interface(), val.Interface())
+	} else {
+		s.leaf("%s\x00%v\x00", val.Type(), val)
+	}
+	return nil
+}
+
+func (s *dupScanner) ForCycle(_ *TravContext, _ *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	s.leaf("%s\x00cycle:%d\x00", val.Type(), ancestor.Depth)
+	return nil
+}
+
+func (s *dupScanner) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%s\x00", val.Type())
+		s.stack = append(s.stack, &dupFrame{h: h})
+		return true, nil
+	}
+	if len(s.stack) == 0 {
+		return false, errors.New("dedup: container stack is empty")
+	}
+	top := s.stack[len(s.stack)-1]
+	s.stack = s.stack[:len(s.stack)-1]
+	sub := &subtree{path: node.Path.String(), seq: node.Seq, val: val, hash: top.h.Sum64(), leaves: top.leaves}
+	if val.Kind() == reflect.Ptr {
+		if _, exist := s.pointers[sub.path]; !exist {
+			s.pointers[sub.path] = sub
+		}
+	} else {
+		s.subtrees = append(s.subtrees, sub)
+	}
+	if len(s.stack) > 0 {
+		parent := s.stack[len(s.stack)-1]
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], sub.hash)
+		parent.h.Write(buf[:])
+		parent.leaves += sub.leaves
+	}
+	return false, nil
+}
+
+func (s *dupScanner) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val)
+}
+
+func (s *dupScanner) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val)
+}
+
+func (s *dupScanner) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val)
+}
+
+func (s *dupScanner) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val)
+}
+
+func (s *dupScanner) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val)
+}
+
+type dupsBySeq struct {
+	dups []Duplicate
+	seqs []int
+}
+
+func (d dupsBySeq) Len() int           { return len(d.dups) }
+func (d dupsBySeq) Less(i, j int) bool { return d.seqs[i] < d.seqs[j] }
+func (d dupsBySeq) Swap(i, j int) {
+	d.dups[i], d.dups[j] = d.dups[j], d.dups[i]
+	d.seqs[i], d.seqs[j] = d.seqs[j], d.seqs[i]
+}
+
+// identity returns the identity of the instance of subtree, subtrees with the same identity are
+// shared already
+func (sub *subtree) identity() interface{} {
+	if key, ok := referenceOf(sub.val); ok {
+		return key
+	}
+	if sub.val.CanAddr() {
+		return refKey{typ: sub.val.Type(), ptr: sub.val.UnsafeAddr()}
+	}
+	return sub
+}
+
+// isUnder returns whether path is a descendant of ancestor
+func isUnder(path, ancestor string) bool {
+	if ancestor == "" {
+		return path != ""
+	}
+	return len(path) > len(ancestor) && strings.HasPrefix(path, ancestor) &&
+		(path[len(ancestor)] == '.' || path[len(ancestor)] == '[')
+}
+
+// intern rewrites the subtree of sub to first, returns false if it's not settable
+func (s *dupScanner) intern(first, sub *subtree) bool {
+	switch sub.val.Kind() {
+	case reflect.Slice, reflect.Map:
+		if sub.val.CanSet() {
+			sub.val.Set(first.val)
+			return true
+		}
+	default:
+		fp, sp := s.pointers[first.path], s.pointers[sub.path]
+		if fp != nil && sp != nil && sp.val.CanSet() && sp.val.Type() == fp.val.Type() {
+			sp.val.Set(fp.val)
+			return true
+		}
+	}
+	return false
+}
+
+// FindDuplicates returns the groups of subtrees (structs, arrays, slices and maps) of obj with the
+// same content, which are not parts of larger duplicates. Subtrees are hashed in a single pass, and
+// compared by reflect.DeepEqual in case of hash collision.
+func FindDuplicates(obj interface{}, opts ...*DedupOptions) ([]Duplicate, error) {
+	var o DedupOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.MinLeaves <= 0 {
+		o.MinLeaves = 1
+	}
+	c := confOf(o.Conf)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	s := &dupScanner{pointers: make(map[string]*subtree)}
+	tr, err := NewTraveller(s, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+
+	// group by hash and then by content
+	byHash := make(map[uint64][][]*subtree)
+	for _, sub := range s.subtrees {
+		if sub.leaves < o.MinLeaves || !sub.val.CanInterface() {
+			continue
+		}
+		groups := byHash[sub.hash]
+		found := false
+		for i, g := range groups {
+			if g[0].val.Type() == sub.val.Type() && reflect.DeepEqual(g[0].val.Interface(), sub.val.Interface()) {
+				groups[i] = append(g, sub)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []*subtree{sub})
+		}
+		byHash[sub.hash] = groups
+	}
+	var groups [][]*subtree
+	for _, gs := range byHash {
+		for _, g := range gs {
+			if len(g) > 1 {
+				sort.Slice(g, func(i, j int) bool { return g[i].seq < g[j].seq })
+				groups = append(groups, g)
+			}
+		}
+	}
+	// larger subtrees first, to skip the duplicates in them
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i][0].leaves != groups[j][0].leaves {
+			return groups[i][0].leaves > groups[j][0].leaves
+		}
+		return groups[i][0].seq < groups[j][0].seq
+	})
+	var covered []string
+	var dups []Duplicate
+	var seqs []int
+	for _, g := range groups {
+		identities := make(map[interface{}]struct{})
+		inner := true
+		for _, sub := range g {
+			identities[sub.identity()] = struct{}{}
+			under := false
+			for _, p := range covered {
+				if isUnder(sub.path, p) {
+					under = true
+					break
+				}
+			}
+			inner = inner && under
+		}
+		if inner || len(identities) < 2 {
+			continue
+		}
+		dup := Duplicate{Type: g[0].val.Type(), Instances: len(identities), Leaves: g[0].leaves}
+		for _, sub := range g {
+			dup.Paths = append(dup.Paths, sub.path)
+			covered = append(covered, sub.path)
+			if o.Intern && sub != g[0] && sub.identity() != g[0].identity() && s.intern(g[0], sub) {
+				dup.Interned++
+			}
+		}
+		dups = append(dups, dup)
+		seqs = append(seqs, g[0].seq)
+	}
+	// in traversal order
+	sort.Sort(dupsBySeq{dups: dups, seqs: seqs})
+	return dups, nil
+}
@@ -0,0 +1,72 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"testing"
+)
+
+type (
+	dupAddr struct {
+		City string
+		Zip  []int
+	}
+
+	dupPerson struct {
+		Name string
+		Home *dupAddr
+		Work *dupAddr
+		Tags []string
+	}
+)
+
+func TestFindDuplicates(t *testing.T) {
+	shared := &dupAddr{City: "x", Zip: []int{3}}
+	people := &[]dupPerson{
+		{Name: "a", Home: &dupAddr{City: "x", Zip: []int{1, 2}}, Work: shared, Tags: []string{"t"}},
+		{Name: "b", Home: &dupAddr{City: "x", Zip: []int{1, 2}}, Work: shared, Tags: []string{"t"}},
+	}
+	dups, err := FindDuplicates(people)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dups) != 2 {
+		t.Fatalf("dups: %+v", dups)
+	}
+	// [0].Work and [1].Work are the same instance
+	if d := dups[0]; d.Paths[0] != "[0].Home" || d.Paths[1] != "[1].Home" || d.Instances != 2 || d.Leaves != 3 {
+		t.Fatalf("dup 0: %+v", d)
+	}
+	if d := dups[1]; d.Paths[0] != "[0].Tags" || d.Paths[1] != "[1].Tags" || d.Instances != 2 {
+		t.Fatalf("dup 1: %+v", d)
+	}
+
+	dups, err = FindDuplicates(people, &DedupOptions{Intern: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dups[0].Interned != 1 || dups[1].Interned != 1 {
+		t.Fatalf("interned: %+v", dups)
+	}
+	if (*people)[0].Home != (*people)[1].Home || &(*people)[0].Tags[0] != &(*people)[1].Tags[0] {
+		t.Fatal("duplicates should be shared")
+	}
+	if dups, err = FindDuplicates(people); err != nil || len(dups) != 0 {
+		t.Fatalf("after interning: %+v %v", dups, err)
+	}
+}
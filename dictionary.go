@@ -0,0 +1,128 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+type (
+	// StringCount is a distinct string with its occurrences
+	StringCount struct {
+		Value string
+		Count int
+	}
+
+	// StringDictionary collects the distinct string leaves (including map keys and strings in
+	// interfaces) with their occurrences of one or more objects, for building dictionaries for
+	// compression or discovering enums.
+	StringDictionary struct {
+		lock sync.Mutex
+		// occurrences of each distinct string
+		Counts map[string]int
+		// by path pattern, with indexes and map keys replaced by *, e.g. "Users[*].Role", nil if the
+		// dictionary is not grouped by paths
+		ByPath map[string]map[string]int
+	}
+)
+
+// NewStringDictionary returns an empty dictionary, strings are also counted by their path patterns
+// if byPath is true.
+func NewStringDictionary(byPath bool) *StringDictionary {
+	d := &StringDictionary{Counts: make(map[string]int)}
+	if byPath {
+		d.ByPath = make(map[string]map[string]int)
+	}
+	return d
+}
+
+func (d *StringDictionary) add(node *NodeInfo, s string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.Counts[s]++
+	if d.ByPath != nil {
+		pattern := pathPattern(node.Path)
+		counts, ok := d.ByPath[pattern]
+		if !ok {
+			counts = make(map[string]int)
+			d.ByPath[pattern] = counts
+		}
+		counts[s]++
+	}
+}
+
+// Analyze adds the string leaves of obj to the dictionary, cycles are not traversed repeatedly.
+func (d *StringDictionary) Analyze(obj interface{}, conf ...*TraverseConf) error {
+	_, err := checkLeaves(obj, func(node *NodeInfo, val reflect.Value) []error {
+		if val.Kind() == reflect.String {
+			d.add(node, val.String())
+		}
+		return nil
+	}, conf...)
+	return err
+}
+
+// topStrings returns the n (all if n <= 0) most frequent strings of counts, ties are in the order
+// of the strings.
+func topStrings(counts map[string]int, n int) []StringCount {
+	ret := make([]StringCount, 0, len(counts))
+	for s, c := range counts {
+		ret = append(ret, StringCount{Value: s, Count: c})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count != ret[j].Count {
+			return ret[i].Count > ret[j].Count
+		}
+		return ret[i].Value < ret[j].Value
+	})
+	if n > 0 && len(ret) > n {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// Top returns the n (all if n <= 0) most frequent strings
+func (d *StringDictionary) Top(n int) []StringCount {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return topStrings(d.Counts, n)
+}
+
+// TopByPath returns the n (all if n <= 0) most frequent strings at the path pattern, e.g.
+// "Users[*].Role", nil if the dictionary is not grouped by paths.
+func (d *StringDictionary) TopByPath(pattern string, n int) []StringCount {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	counts, ok := d.ByPath[pattern]
+	if !ok {
+		return nil
+	}
+	return topStrings(counts, n)
+}
+
+// CollectStrings returns the dictionary of the string leaves of obj, grouped by path patterns if
+// byPath is true.
+func CollectStrings(obj interface{}, byPath bool, conf ...*TraverseConf) (*StringDictionary, error) {
+	d := NewStringDictionary(byPath)
+	if err := d.Analyze(obj, conf...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
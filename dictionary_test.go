@@ -0,0 +1,61 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCollectStrings(t *testing.T) {
+	type user struct {
+		Name string
+		Role string
+	}
+	obj := &struct {
+		Users  []user
+		Labels map[string]interface{}
+	}{
+		Users:  []user{{"alice", "admin"}, {"bob", "guest"}, {"carol", "guest"}},
+		Labels: map[string]interface{}{"guest": "admin", "n": 1},
+	}
+	d, err := CollectStrings(obj, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(d.Top(2)); got != "[{guest 3} {admin 2}]" {
+		t.Fatalf("top: %s", got)
+	}
+	if got := fmt.Sprint(d.TopByPath("Users[*].Role", 0)); got != "[{guest 2} {admin 1}]" {
+		t.Fatalf("roles: %s", got)
+	}
+	if got := fmt.Sprint(d.TopByPath("Labels{*}", 0)); got != "[{guest 1} {n 1}]" {
+		t.Fatalf("keys: %s", got)
+	}
+	if got := d.TopByPath("Users", 0); got != nil {
+		t.Fatalf("unexpected %v", got)
+	}
+
+	d, err = CollectStrings(obj, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ByPath != nil || len(d.Top(0)) != 6 {
+		t.Fatalf("got %v %v", d.ByPath, d.Top(0))
+	}
+}
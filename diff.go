@@ -0,0 +1,223 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ChangeKind is the kind of a Change
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "Added"
+	case ChangeRemoved:
+		return "Removed"
+	case ChangeModified:
+		return "Modified"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", int(k))
+	}
+}
+
+type (
+	// Change is a difference between the old object and the new one found by Diff. Old is nil if
+	// the value is added, New is nil if it's removed.
+	Change struct {
+		Path Path
+		Kind ChangeKind
+		Old  interface{}
+		New  interface{}
+	}
+
+	// differ is the adapter comparing the values of the old object with their counterparts in the
+	// new one in lockstep
+	differ struct {
+		pairs   *pairStack
+		changes []Change
+	}
+)
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeAdded:
+		return fmt.Sprintf("+ %s: %v", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("- %s: %v", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %v -> %v", c.Path, c.Old, c.New)
+	}
+}
+
+func interfaceOf(val reflect.Value) interface{} {
+	if !val.IsValid() || !val.CanInterface() {
+		return nil
+	}
+	return val.Interface()
+}
+
+func (d *differ) add(path Path, kind ChangeKind, old, new reflect.Value) {
+	d.changes = append(d.changes, Change{Path: path, Kind: kind, Old: interfaceOf(old), New: interfaceOf(new)})
+}
+
+func (d *differ) leaf(node *NodeInfo, val reflect.Value) error {
+	if isMapKey(node.Path) {
+		// changes of map keys are reported as the values added or removed
+		return nil
+	}
+	other := d.pairs.counterpart(node)
+	if !other.IsValid() {
+		d.add(node.Path, ChangeRemoved, val, other)
+	} else if !sameValue(val, other, nil) {
+		d.add(node.Path, ChangeModified, val, other)
+	}
+	return nil
+}
+
+func (d *differ) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		d.added(node, val, d.pairs.frames[len(d.pairs.frames)-1].other)
+		d.pairs.pop()
+		return true, nil
+	}
+	if isMapKey(node.Path) {
+		return false, nil
+	}
+	other := d.pairs.counterpart(node)
+	switch {
+	case !other.IsValid():
+		d.add(node.Path, ChangeRemoved, val, other)
+		return false, nil
+	case val.Type() != other.Type() || opaqueStruct(node, val):
+		if !sameValue(val, other, nil) {
+			d.add(node.Path, ChangeModified, val, other)
+		}
+		return false, nil
+	}
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if val.IsNil() != other.IsNil() && (val.Kind() == reflect.Ptr || (val.Len() == 0 && other.Len() == 0)) {
+			d.add(node.Path, ChangeModified, val, other)
+			return false, nil
+		}
+	}
+	d.pairs.push(node, other)
+	return true, nil
+}
+
+// added reports the elements of the new container other which are not in the old one val
+func (d *differ) added(node *NodeInfo, val, other reflect.Value) {
+	child := func(n PathNode) Path {
+		path := make(Path, len(node.Path), len(node.Path)+1)
+		copy(path, node.Path)
+		return append(path, n)
+	}
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := val.Len(); i < other.Len(); i++ {
+			d.add(child(PathNode{Kind: val.Kind(), Index: i}), ChangeAdded, reflect.Value{}, other.Index(i))
+		}
+	case reflect.Map:
+		keys := other.MapKeys()
+		sortValues(keys)
+		for _, key := range keys {
+			if !val.MapIndex(key).IsValid() {
+				d.add(child(PathNode{Kind: reflect.Map, Key: key}), ChangeAdded, reflect.Value{}, other.MapIndex(key))
+			}
+		}
+	}
+}
+
+func (d *differ) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return d.leaf(node, val)
+}
+
+func (d *differ) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return d.leaf(node, val)
+}
+
+func (d *differ) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	other := d.pairs.counterpart(node)
+	key, ok := referenceOf(other)
+	akey, aok := referenceOf(d.pairs.ancestor(ancestor))
+	if !ok || !aok || key != akey {
+		d.add(node.Path, ChangeModified, val, other)
+	}
+	return nil
+}
+
+func (d *differ) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return d.container(node, startOrEnd, val)
+}
+
+func (d *differ) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return d.container(node, startOrEnd, val)
+}
+
+func (d *differ) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return d.container(node, startOrEnd, val)
+}
+
+func (d *differ) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return d.container(node, startOrEnd, val)
+}
+
+func (d *differ) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return d.container(node, startOrEnd, val)
+}
+
+// Diff walks old and new in lockstep and returns their differences in traversal order: leaves and
+// containers only in old are Removed, the ones only in new are Added (elements appended to slices
+// and entries of new keys in maps), and the ones with different values, types or nil-ness are
+// Modified. Changed containers are reported as a whole without their children. Map keys are sorted
+// for a stable result.
+func Diff(old, new interface{}, conf ...*TraverseConf) ([]Change, error) {
+	if old == nil || new == nil {
+		if old != nil {
+			return []Change{{Kind: ChangeRemoved, Old: old}}, nil
+		}
+		if new != nil {
+			return []Change{{Kind: ChangeAdded, New: new}}, nil
+		}
+		return nil, nil
+	}
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.TrackReferences = false
+	c.AsyncLeaves = 0
+	d := &differ{pairs: newPairStack(new)}
+	tr, err := NewTraveller(d, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), old); err != nil {
+		return nil, err
+	}
+	return d.changes, nil
+}
@@ -0,0 +1,94 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	type address struct {
+		City string
+	}
+	type person struct {
+		Name    string
+		Age     int
+		Tags    []string
+		Attrs   map[string]interface{}
+		Home    *address
+		Work    *address
+		Friends []string
+	}
+	old := &person{
+		Name:  "alice",
+		Age:   30,
+		Tags:  []string{"a", "b", "c"},
+		Attrs: map[string]interface{}{"k1": 1, "k2": "v", "k3": true},
+		Home:  &address{City: "x"},
+	}
+	new := &person{
+		Name:    "alice",
+		Age:     31,
+		Tags:    []string{"a", "z"},
+		Attrs:   map[string]interface{}{"k1": 1, "k2": 2, "k4": "new"},
+		Work:    &address{City: "y"},
+		Friends: []string{"bob"},
+	}
+	changes, err := Diff(old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []string{
+		"~ Age: 30 -> 31",
+		"~ Tags[1]: b -> z",
+		"- Tags[2]: c",
+		"~ Attrs[k2]: v -> 2",
+		"- Attrs[k3]: true",
+		"+ Attrs[k4]: new",
+		"~ Home: &{x} -> <nil>",
+		"~ Work: <nil> -> &{y}",
+		"+ Friends[0]: bob",
+	}
+	if len(changes) != len(expects) {
+		t.Fatalf("changes: %v", changes)
+	}
+	for i, change := range changes {
+		if change.String() != expects[i] {
+			t.Errorf("change %d: %s, expecting %s", i, change, expects[i])
+		}
+	}
+
+	changes, err = Diff(new, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(changes[1:3]) != "[~ Tags[1]: z -> b + Tags[2]: c]" {
+		t.Fatalf("reversed changes: %v", changes)
+	}
+
+	if changes, err = Diff(old, old); err != nil || len(changes) != 0 {
+		t.Fatalf("no changes expected: %v %v", changes, err)
+	}
+	if changes, _ = Diff(1, "1"); len(changes) != 1 || changes[0].Kind != ChangeModified {
+		t.Fatalf("root changes: %v", changes)
+	}
+	if changes, _ = Diff(nil, 1); len(changes) != 1 || changes[0].Kind != ChangeAdded {
+		t.Fatalf("root changes: %v", changes)
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type (
+	// EqualOption customizes Equal
+	EqualOption func(c *equalConfig)
+
+	equalConfig struct {
+		conf        *TraverseConf
+		comparators map[reflect.Type]reflect.Value
+		err         error
+	}
+
+	// equalizer is the adapter comparing the values with their counterparts in the other object,
+	// the traversal is stopped at the first difference.
+	equalizer struct {
+		cfg   *equalConfig
+		pairs *pairStack
+		equal bool
+	}
+)
+
+// WithComparator registers fn with signature func(a, b T) bool to compare values of type T in
+// Equal, instead of comparing them property by property, e.g. time.Time values within tolerance.
+func WithComparator(fn interface{}) EqualOption {
+	return func(c *equalConfig) {
+		f := reflect.ValueOf(fn)
+		if f.Kind() != reflect.Func || f.IsNil() {
+			c.err = fmt.Errorf("comparator should be a func(a, b T) bool, got %T", fn)
+			return
+		}
+		ft := f.Type()
+		if ft.NumIn() != 2 || ft.In(0) != ft.In(1) || ft.NumOut() != 1 || ft.Out(0) != _typeOfBool {
+			c.err = fmt.Errorf("comparator should be a func(a, b T) bool, got %s", ft)
+			return
+		}
+		if c.comparators == nil {
+			c.comparators = make(map[reflect.Type]reflect.Value)
+		}
+		c.comparators[ft.In(0)] = f
+	}
+}
+
+// WithEqualConf sets the configuration of the traversal in Equal, e.g. the Propertier
+func WithEqualConf(conf *TraverseConf) EqualOption {
+	return func(c *equalConfig) {
+		c.conf = conf
+	}
+}
+
+func (e *equalizer) differ() error {
+	e.equal = false
+	return ErrStopTraversal
+}
+
+// same compares the values without going into them
+func (e *equalizer) same(a, b reflect.Value) bool {
+	return sameValue(a, b, e.cfg.comparators)
+}
+
+// sameValue compares a and b as a whole, by the comparator of their type if any
+func sameValue(a, b reflect.Value, comparators map[reflect.Type]reflect.Value) bool {
+	if !b.IsValid() || a.Type() != b.Type() {
+		return false
+	}
+	if cmp, ok := comparators[a.Type()]; ok {
+		return cmp.Call([]reflect.Value{a, b})[0].Bool()
+	}
+	if a.Kind() == reflect.Interface {
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return sameValue(a.Elem(), b.Elem(), comparators)
+	}
+	if a.CanInterface() && b.CanInterface() {
+		return reflect.DeepEqual(a.Interface(), b.Interface())
+	}
+	return compareValue(a, b) == 0
+}
+
+// opaqueStruct returns whether val is a struct without properties (e.g. time.Time), which should be
+// compared as a whole
+func opaqueStruct(node *NodeInfo, val reflect.Value) bool {
+	return val.Kind() == reflect.Struct && node.Size == 0 && val.NumField() > 0
+}
+
+func (e *equalizer) leaf(node *NodeInfo, val reflect.Value) error {
+	if !e.same(val, e.pairs.counterpart(node)) {
+		return e.differ()
+	}
+	return nil
+}
+
+func (e *equalizer) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		e.pairs.pop()
+		return true, nil
+	}
+	other := e.pairs.counterpart(node)
+	if !other.IsValid() || val.Type() != other.Type() {
+		return false, e.differ()
+	}
+	_, compared := e.cfg.comparators[val.Type()]
+	if compared || opaqueStruct(node, val) {
+		// compared by comparator, or a struct without properties (e.g. time.Time) compared as a whole
+		if !e.same(val, other) {
+			return false, e.differ()
+		}
+		return false, nil
+	}
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if val.IsNil() != other.IsNil() || (val.Kind() != reflect.Ptr && val.Len() != other.Len()) {
+			return false, e.differ()
+		}
+	}
+	e.pairs.push(node, other)
+	return true, nil
+}
+
+func (e *equalizer) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return e.leaf(node, val)
+}
+
+func (e *equalizer) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return e.leaf(node, val)
+}
+
+func (e *equalizer) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, _ reflect.Value) error {
+	// the counterpart should reference the counterpart of the ancestor too
+	key, ok := referenceOf(e.pairs.counterpart(node))
+	akey, aok := referenceOf(e.pairs.ancestor(ancestor))
+	if !ok || !aok || key != akey {
+		return e.differ()
+	}
+	return nil
+}
+
+func (e *equalizer) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e *equalizer) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e *equalizer) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e *equalizer) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e *equalizer) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+// Equal reports whether a and b are deeply equal by traversing a and comparing each value with its
+// counterpart in b in lockstep. Unlike reflect.DeepEqual, structs are compared by their properties
+// (exported fields by default, see TraverseConf.Propertier), structs without properties (e.g.
+// time.Time) are compared as a whole, and values of the types with comparators registered by
+// WithComparator are compared by the comparators.
+func Equal(a, b interface{}, opts ...EqualOption) (bool, error) {
+	cfg := &equalConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	if cfg.err != nil {
+		return false, cfg.err
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil, nil
+	}
+	c := confOf(cfg.conf)
+	c.ContainerEnd = true
+	c.DetectCycles = true
+	c.TrackReferences = false
+	c.AsyncLeaves = 0
+	e := &equalizer{cfg: cfg, pairs: newPairStack(b), equal: true}
+	tr, err := NewTraveller(e, c)
+	if err != nil {
+		return false, err
+	}
+	if err = tr.Traverse(NewContext(), a); err != nil {
+		return false, err
+	}
+	return e.equal, nil
+}
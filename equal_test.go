@@ -0,0 +1,112 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"testing"
+	"time"
+)
+
+type equalNode struct {
+	Name  string
+	At    time.Time
+	Tags  []string
+	Attrs map[string]interface{}
+	Next  *equalNode
+}
+
+func TestEqual(t *testing.T) {
+	now := time.Now()
+	newNode := func() *equalNode {
+		return &equalNode{
+			Name:  "a",
+			At:    now,
+			Tags:  []string{"x", "y"},
+			Attrs: map[string]interface{}{"n": 1, "s": "v"},
+			Next:  &equalNode{Name: "b"},
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(n *equalNode)
+		expect bool
+	}{
+		{"same", func(n *equalNode) {}, true},
+		{"leaf", func(n *equalNode) { n.Next.Name = "c" }, false},
+		{"time", func(n *equalNode) { n.At = now.Add(time.Second) }, false},
+		{"longer", func(n *equalNode) { n.Tags = append(n.Tags, "z") }, false},
+		{"nil slice", func(n *equalNode) { n.Next.Tags = []string{} }, false},
+		{"map key", func(n *equalNode) { delete(n.Attrs, "n"); n.Attrs["m"] = 1 }, false},
+		{"interface type", func(n *equalNode) { n.Attrs["n"] = int64(1) }, false},
+		{"nil pointer", func(n *equalNode) { n.Next = nil }, false},
+	}
+	for _, test := range tests {
+		b := newNode()
+		test.modify(b)
+		got, err := Equal(newNode(), b)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if got != test.expect {
+			t.Errorf("%s: got %t, expecting %t", test.name, got, test.expect)
+		}
+	}
+
+	within := WithComparator(func(a, b time.Time) bool {
+		d := a.Sub(b)
+		return d < time.Minute && d > -time.Minute
+	})
+	b := newNode()
+	b.At = now.Add(time.Second)
+	if eq, err := Equal(newNode(), b, within); err != nil || !eq {
+		t.Fatalf("with comparator: %t %v", eq, err)
+	}
+	b.At = now.Add(time.Hour)
+	if eq, err := Equal(newNode(), b, within); err != nil || eq {
+		t.Fatalf("with comparator: %t %v", eq, err)
+	}
+
+	if _, err := Equal(1, 1, WithComparator(func(a int, b string) bool { return true })); err == nil {
+		t.Fatal("expecting error of illegal comparator")
+	}
+	if eq, _ := Equal(nil, nil); !eq {
+		t.Fatal("nils should be equal")
+	}
+	if eq, _ := Equal(1, "1"); eq {
+		t.Fatal("values of different types should not be equal")
+	}
+}
+
+func TestEqualCycle(t *testing.T) {
+	newRing := func(name string) *equalNode {
+		n := &equalNode{Name: name}
+		n.Next = &equalNode{Name: "tail", Next: n}
+		return n
+	}
+	if eq, err := Equal(newRing("a"), newRing("a")); err != nil || !eq {
+		t.Fatalf("equal rings: %t %v", eq, err)
+	}
+	if eq, err := Equal(newRing("a"), newRing("b")); err != nil || eq {
+		t.Fatalf("different rings: %t %v", eq, err)
+	}
+	a, b := newRing("a"), newRing("a")
+	b.Next.Next = b.Next
+	if eq, err := Equal(a, b); err != nil || eq {
+		t.Fatalf("different cycles: %t %v", eq, err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// EventExporter is a ScriptRuntime writing the events to w in the serializable form of traversals:
+// one JSON object of ScriptEvent per line, so that a traversal in one process can drive a
+// ScriptRuntime in another (see ImportEvents). Complex numbers and non-finite floats are written
+// as strings, since JSON can't represent them.
+type EventExporter struct {
+	enc *json.Encoder
+}
+
+func NewEventExporter(w io.Writer) *EventExporter {
+	return &EventExporter{enc: json.NewEncoder(w)}
+}
+
+func (e *EventExporter) Handle(ev ScriptEvent) (ScriptResult, error) {
+	switch v := ev.Value.(type) {
+	case complex128:
+		ev.Value = fmt.Sprint(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			ev.Value = strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	}
+	return ScriptResult{}, e.enc.Encode(ev)
+}
+
+// ExportEvents writes the events of the traversal of obj to w, see EventExporter
+func ExportEvents(w io.Writer, obj interface{}, conf ...*TraverseConf) error {
+	return RunScript(obj, NewEventExporter(w), conf...)
+}
+
+// ImportEvents reads the events written by EventExporter from r and passes them to runtime in
+// order, the values of the leaves are restored to the types of ScriptEvent.Value. Replacements of
+// the runtime are ignored, children of the containers skipped are not passed, and ScriptResult.Stop
+// stops the reading.
+func ImportEvents(r io.Reader, runtime ScriptRuntime) error {
+	if runtime == nil {
+		return ErrInvalidAdapter
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var skipping *ScriptEvent // start of the container being skipped
+	for {
+		var ev ScriptEvent
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read event: %w", err)
+		}
+		if skipping != nil {
+			if ev.Kind == EventEnd && ev.Seq == skipping.Seq {
+				skipping = nil
+			}
+			continue
+		}
+		value, err := wireValue(ev.ValueKind, ev.Value)
+		if err != nil {
+			return fmt.Errorf("event %d at %s: %w", ev.Seq, ev.Path, err)
+		}
+		ev.Value = value
+		ret, err := runtime.Handle(ev)
+		if err != nil {
+			return fmt.Errorf("script of %s at %s: %w", ev.Kind, ev.Path, err)
+		}
+		if ret.Stop {
+			return nil
+		}
+		if ret.Skip && ev.Kind == EventStart {
+			skipping = &ev
+		}
+	}
+}
+
+// wireValue restores the value of a leaf of the kind (ScriptEvent.ValueKind) decoded from JSON
+func wireValue(kind string, v interface{}) (interface{}, error) {
+	rkind := _kindMap[kind]
+	nk, isNumber := numberKindOf(rkind)
+	switch val := v.(type) {
+	case json.Number:
+		switch {
+		case isNumber && nk == NumberInt:
+			return val.Int64()
+		case isNumber && nk == NumberUint:
+			return strconv.ParseUint(val.String(), 10, 64)
+		case isNumber && nk == NumberFloat:
+			return val.Float64()
+		}
+		if i, err := val.Int64(); err == nil {
+			return i, nil
+		}
+		return val.Float64()
+	case string:
+		if isNumber && nk == NumberFloat {
+			return strconv.ParseFloat(val, 64)
+		}
+		if rkind == reflect.Complex64 || rkind == reflect.Complex128 {
+			var c complex128
+			if _, err := fmt.Sscan(val, &c); err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,79 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExportEvents(t *testing.T) {
+	type payload struct {
+		I   int
+		U   uint64
+		F   float64
+		C   complex128
+		S   string
+		L   []int
+		P   *int
+		Tag string
+	}
+	obj := payload{I: -1, U: math.MaxUint64, F: math.NaN(), C: 1 + 2i, S: "s", L: []int{1, 2}, Tag: "t"}
+	var buf bytes.Buffer
+	if err := ExportEvents(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 13 {
+		t.Fatalf("got %d events: %s", len(lines), buf.String())
+	}
+	if lines[1] != `{"kind":"Leaf","seq":1,"path":"I","name":"I","index":0,"depth":1,"type":"int","valueKind":"Int","value":-1}` {
+		t.Fatalf("got %s", lines[1])
+	}
+
+	var got []string
+	err := ImportEvents(strings.NewReader(buf.String()), ScriptFuncs{
+		"Slice": func(ev ScriptEvent) (ScriptResult, error) {
+			got = append(got, fmt.Sprintf("%s:%s", ev.Kind, ev.Path))
+			return ScriptResult{Skip: true}, nil
+		},
+		"String": func(ev ScriptEvent) (ScriptResult, error) {
+			return ScriptResult{Stop: ev.Name == "Tag"}, nil
+		},
+		AnyScriptKind: func(ev ScriptEvent) (ScriptResult, error) {
+			if ev.Kind == EventLeaf {
+				got = append(got, fmt.Sprintf("%s=%T(%v)", ev.Path, ev.Value, ev.Value))
+			}
+			return ScriptResult{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[I=int64(-1) U=uint64(18446744073709551615) F=float64(NaN) C=complex128((1+2i)) Start:L P=<nil>(<nil>)]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("got %v", got)
+	}
+
+	if err = ImportEvents(strings.NewReader(`{"kind":"Bad"}`), ScriptFuncs{}); err == nil {
+		t.Fatal("expecting error of unknown event kind")
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"reflect"
+)
+
+type (
+	// PathValue is a leaf value in the result of Flatten
+	PathValue struct {
+		Path  string
+		Value interface{}
+	}
+
+	// FlattenCallback receives the leaves of Flatten one by one
+	FlattenCallback func(path string, v interface{}) error
+
+	// flattener passes all leaves of an object with their paths to the callback, map keys are not leaves.
+	flattener struct {
+		callback FlattenCallback
+	}
+)
+
+func (f flattener) ForNilPtr(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	return f.put(node, nil)
+}
+
+func (f flattener) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	var v interface{}
+	if val.CanInterface() {
+		v = val.Interface()
+	}
+	return f.put(node, v)
+}
+
+func (f flattener) ForContainerArray(_ *TravContext, node *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return !isMapKey(node.Path), nil
+}
+
+func (f flattener) ForContainerMap(_ *TravContext, node *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return !isMapKey(node.Path), nil
+}
+
+func (f flattener) ForContainerPtr(_ *TravContext, node *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return !isMapKey(node.Path), nil
+}
+
+func (f flattener) ForContainerSlice(_ *TravContext, node *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return !isMapKey(node.Path), nil
+}
+
+func (f flattener) ForContainerStruct(_ *TravContext, node *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return !isMapKey(node.Path), nil
+}
+
+func (f flattener) put(node *NodeInfo, v interface{}) error {
+	if isMapKey(node.Path) {
+		return nil
+	}
+	return f.callback(node.Path.String(), v)
+}
+
+func isMapKey(path Path) bool {
+	return len(path) > 0 && path[len(path)-1].IsKey
+}
+
+// Flatten returns all leaf values of obj with their paths in traversal order: struct fields in the
+// order given by the Propertier (if any in conf), map entries in the order of sorted keys, so the
+// result can be compared textually and hashed stably. Map keys are part of the paths but not leaves.
+func Flatten(obj interface{}, conf ...*TraverseConf) ([]PathValue, error) {
+	var pairs []PathValue
+	err := FlattenFunc(obj, func(path string, v interface{}) error {
+		pairs = append(pairs, PathValue{Path: path, Value: v})
+		return nil
+	}, conf...)
+	if err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// FlattenFunc passes the leaves of obj to callback in the same order as Flatten, without holding
+// the whole result. The traversal stops at the first error returned by callback.
+func FlattenFunc(obj interface{}, callback FlattenCallback, conf ...*TraverseConf) error {
+	if callback == nil {
+		return errors.New("nil flatten callback")
+	}
+	c := confOf(conf...)
+	c.SortMapKeys = true
+	tr, err := NewTraveller(flattener{callback: callback}, c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), obj)
+}
@@ -0,0 +1,79 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type flatObj struct {
+	Name   string
+	Labels map[string]int
+	Items  []*Inner0
+	Next   *flatObj
+}
+
+func TestFlatten(t *testing.T) {
+	obj := &flatObj{
+		Name:   "root",
+		Labels: map[string]int{"c": 3, "a": 1, "b": 2},
+		Items:  []*Inner0{{A: 1, E: 2}},
+	}
+	for i := 0; i < 3; i++ {
+		pairs, err := Flatten(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := fmt.Sprint(pairs)
+		expected := "[{Name root} {Labels[a] 1} {Labels[b] 2} {Labels[c] 3} {Items[0].A 1} {Items[0].E 2} " +
+			"{Items[0].B 0} {Items[0].C 0} {Items[0].D 0} {Items[0].F 0} {Items[0].Z <nil>} {Next <nil>}]"
+		if s != expected {
+			t.Fatalf("got %s, expecting %s", s, expected)
+		}
+	}
+
+	pairs, err := Flatten(obj.Items[0], &TraverseConf{Propertier: rtlpropertier{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(pairs)
+	if pairs[0].Path != "A" || pairs[1].Path != "B" || pairs[len(pairs)-1].Path != "F" {
+		t.Fatalf("propertier order not respected: %v", pairs)
+	}
+}
+
+func TestFlattenFunc(t *testing.T) {
+	obj := &flatObj{Name: "a", Next: &flatObj{Name: "b"}}
+	stop := errors.New("stop")
+	var paths []string
+	err := FlattenFunc(obj, func(path string, v interface{}) error {
+		paths = append(paths, path)
+		if path == "Next.Name" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("expecting error %v, got %v", stop, err)
+	}
+	if fmt.Sprint(paths) != "[Name Next.Name]" {
+		t.Fatalf("paths: %v", paths)
+	}
+}
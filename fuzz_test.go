@@ -0,0 +1,261 @@
+//go:build go1.18
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fuzzReader consumes the fuzzing input, zeros are returned after the end of the input
+type fuzzReader struct {
+	data []byte
+}
+
+func (r *fuzzReader) byte() byte {
+	if len(r.data) == 0 {
+		return 0
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *fuzzReader) intn(n int) int {
+	return int(r.byte()) % n
+}
+
+func (r *fuzzReader) bool() bool {
+	return r.byte()&1 == 1
+}
+
+func (r *fuzzReader) string() string {
+	n := r.intn(8)
+	if n > len(r.data) {
+		n = len(r.data)
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
+
+type fuzzNode struct {
+	A int
+	B string `dfpt:"secret"`
+	C interface{}
+	D *fuzzNode
+	E []byte
+	F map[int]*fuzzNode
+	G [2]byte
+	h int
+}
+
+// fuzzGraph builds a random value graph, the nodes may reference each other (and themselves)
+type fuzzGraph struct {
+	*fuzzReader
+	nodes []*fuzzNode
+}
+
+func (g *fuzzGraph) value(depth int) interface{} {
+	if depth > 6 || len(g.data) == 0 {
+		return nil
+	}
+	switch g.intn(12) {
+	case 0:
+		return int(int8(g.byte()))
+	case 1:
+		return g.string()
+	case 2:
+		s := make([]interface{}, g.intn(4))
+		for i := range s {
+			s[i] = g.value(depth + 1)
+		}
+		return s
+	case 3:
+		m := make(map[string]interface{})
+		for i, n := 0, g.intn(4); i < n; i++ {
+			m[g.string()] = g.value(depth + 1)
+		}
+		return m
+	case 4:
+		v := g.value(depth + 1)
+		return &v
+	case 5:
+		return g.node(depth + 1)
+	case 6:
+		return float64(g.byte()) / 3
+	case 7:
+		return g.bool()
+	case 8:
+		return []byte(g.string())
+	case 9:
+		return uint16(g.byte())
+	case 10:
+		var err error
+		return struct{ Err error }{err}
+	default:
+		if g.bool() {
+			return map[string]int(nil)
+		}
+		return []string(nil)
+	}
+}
+
+func (g *fuzzGraph) node(depth int) *fuzzNode {
+	if len(g.nodes) > 0 && g.bool() {
+		return g.nodes[g.intn(len(g.nodes))]
+	}
+	n := &fuzzNode{A: int(g.byte()), h: 1}
+	g.nodes = append(g.nodes, n)
+	if depth > 6 {
+		return n
+	}
+	n.B = g.string()
+	n.C = g.value(depth + 1)
+	if g.bool() {
+		n.D = g.node(depth + 1)
+	}
+	if g.bool() {
+		n.E = []byte(g.string())
+	}
+	if g.bool() {
+		n.F = map[int]*fuzzNode{int(g.byte()): g.node(depth + 1)}
+	}
+	return n
+}
+
+var (
+	_fuzzErrors = []error{nil, nil, nil, ErrSkipContainer, errors.New("fuzz")}
+
+	// names of the bindings chosen by the fuzzing adapters
+	_fuzzNames = []string{
+		NilPtrName, NilSliceName, NilMapName, NilInterfaceName, ZeroName, BytesName, MapEntryName, IntXName,
+		UintXName, FloatXName, ComplexXName, NumberName, AllKindsName, ReferenceName, CycleName, DefaultName,
+		KindPrefix + "Int", KindPrefix + "String", KindPrefix + "Bool", KindPrefix + "Float64", KindPrefix + "Map",
+		ContainerPrefix + "Map", ContainerPrefix + "Slice", ContainerPrefix + "Struct", ContainerPrefix + "Ptr",
+		ContainerPrefix + "Array", ContainerPrefix + "String", MapKeyPrefix + "String", MapValuePrefix + "Int", NamedPrefix + "String",
+		TagPrefix + "Secret", ImplPrefix + "Error", AssignPrefix + "Node", "ForUnknown", "ForX", "",
+	}
+)
+
+// fuzzBindings returns functions of valid and invalid binding signatures, the leaf and container
+// bindings fail with the errors chosen by r
+func fuzzBindings(r *fuzzReader) []interface{} {
+	fail := func() error { return _fuzzErrors[r.intn(len(_fuzzErrors))] }
+	return []interface{}{
+		func(*TravContext, int, int, string, interface{}) error { return fail() },
+		func(*TravContext, int, int, string, string) error { return fail() },
+		func(*TravContext, int, int, string, error) error { return fail() },
+		func(*TravContext, int, int, string, []byte) error { return fail() },
+		func(*TravContext, int, int, string, NumberKind, interface{}) error { return fail() },
+		func(*TravContext, int, int, string, interface{}, interface{}) error { return fail() },
+		func(*TravContext, int, int, int, bool, string, interface{}) (bool, error) { return r.bool(), fail() },
+		func(*TravContext, *NodeInfo, reflect.Value) error { return fail() },
+		func(_ *TravContext, _ *NodeInfo, v reflect.Value) (interface{}, bool, error) {
+			if r.bool() {
+				return nil, true, fail()
+			}
+			return v.Interface(), r.bool(), fail()
+		},
+		func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) { return r.bool(), fail() },
+		func(*TravContext, *NodeInfo, NumberKind, reflect.Value) error { return fail() },
+		func(*TravContext, *NodeInfo, reflect.Value, reflect.Value) error { return fail() },
+		func(*TravContext, *NodeInfo, Path, reflect.Value) error { return fail() },
+		func(*TravContext, *NodeInfo, *NodeInfo, reflect.Value) error { return fail() },
+		func() {},
+		func(int) error { return nil },
+	}
+}
+
+// fuzzAdapter builds an adapter with random bindings, which may be invalid
+func fuzzAdapter(r *fuzzReader) *AdapterBuilder {
+	b := NewAdapterBuilder()
+	fns := fuzzBindings(r)
+	for i, n := 0, 1+r.intn(8); i < n; i++ {
+		name := _fuzzNames[r.intn(len(_fuzzNames))]
+		if r.intn(16) == 0 {
+			name = "For" + r.string()
+		}
+		b.bind(name, fns[r.intn(len(fns))], false)
+	}
+	return b
+}
+
+func fuzzConf(r *fuzzReader) *TraverseConf {
+	return &TraverseConf{
+		IgnoreMissedBinding: r.bool(),
+		ContainerEnd:        r.bool(),
+		PtrAutoGoIn:         r.bool(),
+		SortMapKeys:         r.bool(),
+		BestEffort:          r.bool(),
+		TrackReferences:     r.bool(),
+		DetectCycles:        r.bool(),
+		Addressable:         r.bool(),
+		SkipZeroValues:      r.bool(),
+		BytesArrays:         r.bool(),
+		SampleSize:          r.intn(3),
+		MatchPolicy:         MatchPolicy(r.intn(3)),
+		// cycles are not always detected, the budget keeps the traversal finite
+		MaxNodes: 2000,
+	}
+}
+
+func FuzzWhich(f *testing.F) {
+	for _, name := range _fuzzNames {
+		f.Add(name)
+	}
+	f.Add("ForContai")
+	f.Add("ForMapKeyMap")
+	f.Fuzz(func(t *testing.T, name string) {
+		itype, kind, ok := Unknown.Which(name)
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(name, itype.String()) {
+			t.Fatalf("%s is recognized as %s", name, itype)
+		}
+		if kind != reflect.Invalid && itype != ForKind && itype != ForContainer && itype != ForMapKey &&
+			itype != ForMapValue && itype != ForNamed {
+			t.Fatalf("%s of %s has kind %s", name, itype, kind)
+		}
+	})
+}
+
+func FuzzTraverse(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{5, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})
+	f.Add([]byte("\x03\x02ab\x00\x07\x05\x01\x00\x01\x06\x02\x00\x09\x01\x00\x00\x01\x00\x01\x01"))
+	f.Add([]byte("\x05\x07\x03x\x05\x01\x01\x01\x01\x01\x00\x02\x01\x03\x05\x01\xff\xfe\xfd\xfc\xfb\xfa"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := &fuzzReader{data: data}
+		g := &fuzzGraph{fuzzReader: r}
+		obj := g.value(0)
+		adapter, conf := fuzzAdapter(r), fuzzConf(r)
+		tr, err := NewTraveller(adapter, conf)
+		if err != nil {
+			return
+		}
+		// errors are expected from the random bindings, only panics and hangs fail
+		_ = tr.Traverse(nil, obj)
+		_ = tr.Traverse(nil, &obj)
+	})
+}
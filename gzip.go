@@ -0,0 +1,105 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+)
+
+// gzipper is the adapter compressing or decompressing the []byte fields tagged with TagGzip
+type gzipper struct {
+	goInAll
+	decompress bool
+	level      int
+}
+
+func (g gzipper) ForAssignBytes(_ *TravContext, node *NodeInfo, data []byte) (interface{}, bool, error) {
+	if len(data) == 0 || !fieldTagged(node, TagGzip) {
+		return nil, false, nil
+	}
+	var ret []byte
+	var err error
+	if g.decompress {
+		ret, err = gunzipBytes(data)
+	} else {
+		ret, err = gzipBytes(data, g.level)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %v", node.Path, err)
+	}
+	return ret, true, nil
+}
+
+func (g gzipper) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (g gzipper) ForAllKinds(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func gzipBytes(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipBytes(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func gzipTransform(obj interface{}, g gzipper, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.Addressable = true
+	c.DetectCycles = true
+	tr, err := NewTraveller(g, c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), obj)
+}
+
+// CompressBytes replaces the non-empty []byte fields tagged with `dfpt:"gzip"` in obj with their
+// gzip compressed data of level (e.g. gzip.DefaultCompression) in place, so obj should be given by
+// pointer.
+func CompressBytes(obj interface{}, level int, conf ...*TraverseConf) error {
+	return gzipTransform(obj, gzipper{level: level}, conf...)
+}
+
+// DecompressBytes restores the []byte fields compressed by CompressBytes in obj in place.
+func DecompressBytes(obj interface{}, conf ...*TraverseConf) error {
+	return gzipTransform(obj, gzipper{decompress: true}, conf...)
+}
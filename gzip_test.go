@@ -0,0 +1,75 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+	"testing"
+)
+
+type gzipRecord struct {
+	ID      int
+	Payload []byte `dfpt:"gzip"`
+	Raw     []byte
+}
+
+func TestCompressBytes(t *testing.T) {
+	payload := bytes.Repeat([]byte("dfpt "), 100)
+	newObj := func() *struct {
+		Main    gzipRecord
+		Records []gzipRecord
+		ByName  map[string]gzipRecord
+		Empty   gzipRecord
+	} {
+		return &struct {
+			Main    gzipRecord
+			Records []gzipRecord
+			ByName  map[string]gzipRecord
+			Empty   gzipRecord
+		}{
+			Main:    gzipRecord{ID: 1, Payload: payload, Raw: payload},
+			Records: []gzipRecord{{ID: 2, Payload: payload}},
+			ByName:  map[string]gzipRecord{"a": {ID: 3, Payload: payload}},
+		}
+	}
+	for _, conf := range []*TraverseConf{nil, {AsyncLeaves: 2}} {
+		obj := newObj()
+		if err := CompressBytes(obj, gzip.BestCompression, conf); err != nil {
+			t.Fatal(err)
+		}
+		if len(obj.Main.Payload) >= len(payload) || len(obj.Records[0].Payload) >= len(payload) ||
+			len(obj.ByName["a"].Payload) >= len(payload) || !bytes.Equal(obj.Main.Raw, payload) ||
+			obj.Empty.Payload != nil {
+			t.Fatalf("not compressed: %d %d %d", len(obj.Main.Payload), len(obj.Records[0].Payload),
+				len(obj.ByName["a"].Payload))
+		}
+		if err := DecompressBytes(obj, conf); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(obj, newObj()) {
+			t.Fatalf("not restored: %+v", obj)
+		}
+	}
+
+	bad := &gzipRecord{Payload: []byte("not gzip")}
+	if err := DecompressBytes(bad); err == nil {
+		t.Fatal("expecting error of illegal gzip data")
+	}
+}
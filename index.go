@@ -0,0 +1,101 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+)
+
+type (
+	// IndexOptions are the options of BuildIndex
+	IndexOptions struct {
+		// config of the traversal, nil for default
+		Conf *TraverseConf
+		// if true, values are indexed by their hashes (of type and formatted value) instead of
+		// themselves, which saves memory for large values and makes uncomparable values indexable,
+		// but different values may share the same entry in case of hash collision.
+		Hash bool
+	}
+
+	// Index is an inverted index of the leaf values of an object to their paths. Values are matched
+	// with their types, e.g. int(1) and int64(1) are different values.
+	Index struct {
+		hash    bool
+		entries map[interface{}][]string
+	}
+)
+
+func (ix *Index) key(v interface{}) (interface{}, bool) {
+	if ix.hash {
+		h := fnv.New64a()
+		if v != nil {
+			fmt.Fprintf(h, "%T\x00%v", v, v)
+		}
+		return h.Sum64(), true
+	}
+	if v != nil && !reflect.TypeOf(v).Comparable() {
+		return nil, false
+	}
+	return v, true
+}
+
+func (ix *Index) add(path string, v interface{}) {
+	if key, ok := ix.key(v); ok {
+		ix.entries[key] = append(ix.entries[key], path)
+	}
+}
+
+// Paths returns the paths where v occurs in traversal order, nil if not found. nil for the paths of
+// nil pointers.
+func (ix *Index) Paths(v interface{}) []string {
+	key, ok := ix.key(v)
+	if !ok {
+		return nil
+	}
+	return ix.entries[key]
+}
+
+// Len returns the number of different values (or hashes) in the index
+func (ix *Index) Len() int {
+	return len(ix.entries)
+}
+
+// BuildIndex returns the index of the leaf values of obj (as Flatten) to their paths. Uncomparable
+// values are not indexed unless IndexOptions.Hash is set.
+func BuildIndex(obj interface{}, opts ...*IndexOptions) (*Index, error) {
+	var o IndexOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	conf := o.Conf
+	if conf != nil && conf.AsyncLeaves > 0 {
+		conf = conf.Clone()
+		conf.AsyncLeaves = 0
+	}
+	ix := &Index{hash: o.Hash, entries: make(map[interface{}][]string)}
+	err := FlattenFunc(obj, func(path string, v interface{}) error {
+		ix.add(path, v)
+		return nil
+	}, conf)
+	if err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
@@ -0,0 +1,68 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"testing"
+)
+
+type indexDoc struct {
+	Owner  string
+	Users  []string
+	Groups map[string][]string
+	Hook   func()
+	Parent *indexDoc
+}
+
+func TestBuildIndex(t *testing.T) {
+	doc := &indexDoc{
+		Owner:  "u1",
+		Users:  []string{"u1", "u2"},
+		Groups: map[string][]string{"admin": {"u2"}, "dev": {"u1", "u3"}},
+		Hook:   func() {},
+	}
+	ix, err := BuildIndex(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := ix.Paths("u1"); !reflect.DeepEqual(paths, []string{"Owner", "Users[0]", "Groups[dev][0]"}) {
+		t.Fatalf("u1: %v", paths)
+	}
+	if paths := ix.Paths(nil); !reflect.DeepEqual(paths, []string{"Parent"}) {
+		t.Fatalf("nil: %v", paths)
+	}
+	if ix.Paths("admin") != nil || ix.Paths(1) != nil {
+		t.Fatal("map keys and missing values should not be found")
+	}
+	// u1, u2, u3, nil, the func is not comparable
+	if ix.Len() != 4 {
+		t.Fatalf("len: %d", ix.Len())
+	}
+
+	hashed, err := BuildIndex(doc, &IndexOptions{Hash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paths := hashed.Paths("u2"); !reflect.DeepEqual(paths, []string{"Users[1]", "Groups[admin][0]"}) {
+		t.Fatalf("hashed u2: %v", paths)
+	}
+	if hashed.Len() != 5 {
+		t.Fatalf("hashed len: %d", hashed.Len())
+	}
+}
@@ -0,0 +1,187 @@
+//go:build go1.18
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"testing/quick"
+)
+
+// invariantFrame is an open container seen by invariantChecker
+type invariantFrame struct {
+	node     *NodeInfo
+	children int
+}
+
+// invariantChecker checks the invariants of the bindings calls in a traversal, the first violation
+// is kept in err
+type invariantChecker struct {
+	stack []*invariantFrame
+	err   error
+}
+
+func (c *invariantChecker) fail(format string, args ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf(format, args...)
+	}
+}
+
+// child checks the position of a child node in the current container
+func (c *invariantChecker) child(node *NodeInfo) {
+	if node.Depth != len(c.stack) {
+		c.fail("%s: depth %d, but %d containers open", node.Path, node.Depth, len(c.stack))
+		return
+	}
+	if len(c.stack) == 0 {
+		return
+	}
+	top := c.stack[len(c.stack)-1]
+	parent := top.node
+	switch parent.Value.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface:
+		if node.Index != top.children {
+			c.fail("%s: index %d, but %d children visited", node.Path, node.Index, top.children)
+		}
+		if node.Index < 0 || node.Index >= parent.Size {
+			c.fail("%s: index %d out of size %d", node.Path, node.Index, parent.Size)
+		}
+	case reflect.Struct:
+		if node.Index < 0 || node.Index >= parent.Value.NumField() {
+			c.fail("%s: field index %d out of %d fields", node.Path, node.Index, parent.Value.NumField())
+		}
+	}
+	if parent.Value.Kind() == reflect.Map {
+		last := node.Path[len(node.Path)-1]
+		if last.IsKey != (node.Index%2 == 0) {
+			c.fail("%s: offset %d of map but IsKey is %t", node.Path, node.Index, last.IsKey)
+		}
+	}
+	top.children++
+}
+
+func (c *invariantChecker) container(node *NodeInfo, start bool) {
+	if start {
+		c.child(node)
+		c.stack = append(c.stack, &invariantFrame{node: node})
+		return
+	}
+	if len(c.stack) == 0 {
+		c.fail("%s: end without start", node.Path)
+		return
+	}
+	top := c.stack[len(c.stack)-1]
+	c.stack = c.stack[:len(c.stack)-1]
+	if top.node.Path.String() != node.Path.String() || top.node.Seq != node.Seq {
+		c.fail("%s(seq:%d): end of %s(seq:%d)", node.Path, node.Seq, top.node.Path, top.node.Seq)
+	}
+	if kind := node.Value.Kind(); kind != reflect.Struct && top.children != node.Size {
+		c.fail("%s: %d children visited in container of size %d", node.Path, top.children, node.Size)
+	}
+}
+
+func (c *invariantChecker) ForContainerArray(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	c.container(node, start)
+	return true, nil
+}
+
+func (c *invariantChecker) ForContainerInterface(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	c.container(node, start)
+	return true, nil
+}
+
+func (c *invariantChecker) ForContainerMap(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	c.container(node, start)
+	return true, nil
+}
+
+func (c *invariantChecker) ForContainerPtr(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	c.container(node, start)
+	return true, nil
+}
+
+func (c *invariantChecker) ForContainerSlice(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	c.container(node, start)
+	return true, nil
+}
+
+func (c *invariantChecker) ForContainerStruct(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	c.container(node, start)
+	return true, nil
+}
+
+func (c *invariantChecker) ForAllKinds(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	c.child(node)
+	return nil
+}
+
+func (c *invariantChecker) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, _ reflect.Value) error {
+	c.child(node)
+	if ancestor.Depth >= node.Depth {
+		c.fail("%s: ancestor %s at depth %d", node.Path, ancestor.Path, ancestor.Depth)
+	}
+	return nil
+}
+
+func (c *invariantChecker) ForReference(_ *TravContext, node *NodeInfo, _ Path, _ reflect.Value) error {
+	c.child(node)
+	return nil
+}
+
+// checkInvariants traverses the random value graph built from data, and returns the first violation
+func checkInvariants(data []byte, conf *TraverseConf) error {
+	g := &fuzzGraph{fuzzReader: &fuzzReader{data: data}}
+	obj := g.value(0)
+	c := &invariantChecker{}
+	tr, err := NewTraveller(c, conf)
+	if err != nil {
+		return err
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		return err
+	}
+	if c.err == nil && len(c.stack) > 0 {
+		c.fail("%d containers not ended", len(c.stack))
+	}
+	return c.err
+}
+
+func TestTraversalInvariants(t *testing.T) {
+	confs := map[string]*TraverseConf{
+		"default": {ContainerEnd: true, DetectCycles: true},
+		"sorted":  {ContainerEnd: true, DetectCycles: true, SortMapKeys: true, BytesArrays: true},
+		"refs":    {ContainerEnd: true, DetectCycles: true, TrackReferences: true, SkipZeroValues: true},
+	}
+	for name, conf := range confs {
+		t.Run(name, func(t *testing.T) {
+			prop := func(data []byte) bool {
+				if err := checkInvariants(data, conf); err != nil {
+					t.Logf("%q: %v", data, err)
+					return false
+				}
+				return true
+			}
+			if err := quick.Check(prop, &quick.Config{MaxCount: 500}); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// EventKind is the kind of traversal Event
+type EventKind int
+
+const (
+	EventLeaf      EventKind = iota // a value which is not a container, including nil pointers
+	EventStart                      // start of a container
+	EventEnd                        // end of a container
+	EventReference                  // a value visited before, with TraverseConf.TrackReferences
+	EventCycle                      // a value referencing its ancestor, with TraverseConf.DetectCycles
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventLeaf:
+		return "Leaf"
+	case EventStart:
+		return "Start"
+	case EventEnd:
+		return "End"
+	case EventReference:
+		return "Reference"
+	case EventCycle:
+		return "Cycle"
+	default:
+		return "EventKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *EventKind) UnmarshalText(text []byte) error {
+	for i := EventLeaf; i <= EventCycle; i++ {
+		if i.String() == string(text) {
+			*k = i
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event kind %q", text)
+}
+
+// Event is a step of the traversal pulled from TravIterator
+type Event struct {
+	Kind   EventKind
+	Node   *NodeInfo
+	Value  reflect.Value
+	Target Path // path of the value referenced, for EventReference and EventCycle
+}
+
+var errIteratorClosed = errors.New("iterator closed")
+
+type (
+	// TravIterator pulls the events of a traversal one by one. The traversal runs in a goroutine of
+	// its own, but only between two calls of Next, so it's paused while the caller is processing an
+	// event. The goroutine exits when the traversal is over or the iterator is closed. An iterator
+	// abandoned without either is closed when it's garbage collected, but until then its goroutine
+	// and the object are kept, so defer Close if the iterator may not be exhausted.
+	TravIterator struct {
+		it *iteration
+	}
+
+	// iteration is the state of a TravIterator shared with the goroutine of the traversal, which
+	// doesn't reference the TravIterator, so that an abandoned one can be collected and closed.
+	iteration struct {
+		tr       *Traveller
+		obj      interface{}
+		events   chan Event
+		resume   chan struct{}
+		done     chan struct{}
+		finished chan struct{}
+		started  bool
+		ended    bool
+		closing  sync.Once
+		err      error
+	}
+
+	// eventEmitter is the adapter of the traversal of TravIterator
+	eventEmitter struct {
+		it *iteration
+	}
+)
+
+// emit passes the event to Next, and waits until the next call of Next
+func (e eventEmitter) emit(ev Event) error {
+	select {
+	case e.it.events <- ev:
+	case <-e.it.done:
+		return errIteratorClosed
+	}
+	select {
+	case <-e.it.resume:
+		return nil
+	case <-e.it.done:
+		return errIteratorClosed
+	}
+}
+
+func (e eventEmitter) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return e.emit(Event{Kind: EventLeaf, Node: node, Value: val})
+}
+
+func (e eventEmitter) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return e.emit(Event{Kind: EventLeaf, Node: node, Value: val})
+}
+
+func (e eventEmitter) ForReference(_ *TravContext, node *NodeInfo, target Path, val reflect.Value) error {
+	return e.emit(Event{Kind: EventReference, Node: node, Value: val, Target: target})
+}
+
+func (e eventEmitter) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	return e.emit(Event{Kind: EventCycle, Node: node, Value: val, Target: ancestor.Path})
+}
+
+func (e eventEmitter) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	kind := EventStart
+	if !startOrEnd {
+		kind = EventEnd
+	}
+	return startOrEnd, e.emit(Event{Kind: kind, Node: node, Value: val})
+}
+
+func (e eventEmitter) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e eventEmitter) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e eventEmitter) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e eventEmitter) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+func (e eventEmitter) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, val)
+}
+
+// Iterator returns an iterator of the traversal of obj with the config of t. The bindings of the
+// adapter of t are not called: every value is emitted as an Event instead, containers are always
+// traversed and ended with EventEnd. The iterator should be closed if it's not exhausted, see
+// TravIterator.
+func (t *Traveller) Iterator(obj interface{}) *TravIterator {
+	c := confOf(t.conf)
+	c.ContainerEnd = true
+	c.AsyncLeaves = 0
+	it := &iteration{
+		obj:      obj,
+		events:   make(chan Event),
+		resume:   make(chan struct{}),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+	}
+	it.tr, it.err = NewTraveller(eventEmitter{it: it}, c)
+	handle := &TravIterator{it: it}
+	runtime.SetFinalizer(handle, func(h *TravIterator) { h.it.close() })
+	return handle
+}
+
+func (it *iteration) run() {
+	defer close(it.finished)
+	defer close(it.events)
+	err := it.tr.Traverse(NewContext(), it.obj)
+	if err != nil && !errors.Is(err, errIteratorClosed) {
+		it.err = err
+	}
+}
+
+// Next returns the next event, false if the traversal is over (or failed, see Err) or the iterator
+// is closed.
+func (h *TravIterator) Next() (Event, bool) {
+	it := h.it
+	if it.ended || it.err != nil && !it.started {
+		return Event{}, false
+	}
+	if !it.started {
+		it.started = true
+		go it.run()
+	} else {
+		select {
+		case it.resume <- struct{}{}:
+		case <-it.finished:
+		}
+	}
+	ev, ok := <-it.events
+	if !ok {
+		it.ended = true
+	}
+	return ev, ok
+}
+
+// Err returns the error of the traversal, if any
+func (h *TravIterator) Err() error {
+	if h.it.started && !h.it.ended {
+		return nil
+	}
+	return h.it.err
+}
+
+// Close stops the traversal, and waits until its goroutine exits. It can be called more than once,
+// and deferred no matter whether the iterator is exhausted.
+func (h *TravIterator) Close() {
+	h.it.close()
+}
+
+func (it *iteration) close() {
+	it.closing.Do(func() {
+		close(it.done)
+		if it.started {
+			<-it.finished
+		}
+		it.ended = true
+	})
+}
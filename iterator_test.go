@@ -0,0 +1,136 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIterator(t *testing.T) {
+	tr, err := NewTraveller(flattener{}, &TraverseConf{PtrAutoGoIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &listNode{V: 1, Next: &listNode{V: 2}}
+	it := tr.Iterator(obj)
+	var got []string
+	for ev, ok := it.Next(); ok; ev, ok = it.Next() {
+		got = append(got, fmt.Sprintf("%s:%s", ev.Kind, ev.Node.Path))
+	}
+	if err = it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := "[Start: Start: Leaf:V Start:Next Start:Next Leaf:Next.V Leaf:Next.Next End:Next End:Next End: End:]"
+	if fmt.Sprint(got) != want {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestIteratorLockstep(t *testing.T) {
+	tr, err := NewTraveller(flattener{}, &TraverseConf{SortMapKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := tr.Iterator(map[string]int{"x": 1, "y": 2})
+	b := tr.Iterator(map[string]int{"x": 1, "y": 3})
+	defer a.Close()
+	defer b.Close()
+	var diff string
+	for {
+		ea, oka := a.Next()
+		eb, okb := b.Next()
+		if !oka || !okb {
+			break
+		}
+		if ea.Kind == EventLeaf && ea.Value.Interface() != eb.Value.Interface() {
+			diff = ea.Node.Path.String()
+			break
+		}
+	}
+	if diff != "[y]" {
+		t.Fatalf("diff: %q", diff)
+	}
+}
+
+func TestIteratorClose(t *testing.T) {
+	tr, err := NewTraveller(flattener{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	goroutines := runtime.NumGoroutine()
+	it := tr.Iterator([]int{1, 2, 3})
+	if ev, ok := it.Next(); !ok || ev.Kind != EventStart {
+		t.Fatalf("first event: %v %t", ev, ok)
+	}
+	it.Close()
+	if _, ok := it.Next(); ok {
+		t.Fatal("closed iterator should be over")
+	}
+	if it.Err() != nil || runtime.NumGoroutine() > goroutines {
+		t.Fatalf("err: %v, goroutines: %d -> %d", it.Err(), goroutines, runtime.NumGoroutine())
+	}
+
+	if tr, err = NewTraveller(flattener{}, &TraverseConf{MaxNodes: 2}); err != nil {
+		t.Fatal(err)
+	}
+	it = tr.Iterator([]int{1, 2, 3})
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+	}
+	if !errors.Is(it.Err(), ErrBudgetExceeded) {
+		t.Fatalf("err: %v", it.Err())
+	}
+}
+
+func TestIteratorAbandoned(t *testing.T) {
+	tr, err := NewTraveller(flattener{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	goroutines := runtime.NumGoroutine()
+	func() {
+		it := tr.Iterator([]int{1, 2, 3})
+		if _, ok := it.Next(); !ok {
+			t.Fatal("no event")
+		}
+		// abandoned while the traversal is paused
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > goroutines {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutine of the abandoned iterator is still running: %d -> %d", goroutines,
+				runtime.NumGoroutine())
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Close is idempotent and safe to defer after exhausting the iterator
+	it := tr.Iterator([]int{1})
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+	}
+	it.Close()
+	it.Close()
+	if it.Err() != nil {
+		t.Fatal(it.Err())
+	}
+	tr.Iterator([]int{1}).Close()
+}
@@ -0,0 +1,270 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"unicode/utf8"
+)
+
+// A JSON encoder built on the traversal:
+//
+//	struct: object of field names in the order given by the Propertier (if any)
+//	map: object in the order of sorted keys, keys must be strings, integers or unsigned integers
+//	slice/array: array, []byte is written as a base64 string, nil slice is null
+//	pointer: the pointed value, nil pointer is null
+//	interface: null or the value held
+//
+// Complex numbers, channels, functions, NaN, infinities and cycles can't be encoded.
+
+type (
+	jsonFrame struct {
+		kind  reflect.Kind // kind of the container
+		count int          // number of members written
+	}
+
+	jsonEncoder struct {
+		w     *bufio.Writer
+		stack []*jsonFrame
+	}
+)
+
+// member writes the separator, and the name of the member in an object if any, before the value
+// of node. It returns true if the value is the key of a map entry, which is written by key.
+func (e *jsonEncoder) member(node *NodeInfo) bool {
+	if len(e.stack) == 0 {
+		return false
+	}
+	top := e.stack[len(e.stack)-1]
+	switch top.kind {
+	case reflect.Ptr:
+		return false
+	case reflect.Map:
+		if len(node.Path) == 0 || !node.Path[len(node.Path)-1].IsKey {
+			return false
+		}
+	}
+	if top.count > 0 {
+		e.w.WriteByte(',')
+	}
+	top.count++
+	switch top.kind {
+	case reflect.Struct:
+		e.w.WriteString(jsonQuote(node.Name))
+		e.w.WriteByte(':')
+	case reflect.Map:
+		return true
+	}
+	return false
+}
+
+// value is member for the values which can't be map keys
+func (e *jsonEncoder) value(node *NodeInfo) error {
+	if e.member(node) {
+		return fmt.Errorf("json: unsupported map key %s at %s", node.Value.Type(), node.Path)
+	}
+	return nil
+}
+
+// key writes the key of a map entry
+func (e *jsonEncoder) key(node *NodeInfo, val reflect.Value) error {
+	var key string
+	switch val.Kind() {
+	case reflect.String:
+		key = val.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		key = strconv.FormatInt(val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		key = strconv.FormatUint(val.Uint(), 10)
+	default:
+		return fmt.Errorf("json: unsupported map key %s at %s", val.Type(), node.Path)
+	}
+	e.w.WriteString(jsonQuote(key))
+	e.w.WriteByte(':')
+	return nil
+}
+
+func (e *jsonEncoder) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if e.member(node) {
+		return e.key(node, val)
+	}
+	e.w.WriteString("null")
+	return nil
+}
+
+func (e *jsonEncoder) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if e.member(node) {
+		return e.key(node, val)
+	}
+	return e.scalar(node, val)
+}
+
+func (e *jsonEncoder) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, _ reflect.Value) error {
+	return fmt.Errorf("json: cycle at %s referencing %s", node.Path, ancestor.Path)
+}
+
+func (e *jsonEncoder) container(node *NodeInfo, startOrEnd bool, kind reflect.Kind, open, close string) (bool, error) {
+	if !startOrEnd {
+		if len(e.stack) == 0 {
+			return false, errors.New("json: container stack is empty")
+		}
+		e.stack = e.stack[:len(e.stack)-1]
+		e.w.WriteString(close)
+		return false, nil
+	}
+	if err := e.value(node); err != nil {
+		return false, err
+	}
+	e.stack = append(e.stack, &jsonFrame{kind: kind})
+	e.w.WriteString(open)
+	return true, nil
+}
+
+// null writes null for nil slices and maps, which are not traversed
+func (e *jsonEncoder) null(node *NodeInfo) (bool, error) {
+	if err := e.value(node); err != nil {
+		return false, err
+	}
+	e.w.WriteString("null")
+	return false, nil
+}
+
+func (e *jsonEncoder) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, reflect.Array, "[", "]")
+}
+
+// ForContainerInterface goes into the value held by the interface within the traversal, so that
+// cycles through interfaces are detected
+func (e *jsonEncoder) ForContainerInterface(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd && val.IsNil() {
+		return e.null(node)
+	}
+	return e.container(node, startOrEnd, reflect.Ptr, "", "")
+}
+
+func (e *jsonEncoder) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd && val.IsNil() {
+		return e.null(node)
+	}
+	return e.container(node, startOrEnd, reflect.Map, "{", "}")
+}
+
+func (e *jsonEncoder) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, reflect.Ptr, "", "")
+}
+
+func (e *jsonEncoder) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd && val.IsNil() {
+		return e.null(node)
+	}
+	if startOrEnd && val.Type().Elem().Kind() == reflect.Uint8 {
+		if err := e.value(node); err != nil {
+			return false, err
+		}
+		e.w.WriteString(jsonQuote(base64.StdEncoding.EncodeToString(val.Bytes())))
+		return false, nil
+	}
+	return e.container(node, startOrEnd, reflect.Slice, "[", "]")
+}
+
+func (e *jsonEncoder) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return e.container(node, startOrEnd, reflect.Struct, "{", "}")
+}
+
+func (e *jsonEncoder) scalar(node *NodeInfo, val reflect.Value) error {
+	switch val.Kind() {
+	case reflect.Bool:
+		e.w.WriteString(strconv.FormatBool(val.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		e.w.WriteString(strconv.FormatInt(val.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.w.WriteString(strconv.FormatUint(val.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("json: unsupported value %v at %s", f, node.Path)
+		}
+		e.w.WriteString(strconv.FormatFloat(f, 'g', -1, val.Type().Bits()))
+	case reflect.String:
+		e.w.WriteString(jsonQuote(val.String()))
+	default:
+		return fmt.Errorf("json: unsupported type %s at %s", val.Type(), node.Path)
+	}
+	return nil
+}
+
+// jsonQuote returns s as a JSON string, invalid UTF-8 is replaced by U+FFFD
+func jsonQuote(s string) string {
+	buf := make([]byte, 0, len(s)+2)
+	buf = append(buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				buf = append(buf, '\\', c)
+			case c == '\n':
+				buf = append(buf, '\\', 'n')
+			case c == '\r':
+				buf = append(buf, '\\', 'r')
+			case c == '\t':
+				buf = append(buf, '\\', 't')
+			case c < 0x20:
+				buf = append(buf, fmt.Sprintf(`\u%04x`, c)...)
+			default:
+				buf = append(buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, `\ufffd`...)
+		} else {
+			buf = append(buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	return string(append(buf, '"'))
+}
+
+// EncodeJSON writes obj to w in JSON, see the comments of jsonEncoder for the rules. It's compatible
+// with encoding/json for values without json tags or marshalers, see DiffJSON.
+func EncodeJSON(w io.Writer, obj interface{}, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	e := &jsonEncoder{w: bufio.NewWriter(w)}
+	tr, err := NewTraveller(e, c)
+	if err != nil {
+		return err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
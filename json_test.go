@@ -0,0 +1,119 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEncodeJSON(t *testing.T) {
+	type item struct {
+		ID    uint64
+		Ratio float32
+		Data  []byte
+		Attrs map[int]string
+		Next  *item
+		Any   interface{}
+		Empty []int
+		Text  string
+	}
+	obj := &item{ID: 1, Ratio: 0.1, Data: []byte("hi"), Attrs: map[int]string{2: "b", 1: "a"},
+		Next: &item{ID: 2, Any: []int{1}}, Any: item{Text: "in"}, Text: "q\"<\x01\xff"}
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, obj); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"ID":1,"Ratio":0.1,"Data":"aGk=","Attrs":{"1":"a","2":"b"},` +
+		`"Next":{"ID":2,"Ratio":0,"Data":null,"Attrs":null,"Next":null,"Any":[1],"Empty":null,"Text":""},` +
+		`"Any":{"ID":0,"Ratio":0,"Data":null,"Attrs":null,"Next":null,"Any":null,"Empty":null,"Text":"in"},` +
+		`"Empty":null,"Text":"q\"<\u0001\ufffd"}`
+	if buf.String() != want {
+		t.Fatalf("got  %s\nwant %s", buf.String(), want)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Fatal("invalid JSON")
+	}
+
+	for _, bad := range []interface{}{math.NaN(), complex(1, 2), map[[1]int]int{{1}: 1}} {
+		if err := EncodeJSON(&buf, bad); err == nil || !strings.HasPrefix(err.Error(), "json: ") {
+			t.Fatalf("expecting error of %v, got %v", bad, err)
+		}
+	}
+	type node struct {
+		Next *node
+	}
+	cycle := &node{}
+	cycle.Next = cycle
+	if err := EncodeJSON(&buf, cycle); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expecting error of cycle, got %v", err)
+	}
+
+	// cycles through interfaces are detected within the same traversal
+	type anyNode struct {
+		Next interface{}
+	}
+	anyCycle := &anyNode{}
+	anyCycle.Next = anyCycle
+	if err := EncodeJSON(&buf, anyCycle); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expecting error of cycle, got %v", err)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	type plain struct {
+		Name  string
+		Score float64
+		Tags  map[string][]int
+	}
+	type tagged struct {
+		Name   string `json:"name"`
+		Secret string `json:"-"`
+		Count  int    `json:",omitempty"`
+	}
+	corpus := []interface{}{
+		plain{Name: "a", Score: 1e21, Tags: map[string][]int{"x": {1, 2}}},
+		tagged{Name: "b", Secret: "s"},
+		complex(1, 2),
+		func() {},
+	}
+	diffs, err := DiffJSON(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diffs {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`#1 $.Count: dfpt 0, encoding/json <missing>`,
+		`#1 $.Name: dfpt "b", encoding/json <missing>`,
+		`#1 $.Secret: dfpt "s", encoding/json <missing>`,
+		`#1 $.name: dfpt <missing>, encoding/json "b"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s", strings.Join(got, "\n"))
+	}
+
+	if diffs, err = DiffJSON([]interface{}{math.Inf(1), []interface{}{1, "2"}}); err != nil || len(diffs) != 0 {
+		t.Fatalf("got %v, %v", diffs, err)
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// JSONDifference is a semantic difference between the encodings of a value by EncodeJSON and by
+// encoding/json, e.g. caused by json tags, marshalers or the Propertier of the TraverseConf.
+type JSONDifference struct {
+	Index int    // index of the value in the corpus
+	Path  string // JSON path of the difference, e.g. $.Users[0].Name
+	Ours  string // JSON text by EncodeJSON, empty if missing, or the error of encoding
+	Std   string // JSON text by encoding/json, empty if missing, or the error of encoding
+}
+
+func (d JSONDifference) String() string {
+	return fmt.Sprintf("#%d %s: dfpt %s, encoding/json %s", d.Index, d.Path, jsonOrMissing(d.Ours), jsonOrMissing(d.Std))
+}
+
+func jsonOrMissing(s string) string {
+	if s == "" {
+		return "<missing>"
+	}
+	return s
+}
+
+// DiffJSON encodes each value of the corpus by both EncodeJSON with conf and encoding/json, and
+// returns the semantic differences of the results: order of object members and formats of numbers
+// and strings are ignored. It helps to verify that the Propertier and tags are set up as expected
+// before switching serializers, e.g. in tests:
+//
+//	diffs, err := DiffJSON(samples, conf)
+//	for _, d := range diffs {
+//		t.Error(d)
+//	}
+func DiffJSON(corpus []interface{}, conf ...*TraverseConf) ([]JSONDifference, error) {
+	var diffs []JSONDifference
+	for i, obj := range corpus {
+		var ours bytes.Buffer
+		oursErr := EncodeJSON(&ours, obj, conf...)
+		std, stdErr := json.Marshal(obj)
+		switch {
+		case oursErr != nil && stdErr != nil:
+			continue
+		case oursErr != nil || stdErr != nil:
+			d := JSONDifference{Index: i, Path: "$", Ours: ours.String(), Std: string(std)}
+			if oursErr != nil {
+				d.Ours = "error: " + oursErr.Error()
+			} else {
+				d.Std = "error: " + stdErr.Error()
+			}
+			diffs = append(diffs, d)
+			continue
+		}
+		a, err := decodeJSON(ours.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("decode #%d encoded by EncodeJSON: %w", i, err)
+		}
+		b, err := decodeJSON(std)
+		if err != nil {
+			return nil, fmt.Errorf("decode #%d encoded by encoding/json: %w", i, err)
+		}
+		diffs = diffJSONValue(diffs, i, "$", a, b, true, true)
+	}
+	return diffs, nil
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func jsonText(v interface{}, exist bool) string {
+	if !exist {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// diffJSONValue appends the differences between decoded values a and b at path
+func diffJSONValue(diffs []JSONDifference, index int, path string, a, b interface{}, aExist, bExist bool) []JSONDifference {
+	differ := func() []JSONDifference {
+		return append(diffs, JSONDifference{Index: index, Path: path, Ours: jsonText(a, aExist), Std: jsonText(b, bExist)})
+	}
+	if !aExist || !bExist {
+		return differ()
+	}
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return differ()
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, dup := av[k]; !dup {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			x, xok := av[k]
+			y, yok := bv[k]
+			diffs = diffJSONValue(diffs, index, path+"."+k, x, y, xok, yok)
+		}
+		return diffs
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			return differ()
+		}
+		for i := 0; i < len(av) || i < len(bv); i++ {
+			var x, y interface{}
+			if i < len(av) {
+				x = av[i]
+			}
+			if i < len(bv) {
+				y = bv[i]
+			}
+			diffs = diffJSONValue(diffs, index, fmt.Sprintf("%s[%d]", path, i), x, y, i < len(av), i < len(bv))
+		}
+		return diffs
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok || !sameNumber(av, bv) {
+			return differ()
+		}
+		return diffs
+	default:
+		if !reflect.DeepEqual(a, b) {
+			return differ()
+		}
+		return diffs
+	}
+}
+
+// sameNumber returns whether the JSON numbers are the same in different formats, e.g. 1e2 and 100
+func sameNumber(a, b json.Number) bool {
+	if a == b {
+		return true
+	}
+	x, xerr := strconv.ParseInt(a.String(), 10, 64)
+	y, yerr := strconv.ParseInt(b.String(), 10, 64)
+	if xerr == nil && yerr == nil {
+		return x == y
+	}
+	f, ferr := a.Float64()
+	g, gerr := b.Float64()
+	return ferr == nil && gerr == nil && f == g
+}
@@ -0,0 +1,165 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+type (
+	// sizeLimits are the limits of a value declared by LimitTagName, 0 for no limit
+	sizeLimits struct {
+		maxLen   int
+		maxItems int
+	}
+
+	// limitChecker is the adapter checking the values against the limits of their fields
+	limitChecker struct {
+		goInAll
+		issues Diagnostics
+		ptrs   []sizeLimits // limits of the pointer fields being traversed, for the values they point to
+	}
+)
+
+func parseLimits(opts tagOptions) (sizeLimits, error) {
+	var limits sizeLimits
+	for name, value := range opts {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("illegal %s=%s", name, value)
+		}
+		switch name {
+		case LimitMaxLen:
+			limits.maxLen = n
+		case LimitMaxItems:
+			limits.maxItems = n
+		default:
+			return limits, fmt.Errorf("unknown limit %s", name)
+		}
+	}
+	return limits, nil
+}
+
+// limitsOf returns the limits of the value of node, which are declared on its struct field, or on
+// the pointer field pointing to it.
+func (c *limitChecker) limitsOf(node *NodeInfo) (sizeLimits, error) {
+	if index, ok := fieldIndex(node); ok {
+		limits, err := parseLimits(structInfo(node.Parent.Type()).limits[index])
+		if err != nil {
+			return limits, fmt.Errorf("field %s of type %s: %v", node.Name, node.Parent.Type(), err)
+		}
+		return limits, nil
+	}
+	if node.Parent.IsValid() && node.Parent.Kind() == reflect.Ptr && len(c.ptrs) > 0 {
+		return c.ptrs[len(c.ptrs)-1], nil
+	}
+	return sizeLimits{}, nil
+}
+
+func (c *limitChecker) check(node *NodeInfo, val reflect.Value) error {
+	limits, err := c.limitsOf(node)
+	if err != nil || limits == (sizeLimits{}) {
+		return err
+	}
+	if val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.String:
+		c.exceeds(node, LimitMaxLen, val.Len(), limits.maxLen)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if val.Kind() != reflect.Map && val.Type().Elem().Kind() == reflect.Uint8 {
+			c.exceeds(node, LimitMaxLen, val.Len(), limits.maxLen)
+		}
+		c.exceeds(node, LimitMaxItems, val.Len(), limits.maxItems)
+	}
+	return nil
+}
+
+func (c *limitChecker) exceeds(node *NodeInfo, name string, n, max int) {
+	if max > 0 && n > max {
+		c.issues = append(c.issues, Diagnostic{
+			Path: node.Path,
+			Err:  fmt.Errorf("%w: %s %d > %d", ErrLimitExceeded, name, n, max),
+		})
+	}
+}
+
+func (c *limitChecker) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (c *limitChecker) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return c.check(node, val)
+}
+
+func (c *limitChecker) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return true, nil
+	}
+	return true, c.check(node, val)
+}
+
+func (c *limitChecker) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return true, nil
+	}
+	return true, c.check(node, val)
+}
+
+func (c *limitChecker) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	if !startOrEnd {
+		c.ptrs = c.ptrs[:len(c.ptrs)-1]
+		return true, nil
+	}
+	limits, err := c.limitsOf(node)
+	c.ptrs = append(c.ptrs, limits)
+	return true, err
+}
+
+func (c *limitChecker) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return true, nil
+	}
+	return true, c.check(node, val)
+}
+
+// CheckLimits returns the values of obj exceeding the size limits declared in the tags of their
+// struct fields (or the pointer fields pointing to them), e.g. `limit:"maxlen=256,maxitems=100"`,
+// see LimitMaxLen and LimitMaxItems. Each Diagnostic has the path of the value and an error
+// wrapping ErrLimitExceeded. Illegal limit tags fail the check.
+func CheckLimits(obj interface{}, conf ...*TraverseConf) (Diagnostics, error) {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	checker := &limitChecker{}
+	tr, err := NewTraveller(checker, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+	return checker.issues, nil
+}
@@ -0,0 +1,77 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckLimits(t *testing.T) {
+	type item struct {
+		Name string `limit:"maxlen=3"`
+	}
+	note := "too long"
+	obj := &struct {
+		Title  string            `limit:"maxlen=5"`
+		Tags   []string          `limit:"maxitems=2"`
+		Attrs  map[string]string `limit:"maxitems=1"`
+		Data   []byte            `limit:"maxlen=2,maxitems=10"`
+		Note   *string           `limit:"maxlen=4"`
+		Items  []item
+		Any    interface{} `limit:"maxlen=1"`
+		Free   string
+		Absent *string `limit:"maxlen=1"`
+	}{
+		Title: "hello",
+		Tags:  []string{"a", "b", "c"},
+		Attrs: map[string]string{"a": "1", "b": "2"},
+		Data:  []byte{1, 2, 3},
+		Note:  &note,
+		Items: []item{{Name: "abc"}, {Name: "abcd"}},
+		Any:   "xy",
+		Free:  "no limit at all",
+	}
+	diags, err := CheckLimits(obj, &TraverseConf{SortMapKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expects := []string{
+		"Tags: limit exceeded: maxitems 3 > 2",
+		"Attrs: limit exceeded: maxitems 2 > 1",
+		"Data: limit exceeded: maxlen 3 > 2",
+		"Note: limit exceeded: maxlen 8 > 4",
+		"Items[1].Name: limit exceeded: maxlen 4 > 3",
+		"Any: limit exceeded: maxlen 2 > 1",
+	}
+	if len(diags) != len(expects) {
+		t.Fatalf("diagnostics: %v", diags)
+	}
+	for i, diag := range diags {
+		if !errors.Is(diag.Err, ErrLimitExceeded) || diag.Path.String()+": "+diag.Err.Error() != expects[i] {
+			t.Errorf("diagnostic %d: %s: %v, expecting %s", i, diag.Path, diag.Err, expects[i])
+		}
+	}
+
+	bad := struct {
+		S string `limit:"maxlen=x"`
+	}{}
+	if _, err = CheckLimits(bad); err == nil {
+		t.Fatal("expecting error of illegal limit")
+	}
+}
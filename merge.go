@@ -0,0 +1,231 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// SliceMerge is how Merge writes a slice of src into dst
+type SliceMerge int
+
+const (
+	SliceReplace SliceMerge = iota // dst slice is replaced by a copy of the src one
+	SliceAppend                    // src elements are appended to the dst slice
+)
+
+type (
+	// MergePolicy decides which values of src are written into dst by Merge
+	MergePolicy struct {
+		// if true, zero values of src are written too, otherwise they are skipped
+		OverwriteWithZero bool
+		// how slices are written
+		Slices SliceMerge
+		// if not nil, it decides whether the src value (a leaf, a slice, or a nil map or pointer)
+		// of node is written in place of the dst one (invalid if missing in a map), instead of
+		// OverwriteWithZero
+		Select func(node *NodeInfo, dst, src reflect.Value) bool
+	}
+
+	mergeFrame struct {
+		dst     reflect.Value
+		entries []reflect.Value // (key, value) pairs to be stored into the dst map at the end
+	}
+
+	// merger is the adapter writing the values of src into their counterparts in dst
+	merger struct {
+		policy MergePolicy
+		root   reflect.Value
+		frames []*mergeFrame
+	}
+)
+
+// should returns whether src should be written into dst
+func (m *merger) should(node *NodeInfo, dst, src reflect.Value) bool {
+	if m.policy.Select != nil {
+		return m.policy.Select(node, dst, src)
+	}
+	return m.policy.OverwriteWithZero || !src.IsZero()
+}
+
+// counterpart returns the dst value of node and whether it's a map entry
+func (m *merger) counterpart(node *NodeInfo) (reflect.Value, bool) {
+	if len(node.Path) == 0 {
+		return m.root, false
+	}
+	top := m.frames[len(m.frames)-1]
+	if top.dst.Kind() == reflect.Map {
+		return top.dst.MapIndex(node.Path[len(node.Path)-1].Key), true
+	}
+	return stepValue(top.dst, node.Path[len(node.Path)-1]), false
+}
+
+func (m *merger) write(node *NodeInfo, dst reflect.Value, inMap bool, val reflect.Value) error {
+	if inMap {
+		m.frames[len(m.frames)-1].dst.SetMapIndex(node.Path[len(node.Path)-1].Key, val)
+		return nil
+	}
+	if !dst.CanSet() {
+		return fmt.Errorf("merge: %q of dst is not settable", node.Path)
+	}
+	dst.Set(val)
+	return nil
+}
+
+func (m *merger) leaf(node *NodeInfo, val reflect.Value) error {
+	if isMapKey(node.Path) {
+		return nil
+	}
+	dst, inMap := m.counterpart(node)
+	if !m.should(node, dst, val) {
+		return nil
+	}
+	return m.write(node, dst, inMap, val)
+}
+
+func (m *merger) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		top := m.frames[len(m.frames)-1]
+		m.frames = m.frames[:len(m.frames)-1]
+		for i := 0; i+1 < len(top.entries); i += 2 {
+			top.dst.SetMapIndex(top.entries[i], top.entries[i+1])
+		}
+		return true, nil
+	}
+	if isMapKey(node.Path) {
+		return false, nil
+	}
+	if opaqueStruct(node, val) {
+		// a struct without properties (e.g. time.Time) is written as a whole
+		return false, m.leaf(node, val)
+	}
+	dst, inMap := m.counterpart(node)
+	switch val.Kind() {
+	case reflect.Slice:
+		if !m.should(node, dst, val) {
+			return false, nil
+		}
+		if m.policy.Slices == SliceAppend && dst.IsValid() && !dst.IsNil() {
+			return false, m.write(node, dst, inMap, reflect.AppendSlice(copySlice(dst), val))
+		}
+		return false, m.write(node, dst, inMap, copySlice(val))
+	case reflect.Map, reflect.Ptr:
+		if val.IsNil() {
+			return false, m.leaf(node, val)
+		}
+	}
+	if inMap {
+		// merge into an addressable copy of the entry, which is stored at the end of the map
+		entry := reflect.New(val.Type()).Elem()
+		if dst.IsValid() {
+			entry.Set(dst)
+		}
+		top := m.frames[len(m.frames)-1]
+		top.entries = append(top.entries, node.Path[len(node.Path)-1].Key, entry)
+		dst = entry
+	}
+	switch val.Kind() {
+	case reflect.Map:
+		if dst.IsNil() {
+			if err := m.write(node, dst, false, reflect.MakeMapWithSize(val.Type(), val.Len())); err != nil {
+				return false, err
+			}
+		}
+	case reflect.Ptr:
+		if dst.IsNil() {
+			if err := m.write(node, dst, false, reflect.New(val.Type().Elem())); err != nil {
+				return false, err
+			}
+		}
+	}
+	m.frames = append(m.frames, &mergeFrame{dst: dst})
+	return true, nil
+}
+
+func copySlice(val reflect.Value) reflect.Value {
+	if val.IsNil() {
+		return val
+	}
+	ret := reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+	reflect.Copy(ret, val)
+	return ret
+}
+
+func (m *merger) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return m.leaf(node, val)
+}
+
+func (m *merger) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	return m.leaf(node, val)
+}
+
+func (m *merger) ForCycle(_ *TravContext, _ *NodeInfo, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (m *merger) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return m.container(node, startOrEnd, val)
+}
+
+func (m *merger) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return m.container(node, startOrEnd, val)
+}
+
+func (m *merger) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return m.container(node, startOrEnd, val)
+}
+
+func (m *merger) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return m.container(node, startOrEnd, val)
+}
+
+func (m *merger) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return m.container(node, startOrEnd, val)
+}
+
+// Merge writes the non-zero values (or the ones selected by policy) of src into dst, which should be
+// a non-nil pointer to a value of the type of src (or src itself if it's a pointer). Structs,
+// arrays, maps and pointers are merged recursively (nil dst maps and pointers are allocated),
+// leaves, slices and structs without properties (e.g. time.Time) are written as a whole, and cycles in src are ignored.
+func Merge(dst, src interface{}, policy MergePolicy, conf ...*TraverseConf) error {
+	dv, sv := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if !dv.IsValid() || dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return errors.New("merge: dst should be a non-nil pointer")
+	}
+	if !sv.IsValid() {
+		return nil
+	}
+	if dv.Type() != sv.Type() {
+		if dv.Elem().Type() != sv.Type() {
+			return fmt.Errorf("merge: can not merge %s into %s", sv.Type(), dv.Type())
+		}
+		dv = dv.Elem()
+	}
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.DetectCycles = true
+	c.TrackReferences = false
+	c.AsyncLeaves = 0
+	tr, err := NewTraveller(&merger{policy: policy, root: dv}, c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), src)
+}
@@ -0,0 +1,142 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mergeServer struct {
+	Host string
+	Port int
+}
+
+type mergeConfig struct {
+	Name    string
+	Debug   bool
+	Server  mergeServer
+	Backup  *mergeServer
+	Peers   []string
+	Limits  map[string]int
+	Servers map[string]mergeServer
+}
+
+func newMergeBase() *mergeConfig {
+	return &mergeConfig{
+		Name:    "base",
+		Server:  mergeServer{Host: "localhost", Port: 80},
+		Peers:   []string{"a"},
+		Limits:  map[string]int{"cpu": 1, "mem": 2},
+		Servers: map[string]mergeServer{"s1": {Host: "h1", Port: 1}},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	override := mergeConfig{
+		Debug:   true,
+		Server:  mergeServer{Port: 8080},
+		Backup:  &mergeServer{Host: "backup"},
+		Peers:   []string{"b", "c"},
+		Limits:  map[string]int{"mem": 4, "disk": 8},
+		Servers: map[string]mergeServer{"s1": {Port: 10}, "s2": {Host: "h2"}},
+	}
+	dst := newMergeBase()
+	if err := Merge(dst, override, MergePolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	expect := &mergeConfig{
+		Name:    "base",
+		Debug:   true,
+		Server:  mergeServer{Host: "localhost", Port: 8080},
+		Backup:  &mergeServer{Host: "backup"},
+		Peers:   []string{"b", "c"},
+		Limits:  map[string]int{"cpu": 1, "mem": 4, "disk": 8},
+		Servers: map[string]mergeServer{"s1": {Host: "h1", Port: 10}, "s2": {Host: "h2"}},
+	}
+	if !reflect.DeepEqual(dst, expect) {
+		t.Fatalf("merged: %+v, expecting %+v", dst, expect)
+	}
+	override.Peers[0] = "x"
+	if dst.Peers[0] != "b" {
+		t.Fatal("slices should be copied")
+	}
+
+	dst = newMergeBase()
+	if err := Merge(dst, &mergeConfig{Peers: []string{"b"}}, MergePolicy{Slices: SliceAppend}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(dst.Peers) != "[a b]" || dst.Name != "base" {
+		t.Fatalf("appended: %+v", dst)
+	}
+
+	dst = newMergeBase()
+	if err := Merge(dst, mergeConfig{Name: "new"}, MergePolicy{OverwriteWithZero: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, &mergeConfig{Name: "new"}) {
+		t.Fatalf("overwritten: %+v", dst)
+	}
+
+	dst = newMergeBase()
+	onlyName := MergePolicy{Select: func(node *NodeInfo, _, _ reflect.Value) bool {
+		return node.Path.String() == "Name"
+	}}
+	if err := Merge(dst, mergeConfig{Name: "new", Debug: true}, onlyName); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name != "new" || dst.Debug {
+		t.Fatalf("selected: %+v", dst)
+	}
+
+	if err := Merge(mergeConfig{}, override, MergePolicy{}); err == nil {
+		t.Fatal("expecting error of non-pointer dst")
+	}
+	if err := Merge(&mergeServer{}, override, MergePolicy{}); err == nil {
+		t.Fatal("expecting error of different types")
+	}
+}
+
+func TestMergeOpaqueStruct(t *testing.T) {
+	type stamped struct {
+		Name string
+		T    time.Time
+		Map  map[string]time.Time
+	}
+	now := time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC)
+	dst := &stamped{Name: "a"}
+	if err := Merge(dst, stamped{T: now, Map: map[string]time.Time{"k": now}}, MergePolicy{}); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Name != "a" || !dst.T.Equal(now) || !dst.Map["k"].Equal(now) {
+		t.Fatalf("time not merged: %+v", dst)
+	}
+	// zero times are written only with OverwriteWithZero
+	if err := Merge(dst, stamped{}, MergePolicy{}); err != nil || !dst.T.Equal(now) {
+		t.Fatalf("zero time overwrote: %+v %v", dst, err)
+	}
+	if err := Merge(dst, stamped{}, MergePolicy{OverwriteWithZero: true}); err != nil || !dst.T.IsZero() {
+		t.Fatalf("zero time not written: %+v %v", dst, err)
+	}
+	var bare time.Time
+	if err := Merge(&bare, now, MergePolicy{}); err != nil || !bare.Equal(now) {
+		t.Fatalf("root time not merged: %v %v", bare, err)
+	}
+}
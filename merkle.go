@@ -0,0 +1,141 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+)
+
+type (
+	// MerkleNode is a value in the MerkleTree, the hash of a container is derived from the type of
+	// the container and the hashes of its children in order, which is the same as its digest by
+	// Checksum.
+	MerkleNode struct {
+		Path     string
+		Hash     []byte
+		Children []*MerkleNode
+		header   []byte // bytes hashed before the hashes of the children, nil for leaves
+		parent   *MerkleNode
+	}
+
+	// MerkleTree is the tree of the hashes of all values of an object, built by BuildMerkleTree
+	MerkleTree struct {
+		Root    *MerkleNode
+		newHash func() hash.Hash
+		byPath  map[string]*MerkleNode
+	}
+
+	// MerkleStep is a container on the way from a value to the root in a MerkleProof
+	MerkleStep struct {
+		Header []byte   // bytes of the container type hashed before the hashes of its children
+		Before [][]byte // hashes of the children before the one on the way
+		After  [][]byte // hashes of the children after the one on the way
+	}
+
+	// MerkleProof proves that the value with Hash is at Path of the object with the root hash
+	MerkleProof struct {
+		Path  string
+		Hash  []byte
+		Steps []MerkleStep // from the container of the value up to the root
+	}
+)
+
+// merkle records the node of the value with its hash into the tree being built
+func (c *checksummer) merkle(node *NodeInfo, sum []byte, container bool) {
+	var m *MerkleNode
+	if container {
+		m = c.nodes[len(c.nodes)-1]
+		c.nodes = c.nodes[:len(c.nodes)-1]
+	} else {
+		m = &MerkleNode{}
+	}
+	m.Path, m.Hash = node.Path.String(), sum
+	// a pointer and the value it points to share the same path, the pointer finishes later
+	c.tree.byPath[m.Path] = m
+	if len(c.nodes) == 0 {
+		c.tree.Root = m
+		return
+	}
+	m.parent = c.nodes[len(c.nodes)-1]
+	m.parent.Children = append(m.parent.Children, m)
+}
+
+// BuildMerkleTree builds the Merkle tree of obj, with the same options of Checksum (Subtrees is
+// ignored), the hash of the root is the digest of obj by Checksum.
+func BuildMerkleTree(obj interface{}, opts ...*ChecksumOptions) (*MerkleTree, error) {
+	s, err := checksum(obj, true, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return s.tree, nil
+}
+
+// Node returns the node at path (in the form of Path.String()), nil if not found
+func (t *MerkleTree) Node(path string) *MerkleNode {
+	return t.byPath[path]
+}
+
+// Proof returns the proof of the value at path (in the form of Path.String())
+func (t *MerkleTree) Proof(path string) (*MerkleProof, error) {
+	node := t.byPath[path]
+	if node == nil {
+		return nil, fmt.Errorf("merkle: no value at %q", path)
+	}
+	proof := &MerkleProof{Path: path, Hash: node.Hash}
+	for child := node; child.parent != nil; child = child.parent {
+		step := MerkleStep{Header: child.parent.header}
+		before := true
+		for _, sibling := range child.parent.Children {
+			switch {
+			case sibling == child:
+				before = false
+			case before:
+				step.Before = append(step.Before, sibling.Hash)
+			default:
+				step.After = append(step.After, sibling.Hash)
+			}
+		}
+		proof.Steps = append(proof.Steps, step)
+	}
+	return proof, nil
+}
+
+// Verify returns whether the proof leads to the root hash with the hash function (sha256.New if
+// nil) used to build the tree.
+func (p *MerkleProof) Verify(root []byte, newHash func() hash.Hash) bool {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	sum := p.Hash
+	for _, step := range p.Steps {
+		h := newHash()
+		h.Write(step.Header)
+		for _, b := range step.Before {
+			h.Write(b)
+		}
+		h.Write(sum)
+		for _, a := range step.After {
+			h.Write(a)
+		}
+		sum = h.Sum(nil)
+	}
+	return bytes.Equal(sum, root)
+}
@@ -0,0 +1,78 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"crypto/md5"
+	"testing"
+)
+
+func TestMerkleTree(t *testing.T) {
+	type account struct {
+		ID      string
+		Balance int
+	}
+	state := &struct {
+		Height   int
+		Accounts []*account
+		Meta     map[string]string
+	}{
+		Height:   7,
+		Accounts: []*account{{ID: "a", Balance: 10}, {ID: "b", Balance: 20}},
+		Meta:     map[string]string{"chain": "x", "net": "y"},
+	}
+	for _, opts := range []*ChecksumOptions{nil, {New: md5.New}} {
+		tree, err := BuildMerkleTree(state, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sums, err := Checksum(state, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.Root.Hash, sums.Root) {
+			t.Fatalf("root hash %x, expecting %x", tree.Root.Hash, sums.Root)
+		}
+		var newHash = md5.New
+		if opts == nil {
+			newHash = nil
+		}
+		for _, path := range []string{"", "Height", "Accounts[1].Balance", "Accounts[0]", "Meta[net]"} {
+			proof, err := tree.Proof(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proof.Verify(tree.Root.Hash, newHash) {
+				t.Fatalf("proof of %q failed", path)
+			}
+			if len(proof.Steps) > 0 {
+				proof.Steps[0].Header = append([]byte(nil), "tampered"...)
+				if proof.Verify(tree.Root.Hash, newHash) {
+					t.Fatalf("tampered proof of %q verified", path)
+				}
+			}
+		}
+		if tree.Node("Accounts[1].Balance") == nil || tree.Node("Accounts[1].Balance").Children != nil {
+			t.Fatal("leaf node not found")
+		}
+		if _, err = tree.Proof("Nothing"); err == nil {
+			t.Fatal("expecting error of unknown path")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import "reflect"
+
+// NameMapping maps the Go field names of struct types to their wire names (see
+// TraverseConf.FieldNames), so that the same structs can be encoded in more than one vocabulary,
+// e.g. the legacy and the new API. Fields not in the mapping keep their Go names.
+type NameMapping map[reflect.Type]map[string]string
+
+// Set sets the wire names of the fields of the struct type of sample (or the struct type it points
+// to), and returns m for chaining
+func (m NameMapping) Set(sample interface{}, names map[string]string) NameMapping {
+	typ := reflect.TypeOf(sample)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ != nil {
+		m[typ] = names
+	}
+	return m
+}
+
+// WireName returns the wire name of the field of typ
+func (m NameMapping) WireName(typ reflect.Type, field string) string {
+	if name, ok := m[typ][field]; ok {
+		return name
+	}
+	return field
+}
+
+// FieldName returns the Go name of the field of typ with the wire name, false if there's no such
+// field
+func (m NameMapping) FieldName(typ reflect.Type, wire string) (string, bool) {
+	for field, name := range m[typ] {
+		if name == wire {
+			return field, true
+		}
+	}
+	if _, renamed := m[typ][wire]; renamed {
+		return "", false
+	}
+	if typ.Kind() != reflect.Struct {
+		return "", false
+	}
+	if f, ok := typ.FieldByName(wire); ok && len(f.Index) == 1 {
+		return wire, true
+	}
+	return "", false
+}
+
+// rename returns the properties of typ with their wire names, props is not modified
+func (m NameMapping) rename(typ reflect.Type, props []Property) []Property {
+	names := m[typ]
+	if len(names) == 0 {
+		return props
+	}
+	ret := make([]Property, len(props))
+	copy(ret, props)
+	for i := range ret {
+		if name, ok := names[ret[i].Name]; ok && ret[i].Index >= 0 {
+			ret[i].Name = name
+		}
+	}
+	return ret
+}
@@ -0,0 +1,114 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestNameMapping(t *testing.T) {
+	type account struct {
+		UserName string
+		Email    string `limit:"maxlen=5"`
+		Age      int
+	}
+	legacy := NameMapping{}.Set(&account{}, map[string]string{"UserName": "login", "Email": "mail"})
+	modern := NameMapping{}.Set(account{}, map[string]string{"UserName": "user_name", "Age": "age"})
+	obj := &account{UserName: "ann", Email: "ann@example.com", Age: 30}
+
+	for _, c := range []struct {
+		names NameMapping
+		want  string
+	}{
+		{nil, `{"UserName":"ann","Email":"ann@example.com","Age":30}`},
+		{legacy, `{"login":"ann","mail":"ann@example.com","Age":30}`},
+		{modern, `{"user_name":"ann","Email":"ann@example.com","age":30}`},
+	} {
+		var buf bytes.Buffer
+		if err := EncodeJSON(&buf, obj, &TraverseConf{FieldNames: c.names}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != c.want {
+			t.Fatalf("expecting %s, got %s", c.want, buf.String())
+		}
+	}
+
+	// tags of the renamed fields still work
+	issues, err := CheckLimits(obj, &TraverseConf{FieldNames: legacy})
+	if err != nil || len(issues) != 1 || issues[0].Path.String() != "mail" {
+		t.Fatalf("limits of renamed field: %v %v", issues, err)
+	}
+
+	typ := reflect.TypeOf(account{})
+	if legacy.WireName(typ, "UserName") != "login" || legacy.WireName(typ, "Age") != "Age" {
+		t.Fatal("wrong wire names")
+	}
+	for wire, want := range map[string]string{"login": "UserName", "Age": "Age", "UserName": "", "none": ""} {
+		if got, ok := legacy.FieldName(typ, wire); got != want || ok != (want != "") {
+			t.Fatalf("field name of %s: expecting %q, got %q %t", wire, want, got, ok)
+		}
+	}
+}
+
+func TestResolveRenamed(t *testing.T) {
+	type account struct {
+		A, B string
+	}
+	for _, names := range []map[string]string{
+		{"A": "login"},
+		{"A": "B", "B": "A"}, // swapped
+	} {
+		var resolved []string
+		b := NewAdapterBuilder().
+			OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+				return true, nil
+			}).
+			OnKind(reflect.String, func(ctx *TravContext, node *NodeInfo, val reflect.Value) error {
+				got, err := ctx.Resolve(node.Path)
+				if err != nil {
+					return err
+				}
+				if got.String() != val.String() {
+					return fmt.Errorf("%s resolved %q, expecting %q", node.Path, got, val)
+				}
+				// hand-written path of the wire name
+				got, err = ctx.Resolve(Path{{Kind: reflect.Struct, Name: node.Name}})
+				if err != nil {
+					return err
+				}
+				resolved = append(resolved, node.Name+"="+got.String())
+				return nil
+			})
+		conf := &TraverseConf{FieldNames: NameMapping{}.Set(account{}, names)}
+		tr, err := NewTraveller(b, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tr.Traverse(nil, account{A: "aa", B: "bb"}); err != nil {
+			t.Fatalf("%v: %v", names, err)
+		}
+		want := "[" + conf.FieldNames.WireName(reflect.TypeOf(account{}), "A") + "=aa " +
+			conf.FieldNames.WireName(reflect.TypeOf(account{}), "B") + "=bb]"
+		if fmt.Sprint(resolved) != want {
+			t.Fatalf("%v: resolved %v, expecting %s", names, resolved, want)
+		}
+	}
+}
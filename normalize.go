@@ -0,0 +1,170 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+type (
+	// StringNormalization canonicalizes strings by case folding, Unicode normalization and trimming,
+	// in this order.
+	StringNormalization struct {
+		// Unicode normalization form, e.g. norm.NFC.String or norm.NFKC.String of
+		// golang.org/x/text/unicode/norm, nil for no normalization
+		Form func(string) string
+		// remove leading and trailing white spaces
+		TrimSpace bool
+		// fold the case of letters by mapping them to the lower case of their upper case
+		FoldCase bool
+		// case mapping of the locale for FoldCase, e.g. unicode.TurkishCase, nil for the default
+		Locale unicode.SpecialCase
+		// if true, strings in the fields tagged with `dfpt:"normalize"` (including the strings in
+		// their elements) are selected
+		Tagged bool
+		// the strings at the paths are selected, a path is either exact (e.g. "Users[0].Name") or a
+		// pattern matching any index and map value with [*] (e.g. "Users[*].Name")
+		Paths []string
+	}
+
+	// normalizer is the adapter replacing the selected strings with their normalized ones
+	normalizer struct {
+		n      StringNormalization
+		paths  map[string]struct{}
+		tagged []bool // whether the containers being traversed are in tagged fields
+	}
+)
+
+// Apply returns the normalized s
+func (n StringNormalization) Apply(s string) string {
+	if n.FoldCase {
+		s = strings.Map(func(r rune) rune {
+			return n.Locale.ToLower(n.Locale.ToUpper(r))
+		}, s)
+	}
+	if n.Form != nil {
+		s = n.Form(s)
+	}
+	if n.TrimSpace {
+		s = strings.TrimSpace(s)
+	}
+	return s
+}
+
+// selected returns whether the string of node should be normalized
+func (a *normalizer) selected(node *NodeInfo) bool {
+	if !a.n.Tagged && len(a.paths) == 0 {
+		return true
+	}
+	if a.n.Tagged && (a.inTagged() || fieldTagged(node, TagNormalize)) {
+		return true
+	}
+	if len(a.paths) > 0 {
+		if _, ok := a.paths[node.Path.String()]; ok {
+			return true
+		}
+		_, ok := a.paths[pathPattern(node.Path)]
+		return ok
+	}
+	return false
+}
+
+func (a *normalizer) inTagged() bool {
+	return len(a.tagged) > 0 && a.tagged[len(a.tagged)-1]
+}
+
+func (a *normalizer) container(node *NodeInfo, startOrEnd bool) (bool, error) {
+	if startOrEnd {
+		a.tagged = append(a.tagged, a.inTagged() || (a.n.Tagged && fieldTagged(node, TagNormalize)))
+	} else {
+		a.tagged = a.tagged[:len(a.tagged)-1]
+	}
+	return true, nil
+}
+
+func (a *normalizer) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (a *normalizer) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	if len(node.Path) > 0 && node.Path[len(node.Path)-1].IsKey {
+		// map keys can not be replaced
+		return nil, false, nil
+	}
+	str := val
+	if str.Kind() == reflect.Interface {
+		str = str.Elem()
+	}
+	if str.Kind() != reflect.String || !a.selected(node) {
+		return nil, false, nil
+	}
+	s := a.n.Apply(str.String())
+	if s == str.String() {
+		return nil, false, nil
+	}
+	if val.Kind() == reflect.Interface {
+		// keep the dynamic type held by the interface
+		return reflect.ValueOf(s).Convert(str.Type()).Interface(), true, nil
+	}
+	return s, true, nil
+}
+
+func (a *normalizer) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return a.container(node, startOrEnd)
+}
+
+func (a *normalizer) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return a.container(node, startOrEnd)
+}
+
+func (a *normalizer) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return a.container(node, startOrEnd)
+}
+
+func (a *normalizer) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return a.container(node, startOrEnd)
+}
+
+func (a *normalizer) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return a.container(node, startOrEnd)
+}
+
+// NormalizeStrings replaces the strings (including the ones held by interfaces, but not map keys)
+// of obj selected by n with their normalized ones in place, so obj should be given by pointer. All
+// strings are selected if neither Tagged nor Paths is set.
+func NormalizeStrings(obj interface{}, n StringNormalization, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.Addressable = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	a := &normalizer{n: n}
+	if len(n.Paths) > 0 {
+		a.paths = make(map[string]struct{}, len(n.Paths))
+		for _, p := range n.Paths {
+			a.paths[p] = struct{}{}
+		}
+	}
+	tr, err := NewTraveller(a, c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), obj)
+}
@@ -0,0 +1,103 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+type normalizedUser struct {
+	Name  string
+	Email string `dfpt:"normalize"`
+}
+
+type normalizedRequest struct {
+	Title   string
+	Keys    []string `dfpt:"normalize"`
+	Users   []*normalizedUser
+	Labels  map[string]string
+	Comment interface{}
+}
+
+func newNormalizedRequest() *normalizedRequest {
+	return &normalizedRequest{
+		Title:   " Hello ",
+		Keys:    []string{" A ", "B"},
+		Users:   []*normalizedUser{{Name: " Bob ", Email: " Bob@X.org "}, nil},
+		Labels:  map[string]string{" K ": " V "},
+		Comment: " Hi ",
+	}
+}
+
+func TestNormalizeStrings(t *testing.T) {
+	n := StringNormalization{TrimSpace: true, FoldCase: true}
+	req := newNormalizedRequest()
+	if err := NormalizeStrings(req, n); err != nil {
+		t.Fatal(err)
+	}
+	if req.Title != "hello" || fmt.Sprint(req.Keys) != "[a b]" || req.Users[0].Name != "bob" ||
+		req.Users[0].Email != "bob@x.org" || req.Labels[" K "] != "v" || req.Comment != "hi" {
+		t.Fatalf("all strings: %+v %+v", req, req.Users[0])
+	}
+
+	n.Tagged = true
+	req = newNormalizedRequest()
+	if err := NormalizeStrings(req, n); err != nil {
+		t.Fatal(err)
+	}
+	if req.Title != " Hello " || fmt.Sprint(req.Keys) != "[a b]" || req.Users[0].Name != " Bob " ||
+		req.Users[0].Email != "bob@x.org" {
+		t.Fatalf("tagged strings: %+v %+v", req, req.Users[0])
+	}
+
+	n.Tagged = false
+	n.Paths = []string{"Users[*].Name", "Title"}
+	req = newNormalizedRequest()
+	if err := NormalizeStrings(req, n); err != nil {
+		t.Fatal(err)
+	}
+	if req.Title != "hello" || req.Keys[0] != " A " || req.Users[0].Name != "bob" ||
+		req.Users[0].Email != " Bob@X.org " || req.Comment != " Hi " {
+		t.Fatalf("strings by paths: %+v %+v", req, req.Users[0])
+	}
+
+	if err := NormalizeStrings(*newNormalizedRequest(), n); err == nil || !strings.Contains(err.Error(), "not settable") {
+		t.Fatalf("expecting not settable error, got %v", err)
+	}
+}
+
+func TestStringNormalizationApply(t *testing.T) {
+	tests := []struct {
+		n      StringNormalization
+		in     string
+		expect string
+	}{
+		{StringNormalization{FoldCase: true}, "Straße ΣΑΣ", "straße σασ"},
+		{StringNormalization{FoldCase: true, Locale: unicode.TurkishCase}, "İstanbul", "istanbul"},
+		{StringNormalization{Form: strings.ToUpper, TrimSpace: true}, " a\t", "A"},
+		{StringNormalization{}, " a ", " a "},
+	}
+	for _, test := range tests {
+		if got := test.n.Apply(test.in); got != test.expect {
+			t.Errorf("%q: got %q, expecting %q", test.in, got, test.expect)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// MaxSafeInteger is the max integer which can be represented exactly by float64 (and JavaScript
+// numbers), so do -MaxSafeInteger.
+const MaxSafeInteger = 1<<53 - 1
+
+var (
+	ErrNotFloat32     = errors.New("not exactly representable as float32")
+	ErrUnsafeInteger  = errors.New("integer out of the safe range")
+	ErrNegativeNumber = errors.New("negative number in unsigned field")
+)
+
+// NumericChecks selects the checks of CheckNumbers
+type NumericChecks struct {
+	// float64 values should be exactly representable as float32
+	Float32 bool
+	// integers should be in [-MaxSafeInteger, MaxSafeInteger]
+	SafeInteger bool
+	// signed numbers of struct fields tagged with `dfpt:"unsigned"` should not be negative
+	Unsigned bool
+}
+
+// check returns the problem of number val
+func (checks NumericChecks) check(node *NodeInfo, val reflect.Value) error {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := val.Int()
+		if checks.SafeInteger && (i > MaxSafeInteger || i < -MaxSafeInteger) {
+			return fmt.Errorf("%w: %d", ErrUnsafeInteger, i)
+		}
+		if checks.Unsigned && i < 0 && fieldTagged(node, TagUnsigned) {
+			return fmt.Errorf("%w: %d", ErrNegativeNumber, i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := val.Uint(); checks.SafeInteger && u > MaxSafeInteger {
+			return fmt.Errorf("%w: %d", ErrUnsafeInteger, u)
+		}
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		if checks.Float32 && val.Kind() == reflect.Float64 && !math.IsNaN(f) && float64(float32(f)) != f {
+			return fmt.Errorf("%w: %v", ErrNotFloat32, f)
+		}
+		if checks.Unsigned && f < 0 && fieldTagged(node, TagUnsigned) {
+			return fmt.Errorf("%w: %v", ErrNegativeNumber, f)
+		}
+	}
+	return nil
+}
+
+// CheckNumbers returns the numbers of obj failing the checks, e.g. before it's encoded for
+// JavaScript consumers. Each Diagnostic has the path of the number and an error wrapping
+// ErrNotFloat32, ErrUnsafeInteger or ErrNegativeNumber.
+func CheckNumbers(obj interface{}, checks NumericChecks, conf ...*TraverseConf) (Diagnostics, error) {
+	return checkLeaves(obj, func(node *NodeInfo, val reflect.Value) []error {
+		if err := checks.check(node, val); err != nil {
+			return []error{err}
+		}
+		return nil
+	}, conf...)
+}
@@ -0,0 +1,75 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"testing"
+)
+
+type payload struct {
+	ID      int64
+	Count   int `dfpt:"unsigned"`
+	Delta   int
+	Price   float64 `dfpt:"unsigned"`
+	Ratio   float64
+	Big     uint64
+	Samples []float64
+	Any     interface{}
+}
+
+func TestCheckNumbers(t *testing.T) {
+	p := &payload{
+		ID:      MaxSafeInteger + 1,
+		Count:   -1,
+		Delta:   -1,
+		Price:   -0.5,
+		Ratio:   0.1,
+		Big:     MaxSafeInteger,
+		Samples: []float64{0.5, 1e300},
+		Any:     int64(-MaxSafeInteger - 1),
+	}
+	issues, err := CheckNumbers(p, NumericChecks{Float32: true, SafeInteger: true, Unsigned: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		path string
+		err  error
+	}{
+		{"ID", ErrUnsafeInteger},
+		{"Count", ErrNegativeNumber},
+		{"Price", ErrNegativeNumber},
+		{"Ratio", ErrNotFloat32},
+		{"Samples[1]", ErrNotFloat32},
+		{"Any", ErrUnsafeInteger},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues: %v", issues)
+	}
+	for i, w := range want {
+		if issues[i].Path.String() != w.path || !errors.Is(issues[i].Err, w.err) {
+			t.Fatalf("issue %d: %v, want %s %v", i, issues[i], w.path, w.err)
+		}
+	}
+
+	issues, err = CheckNumbers(p, NumericChecks{Unsigned: true})
+	if err != nil || len(issues) != 2 {
+		t.Fatalf("unsigned only: %v %v", issues, err)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+)
+
+type (
+	// OrderedCollector assembles the outputs of bindings in traversal order (by NodeInfo.Seq), even
+	// if the bindings are called asynchronously (TraverseConf.AsyncLeaves). An output is emitted once
+	// all asynchronous bindings of the values before it have returned. Set it to the TravContext by
+	// SetCollector before traversal, and Close it after.
+	OrderedCollector struct {
+		lock    sync.Mutex
+		emit    func(seq int, output interface{}) error
+		pending map[int]int // seq -> count of running asynchronous bindings
+		waiting seqHeap     // seqs of pending bindings
+		outputs outputHeap  // buffered outputs
+		count   int         // count of Put, to keep the order of outputs with the same seq
+		err     error
+	}
+
+	seqHeap []int
+
+	orderedOutput struct {
+		seq    int
+		count  int
+		output interface{}
+	}
+
+	outputHeap []orderedOutput
+)
+
+func NewOrderedCollector(emit func(seq int, output interface{}) error) *OrderedCollector {
+	return &OrderedCollector{emit: emit, pending: make(map[int]int)}
+}
+
+// Put buffers the output of the value with seq, and emits all outputs could be emitted in order
+func (c *OrderedCollector) Put(seq int, output interface{}) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	heap.Push(&c.outputs, orderedOutput{seq: seq, count: c.count, output: output})
+	c.count++
+	return c.release(false)
+}
+
+// Close emits all the buffered outputs, it should be called after the traversal returns.
+func (c *OrderedCollector) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if len(c.waiting) > 0 {
+		return errors.New("closing collector with running bindings")
+	}
+	return c.release(true)
+}
+
+func (c *OrderedCollector) begin(seq int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.pending[seq] == 0 {
+		heap.Push(&c.waiting, seq)
+	}
+	c.pending[seq]++
+}
+
+func (c *OrderedCollector) end(seq int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pending[seq]--
+	if c.pending[seq] > 0 {
+		return
+	}
+	delete(c.pending, seq)
+	for len(c.waiting) > 0 {
+		if _, ok := c.pending[c.waiting[0]]; ok {
+			break
+		}
+		heap.Pop(&c.waiting)
+	}
+	if c.err == nil {
+		c.err = c.release(false)
+	}
+}
+
+// release emits outputs before the first pending binding, or all of them if all is true
+func (c *OrderedCollector) release(all bool) error {
+	for len(c.outputs) > 0 {
+		first := c.outputs[0]
+		if !all && len(c.waiting) > 0 && first.seq > c.waiting[0] {
+			// outputs of the values after the first pending binding should wait
+			break
+		}
+		heap.Pop(&c.outputs)
+		if err := c.emit(first.seq, first.output); err != nil {
+			c.err = err
+			return err
+		}
+	}
+	return nil
+}
+
+func (h seqHeap) Len() int            { return len(h) }
+func (h seqHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h seqHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *seqHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *seqHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func (h outputHeap) Len() int { return len(h) }
+func (h outputHeap) Less(i, j int) bool {
+	if h[i].seq != h[j].seq {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].count < h[j].count
+}
+func (h outputHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *outputHeap) Push(x interface{}) { *h = append(*h, x.(orderedOutput)) }
+func (h *outputHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
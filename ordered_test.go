@@ -0,0 +1,137 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type orderedPrinter struct{}
+
+func (orderedPrinter) ForKindInt(ctx *TravContext, node *NodeInfo, val reflect.Value) error {
+	// later values finish earlier
+	time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+	return ctx.Collector().Put(node.Seq, fmt.Sprintf("%s=%d", node.Path, val.Int()))
+}
+
+func (orderedPrinter) ForContainerSlice(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	if startOrEnd {
+		return true, ctx.Collector().Put(node.Seq, fmt.Sprintf("%s(%d)", node.Path, node.Size))
+	}
+	return true, nil
+}
+
+func TestOrderedCollector(t *testing.T) {
+	tr, err := NewTraveller(orderedPrinter{}, &TraverseConf{AsyncLeaves: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := [][]int{{1, 2, 3, 4, 5}, {}, {6, 7, 8}}
+	expected := "[(3) [0](5) [0][0]=1 [0][1]=2 [0][2]=3 [0][3]=4 [0][4]=5 [1](0) [2](3) [2][0]=6 [2][1]=7 [2][2]=8]"
+	for i := 0; i < 10; i++ {
+		var lock sync.Mutex
+		var outputs []string
+		last := -1
+		collector := NewOrderedCollector(func(seq int, output interface{}) error {
+			lock.Lock()
+			defer lock.Unlock()
+			if seq < last {
+				return fmt.Errorf("seq %d after %d", seq, last)
+			}
+			last = seq
+			outputs = append(outputs, output.(string))
+			return nil
+		})
+		if err = tr.Traverse(NewContext().SetCollector(collector), obj); err != nil {
+			t.Fatal(err)
+		}
+		if err = collector.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(outputs) != expected {
+			t.Fatalf("got %v, expecting %s", outputs, expected)
+		}
+	}
+}
+
+// asyncProbe records whether the leaf bindings are called asynchronously, which hold worker
+// tokens of the traversal while they run
+type asyncProbe struct {
+	lock  *sync.Mutex
+	async map[string]bool // path -> called asynchronously
+}
+
+func (p asyncProbe) ForKindInt(ctx *TravContext, node *NodeInfo, _ reflect.Value) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.async[node.Path.String()] = len(ctx.workers) > 0
+	return nil
+}
+
+func (p asyncProbe) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (p asyncProbe) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestAsyncMinLeaves(t *testing.T) {
+	type inner struct {
+		X int
+	}
+	type lopsided struct {
+		A          int
+		B, C, D, E inner
+	}
+	p := asyncProbe{lock: new(sync.Mutex), async: make(map[string]bool)}
+	tr, err := NewTraveller(p, &TraverseConf{AsyncLeaves: 2, AsyncMinLeaves: 3, InterfaceAutoGoIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// lopsided has 5 children but 1 leaf estimated by sampling, []int has 3
+	if err = tr.Traverse(NewContext(), []interface{}{lopsided{}, []int{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]bool{"[0].A": false, "[0].B.X": false, "[0].C.X": false, "[0].D.X": false,
+		"[0].E.X": false, "[1][0]": true, "[1][1]": true, "[1][2]": true}
+	if !reflect.DeepEqual(p.async, expected) {
+		t.Fatalf("got %v, expecting %v", p.async, expected)
+	}
+
+	// by the running statistics of []interface{}, 1 leaf of the 8 children seen, the 4 leaves are
+	// estimated 0 though all of them would be leaves in a sample
+	for k := range p.async {
+		delete(p.async, k)
+	}
+	if err = tr.Traverse(NewContext(), []interface{}{0, []int{}, []int{}, []int{}, []int{}, []int{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(NewContext(), []interface{}{1, 2, 3, 4}); err != nil {
+		t.Fatal(err)
+	}
+	expected = map[string]bool{"[0]": false, "[1]": false, "[2]": false, "[3]": false}
+	if !reflect.DeepEqual(p.async, expected) {
+		t.Fatalf("got %v, expecting %v", p.async, expected)
+	}
+}
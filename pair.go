@@ -0,0 +1,106 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+)
+
+type (
+	// pairFrame is the counterpart in the other object of a container being traversed
+	pairFrame struct {
+		depth int // len(Path) of the container
+		other reflect.Value
+	}
+
+	// pairStack follows the counterparts in another object of the values being traversed, so that
+	// two objects can be walked in lockstep by traversing one of them.
+	pairStack struct {
+		root   reflect.Value
+		frames []pairFrame
+	}
+)
+
+func newPairStack(other interface{}) *pairStack {
+	return &pairStack{root: reflect.ValueOf(other)}
+}
+
+// stepValue returns the child of val located by the path node n, invalid if there's no such child
+func stepValue(val reflect.Value, n PathNode) reflect.Value {
+	if !val.IsValid() || val.Kind() != n.Kind {
+		return reflect.Value{}
+	}
+	switch n.Kind {
+	case reflect.Struct:
+		if n.Index < 0 || n.Index >= val.NumField() {
+			return reflect.Value{}
+		}
+		return val.Field(n.Index)
+	case reflect.Array, reflect.Slice:
+		if n.Index < 0 || n.Index >= val.Len() {
+			return reflect.Value{}
+		}
+		return val.Index(n.Index)
+	case reflect.Map:
+		if !n.Key.IsValid() || !n.Key.Type().AssignableTo(val.Type().Key()) {
+			return reflect.Value{}
+		}
+		v := val.MapIndex(n.Key)
+		if n.IsKey && v.IsValid() {
+			return n.Key
+		}
+		return v
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return reflect.Value{}
+		}
+		return val.Elem()
+	default:
+		return reflect.Value{}
+	}
+}
+
+// counterpart returns the value in the other object at the path of node, invalid if missing
+func (s *pairStack) counterpart(node *NodeInfo) reflect.Value {
+	if len(node.Path) == 0 {
+		return s.root
+	}
+	if len(s.frames) == 0 {
+		return reflect.Value{}
+	}
+	return stepValue(s.frames[len(s.frames)-1].other, node.Path[len(node.Path)-1])
+}
+
+// push records the counterpart of the container node which is going to be traversed
+func (s *pairStack) push(node *NodeInfo, other reflect.Value) {
+	s.frames = append(s.frames, pairFrame{depth: len(node.Path), other: other})
+}
+
+func (s *pairStack) pop() {
+	s.frames = s.frames[:len(s.frames)-1]
+}
+
+// ancestor returns the counterpart of the ancestor container being traversed
+func (s *pairStack) ancestor(node *NodeInfo) reflect.Value {
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		if s.frames[i].depth == len(node.Path) {
+			return s.frames[i].other
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,131 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+type (
+	// Pass is a step of a Pipeline, either an adapter traversing the object with Conf, or a function
+	// processing it (e.g. wrapping NormalizeStrings or EncodeYAML).
+	Pass struct {
+		Name    string
+		Adapter interface{}
+		Conf    *TraverseConf
+		Func    func(ctx *TravContext, obj interface{}) error
+		// if not empty, the pass only runs for objects of these types, pointers to them, or
+		// implementations of the interfaces in them
+		Types []reflect.Type
+		// if not nil, the pass only runs for objects it returns true
+		When func(obj interface{}) bool
+	}
+
+	// Pipeline runs passes over the same object in sequence (e.g. validate, default, normalize,
+	// encode) with a shared TravContext, so that locals put by a pass can be read by the following
+	// ones. It stops at the first failed pass.
+	Pipeline struct {
+		passes     []Pass
+		travellers []*Traveller // travellers of the adapter passes, nil for function passes
+	}
+)
+
+// NewPipeline returns a pipeline of passes, the travellers of the adapter passes are created here,
+// so that invalid adapters are reported before running.
+func NewPipeline(passes ...Pass) (*Pipeline, error) {
+	p := &Pipeline{passes: passes, travellers: make([]*Traveller, len(passes))}
+	for i, pass := range passes {
+		if (pass.Adapter == nil) == (pass.Func == nil) {
+			return nil, fmt.Errorf("pass %s: one and only one of Adapter and Func should be set", pass.label(i))
+		}
+		if pass.Adapter == nil {
+			continue
+		}
+		tr, err := NewTraveller(pass.Adapter, pass.Conf)
+		if err != nil {
+			return nil, fmt.Errorf("pass %s: %w", pass.label(i), err)
+		}
+		p.travellers[i] = tr
+	}
+	return p, nil
+}
+
+// label returns the name of the pass, or its index if it has no name
+func (p Pass) label(i int) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// applies returns whether the pass runs for obj with its Types and When
+func (p Pass) applies(obj interface{}) bool {
+	if len(p.Types) > 0 {
+		typ := reflect.TypeOf(obj)
+		if typ == nil {
+			return false
+		}
+		matched := false
+		for _, t := range p.Types {
+			if typeMatches(typ, t) || (typ.Kind() == reflect.Ptr && typeMatches(typ.Elem(), t)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return p.When == nil || p.When(obj)
+}
+
+func typeMatches(typ, want reflect.Type) bool {
+	if want.Kind() == reflect.Interface {
+		return typ.Implements(want)
+	}
+	return typ == want
+}
+
+// Run runs the passes applying to obj (see Pass.Types and Pass.When) over it in sequence with ctx
+// (a new one if nil), and returns the error of the first failed pass with its name. The statistics
+// of ctx are reset by each adapter pass, the locals are kept.
+func (p *Pipeline) Run(ctx *TravContext, obj interface{}) error {
+	if p == nil {
+		return errors.New("nil pipeline")
+	}
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	for i, pass := range p.passes {
+		if !pass.applies(obj) {
+			continue
+		}
+		var err error
+		if tr := p.travellers[i]; tr != nil {
+			err = tr.Traverse(ctx, obj)
+		} else {
+			err = pass.Func(ctx, obj)
+		}
+		if err != nil {
+			return fmt.Errorf("pass %s: %w", pass.label(i), err)
+		}
+	}
+	return nil
+}
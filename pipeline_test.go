@@ -0,0 +1,137 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type emptyCounter struct{}
+
+func (emptyCounter) ForKindString(ctx *TravContext, _ *NodeInfo, val reflect.Value) error {
+	if strings.TrimSpace(val.String()) == "" {
+		n, _ := ctx.GetLocal("empty")
+		count, _ := n.(int)
+		ctx.PutLocal("empty", count+1)
+	}
+	return nil
+}
+
+func (emptyCounter) ForContainerPtr(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (emptyCounter) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestPipeline(t *testing.T) {
+	type config struct {
+		Name string
+		Host string
+	}
+	var out bytes.Buffer
+	p, err := NewPipeline(
+		Pass{Name: "validate", Adapter: emptyCounter{}},
+		Pass{Name: "default", Func: func(ctx *TravContext, obj interface{}) error {
+			if n, _ := ctx.GetLocal("empty"); n != 1 {
+				return errors.New("expecting 1 empty string")
+			}
+			if c := obj.(*config); c.Host == "" {
+				c.Host = "localhost"
+			}
+			return nil
+		}},
+		Pass{Name: "normalize", Func: func(_ *TravContext, obj interface{}) error {
+			return NormalizeStrings(obj, StringNormalization{TrimSpace: true})
+		}},
+		Pass{Name: "encode", Func: func(_ *TravContext, obj interface{}) error {
+			return EncodeYAML(&out, obj)
+		}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Run(nil, &config{Name: " app "}); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Name: app\nHost: localhost\n" {
+		t.Fatalf("got %q", out.String())
+	}
+
+	out.Reset()
+	err = p.Run(nil, &config{Name: "app", Host: "h"})
+	if err == nil || err.Error() != "pass default: expecting 1 empty string" || out.Len() != 0 {
+		t.Fatalf("got %v, output %q", err, out.String())
+	}
+
+	if _, err = NewPipeline(Pass{}); err == nil {
+		t.Fatal("expecting error of empty pass")
+	}
+	if _, err = NewPipeline(Pass{Adapter: struct{}{}}); err == nil || !strings.HasPrefix(err.Error(), "pass #0:") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestPipelineGating(t *testing.T) {
+	type order struct{ ID int }
+	type refund struct{ ID int }
+	var ran []string
+	pass := func(name string, types []reflect.Type, when func(interface{}) bool) Pass {
+		return Pass{Name: name, Types: types, When: when, Func: func(*TravContext, interface{}) error {
+			ran = append(ran, name)
+			return nil
+		}}
+	}
+	p, err := NewPipeline(
+		pass("all", nil, nil),
+		pass("order", []reflect.Type{reflect.TypeOf(order{})}, nil),
+		pass("refund", []reflect.Type{reflect.TypeOf(refund{})}, nil),
+		pass("stringer", []reflect.Type{reflect.TypeOf((*fmt.Stringer)(nil)).Elem()}, nil),
+		pass("big", nil, func(obj interface{}) bool {
+			o, ok := obj.(*order)
+			return ok && o.ID > 100
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		obj  interface{}
+		want string
+	}{
+		{order{ID: 1}, "[all order]"},
+		{&order{ID: 101}, "[all order big]"},
+		{&refund{}, "[all refund]"},
+		{time.Second, "[all stringer]"},
+	} {
+		ran = nil
+		if err = p.Run(nil, c.obj); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(ran) != c.want {
+			t.Fatalf("%#v: got %v, want %s", c.obj, ran, c.want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// maxPrealloc is the max number of elements of a slice or map allocated before they are populated
+const maxPrealloc = 64
+
+var _typeOfUnknowns = reflect.TypeOf(map[string]interface{}(nil))
+
+type (
+	// Source provides values for Populate in the order of a depth-first traversal of the target
+	// object, the same order in which a Traveller with the same Propertier visits it. node.Value is
+	// the value going to be set, its type tells what is expected.
+	Source interface {
+		NextBool(node *NodeInfo) (bool, error)
+		NextInt(node *NodeInfo) (int64, error)     // for Int/Int8/Int16/Int32/Int64
+		NextUint(node *NodeInfo) (uint64, error)   // for Uint/Uint8/Uint16/Uint32/Uint64/Uintptr
+		NextFloat(node *NodeInfo) (float64, error) // for Float32/Float64
+		NextComplex(node *NodeInfo) (complex128, error)
+		NextString(node *NodeInfo) (string, error)
+		// BeginContainer starts a container of kind (Array/Slice/Map/Ptr/Struct) and returns the count
+		// of its children: elements of Array/Slice, entries of Map, slots of Struct (including
+		// placeholders), 0 for nil Ptr and 1 for others.
+		BeginContainer(node *NodeInfo, kind reflect.Kind) (int, error)
+		EndContainer(node *NodeInfo, kind reflect.Kind) error
+		// NextPlaceholder consumes a placeholder slot of a struct (Property.Index < 0)
+		NextPlaceholder(node *NodeInfo) error
+	}
+
+	// UnknownSource is a Source keyed by names, e.g. decoded from JSON objects, whose structs may
+	// have inputs without corresponding properties, like the fields added by newer versions. They are
+	// kept in the map[string]interface{} field tagged with `dfpt:"unknown"` for forward compatibility,
+	// like the unknown fields of protobuf, or dropped if the struct has no such field. The tagged
+	// field is not a property of the struct in traversals either, a fixed slot of it (given by
+	// SlotPropertier) is kept as a placeholder.
+	UnknownSource interface {
+		Source
+		// UnknownFields returns the inputs of the struct node.Value without corresponding properties
+		// by their names, it's called before EndContainer of the struct.
+		UnknownFields(node *NodeInfo) (map[string]interface{}, error)
+	}
+
+	populater struct {
+		conf *TraverseConf
+		src  Source
+	}
+)
+
+// Populate fills obj, which must be a non-nil pointer, with values from src. Struct fields are
+// populated in the order given by the Propertier in conf (exported fields in declaration order by
+// default), so src can be the inverse of an encoder adapter of a Traveller with the same conf.
+func Populate(src Source, obj interface{}, conf ...*TraverseConf) error {
+	if src == nil {
+		return errors.New("nil source")
+	}
+	val := reflect.ValueOf(obj)
+	if !val.IsValid() || val.Kind() != reflect.Ptr || val.IsNil() {
+		return errors.New("populating needs a non-nil pointer")
+	}
+	p := &populater{src: src}
+	if len(conf) > 0 && conf[0] != nil {
+		p.conf = conf[0].Clone()
+	}
+	return p.populate(nil, val.Elem())
+}
+
+func (p *populater) populate(parent *parentInfo, val reflect.Value) error {
+	_, isContainer := _containers[val.Kind()]
+	node := parent.nodeInfo(val, 0, isContainer)
+	switch kind := val.Kind(); kind {
+	case reflect.Bool:
+		b, err := p.src.NextBool(node)
+		if err != nil {
+			return err
+		}
+		val.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := p.src.NextInt(node)
+		if err != nil {
+			return err
+		}
+		if val.OverflowInt(i) {
+			return fmt.Errorf("%d overflows %s at %s", i, val.Type(), node.Path)
+		}
+		val.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := p.src.NextUint(node)
+		if err != nil {
+			return err
+		}
+		if val.OverflowUint(u) {
+			return fmt.Errorf("%d overflows %s at %s", u, val.Type(), node.Path)
+		}
+		val.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := p.src.NextFloat(node)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := p.src.NextComplex(node)
+		if err != nil {
+			return err
+		}
+		val.SetComplex(c)
+	case reflect.String:
+		s, err := p.src.NextString(node)
+		if err != nil {
+			return err
+		}
+		val.SetString(s)
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Ptr, reflect.Struct:
+		return p.populateContainer(parent, node, val)
+	case reflect.Interface:
+		return p.populateInterface(parent, node, val)
+	default:
+		return fmt.Errorf("populating %s at %s not supported", val.Type(), node.Path)
+	}
+	return nil
+}
+
+// populateInterface populates the implementation named by TypedSource, and set it to val
+func (p *populater) populateInterface(parent *parentInfo, node *NodeInfo, val reflect.Value) error {
+	src, ok := p.src.(TypedSource)
+	if !ok || p.conf == nil || p.conf.Types == nil {
+		return fmt.Errorf("populating %s at %s needs TypedSource and TypeRegistry", val.Type(), node.Path)
+	}
+	name, err := src.NextTypeName(node)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		val.Set(reflect.Zero(val.Type()))
+		return nil
+	}
+	impl, err := p.conf.Types.New(val.Type(), name)
+	if err != nil {
+		return fmt.Errorf("%v at %s", err, node.Path)
+	}
+	if err = p.populate(parent, impl); err != nil {
+		return err
+	}
+	val.Set(impl)
+	return nil
+}
+
+// populateUnknown sets the inputs of the struct val without corresponding properties given by
+// UnknownSource to its field of index tagged with `dfpt:"unknown"`
+func (p *populater) populateUnknown(node *NodeInfo, val reflect.Value, index int) error {
+	src, ok := p.src.(UnknownSource)
+	if !ok {
+		return nil
+	}
+	unknowns, err := src.UnknownFields(node)
+	if err != nil || index < 0 || len(unknowns) == 0 {
+		return err
+	}
+	field := val.Type().Field(index)
+	if field.Type != _typeOfUnknowns || field.PkgPath != "" {
+		return fmt.Errorf("unknown field %s of %s at %s should be an exported map[string]interface{}",
+			field.Name, val.Type(), node.Path)
+	}
+	val.Field(index).Set(reflect.ValueOf(unknowns))
+	return nil
+}
+
+// preallocSize returns the capacity allocated in advance for size elements from a Source
+func preallocSize(size int) int {
+	if size > maxPrealloc {
+		return maxPrealloc
+	}
+	return size
+}
+
+func (p *populater) populateContainer(parent *parentInfo, node *NodeInfo, val reflect.Value) error {
+	kind := val.Kind()
+	size, err := p.src.BeginContainer(node, kind)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		return fmt.Errorf("illegal size %d of %s at %s", size, val.Type(), node.Path)
+	}
+	node.Size = size
+	info := &parentInfo{
+		depth:  parent.nextDepth(),
+		value:  val,
+		size:   size,
+		offset: -1,
+		path:   node.Path,
+	}
+	switch kind {
+	case reflect.Array:
+		if size != val.Len() {
+			return fmt.Errorf("length %d of %s at %s", size, val.Type(), node.Path)
+		}
+		for i := 0; i < size; i++ {
+			info.offset = i
+			if err = p.populate(info, val.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		// size is not trusted, the slice grows as the elements are produced
+		val.Set(reflect.MakeSlice(val.Type(), 0, preallocSize(size)))
+		zero := reflect.Zero(val.Type().Elem())
+		for i := 0; i < size; i++ {
+			val.Set(reflect.Append(val, zero))
+			info.offset = i
+			if err = p.populate(info, val.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		m := reflect.MakeMapWithSize(val.Type(), preallocSize(size))
+		info.size = size << 1
+		for i := 0; i < size; i++ {
+			k := reflect.New(val.Type().Key()).Elem()
+			info.offset, info.mapKey = i<<1, reflect.Value{}
+			if err = p.populate(info, k); err != nil {
+				return err
+			}
+			v := reflect.New(val.Type().Elem()).Elem()
+			info.offset, info.mapKey = i<<1+1, k
+			if err = p.populate(info, v); err != nil {
+				return err
+			}
+			m.SetMapIndex(k, v)
+		}
+		val.Set(m)
+	case reflect.Ptr:
+		switch size {
+		case 0:
+			val.Set(reflect.Zero(val.Type()))
+		case 1:
+			elem := reflect.New(val.Type().Elem())
+			info.offset = 0
+			if err = p.populate(info, elem.Elem()); err != nil {
+				return err
+			}
+			val.Set(elem)
+		default:
+			return fmt.Errorf("illegal size %d of %s at %s", size, val.Type(), node.Path)
+		}
+	case reflect.Struct:
+		psize, fields := structProperties(p.conf, val)
+		if size != psize {
+			return fmt.Errorf("%d slots of %s at %s, expecting %d", size, val.Type(), node.Path, psize)
+		}
+		info.structFields = fields
+		for i, field := range fields {
+			info.offset = i
+			if field.Index < 0 {
+				if err = p.src.NextPlaceholder(info.nodeInfo(reflect.Value{}, 0, false)); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = p.populate(info, val.Field(field.Index)); err != nil {
+				return err
+			}
+		}
+		if err = p.populateUnknown(node, val, structInfo(val.Type()).unknown); err != nil {
+			return err
+		}
+	}
+	return p.src.EndContainer(node, kind)
+}
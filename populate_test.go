@@ -0,0 +1,247 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tokenSource provides values from a token list, containers are started by their sizes
+type tokenSource struct {
+	tokens []interface{}
+	paths  []string
+}
+
+func (s *tokenSource) next(node *NodeInfo) (interface{}, error) {
+	if len(s.tokens) == 0 {
+		return nil, fmt.Errorf("no token for %s", node.Path)
+	}
+	t := s.tokens[0]
+	s.tokens = s.tokens[1:]
+	s.paths = append(s.paths, node.Path.String())
+	return t, nil
+}
+
+func (s *tokenSource) NextBool(node *NodeInfo) (bool, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return false, err
+	}
+	return t.(bool), nil
+}
+
+func (s *tokenSource) NextInt(node *NodeInfo) (int64, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return 0, err
+	}
+	return int64(t.(int)), nil
+}
+
+func (s *tokenSource) NextUint(node *NodeInfo) (uint64, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(t.(int)), nil
+}
+
+func (s *tokenSource) NextFloat(node *NodeInfo) (float64, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return 0, err
+	}
+	return t.(float64), nil
+}
+
+func (s *tokenSource) NextComplex(node *NodeInfo) (complex128, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return 0, err
+	}
+	return t.(complex128), nil
+}
+
+func (s *tokenSource) NextString(node *NodeInfo) (string, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return "", err
+	}
+	return t.(string), nil
+}
+
+func (s *tokenSource) BeginContainer(node *NodeInfo, _ reflect.Kind) (int, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return 0, err
+	}
+	return t.(int), nil
+}
+
+func (s *tokenSource) EndContainer(_ *NodeInfo, _ reflect.Kind) error {
+	return nil
+}
+
+func (s *tokenSource) NextPlaceholder(node *NodeInfo) error {
+	_, err := s.next(node)
+	return err
+}
+
+func TestPopulate(t *testing.T) {
+	src := &tokenSource{tokens: []interface{}{
+		4,          // flatObj slots
+		"root",     // Name
+		1, "k", 10, // Labels
+		2,                               // Items
+		0,                               // Items[0] nil
+		1, 7, 1, 2, 3, 4, 5, -6, 1, 100, // Items[1]
+		1, 4, "next", 0, 0, 0, // Next
+	}}
+	obj := new(flatObj)
+	if err := Populate(src, obj); err != nil {
+		t.Fatal(err)
+	}
+	z := 100
+	expected := &flatObj{
+		Name:   "root",
+		Labels: map[string]int{"k": 10},
+		Items:  []*Inner0{nil, {A: 1, E: 2, B: 3, C: 4, D: 5, F: -6, Z: &z}},
+		Next:   &flatObj{Name: "next", Labels: map[string]int{}, Items: []*Inner0{}},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Fatalf("got %+v, expecting %+v", obj, expected)
+	}
+	t.Log(src.paths)
+	if src.paths[4] != "Labels[k]" {
+		t.Fatalf("paths: %v", src.paths)
+	}
+	if len(src.tokens) != 0 {
+		t.Fatalf("tokens left: %v", src.tokens)
+	}
+	if err := Populate(&tokenSource{tokens: []interface{}{3}}, new(flatObj)); err == nil {
+		t.Fatal("slots mismatch should fail")
+	}
+}
+
+func TestPopulateHostileSizes(t *testing.T) {
+	// sizes are not trusted, nothing is allocated for the elements never produced
+	var ints []int64
+	src := &tokenSource{tokens: []interface{}{1 << 40, 1, 2}}
+	if err := Populate(src, &ints); err == nil || !strings.Contains(err.Error(), "no token") {
+		t.Fatalf("expecting running out of tokens, got %v", err)
+	}
+	if len(ints) != 3 || ints[0] != 1 || ints[1] != 2 || cap(ints) > 1024 {
+		t.Fatalf("populated %v with capacity %d", ints, cap(ints))
+	}
+	var m map[string]int64
+	src = &tokenSource{tokens: []interface{}{1 << 40, "a", 1}}
+	if err := Populate(src, &m); err == nil || !strings.Contains(err.Error(), "no token") {
+		t.Fatalf("expecting running out of tokens, got %v", err)
+	}
+}
+
+// namedSource provides the fields of a flat struct from a map by their names
+type namedSource struct {
+	tokenSource
+	fields map[string]interface{}
+	used   map[string]bool
+}
+
+func (s *namedSource) field(node *NodeInfo) (interface{}, error) {
+	v, ok := s.fields[node.Name]
+	if !ok {
+		return nil, fmt.Errorf("no %s", node.Path)
+	}
+	s.used[node.Name] = true
+	return v, nil
+}
+
+func (s *namedSource) NextInt(node *NodeInfo) (int64, error) {
+	v, err := s.field(node)
+	if err != nil {
+		return 0, err
+	}
+	return int64(v.(int)), nil
+}
+
+func (s *namedSource) NextString(node *NodeInfo) (string, error) {
+	v, err := s.field(node)
+	if err != nil {
+		return "", err
+	}
+	return v.(string), nil
+}
+
+func (s *namedSource) BeginContainer(node *NodeInfo, _ reflect.Kind) (int, error) {
+	s.used = make(map[string]bool)
+	n := 0
+	for i := 0; i < node.Value.NumField(); i++ {
+		if f := node.Value.Type().Field(i); f.PkgPath == "" && f.Tag.Get(TagName) != TagUnknown {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *namedSource) UnknownFields(*NodeInfo) (map[string]interface{}, error) {
+	ret := make(map[string]interface{})
+	for k, v := range s.fields {
+		if !s.used[k] {
+			ret[k] = v
+		}
+	}
+	return ret, nil
+}
+
+func TestPopulateUnknown(t *testing.T) {
+	type v1 struct {
+		Name    string
+		Unknown map[string]interface{} `dfpt:"unknown"`
+		Age     int
+	}
+	src := &namedSource{fields: map[string]interface{}{"Name": "a", "Age": 3, "Email": "a@b", "Level": 2}}
+	obj := new(v1)
+	if err := Populate(src, obj); err != nil {
+		t.Fatal(err)
+	}
+	expected := &v1{Name: "a", Age: 3, Unknown: map[string]interface{}{"Email": "a@b", "Level": 2}}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Fatalf("got %+v, expecting %+v", obj, expected)
+	}
+
+	// dropped without the unknown field
+	type v0 struct {
+		Name string
+		Age  int
+	}
+	if err := Populate(src, new(v0)); err != nil {
+		t.Fatal(err)
+	}
+
+	type illegal struct {
+		Name    string
+		Age     int
+		Unknown map[string]string `dfpt:"unknown"`
+	}
+	if err := Populate(src, new(illegal)); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("expecting illegal unknown field, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+)
+
+type (
+	// NodePredicate is a condition on the values visited in traversals, see Filter and
+	// AdapterBuilder.OnNode. Predicates are composed with And, Or and Not.
+	NodePredicate func(node *NodeInfo) bool
+
+	// filterer is the adapter collecting the values matching the predicate
+	filterer struct {
+		match NodePredicate
+		found []PathValue
+	}
+)
+
+// ByKind matches the values of any of kinds
+func ByKind(kinds ...reflect.Kind) NodePredicate {
+	return func(node *NodeInfo) bool {
+		if !node.Value.IsValid() {
+			return false
+		}
+		for _, kind := range kinds {
+			if node.Value.Kind() == kind {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AtDepth matches the values at depth, 0 for the root
+func AtDepth(depth int) NodePredicate {
+	return func(node *NodeInfo) bool {
+		return node.Depth == depth
+	}
+}
+
+// UnderPath matches the value at path (in the form of Path.String, e.g. "Users[0].Name") and all
+// values under it, "" matches all.
+func UnderPath(path string) NodePredicate {
+	return func(node *NodeInfo) bool {
+		str := node.Path.String()
+		if len(str) < len(path) || str[:len(path)] != path {
+			return false
+		}
+		if len(str) == len(path) || path == "" {
+			return true
+		}
+		switch str[len(path)] {
+		case '.', '[', '{':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// And matches the values all of preds match, it's true if preds is empty
+func And(preds ...NodePredicate) NodePredicate {
+	return func(node *NodeInfo) bool {
+		for _, pred := range preds {
+			if !pred(node) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches the values any of preds matches, it's false if preds is empty
+func Or(preds ...NodePredicate) NodePredicate {
+	return func(node *NodeInfo) bool {
+		for _, pred := range preds {
+			if pred(node) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches the values pred does not match
+func Not(pred NodePredicate) NodePredicate {
+	return func(node *NodeInfo) bool {
+		return !pred(node)
+	}
+}
+
+func (f *filterer) visit(node *NodeInfo, val reflect.Value) {
+	if !f.match(node) {
+		return
+	}
+	pv := PathValue{Path: node.Path.String()}
+	if val.IsValid() && val.CanInterface() {
+		pv.Value = val.Interface()
+	}
+	f.found = append(f.found, pv)
+}
+
+func (f *filterer) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	f.visit(node, val)
+	return nil
+}
+
+func (f *filterer) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	f.visit(node, val)
+	return nil
+}
+
+func (f *filterer) ForContainerArray(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	f.visit(node, val)
+	return true, nil
+}
+
+func (f *filterer) ForContainerInterface(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	f.visit(node, val)
+	return true, nil
+}
+
+func (f *filterer) ForContainerMap(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	f.visit(node, val)
+	return true, nil
+}
+
+func (f *filterer) ForContainerPtr(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	f.visit(node, val)
+	return true, nil
+}
+
+func (f *filterer) ForContainerSlice(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	f.visit(node, val)
+	return true, nil
+}
+
+func (f *filterer) ForContainerStruct(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	f.visit(node, val)
+	return true, nil
+}
+
+// Filter returns the values (containers and leaves) of obj matching pred with their paths, in
+// traversal order (containers before their children).
+func Filter(obj interface{}, pred NodePredicate, conf ...*TraverseConf) ([]PathValue, error) {
+	c := confOf(conf...)
+	c.ContainerEnd = false
+	c.AsyncLeaves = 0
+	f := &filterer{match: pred}
+	tr, err := NewTraveller(f, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+	return f.found, nil
+}
@@ -0,0 +1,37 @@
+//go:build go1.18
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import "reflect"
+
+// ByType matches the values of type T, or implementing T if it's an interface, e.g.
+// ByType[time.Time]() or ByType[fmt.Stringer]()
+func ByType[T any]() NodePredicate {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return func(node *NodeInfo) bool {
+		if !node.Value.IsValid() {
+			return false
+		}
+		if typ.Kind() == reflect.Interface {
+			return node.Value.Type().Implements(typ)
+		}
+		return node.Value.Type() == typ
+	}
+}
@@ -0,0 +1,106 @@
+//go:build go1.18
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type predUser struct {
+	Name    string
+	Age     int
+	Created time.Time
+	Tags    map[string]string
+}
+
+type predTeam struct {
+	Lead  predUser
+	Users []predUser
+	Note  fmt.Stringer
+}
+
+func TestFilter(t *testing.T) {
+	created := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	team := predTeam{
+		Lead:  predUser{Name: "ann", Age: 40},
+		Users: []predUser{{Name: "bob", Age: 20, Created: created, Tags: map[string]string{"k": "v"}}, {Name: "cid"}},
+		Note:  created,
+	}
+	conf := &TraverseConf{SortMapKeys: true, IgnoreMissedBinding: true}
+	cases := []struct {
+		pred NodePredicate
+		want string
+	}{
+		{And(ByKind(reflect.String), UnderPath("Users")), "[{Users[0].Name bob} {Users[0].Tags{k} k} {Users[0].Tags[k] v} {Users[1].Name cid}]"},
+		{And(ByKind(reflect.String), UnderPath("Users[0].Tags"), Not(func(n *NodeInfo) bool { return isMapKey(n.Path) })), "[{Users[0].Tags[k] v}]"},
+		{And(ByKind(reflect.Int), Or(AtDepth(2), UnderPath("Users[1]"))), "[{Lead.Age 40} {Users[1].Age 0}]"},
+		{ByType[predUser](), "[{Lead {ann 40 0001-01-01 00:00:00 +0000 UTC map[]}} {Users[0] {bob 20 2023-01-02 00:00:00 +0000 UTC map[k:v]}} {Users[1] {cid 0 0001-01-01 00:00:00 +0000 UTC map[]}}]"},
+		{And(ByType[fmt.Stringer](), Not(ByKind(reflect.Interface)), Not(UnderPath("Lead")), Not(UnderPath("Users[1]"))), "[{Users[0].Created 2023-01-02 00:00:00 +0000 UTC} {Note 2023-01-02 00:00:00 +0000 UTC}]"},
+		{UnderPath("Use"), "[]"},
+		{And(), ""},
+	}
+	for i, c := range cases {
+		found, err := Filter(team, c.pred, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.want == "" {
+			if len(found) == 0 || found[0].Path != "" {
+				t.Fatalf("case %d: all values should be found, but %v", i, found)
+			}
+			continue
+		}
+		if got := fmt.Sprint(found); got != c.want {
+			t.Fatalf("case %d: got %s", i, got)
+		}
+	}
+}
+
+func TestOnNode(t *testing.T) {
+	var got []string
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnContainer(reflect.Slice, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnNode(And(UnderPath("Users"), ByKind(reflect.String)), func(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+			got = append(got, node.Path.String()+"="+val.String())
+			return nil
+		})
+	tr, err := NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := predTeam{Lead: predUser{Name: "ann"}, Users: []predUser{{Name: "bob"}}}
+	if err = tr.Traverse(nil, team); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Users[0].Name=bob]" {
+		t.Fatalf("got %v", got)
+	}
+	if _, err = NewTraveller(NewAdapterBuilder().OnNode(nil, func() {})); err == nil {
+		t.Fatal("nil predicate should fail")
+	}
+}
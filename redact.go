@@ -0,0 +1,114 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"strings"
+)
+
+type (
+	// Redactor makes redacted copies of objects, e.g. for logging, in which the fields tagged with
+	// `dfpt:"sensitive"` or with the configured names are blanked or masked.
+	Redactor struct {
+		// names of the fields (case-insensitive) to be redacted in addition to the tagged ones
+		Names []string
+		// replacement of the redacted strings, the strings are blanked if it's empty. Values of
+		// other types are always replaced with zero values.
+		Mask string
+		// config of the traversal, nil for default
+		Conf *TraverseConf
+	}
+
+	// redacter is the adapter replacing the values of the sensitive fields in a copy
+	redacter struct {
+		*Redactor
+	}
+)
+
+func (r redacter) sensitive(node *NodeInfo) bool {
+	if node.Name == "" || !node.Parent.IsValid() || node.Parent.Kind() != reflect.Struct {
+		return false
+	}
+	if fieldTagged(node, TagSensitive) {
+		return true
+	}
+	for _, name := range r.Names {
+		if strings.EqualFold(name, node.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r redacter) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	if !r.sensitive(node) {
+		return nil, false, nil
+	}
+	str := val
+	if str.Kind() == reflect.Interface && !str.IsNil() {
+		str = str.Elem()
+	}
+	if r.Mask != "" && str.Kind() == reflect.String {
+		return reflect.ValueOf(r.Mask).Convert(str.Type()).Interface(), true, nil
+	}
+	return nil, !val.IsZero(), nil
+}
+
+func (r redacter) container(node *NodeInfo, val reflect.Value) (bool, error) {
+	if !r.sensitive(node) {
+		return true, nil
+	}
+	if val.CanSet() {
+		val.Set(reflect.Zero(val.Type()))
+	}
+	return false, nil
+}
+
+func (r redacter) ForContainerArray(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return r.container(node, val)
+}
+
+func (r redacter) ForContainerMap(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return r.container(node, val)
+}
+
+func (r redacter) ForContainerPtr(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return r.container(node, val)
+}
+
+func (r redacter) ForContainerSlice(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return r.container(node, val)
+}
+
+func (r redacter) ForContainerStruct(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return r.container(node, val)
+}
+
+// Redact returns a redacted copy of obj, obj itself is left untouched.
+func (r *Redactor) Redact(obj interface{}) (interface{}, error) {
+	c := confOf(r.Conf)
+	c.Addressable = true
+	c.DetectCycles = true
+	c.ContainerEnd = false
+	tr, err := NewTraveller(redacter{r}, c)
+	if err != nil {
+		return nil, err
+	}
+	return tr.Transform(obj)
+}
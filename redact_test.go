@@ -0,0 +1,81 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"testing"
+)
+
+type redactCard struct {
+	Number string `dfpt:"sensitive"`
+	Expiry string
+}
+
+type redactUser struct {
+	Name     string
+	Password string
+	Token    []byte `dfpt:"sensitive"`
+	Card     *redactCard
+	Cards    map[string]redactCard
+	Secrets  map[string]string `dfpt:"sensitive"`
+	Hint     interface{}       `dfpt:"sensitive"`
+	Age      int               `dfpt:"sensitive"`
+}
+
+func TestRedactor(t *testing.T) {
+	user := &redactUser{
+		Name:     "alice",
+		Password: "p@ss",
+		Token:    []byte("token"),
+		Card:     &redactCard{Number: "4111", Expiry: "12/30"},
+		Cards:    map[string]redactCard{"backup": {Number: "5500", Expiry: "01/31"}},
+		Secrets:  map[string]string{"k": "v"},
+		Hint:     "pet",
+		Age:      30,
+	}
+	origin := &redactUser{}
+	*origin = *user
+	r := &Redactor{Names: []string{"password"}, Mask: "***"}
+	ret, err := r.Redact(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ret.(*redactUser)
+	expect := &redactUser{
+		Name:     "alice",
+		Password: "***",
+		Card:     &redactCard{Number: "***", Expiry: "12/30"},
+		Cards:    map[string]redactCard{"backup": {Number: "***", Expiry: "01/31"}},
+		Hint:     "***",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("redacted: %+v %+v %+v, expecting %+v", got, got.Card, got.Cards, expect)
+	}
+	if !reflect.DeepEqual(user, origin) || user.Card.Number != "4111" || user.Cards["backup"].Number != "5500" {
+		t.Fatalf("original modified: %+v", user)
+	}
+
+	ret, err = (&Redactor{}).Redact(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blanked := ret.(redactUser); blanked.Password != "p@ss" || blanked.Card.Number != "" || blanked.Hint != nil {
+		t.Fatalf("blanked: %+v %+v", blanked, blanked.Card)
+	}
+}
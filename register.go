@@ -0,0 +1,34 @@
+//go:build go1.18
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"reflect"
+)
+
+// Register binds fn to the values of type T (or implementing T if it's an interface) in b, like
+// OnType but with the property typed at compile time, so that the signature of fn needs no check.
+func Register[T any](b *AdapterBuilder, fn func(*TravContext, int, int, string, T) error) *AdapterBuilder {
+	if fn == nil {
+		return b.fail(errors.New("nil binding function"))
+	}
+	return b.bindType(reflect.TypeOf((*T)(nil)).Elem(), fn, true)
+}
@@ -0,0 +1,62 @@
+//go:build go1.18
+
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegister(t *testing.T) {
+	var logs []string
+	b := NewAdapterBuilder().OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+		return true, nil
+	})
+	Register(b, func(_ *TravContext, _, _ int, name string, d time.Duration) error {
+		logs = append(logs, name+"="+d.String())
+		return nil
+	})
+	Register(b, func(_ *TravContext, _, _ int, name string, s fmt.Stringer) error {
+		logs = append(logs, name+" stringer")
+		return nil
+	})
+	tr, err := NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct {
+		A time.Duration
+		B fmt.Stringer
+		C int
+	}{A: time.Second, B: time.Minute}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(logs, "|"); got != "A=1s|B stringer" {
+		t.Fatalf("got %s", got)
+	}
+
+	if _, err = NewTraveller(Register[int](NewAdapterBuilder(), nil)); err == nil {
+		t.Fatal("expecting error of nil function")
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+type (
+	// TypeRegistry maps interface types to their registered implementations, each of which has a
+	// discriminator name unique in the interface, so that values of interface type can be encoded
+	// with their names and instantiated by names when decoding.
+	TypeRegistry struct {
+		lock   sync.RWMutex
+		ifaces map[reflect.Type]*implementations
+	}
+
+	implementations struct {
+		byName map[string]reflect.Type
+		byType map[reflect.Type]string
+	}
+
+	// TypedSource is a Source which can populate interface values with a TypeRegistry in TraverseConf
+	TypedSource interface {
+		Source
+		// NextTypeName returns the discriminator name of the implementation for the interface value
+		// node.Value, empty name for nil.
+		NextTypeName(node *NodeInfo) (string, error)
+	}
+)
+
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{ifaces: make(map[reflect.Type]*implementations)}
+}
+
+// interfaceType accepts a pointer to interface like (*Shape)(nil), or the interface reflect.Type
+func interfaceType(iface interface{}) (reflect.Type, error) {
+	typ, ok := iface.(reflect.Type)
+	if !ok {
+		typ = reflect.TypeOf(iface)
+		if typ == nil || typ.Kind() != reflect.Ptr {
+			return nil, errors.New("interface should be given as a pointer to interface, e.g. (*Shape)(nil)")
+		}
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Interface {
+		return nil, fmt.Errorf("%s is not an interface", typ)
+	}
+	return typ, nil
+}
+
+// Register registers the type of sample as an implementation of iface with name. iface is a pointer
+// to the interface, e.g. (*Shape)(nil).
+func (r *TypeRegistry) Register(iface interface{}, name string, sample interface{}) error {
+	ityp, err := interfaceType(iface)
+	if err != nil {
+		return err
+	}
+	typ := reflect.TypeOf(sample)
+	if typ == nil || !typ.Implements(ityp) {
+		return fmt.Errorf("%v does not implement %s", typ, ityp)
+	}
+	if name == "" {
+		return errors.New("empty implementation name")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	impls, ok := r.ifaces[ityp]
+	if !ok {
+		impls = &implementations{byName: make(map[string]reflect.Type), byType: make(map[reflect.Type]string)}
+		r.ifaces[ityp] = impls
+	}
+	if exist, ok := impls.byName[name]; ok {
+		return fmt.Errorf("name %s of %s already registered by %s", name, ityp, exist)
+	}
+	if exist, ok := impls.byType[typ]; ok {
+		return fmt.Errorf("%s of %s already registered as %s", typ, ityp, exist)
+	}
+	impls.byName[name] = typ
+	impls.byType[typ] = name
+	return nil
+}
+
+// NameOf returns the name of the dynamic type of val in interface type iface (a reflect.Type or a
+// pointer to the interface), val can be an interface value or a concrete one.
+func (r *TypeRegistry) NameOf(iface interface{}, val reflect.Value) (string, bool) {
+	ityp, err := interfaceType(iface)
+	if err != nil {
+		return "", false
+	}
+	if val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return "", false
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	impls, ok := r.ifaces[ityp]
+	if !ok {
+		return "", false
+	}
+	name, ok := impls.byType[val.Type()]
+	return name, ok
+}
+
+// New returns a new zero value (settable) of the implementation registered with name in interface
+// type iface (a reflect.Type or a pointer to the interface).
+func (r *TypeRegistry) New(iface interface{}, name string) (reflect.Value, error) {
+	ityp, err := interfaceType(iface)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if impls, ok := r.ifaces[ityp]; ok {
+		if typ, ok := impls.byName[name]; ok {
+			return reflect.New(typ).Elem(), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("implementation %s of %s not registered", name, ityp)
+}
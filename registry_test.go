@@ -0,0 +1,110 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type (
+	shape interface {
+		Area() float64
+	}
+
+	circle struct {
+		R float64
+	}
+
+	square struct {
+		Side float64
+	}
+
+	drawing struct {
+		Shapes []shape
+	}
+
+	typedTokenSource struct {
+		*tokenSource
+	}
+)
+
+func (c circle) Area() float64 { return 3 * c.R * c.R }
+
+func (s *square) Area() float64 { return s.Side * s.Side }
+
+func (s typedTokenSource) NextTypeName(node *NodeInfo) (string, error) {
+	t, err := s.next(node)
+	if err != nil {
+		return "", err
+	}
+	return t.(string), nil
+}
+
+func TestTypeRegistry(t *testing.T) {
+	reg := NewTypeRegistry()
+	if err := reg.Register((*shape)(nil), "circle", circle{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register((*shape)(nil), "square", &square{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.Register((*shape)(nil), "square2", square{}); err == nil {
+		t.Fatal("square does not implement shape")
+	}
+	if err := reg.Register((*shape)(nil), "circle", &square{}); err == nil {
+		t.Fatal("duplicated name should fail")
+	}
+	shapeType := reflect.TypeOf((*shape)(nil)).Elem()
+	d := drawing{Shapes: []shape{circle{R: 1}, &square{Side: 2}, nil}}
+	var names []string
+	shapes := reflect.ValueOf(d.Shapes)
+	for i := 0; i < shapes.Len(); i++ {
+		name, _ := reg.NameOf(shapeType, shapes.Index(i))
+		names = append(names, name)
+	}
+	if names[0] != "circle" || names[1] != "square" || names[2] != "" {
+		t.Fatalf("names: %v", names)
+	}
+
+	tokens := []interface{}{
+		1, 3, // drawing, Shapes
+		"circle", 1, 1.0,
+		"square", 1, 1, 2.0,
+		"",
+	}
+	newSource := func() typedTokenSource {
+		return typedTokenSource{&tokenSource{tokens: append([]interface{}(nil), tokens...)}}
+	}
+	decoded := new(drawing)
+	if err := Populate(newSource(), decoded, &TraverseConf{Types: reg}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, &d) {
+		t.Fatalf("got %+v, expecting %+v", decoded, d)
+	}
+	err := Populate(newSource(), new(drawing))
+	if err == nil || !strings.Contains(err.Error(), "needs TypedSource and TypeRegistry") {
+		t.Fatalf("populating interface without registry should fail, got %v", err)
+	}
+	err = Populate(newSource(), new(drawing), &TraverseConf{Types: NewTypeRegistry()})
+	if err == nil || !strings.Contains(err.Error(), "implementation circle of dfpt.shape not registered") {
+		t.Fatalf("populating unregistered implementation should fail, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// recordedNode is a value in the tree of a recording
+type recordedNode struct {
+	ev       ScriptEvent
+	children []*recordedNode
+}
+
+var _leafTypes = map[reflect.Kind]reflect.Type{
+	reflect.Bool:       reflect.TypeOf(false),
+	reflect.Int:        reflect.TypeOf(int(0)),
+	reflect.Int8:       reflect.TypeOf(int8(0)),
+	reflect.Int16:      reflect.TypeOf(int16(0)),
+	reflect.Int32:      reflect.TypeOf(int32(0)),
+	reflect.Int64:      reflect.TypeOf(int64(0)),
+	reflect.Uint:       reflect.TypeOf(uint(0)),
+	reflect.Uint8:      reflect.TypeOf(uint8(0)),
+	reflect.Uint16:     reflect.TypeOf(uint16(0)),
+	reflect.Uint32:     reflect.TypeOf(uint32(0)),
+	reflect.Uint64:     reflect.TypeOf(uint64(0)),
+	reflect.Uintptr:    reflect.TypeOf(uintptr(0)),
+	reflect.Float32:    reflect.TypeOf(float32(0)),
+	reflect.Float64:    reflect.TypeOf(float64(0)),
+	reflect.Complex64:  reflect.TypeOf(complex64(0)),
+	reflect.Complex128: reflect.TypeOf(complex128(0)),
+	reflect.String:     reflect.TypeOf(""),
+	reflect.Ptr:        reflect.PtrTo(_typeOfInterface),
+	reflect.Slice:      reflect.SliceOf(_typeOfInterface),
+	reflect.Map:        reflect.MapOf(_typeOfString, _typeOfInterface),
+	reflect.Interface:  _typeOfInterface,
+	reflect.Func:       reflect.TypeOf(func() {}),
+}
+
+// RecordFile writes the events of the traversal of obj to the file name (created or truncated), so
+// that they can be replayed by ReplayFile, e.g. a captured real-world payload for tests.
+func RecordFile(name string, obj interface{}, conf ...*TraverseConf) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return ExportEvents(f, obj, conf...)
+}
+
+// ReplayFile replays the events recorded by RecordFile into adapter, see Replay
+func ReplayFile(name string, adapter interface{}, conf ...*TraverseConf) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Replay(f, adapter, conf...)
+}
+
+// Replay rebuilds the value from the events written by EventExporter (see Rebuild), and traverses it
+// with adapter and conf, so that the adapter is tested without the original Go types.
+func Replay(r io.Reader, adapter interface{}, conf ...*TraverseConf) error {
+	val, err := Rebuild(r)
+	if err != nil {
+		return err
+	}
+	tr, err := NewTraveller(adapter, conf...)
+	if err != nil {
+		return err
+	}
+	return tr.traverseValue(NewContext(), val)
+}
+
+// Rebuild returns a value of the same shape as the one whose events are written by EventExporter:
+// containers, field names, kinds and values of leaves are kept, while named types are replaced by
+// unnamed ones (so ForImpl/ForAssign bindings of them don't match). Slices, arrays and maps with
+// elements of different types become ones of interface{}, referenced values (EventReference and
+// EventCycle) become nil, and nil containers become empty ones.
+func Rebuild(r io.Reader) (reflect.Value, error) {
+	var root *recordedNode
+	var stack []*recordedNode
+	err := ImportEvents(r, ScriptFunc(func(ev ScriptEvent) (ScriptResult, error) {
+		node := &recordedNode{ev: ev}
+		switch ev.Kind {
+		case EventEnd:
+			if len(stack) == 0 || stack[len(stack)-1].ev.Seq != ev.Seq {
+				return ScriptResult{}, fmt.Errorf("unexpected end of %s", ev.Path)
+			}
+			node, stack = stack[len(stack)-1], stack[:len(stack)-1]
+		case EventStart:
+			stack = append(stack, node)
+			return ScriptResult{}, nil
+		}
+		if len(stack) == 0 {
+			if root != nil {
+				return ScriptResult{}, fmt.Errorf("more than one root value: %s", ev.Path)
+			}
+			root = node
+		} else {
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, node)
+		}
+		return ScriptResult{}, nil
+	}))
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if len(stack) > 0 || root == nil {
+		return reflect.Value{}, io.ErrUnexpectedEOF
+	}
+	return root.value()
+}
+
+func (n *recordedNode) kind() reflect.Kind {
+	return _kindMap[n.ev.ValueKind]
+}
+
+func (n *recordedNode) value() (reflect.Value, error) {
+	if n.ev.Kind != EventStart {
+		return n.leaf()
+	}
+	values := make([]reflect.Value, len(n.children))
+	for i, child := range n.children {
+		v, err := child.value()
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		values[i] = v
+	}
+	switch n.kind() {
+	case reflect.Ptr:
+		if len(values) == 0 {
+			return reflect.Zero(_leafTypes[reflect.Ptr]), nil
+		}
+		ptr := reflect.New(values[0].Type())
+		ptr.Elem().Set(values[0])
+		return ptr, nil
+	case reflect.Struct:
+		fields := make([]reflect.StructField, len(values))
+		for i, child := range n.children {
+			if !isExportedName(child.ev.Name) {
+				return reflect.Value{}, fmt.Errorf("field name %q of %s can't be rebuilt", child.ev.Name, n.ev.Path)
+			}
+			fields[i] = reflect.StructField{Name: child.ev.Name, Type: values[i].Type()}
+		}
+		val := reflect.New(reflect.StructOf(fields)).Elem()
+		for i, v := range values {
+			val.Field(i).Set(v)
+		}
+		return val, nil
+	case reflect.Slice, reflect.Array:
+		elem := commonType(values)
+		var val reflect.Value
+		if n.kind() == reflect.Slice {
+			val = reflect.MakeSlice(reflect.SliceOf(elem), len(values), len(values))
+		} else {
+			val = reflect.New(reflect.ArrayOf(len(values), elem)).Elem()
+		}
+		for i, v := range values {
+			val.Index(i).Set(v)
+		}
+		return val, nil
+	case reflect.Map:
+		if len(values)%2 != 0 {
+			return reflect.Value{}, fmt.Errorf("odd number of keys and values of map %s", n.ev.Path)
+		}
+		var keys, elems []reflect.Value
+		for i := 0; i < len(values); i += 2 {
+			keys = append(keys, values[i])
+			elems = append(elems, values[i+1])
+		}
+		keyType := commonType(keys)
+		if len(keys) == 0 {
+			keyType = _typeOfString
+		}
+		if !keyType.Comparable() {
+			return reflect.Value{}, fmt.Errorf("keys of map %s are not comparable", n.ev.Path)
+		}
+		val := reflect.MakeMapWithSize(reflect.MapOf(keyType, commonType(elems)), len(keys))
+		for i := range keys {
+			val.SetMapIndex(keys[i], elems[i])
+		}
+		return val, nil
+	default:
+		return reflect.Value{}, fmt.Errorf("container %s of kind %q can't be rebuilt", n.ev.Path, n.ev.ValueKind)
+	}
+}
+
+// leaf returns the value of a leaf, or the nil value of a reference
+func (n *recordedNode) leaf() (reflect.Value, error) {
+	kind := n.kind()
+	if n.ev.Value == nil {
+		if typ, ok := _leafTypes[kind]; ok {
+			return reflect.Zero(typ), nil
+		}
+		return reflect.Value{}, fmt.Errorf("leaf %s of kind %q can't be rebuilt", n.ev.Path, n.ev.ValueKind)
+	}
+	val := reflect.ValueOf(n.ev.Value)
+	if kind == reflect.Interface {
+		iface := reflect.New(_typeOfInterface).Elem()
+		iface.Set(val)
+		return iface, nil
+	}
+	typ, ok := _leafTypes[kind]
+	if !ok || !val.Type().ConvertibleTo(typ) {
+		return reflect.Value{}, fmt.Errorf("leaf %s of kind %q can't be rebuilt from %T", n.ev.Path, n.ev.ValueKind, n.ev.Value)
+	}
+	return val.Convert(typ), nil
+}
+
+// commonType returns the type of all values, or interface{} if they are of different types
+func commonType(values []reflect.Value) reflect.Type {
+	if len(values) == 0 {
+		return _typeOfInterface
+	}
+	typ := values[0].Type()
+	for _, v := range values[1:] {
+		if v.Type() != typ {
+			return _typeOfInterface
+		}
+	}
+	return typ
+}
+
+func isExportedName(name string) bool {
+	r, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(r)
+}
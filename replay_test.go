@@ -0,0 +1,115 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// kindLogger logs the values by their kinds, which are kept in the recordings
+type kindLogger struct {
+	logs *[]string
+}
+
+func (l kindLogger) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*l.logs = append(*l.logs, fmt.Sprintf("%s:%s=%v", node.Path, val.Kind(), val.Interface()))
+	return nil
+}
+
+func (l kindLogger) ForNilPtr(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	*l.logs = append(*l.logs, node.Path.String()+":nil")
+	return nil
+}
+
+func (l kindLogger) container(node *NodeInfo, start bool, val reflect.Value) (bool, error) {
+	if start {
+		*l.logs = append(*l.logs, fmt.Sprintf("%s:%s(%d)", node.Path, val.Kind(), node.Size))
+	}
+	return true, nil
+}
+
+func (l kindLogger) ForContainerArray(_ *TravContext, node *NodeInfo, start bool, val reflect.Value) (bool, error) {
+	return l.container(node, start, val)
+}
+
+func (l kindLogger) ForContainerMap(_ *TravContext, node *NodeInfo, start bool, val reflect.Value) (bool, error) {
+	return l.container(node, start, val)
+}
+
+func (l kindLogger) ForContainerPtr(_ *TravContext, node *NodeInfo, start bool, val reflect.Value) (bool, error) {
+	return l.container(node, start, val)
+}
+
+func (l kindLogger) ForContainerSlice(_ *TravContext, node *NodeInfo, start bool, val reflect.Value) (bool, error) {
+	return l.container(node, start, val)
+}
+
+func (l kindLogger) ForContainerStruct(_ *TravContext, node *NodeInfo, start bool, val reflect.Value) (bool, error) {
+	return l.container(node, start, val)
+}
+
+func TestRecordReplay(t *testing.T) {
+	type address struct {
+		City string
+		Zip  uint16
+	}
+	type user struct {
+		Name    string
+		Age     int8
+		Score   float32
+		Addr    *address
+		Prev    *address
+		Tags    []string
+		Codes   [2]int64
+		Attrs   map[string]int
+		Any     interface{}
+		Enabled bool
+	}
+	obj := &user{Name: "u", Age: 30, Score: 1.5, Addr: &address{City: "c", Zip: 100}, Tags: []string{"a", "b"},
+		Codes: [2]int64{1, 2}, Attrs: map[string]int{"x": 1, "y": 2}, Any: "any", Enabled: true}
+	conf := &TraverseConf{SortMapKeys: true}
+
+	var want []string
+	tr, err := NewTraveller(kindLogger{logs: &want}, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "user.jsonl")
+	if err = RecordFile(file, obj, conf); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	if err = ReplayFile(file, kindLogger{logs: &got}, conf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("replayed:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err = Rebuild(strings.NewReader(`{"kind":"Start","seq":0,"valueKind":"Struct"}`)); err == nil {
+		t.Fatal("expecting error of unfinished recording")
+	}
+}
@@ -0,0 +1,295 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ReportFormat is the output format of WriteReport
+type ReportFormat int
+
+const (
+	// ReportHTML writes nested tables, each container is a collapsible <details> section
+	ReportHTML ReportFormat = iota
+	// ReportMarkdown writes a table of all values with their paths
+	ReportMarkdown
+)
+
+// reporter writes a report row by row during traversal, pointers are transparent: the pointed value
+// is reported in place of the pointer.
+type reporter struct {
+	w      *bufio.Writer
+	format ReportFormat
+	// if > 0, the remaining values are elided once budget bytes are written
+	budget  int
+	counter *countingWriter
+	frames  []*reportFrame
+}
+
+// reportFrame is a container (not pointer) being reported
+type reportFrame struct {
+	kind   reflect.Kind
+	size   int
+	elided bool
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
+
+// exhausted returns whether the budget is used up
+func (r *reporter) exhausted() bool {
+	return r.budget > 0 && r.counter.n+r.w.Buffered() >= r.budget
+}
+
+// elide returns ErrSkipContainer if the budget is used up, so that the value of node and its
+// remaining siblings are skipped, a marker with the number of them is written for the first one.
+func (r *reporter) elide(node *NodeInfo) error {
+	if len(r.frames) == 0 || node.Parent.Kind() == reflect.Ptr || !r.exhausted() {
+		return nil
+	}
+	top := r.frames[len(r.frames)-1]
+	if top.elided {
+		return ErrSkipContainer
+	}
+	top.elided = true
+	var summary string
+	switch top.kind {
+	case reflect.Map:
+		summary = fmt.Sprintf("%d more entries elided", (top.size-node.Index+1)/2)
+	case reflect.Struct:
+		summary = fmt.Sprintf("%d more fields elided", top.size-node.Index)
+	default:
+		summary = fmt.Sprintf("%d more items elided", top.size-node.Index)
+	}
+	if r.format == ReportHTML {
+		fmt.Fprintf(r.w, "<tr><td>…</td><td></td><td>%s</td></tr>\n", summary)
+	} else {
+		fmt.Fprintf(r.w, "| … | | %s |\n", summary)
+	}
+	return ErrSkipContainer
+}
+
+// pathLabel returns the name of the value at path in its parent (pointers are skipped), empty for
+// the root
+func pathLabel(path Path) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if s := strings.TrimPrefix(path[i].String(), "."); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func (r *reporter) path(node *NodeInfo) string {
+	if p := node.Path.String(); p != "" {
+		return p
+	}
+	return "(root)"
+}
+
+func (r *reporter) typeOf(val reflect.Value) string {
+	if val.Kind() == reflect.Interface && !val.IsNil() {
+		return val.Type().String() + "(" + val.Elem().Type().String() + ")"
+	}
+	return val.Type().String()
+}
+
+func (r *reporter) escape(s string) string {
+	// Markdown tables are rendered as HTML, raw tags in values must not reach the output either
+	s = html.EscapeString(s)
+	if r.format == ReportHTML {
+		return s
+	}
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", "<br>", -1)
+}
+
+func (r *reporter) header() {
+	if r.format == ReportHTML {
+		r.w.WriteString("<table>\n<tr><th>Name</th><th>Type</th><th>Value</th></tr>\n")
+	} else {
+		r.w.WriteString("| Path | Type | Value |\n| --- | --- | --- |\n")
+	}
+}
+
+func (r *reporter) row(node *NodeInfo, typ, value string) error {
+	root := pathLabel(node.Path) == ""
+	if r.format == ReportHTML {
+		if root {
+			r.header()
+		}
+		fmt.Fprintf(r.w, "<tr><td>%s</td><td><code>%s</code></td><td>%s</td></tr>\n",
+			r.escape(pathLabel(node.Path)), r.escape(typ), r.escape(value))
+		if root {
+			r.w.WriteString("</table>\n")
+		}
+		return nil
+	}
+	if root {
+		r.header()
+	}
+	// code spans are not rendered as HTML, only '|' of the table is escaped in the type
+	fmt.Fprintf(r.w, "| %s | `%s` | %s |\n", r.escape(r.path(node)), strings.Replace(typ, "|", `\|`, -1),
+		r.escape(value))
+	return nil
+}
+
+func (r *reporter) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if err := r.elide(node); err != nil {
+		return err
+	}
+	if isMapKey(node.Path) {
+		return nil
+	}
+	return r.row(node, val.Type().String(), "nil")
+}
+
+func (r *reporter) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if err := r.elide(node); err != nil {
+		return err
+	}
+	if isMapKey(node.Path) {
+		return nil
+	}
+	var value string
+	switch {
+	case val.Kind() == reflect.String:
+		value = strconv.Quote(val.String())
+	case val.CanInterface():
+		value = fmt.Sprintf("%v", val.Interface())
+	default:
+		value = val.String()
+	}
+	return r.row(node, r.typeOf(val), value)
+}
+
+func (r *reporter) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	if err := r.elide(node); err != nil {
+		return err
+	}
+	return r.row(node, val.Type().String(), "cycle to "+r.path(ancestor))
+}
+
+// section starts or ends the section of a container
+func (r *reporter) section(node *NodeInfo, startOrEnd bool, val reflect.Value, summary string) (bool, error) {
+	if startOrEnd {
+		if err := r.elide(node); err != nil {
+			return false, err
+		}
+	}
+	if isMapKey(node.Path) {
+		return false, nil
+	}
+	if startOrEnd {
+		r.frames = append(r.frames, &reportFrame{kind: val.Kind(), size: node.Size})
+	} else {
+		r.frames = r.frames[:len(r.frames)-1]
+	}
+	root := pathLabel(node.Path) == ""
+	if r.format == ReportMarkdown {
+		if startOrEnd {
+			return true, r.row(node, val.Type().String(), summary)
+		}
+		return false, nil
+	}
+	if !startOrEnd {
+		r.w.WriteString("</table>\n</details>")
+		if !root {
+			r.w.WriteString("</td></tr>")
+		}
+		r.w.WriteString("\n")
+		return false, nil
+	}
+	if root {
+		fmt.Fprintf(r.w, "<details open><summary><code>%s</code> %s</summary>\n", r.escape(val.Type().String()), summary)
+	} else {
+		fmt.Fprintf(r.w, "<tr><td>%s</td><td><code>%s</code></td><td><details><summary>%s</summary>\n",
+			r.escape(pathLabel(node.Path)), r.escape(val.Type().String()), summary)
+	}
+	r.header()
+	return true, nil
+}
+
+func (r *reporter) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return r.section(node, startOrEnd, val, fmt.Sprintf("%d items", val.Len()))
+}
+
+func (r *reporter) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return r.section(node, startOrEnd, val, fmt.Sprintf("%d entries", val.Len()))
+}
+
+func (r *reporter) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	if startOrEnd {
+		if err := r.elide(node); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (r *reporter) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return r.section(node, startOrEnd, val, fmt.Sprintf("%d items", val.Len()))
+}
+
+func (r *reporter) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return r.section(node, startOrEnd, val, fmt.Sprintf("%d fields", node.Size))
+}
+
+// WriteReport writes a human-readable report of obj to w in format, listing the name (or path), type
+// and value of each value. Cycles are reported instead of being traversed.
+func WriteReport(w io.Writer, obj interface{}, format ReportFormat, conf ...*TraverseConf) error {
+	return WriteReportBudget(w, obj, format, 0, conf...)
+}
+
+// WriteReportBudget writes the report like WriteReport, but once budget bytes are written (0 for
+// unlimited), the remaining values of each container being reported are elided with a marker of
+// their number, e.g. "12 more items elided", and the open containers are still closed. So the report
+// may exceed the budget by the markers and the closings.
+func WriteReportBudget(w io.Writer, obj interface{}, format ReportFormat, budget int, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	counter := &countingWriter{w: w}
+	r := &reporter{w: bufio.NewWriter(counter), format: format, budget: budget, counter: counter}
+	tr, err := NewTraveller(r, c)
+	if err != nil {
+		return err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
@@ -0,0 +1,120 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type reportObj struct {
+	Name  string
+	Tags  map[string]int
+	Items []int
+	Loop  *listNode
+	Empty *int
+}
+
+func newReportObj() *reportObj {
+	loop := &listNode{V: 1}
+	loop.Next = loop
+	return &reportObj{Name: "a|b", Tags: map[string]int{"x": 1}, Items: []int{7}, Loop: loop}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, newReportObj(), ReportMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	want := "| Path | Type | Value |\n| --- | --- | --- |\n" +
+		"| (root) | `dfpt.reportObj` | 5 fields |\n" +
+		"| Name | `string` | &#34;a\\|b&#34; |\n" +
+		"| Tags | `map[string]int` | 1 entries |\n" +
+		"| Tags[x] | `int` | 1 |\n" +
+		"| Items | `[]int` | 1 items |\n" +
+		"| Items[0] | `int` | 7 |\n" +
+		"| Loop | `dfpt.listNode` | 2 fields |\n" +
+		"| Loop.V | `int` | 1 |\n" +
+		"| Loop.Next | `*dfpt.listNode` | cycle to Loop |\n" +
+		"| Empty | `*int` | nil |\n"
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestReportHostile(t *testing.T) {
+	obj := map[string]string{"<b>k</b>": "<img src=x onerror=alert(1)>"}
+	for _, format := range []ReportFormat{ReportMarkdown, ReportHTML} {
+		var buf bytes.Buffer
+		if err := WriteReport(&buf, obj, format); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		if strings.Contains(out, "<img") || strings.Contains(out, "<b>") ||
+			!strings.Contains(out, "&lt;img src=x onerror=alert(1)&gt;") || !strings.Contains(out, "&lt;b&gt;k&lt;/b&gt;") {
+			t.Fatalf("unescaped report:\n%s", out)
+		}
+	}
+}
+
+func TestReportHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, newReportObj(), ReportHTML); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	t.Log("\n" + out)
+	if !strings.HasPrefix(out, "<details open><summary><code>dfpt.reportObj</code> 5 fields</summary>\n") ||
+		strings.Count(out, "<details") != strings.Count(out, "</details>") ||
+		strings.Count(out, "<table>") != strings.Count(out, "</table>") ||
+		!strings.Contains(out, "<tr><td>Name</td><td><code>string</code></td><td>&#34;a|b&#34;</td></tr>") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+}
+
+func TestReportBudget(t *testing.T) {
+	obj := &reportObj{Name: "a", Tags: map[string]int{"x": 1, "y": 2}, Items: []int{1, 2, 3, 4, 5}}
+	var full bytes.Buffer
+	if err := WriteReportBudget(&full, obj, ReportMarkdown, 0); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(full.String(), "\n")
+	// budget used up in the middle of Items
+	budget := len(strings.Join(lines[:9], ""))
+	var buf bytes.Buffer
+	if err := WriteReportBudget(&buf, obj, ReportMarkdown, budget); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join(lines[:9], "") +
+		"| … | | 4 more items elided |\n" +
+		"| … | | 2 more fields elided |\n"
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := WriteReportBudget(&buf, obj, ReportHTML, 200); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "more") || strings.Count(out, "<details") != strings.Count(out, "</details>") ||
+		strings.Count(out, "<table>") != strings.Count(out, "</table>") {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+}
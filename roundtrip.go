@@ -0,0 +1,190 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+)
+
+type (
+	// RoundTripper checks whether a pair of encoder and decoder can restore random generated values.
+	RoundTripper struct {
+		Encode func(obj interface{}) ([]byte, error)
+		Decode func(data []byte, obj interface{}) error
+		// Conf used to find struct properties when generating and comparing values, the same
+		// configuration (Propertier) as the codec should be used.
+		Conf     *TraverseConf
+		Rand     *rand.Rand // random source, seeded with 1 if nil
+		MaxLen   int        // max length of generated strings, slices and maps, 4 if not positive
+		MaxDepth int        // containers deeper than MaxDepth are left zero, 8 if not positive
+	}
+
+	// RoundTripError reports the first divergent leaf between the generated value and the decoded one
+	RoundTripError struct {
+		Round    int
+		Path     string
+		Original interface{}
+		Decoded  interface{}
+		Data     []byte
+	}
+)
+
+func (e *RoundTripError) Error() string {
+	return fmt.Sprintf("round %d diverged at %q: original:%v decoded:%v", e.Round, e.Path, e.Original, e.Decoded)
+}
+
+// Check generates rounds random values of the type pointed by sample, encodes and decodes each of
+// them, and compares the decoded value with the original one leaf by leaf. Returns *RoundTripError if
+// any divergence found.
+func (r *RoundTripper) Check(sample interface{}, rounds int) error {
+	if r.Encode == nil || r.Decode == nil {
+		return errors.New("round trip needs both encoder and decoder")
+	}
+	typ := reflect.TypeOf(sample)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		return errors.New("round trip needs a pointer sample")
+	}
+	rng := r.Rand
+	if rng == nil {
+		rng = rand.New(rand.NewSource(1))
+	}
+	for round := 0; round < rounds; round++ {
+		original := reflect.New(typ.Elem())
+		r.fill(rng, original.Elem(), 0)
+		data, err := r.Encode(original.Interface())
+		if err != nil {
+			return fmt.Errorf("round %d encode failed: %w", round, err)
+		}
+		decoded := reflect.New(typ.Elem())
+		if err = r.Decode(data, decoded.Interface()); err != nil {
+			return fmt.Errorf("round %d decode failed: %w", round, err)
+		}
+		if err = r.compare(round, original.Interface(), decoded.Interface(), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RoundTripper) maxLen() int {
+	if r.MaxLen <= 0 {
+		return 4
+	}
+	return r.MaxLen
+}
+
+func (r *RoundTripper) maxDepth() int {
+	if r.MaxDepth <= 0 {
+		return 8
+	}
+	return r.MaxDepth
+}
+
+func (r *RoundTripper) fill(rng *rand.Rand, val reflect.Value, depth int) {
+	switch val.Kind() {
+	case reflect.Bool:
+		val.SetBool(rng.Intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val.SetInt(int64(rng.Uint64()) >> (64 - uint(val.Type().Bits())))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		val.SetUint(rng.Uint64() >> (64 - uint(val.Type().Bits())))
+	case reflect.Float32, reflect.Float64:
+		val.SetFloat(float64(float32(rng.NormFloat64() * 1e6)))
+	case reflect.Complex64, reflect.Complex128:
+		val.SetComplex(complex(float64(float32(rng.NormFloat64())), float64(float32(rng.NormFloat64()))))
+	case reflect.String:
+		bs := make([]rune, rng.Intn(r.maxLen()+1))
+		for i := range bs {
+			bs[i] = rune(0x20 + rng.Intn(0x3000))
+		}
+		val.SetString(string(bs))
+	case reflect.Array:
+		if depth < r.maxDepth() {
+			for i := 0; i < val.Len(); i++ {
+				r.fill(rng, val.Index(i), depth+1)
+			}
+		}
+	case reflect.Slice:
+		if depth < r.maxDepth() {
+			n := rng.Intn(r.maxLen() + 1)
+			val.Set(reflect.MakeSlice(val.Type(), n, n))
+			for i := 0; i < n; i++ {
+				r.fill(rng, val.Index(i), depth+1)
+			}
+		}
+	case reflect.Map:
+		if depth < r.maxDepth() {
+			n := rng.Intn(r.maxLen() + 1)
+			m := reflect.MakeMapWithSize(val.Type(), n)
+			for i := 0; i < n; i++ {
+				k := reflect.New(val.Type().Key()).Elem()
+				r.fill(rng, k, depth+1)
+				v := reflect.New(val.Type().Elem()).Elem()
+				r.fill(rng, v, depth+1)
+				m.SetMapIndex(k, v)
+			}
+			val.Set(m)
+		}
+	case reflect.Ptr:
+		if depth < r.maxDepth() && rng.Intn(4) > 0 {
+			elem := reflect.New(val.Type().Elem())
+			r.fill(rng, elem.Elem(), depth+1)
+			val.Set(elem)
+		}
+	case reflect.Struct:
+		if depth < r.maxDepth() {
+			_, fields := structProperties(r.Conf, val)
+			for _, field := range fields {
+				if field.Index >= 0 {
+					r.fill(rng, val.Field(field.Index), depth+1)
+				}
+			}
+		}
+	}
+}
+
+func (r *RoundTripper) compare(round int, original, decoded interface{}, data []byte) error {
+	ops, err := Flatten(original, r.Conf)
+	if err != nil {
+		return err
+	}
+	dps, err := Flatten(decoded, r.Conf)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(ops) || i < len(dps); i++ {
+		if i < len(ops) && i < len(dps) && ops[i].Path == dps[i].Path &&
+			reflect.DeepEqual(ops[i].Value, dps[i].Value) {
+			continue
+		}
+		e := &RoundTripError{Round: round, Data: data}
+		if i < len(ops) {
+			e.Path, e.Original = ops[i].Path, ops[i].Value
+		} else {
+			e.Path = dps[i].Path
+		}
+		if i < len(dps) {
+			e.Decoded = dps[i].Value
+		}
+		return e
+	}
+	return nil
+}
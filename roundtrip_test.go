@@ -0,0 +1,58 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRoundTripBinary(t *testing.T) {
+	rt := &RoundTripper{
+		Encode: func(obj interface{}) ([]byte, error) {
+			buf := new(bytes.Buffer)
+			err := EncodeBinary(buf, obj)
+			return buf.Bytes(), err
+		},
+		Decode: func(data []byte, obj interface{}) error {
+			return DecodeBinary(bytes.NewReader(data), obj)
+		},
+		Conf: &TraverseConf{Propertier: SlotPropertier{}},
+	}
+	if err := rt.Check(new(slotObj), 200); err != nil {
+		t.Fatal(err)
+	}
+
+	// a broken decoder must be caught with the divergent path
+	rt.Decode = func(data []byte, obj interface{}) error {
+		if err := DecodeBinary(bytes.NewReader(data), obj); err != nil {
+			return err
+		}
+		obj.(*slotObj).Inner.X++
+		return nil
+	}
+	err := rt.Check(new(slotObj), 10)
+	rterr, ok := err.(*RoundTripError)
+	if !ok {
+		t.Fatalf("expecting *RoundTripError, got %v", err)
+	}
+	if rterr.Path != "Inner.X" {
+		t.Fatalf("expecting divergence at Inner.X, got %v", rterr)
+	}
+	t.Log(rterr)
+}
@@ -0,0 +1,268 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type (
+	// SandboxPolicy is the containment of the bindings of an untrusted adapter
+	SandboxPolicy struct {
+		// max duration of each binding call, 0 for unlimited. The traversal goes on (or fails)
+		// without waiting for a binding exceeding the limit, which keeps running in its goroutine
+		// since it can't be killed.
+		Timeout time.Duration
+		// if false, write-back bindings are not allowed to replace values, and reflect.Value
+		// properties are passed as copies. Values referenced by pointers, maps and slices are still
+		// reachable by the bindings.
+		AllowMutation bool
+	}
+
+	// ViolationKind is the kind of violation of a sandboxed binding
+	ViolationKind int
+
+	// Violation is a breach of the SandboxPolicy by a binding, it's returned as the error of the
+	// binding, so that it stops the traversal, or is recorded as a Diagnostic in BestEffort mode.
+	Violation struct {
+		Path    Path // path of the value, nil if unknown
+		Binding string
+		Kind    ViolationKind
+		Detail  string
+		sandbox *Sandbox
+	}
+
+	// Sandbox wraps the bindings of an adapter (from plugins for example) with a SandboxPolicy:
+	// panics are turned into errors, calls are limited in time, and mutations are rejected unless
+	// granted. The Sandbox is accepted by NewTraveller as the adapter, and records the violations
+	// of all traversals with it.
+	//
+	//	sb, err := NewSandbox(pluginAdapter, &SandboxPolicy{Timeout: time.Second})
+	//	traveller, err := NewTraveller(sb, &TraverseConf{BestEffort: true})
+	//	err = traveller.Traverse(nil, obj)
+	//	for _, v := range sb.Violations() { ... }
+	Sandbox struct {
+		adapter    interface{}
+		policy     SandboxPolicy
+		methods    []adapterMethod
+		lock       sync.Mutex
+		violations []*Violation
+	}
+)
+
+const (
+	ViolationPanic    ViolationKind = iota // the binding panicked
+	ViolationTimeout                       // the binding ran out of SandboxPolicy.Timeout
+	ViolationMutation                      // the binding replaced a value without SandboxPolicy.AllowMutation
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationPanic:
+		return "panic"
+	case ViolationTimeout:
+		return "timeout"
+	case ViolationMutation:
+		return "mutation"
+	default:
+		return fmt.Sprintf("ViolationKind(%d)", int(k))
+	}
+}
+
+func (v *Violation) Error() string {
+	if v.Path == nil {
+		return fmt.Sprintf("sandbox: %s of %s: %s", v.Kind, v.Binding, v.Detail)
+	}
+	return fmt.Sprintf("sandbox: %s of %s at %s: %s", v.Kind, v.Binding, v.Path, v.Detail)
+}
+
+// NewSandbox wraps the bindings of adapter (an adapter object or an AdapterBuilder) with policy,
+// the default policy has no time limit and forbids mutations.
+func NewSandbox(adapter interface{}, policy ...*SandboxPolicy) (*Sandbox, error) {
+	aptVal := reflect.ValueOf(adapter)
+	if !aptVal.IsValid() {
+		return nil, ErrInvalidAdapter
+	}
+	methods, err := adapterMethods(aptVal)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sandbox{adapter: adapter}
+	if len(policy) > 0 && policy[0] != nil {
+		s.policy = *policy[0]
+	}
+	s.methods = make([]adapterMethod, 0, len(methods))
+	for _, m := range methods {
+		if _, _, ok := Unknown.Which(m.Name); !ok {
+			continue
+		}
+		m.fn = s.wrap(m.Name, m.fn)
+		m.Index = len(s.methods)
+		s.methods = append(s.methods, m)
+	}
+	return s, nil
+}
+
+// Order forwards the orders of the bindings if the wrapped adapter is a BindingOrderer
+func (s *Sandbox) Order() map[string]int {
+	if orderer, ok := s.adapter.(BindingOrderer); ok {
+		return orderer.Order()
+	}
+	return nil
+}
+
+// Violations returns the violations recorded so far
+func (s *Sandbox) Violations() []Violation {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ret := make([]Violation, len(s.violations))
+	for i, v := range s.violations {
+		ret[i] = *v
+		ret[i].sandbox = nil
+	}
+	return ret
+}
+
+// Reset clears the recorded violations
+func (s *Sandbox) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.violations = nil
+}
+
+func (s *Sandbox) violate(name string, kind ViolationKind, ins []reflect.Value, detail string) *Violation {
+	v := &Violation{Binding: name, Kind: kind, Detail: detail, sandbox: s}
+	for _, in := range ins {
+		if in.Type() == _typeOfNodeInfoPtr && !in.IsNil() {
+			v.Path = in.Interface().(*NodeInfo).Path
+			break
+		}
+	}
+	s.lock.Lock()
+	s.violations = append(s.violations, v)
+	s.lock.Unlock()
+	return v
+}
+
+// locate sets the path of the violation if it's unknown by the binding (v1 signatures)
+func (s *Sandbox) locate(v *Violation, path Path) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if v.Path == nil {
+		v.Path = path
+	}
+}
+
+// wrap returns the binding function fn called under the policy
+func (s *Sandbox) wrap(name string, fn reflect.Value) reflect.Value {
+	fType := fn.Type()
+	writeBack := fType.NumOut() == 3
+	fail := func(v *Violation) []reflect.Value {
+		outs := make([]reflect.Value, fType.NumOut())
+		for i := range outs {
+			outs[i] = reflect.Zero(fType.Out(i))
+		}
+		outs[len(outs)-1] = reflect.ValueOf(error(v)).Convert(fType.Out(len(outs) - 1))
+		return outs
+	}
+	return reflect.MakeFunc(fType, func(ins []reflect.Value) []reflect.Value {
+		if !s.policy.AllowMutation {
+			ins = readOnly(ins)
+		}
+		outs, v := s.call(name, fn, ins)
+		if v != nil {
+			return fail(v)
+		}
+		if writeBack && outs[1].Bool() && !s.policy.AllowMutation {
+			return fail(s.violate(name, ViolationMutation, ins, "replacing value is not allowed"))
+		}
+		return outs
+	})
+}
+
+// call calls fn with ins, panics and timeout are turned into violations
+func (s *Sandbox) call(name string, fn reflect.Value, ins []reflect.Value) ([]reflect.Value, *Violation) {
+	type result struct {
+		outs  []reflect.Value
+		panic interface{}
+	}
+	invoke := func() (r result) {
+		defer func() {
+			if p := recover(); p != nil {
+				r.panic = p
+			}
+		}()
+		return result{outs: fn.Call(ins)}
+	}
+	var r result
+	if s.policy.Timeout <= 0 {
+		r = invoke()
+	} else {
+		done := make(chan result, 1)
+		go func() {
+			done <- invoke()
+		}()
+		timer := time.NewTimer(s.policy.Timeout)
+		select {
+		case r = <-done:
+			timer.Stop()
+		case <-timer.C:
+			return nil, s.violate(name, ViolationTimeout, ins, fmt.Sprintf("not returned in %s", s.policy.Timeout))
+		}
+	}
+	if r.panic != nil {
+		return nil, s.violate(name, ViolationPanic, ins, fmt.Sprint(r.panic))
+	}
+	return r.outs, nil
+}
+
+// readOnly replaces the settable reflect.Value arguments with their copies
+func readOnly(ins []reflect.Value) []reflect.Value {
+	var ret []reflect.Value
+	for i, in := range ins {
+		if in.Type() != _typeOfValue {
+			continue
+		}
+		val := in.Interface().(reflect.Value)
+		if !val.IsValid() || !val.CanSet() {
+			continue
+		}
+		if ret == nil {
+			ret = append([]reflect.Value(nil), ins...)
+		}
+		cp := reflect.New(val.Type()).Elem()
+		cp.Set(val)
+		ret[i] = reflect.ValueOf(cp)
+	}
+	if ret == nil {
+		return ins
+	}
+	return ret
+}
+
+// locateViolation sets the path of the child of parent to the sandbox violation in err
+func locateViolation(err error, parent *parentInfo) {
+	var v *Violation
+	if err != nil && errors.As(err, &v) && v.sandbox != nil {
+		v.sandbox.locate(v, parent.childPath())
+	}
+}
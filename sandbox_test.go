@@ -0,0 +1,117 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tenantPlugin struct{}
+
+func (tenantPlugin) ForKindString(_ *TravContext, _ *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	s := strings.TrimSpace(val.String())
+	return s, s != val.String(), nil
+}
+
+func (tenantPlugin) ForKindInt(_ *TravContext, _, _ int, _ string, val int) error {
+	if val < 0 {
+		panic("negative")
+	}
+	return nil
+}
+
+func (tenantPlugin) ForKindBool(_ *TravContext, _ *NodeInfo, val reflect.Value) error {
+	if val.CanSet() {
+		val.SetBool(true)
+	}
+	return nil
+}
+
+func (tenantPlugin) ForKindFloat64(*TravContext, *NodeInfo, reflect.Value) error {
+	time.Sleep(50 * time.Millisecond)
+	return nil
+}
+
+func (tenantPlugin) ForContainerPtr(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (tenantPlugin) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestSandbox(t *testing.T) {
+	type record struct {
+		Name string
+		N    int
+		B    bool
+		F    float64
+	}
+	sb, err := NewSandbox(tenantPlugin{}, &SandboxPolicy{Timeout: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := NewTraveller(sb, &TraverseConf{BestEffort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &record{Name: " a ", N: -1, F: 1}
+	err = tr.Traverse(nil, rec)
+	var diags Diagnostics
+	if !errors.As(err, &diags) || len(diags) != 3 {
+		t.Fatalf("got %v", err)
+	}
+	if rec.Name != " a " || rec.B {
+		t.Fatalf("mutated: %+v", rec)
+	}
+	var got []string
+	for _, v := range sb.Violations() {
+		got = append(got, fmt.Sprintf("%s:%s:%s", v.Path, v.Kind, v.Binding))
+	}
+	if fmt.Sprint(got) != "[Name:mutation:ForKindString N:panic:ForKindInt F:timeout:ForKindFloat64]" {
+		t.Fatalf("got %v", got)
+	}
+
+	if sb.Reset(); len(sb.Violations()) != 0 {
+		t.Fatal("violations not cleared")
+	}
+	if sb, err = NewSandbox(tenantPlugin{}, &SandboxPolicy{AllowMutation: true}); err != nil {
+		t.Fatal(err)
+	}
+	if tr, err = NewTraveller(sb); err != nil {
+		t.Fatal(err)
+	}
+	rec = &record{Name: " a ", F: 1}
+	if err = tr.Traverse(nil, rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Name != "a" || !rec.B || len(sb.Violations()) != 0 {
+		t.Fatalf("got %+v, %v", rec, sb.Violations())
+	}
+	rec.N = -2
+	err = tr.Traverse(nil, rec)
+	var v *Violation
+	if !errors.As(err, &v) || v.Kind != ViolationPanic || v.Path.String() != "N" || v.Detail != "negative" {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type (
+	// ScriptEvent is an Event in plain data, to be passed across the boundary of a scripting runtime
+	// (JavaScript, Lua, WASM ...) which can't hold Go values.
+	ScriptEvent struct {
+		Kind  EventKind `json:"kind"`
+		Seq   int       `json:"seq"` // see NodeInfo.Seq
+		Path  string    `json:"path"`
+		Name  string    `json:"name,omitempty"`
+		Index int       `json:"index"`
+		Depth int       `json:"depth"`
+		Type  string    `json:"type,omitempty"` // Go type of the value
+		// kind of the value in the names of ForKindXxxx/ForContainerXxxx bindings, e.g. "String", "Struct"
+		ValueKind string `json:"valueKind,omitempty"`
+		// the value of leaves (or of the dynamic values of interface leaves): nil, bool, int64, uint64,
+		// float64, complex128 or string, nil for the others
+		Value  interface{} `json:"value,omitempty"`
+		Size   int         `json:"size,omitempty"`   // size of containers, see NodeInfo.Size
+		Target string      `json:"target,omitempty"` // path of the value referenced, for EventReference and EventCycle
+	}
+
+	// ScriptResult is the reply of a scripting runtime to a ScriptEvent
+	ScriptResult struct {
+		// replace the leaf value with Value, which is converted to the type of the leaf, e.g. float64
+		// numbers of JavaScript to int
+		Replace bool
+		Value   interface{}
+		Skip    bool // skip the children of the container of EventStart
+		Stop    bool // stop the traversal
+	}
+
+	// ScriptRuntime is the boundary of an embedded scripting runtime handling the events of a
+	// traversal, so that transformations are configured by users without recompiling.
+	ScriptRuntime interface {
+		Handle(ev ScriptEvent) (ScriptResult, error)
+	}
+
+	// ScriptFunc is a handler of ScriptEvent, usually a function of the scripting runtime
+	ScriptFunc func(ev ScriptEvent) (ScriptResult, error)
+
+	// ScriptFuncs is a ScriptRuntime dispatching events to the functions by the ValueKind of the
+	// events, events of the kinds without functions are dispatched to the one of "*" if exists, and
+	// ignored otherwise.
+	ScriptFuncs map[string]ScriptFunc
+
+	// ScriptBridge is the adapter forwarding the events of a traversal to a ScriptRuntime. Calls to
+	// the runtime are not concurrent if TraverseConf.AsyncLeaves is 0, which most runtimes require.
+	// Leaves are replaced in place, so obj should be given by pointer if the scripts replace values.
+	ScriptBridge struct {
+		runtime ScriptRuntime
+	}
+)
+
+// AnyScriptKind is the key of the function of ScriptFuncs for the events of all value kinds
+const AnyScriptKind = "*"
+
+func (f ScriptFunc) Handle(ev ScriptEvent) (ScriptResult, error) {
+	return f(ev)
+}
+
+func (fs ScriptFuncs) Handle(ev ScriptEvent) (ScriptResult, error) {
+	if f, ok := fs[ev.ValueKind]; ok {
+		return f(ev)
+	}
+	if f, ok := fs[AnyScriptKind]; ok {
+		return f(ev)
+	}
+	return ScriptResult{}, nil
+}
+
+func NewScriptBridge(runtime ScriptRuntime) *ScriptBridge {
+	return &ScriptBridge{runtime: runtime}
+}
+
+// scriptEvent converts ev to plain data
+func scriptEvent(ev Event) ScriptEvent {
+	sev := ScriptEvent{
+		Kind:   ev.Kind,
+		Seq:    ev.Node.Seq,
+		Path:   ev.Node.Path.String(),
+		Name:   ev.Node.Name,
+		Index:  ev.Node.Index,
+		Depth:  ev.Node.Depth,
+		Size:   ev.Node.Size,
+		Target: ev.Target.String(),
+	}
+	if ev.Value.IsValid() {
+		sev.Type = ev.Value.Type().String()
+		sev.ValueKind, _ = kindName(ev.Value.Kind())
+		if ev.Kind == EventLeaf {
+			if ev.Value.Kind() == reflect.Interface && !ev.Value.IsNil() {
+				sev.Value = plainValue(ev.Value.Elem())
+			} else {
+				sev.Value = plainValue(ev.Value)
+			}
+		}
+	}
+	return sev
+}
+
+// plainValue returns the value of the leaf in the types which scripting runtimes can hold
+func plainValue(val reflect.Value) interface{} {
+	switch val.Kind() {
+	case reflect.Bool:
+		return val.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return val.Uint()
+	case reflect.Float32, reflect.Float64:
+		return val.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return val.Complex()
+	case reflect.String:
+		return val.String()
+	default:
+		return nil
+	}
+}
+
+func (b *ScriptBridge) handle(ev Event) (ScriptResult, error) {
+	ret, err := b.runtime.Handle(scriptEvent(ev))
+	if err != nil {
+		return ret, fmt.Errorf("script of %s at %s: %w", ev.Kind, ev.Node.Path, err)
+	}
+	if ret.Stop {
+		return ret, ErrStopTraversal
+	}
+	return ret, nil
+}
+
+func (b *ScriptBridge) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	_, err := b.handle(Event{Kind: EventLeaf, Node: node, Value: val})
+	return err
+}
+
+func (b *ScriptBridge) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	ret, err := b.handle(Event{Kind: EventLeaf, Node: node, Value: val})
+	if err != nil || !ret.Replace {
+		return nil, false, err
+	}
+	if ret.Value == nil {
+		return nil, true, nil
+	}
+	newVal := reflect.ValueOf(ret.Value)
+	// numbers are convertible to strings as runes, which is never what scripts mean
+	if !newVal.Type().ConvertibleTo(val.Type()) || (val.Kind() == reflect.String) != (newVal.Kind() == reflect.String) {
+		return nil, false, fmt.Errorf("script replaced %s at %s with %T", val.Type(), node.Path, ret.Value)
+	}
+	return newVal.Convert(val.Type()).Interface(), true, nil
+}
+
+func (b *ScriptBridge) ForReference(_ *TravContext, node *NodeInfo, target Path, val reflect.Value) error {
+	_, err := b.handle(Event{Kind: EventReference, Node: node, Value: val, Target: target})
+	return err
+}
+
+func (b *ScriptBridge) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	_, err := b.handle(Event{Kind: EventCycle, Node: node, Value: val, Target: ancestor.Path})
+	return err
+}
+
+func (b *ScriptBridge) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	kind := EventStart
+	if !startOrEnd {
+		kind = EventEnd
+	}
+	ret, err := b.handle(Event{Kind: kind, Node: node, Value: val})
+	return !ret.Skip, err
+}
+
+func (b *ScriptBridge) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *ScriptBridge) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *ScriptBridge) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *ScriptBridge) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *ScriptBridge) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+// RunScript traverses obj with the events forwarded to runtime, containers are ended with EventEnd
+// and the runtime is called sequentially.
+func RunScript(obj interface{}, runtime ScriptRuntime, conf ...*TraverseConf) error {
+	if runtime == nil {
+		return ErrInvalidAdapter
+	}
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.AsyncLeaves = 0
+	tr, err := NewTraveller(NewScriptBridge(runtime), c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), obj)
+}
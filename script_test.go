@@ -0,0 +1,93 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunScript(t *testing.T) {
+	type config struct {
+		Name    string
+		Port    int
+		Tags    []string
+		Secrets map[string]string
+	}
+	cfg := &config{Name: "svc", Port: 80, Tags: []string{"a", "b"}, Secrets: map[string]string{"k": "v"}}
+	var events []string
+	// handlers by value kinds, as a scripting runtime would register them
+	runtime := ScriptFuncs{
+		"String": func(ev ScriptEvent) (ScriptResult, error) {
+			events = append(events, ev.Path+"="+ev.Value.(string))
+			return ScriptResult{Replace: true, Value: strings.ToUpper(ev.Value.(string))}, nil
+		},
+		"Int": func(ev ScriptEvent) (ScriptResult, error) {
+			// numbers of JavaScript are float64
+			return ScriptResult{Replace: true, Value: float64(ev.Value.(int64)) + 8000}, nil
+		},
+		"Map": func(ev ScriptEvent) (ScriptResult, error) {
+			return ScriptResult{Skip: true}, nil
+		},
+		AnyScriptKind: func(ev ScriptEvent) (ScriptResult, error) {
+			events = append(events, fmt.Sprintf("%s %s %s", ev.Kind, ev.ValueKind, ev.Path))
+			return ScriptResult{}, nil
+		},
+	}
+	if err := RunScript(cfg, runtime); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "SVC" || cfg.Port != 8080 || fmt.Sprint(cfg.Tags) != "[A B]" || cfg.Secrets["k"] != "v" {
+		t.Fatalf("got %+v", cfg)
+	}
+	if fmt.Sprint(events) != "[Start Ptr  Start Struct  Name=svc Start Slice Tags Tags[0]=a Tags[1]=b "+
+		"End Slice Tags End Struct  End Ptr ]" {
+		t.Fatalf("got %q", events)
+	}
+
+	count := 0
+	stop := ScriptFunc(func(ev ScriptEvent) (ScriptResult, error) {
+		count++
+		return ScriptResult{Stop: ev.Kind == EventLeaf}, nil
+	})
+	if err := RunScript(cfg, stop); err != nil || count != 3 {
+		t.Fatalf("got %d, %v", count, err)
+	}
+
+	failed := errors.New("failed")
+	err := RunScript(cfg, ScriptFuncs{"String": func(ScriptEvent) (ScriptResult, error) {
+		return ScriptResult{}, failed
+	}})
+	if !errors.Is(err, failed) {
+		t.Fatalf("got %v", err)
+	}
+	err = RunScript(cfg, ScriptFuncs{"Int": func(ScriptEvent) (ScriptResult, error) {
+		return ScriptResult{Replace: true, Value: "80"}, nil
+	}})
+	if err == nil || !strings.Contains(err.Error(), "script replaced int at Port with string") {
+		t.Fatalf("got %v", err)
+	}
+	err = RunScript(cfg, ScriptFuncs{"String": func(ScriptEvent) (ScriptResult, error) {
+		return ScriptResult{Replace: true, Value: int64(65)}, nil
+	}})
+	if err == nil || !strings.Contains(err.Error(), "script replaced string at Name with int64") {
+		t.Fatalf("got %v", err)
+	}
+}
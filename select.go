@@ -0,0 +1,388 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Selector selects values of an object by path patterns with predicates, e.g.:
+//
+//	Users[?Age>30].Email      emails of users older than 30
+//	Users[*].Name             names of all users
+//	Groups[?Owner.Name=="ann" && Size>=3]
+//	Tags[?@!="internal"]      elements not equal to "internal"
+//	Scores[a]                 value of the map entry with key a
+//
+// Steps are separated by '.', a step is a field name, [index], [key], * or [*] for all children,
+// or [?expr] which keeps the children for which expr holds. expr consists of comparisons joined by
+// && and || (&& first), a comparison is "operand op literal" with op in == != > >= < <=, or a single
+// operand which holds if it exists and is neither nil nor false. An operand is @ (the child itself)
+// or a path relative to the child (e.g. Age, Addr.City, @.Age), a literal is a number, a quoted
+// string, true, false or nil.
+type Selector struct {
+	steps []selectorStep
+}
+
+type (
+	selectorStep struct {
+		name   string          // label of the child to match, "*" for all
+		filter [][]*comparison // disjunction of conjunctions, if not nil
+	}
+
+	comparison struct {
+		operand string // relative path, "" for the child itself
+		op      string // "" for existence
+		literal interface{}
+	}
+)
+
+// ParseSelector parses the selector expression s
+func ParseSelector(s string) (*Selector, error) {
+	sel := &Selector{}
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '.':
+			i++
+		case '[':
+			end, err := closingBracket(s, i)
+			if err != nil {
+				return nil, err
+			}
+			inner := s[i+1 : end]
+			switch {
+			case strings.HasPrefix(inner, "?"):
+				filter, err := parseFilter(inner[1:])
+				if err != nil {
+					return nil, fmt.Errorf("selector: %v in %q", err, s)
+				}
+				sel.steps = append(sel.steps, selectorStep{filter: filter})
+			case inner == "*":
+				sel.steps = append(sel.steps, selectorStep{name: "*"})
+			default:
+				sel.steps = append(sel.steps, selectorStep{name: s[i : end+1]})
+			}
+			i = end + 1
+			if i < len(s) && s[i] != '.' && s[i] != '[' {
+				return nil, fmt.Errorf("selector: unexpected %q after %q in %q", s[i:], s[:i], s)
+			}
+		default:
+			j := i
+			for j < len(s) && s[j] != '.' && s[j] != '[' {
+				j++
+			}
+			sel.steps = append(sel.steps, selectorStep{name: s[i:j]})
+			i = j
+		}
+	}
+	return sel, nil
+}
+
+// closingBracket returns the index of the ']' matching the '[' at start, nested brackets (e.g. the
+// operands of filters like Tags[k]) are matched and quoted strings are skipped
+func closingBracket(s string, start int) (int, error) {
+	var quote byte
+	depth := 0
+	for i := start + 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '[':
+			depth++
+		case s[i] == ']':
+			if depth == 0 {
+				return i, nil
+			}
+			depth--
+		}
+	}
+	return 0, fmt.Errorf("selector: unclosed '[' at %d in %q", start, s)
+}
+
+// splitOutside splits s by sep which is not in quoted strings
+func splitOutside(s, sep string) []string {
+	var parts []string
+	var quote byte
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[last:i])
+			last = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[last:])
+}
+
+func parseFilter(expr string) ([][]*comparison, error) {
+	var filter [][]*comparison
+	for _, or := range splitOutside(expr, "||") {
+		var conj []*comparison
+		for _, and := range splitOutside(or, "&&") {
+			c, err := parseComparison(strings.TrimSpace(and))
+			if err != nil {
+				return nil, err
+			}
+			conj = append(conj, c)
+		}
+		filter = append(filter, conj)
+	}
+	return filter, nil
+}
+
+var _selectorOps = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+func parseComparison(s string) (*comparison, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty predicate")
+	}
+	c := &comparison{operand: s}
+	for i := 0; i < len(s) && c.op == ""; i++ {
+		if s[i] == '"' || s[i] == '\'' {
+			break
+		}
+		for _, op := range _selectorOps {
+			if strings.HasPrefix(s[i:], op) {
+				c.operand, c.op = strings.TrimSpace(s[:i]), op
+				lit, err := parseLiteral(strings.TrimSpace(s[i+len(op):]))
+				if err != nil {
+					return nil, err
+				}
+				c.literal = lit
+				break
+			}
+		}
+	}
+	switch {
+	case c.operand == "@":
+		c.operand = ""
+	case strings.HasPrefix(c.operand, "@."), strings.HasPrefix(c.operand, "@["):
+		c.operand = strings.TrimPrefix(c.operand[1:], ".")
+	case c.operand == "":
+		return nil, fmt.Errorf("missing operand in %q", s)
+	}
+	return c, nil
+}
+
+func parseLiteral(s string) (interface{}, error) {
+	switch s {
+	case "":
+		return nil, fmt.Errorf("missing literal")
+	case "nil", "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	switch s[0] {
+	case '"':
+		return strconv.Unquote(s)
+	case '\'':
+		// no escapes in single-quoted strings
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("invalid literal %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid literal %q", s)
+}
+
+// operandOf returns the node at the relative path from n
+func (c *comparison) operandOf(n *TemplateNode) *TemplateNode {
+	if c.operand == "" {
+		return n
+	}
+	path := c.operand
+	if n.Path != "" && !strings.HasPrefix(path, "[") {
+		path = "." + path
+	}
+	return n.Lookup(n.Path + path)
+}
+
+func (c *comparison) holds(n *TemplateNode) bool {
+	o := c.operandOf(n)
+	if o == nil {
+		return false
+	}
+	if c.op == "" {
+		return !o.IsLeaf() || o.Value != nil && o.Value != false
+	}
+	if c.literal == nil {
+		isNil := o.IsLeaf() && o.Value == nil
+		switch c.op {
+		case "==":
+			return isNil
+		case "!=":
+			return !isNil
+		}
+		return false
+	}
+	if !o.IsLeaf() {
+		return false
+	}
+	cmp, ok := compareLiteral(o.value, c.literal)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+// compareLiteral compares val with the literal, ok is false if they are not comparable
+func compareLiteral(val reflect.Value, literal interface{}) (int, bool) {
+	if val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return 0, false
+		}
+		val = val.Elem()
+	}
+	switch lit := literal.(type) {
+	case bool:
+		if val.Kind() != reflect.Bool {
+			return 0, false
+		}
+		if val.Bool() != lit {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if val.Kind() != reflect.String {
+			return 0, false
+		}
+		return strings.Compare(val.String(), lit), true
+	case int64:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return compareOrdered(val.Int() < lit, val.Int() > lit), true
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if lit < 0 {
+				return 1, true
+			}
+			return compareOrdered(val.Uint() < uint64(lit), val.Uint() > uint64(lit)), true
+		}
+		return compareLiteral(val, float64(lit))
+	case float64:
+		var f float64
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f = float64(val.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			f = float64(val.Uint())
+		case reflect.Float32, reflect.Float64:
+			f = val.Float()
+		default:
+			return 0, false
+		}
+		return compareOrdered(f < lit, f > lit), true
+	}
+	return 0, false
+}
+
+func (s selectorStep) matches(n *TemplateNode) bool {
+	if s.filter == nil {
+		return s.name == "*" || s.name == n.Name
+	}
+	for _, conj := range s.filter {
+		all := true
+		for _, c := range conj {
+			if !c.holds(n) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the values of obj selected by the selector with their paths, in traversal order.
+func (s *Selector) Select(obj interface{}, conf ...*TraverseConf) ([]PathValue, error) {
+	root, err := NewTemplateNode(obj, conf...)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*TemplateNode{root}
+	for _, step := range s.steps {
+		var next []*TemplateNode
+		for _, n := range nodes {
+			for _, child := range n.Children {
+				if step.matches(child) {
+					next = append(next, child)
+				}
+			}
+		}
+		nodes = next
+	}
+	ret := make([]PathValue, 0, len(nodes))
+	for _, n := range nodes {
+		pv := PathValue{Path: n.Path}
+		if n.value.IsValid() && n.value.CanInterface() {
+			pv.Value = n.value.Interface()
+		}
+		ret = append(ret, pv)
+	}
+	return ret, nil
+}
+
+// Select returns the values of obj selected by the selector expression, see Selector for the syntax.
+func Select(obj interface{}, selector string, conf ...*TraverseConf) ([]PathValue, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return sel.Select(obj, conf...)
+}
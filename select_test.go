@@ -0,0 +1,133 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"testing"
+)
+
+type (
+	selUser struct {
+		Name  string
+		Age   int
+		Email string
+		Boss  *selUser
+	}
+
+	selDir struct {
+		Users  []*selUser
+		Tags   []string
+		Scores map[string]float64
+	}
+)
+
+func TestSelect(t *testing.T) {
+	ann := &selUser{Name: "ann", Age: 42, Email: "ann@x"}
+	dir := &selDir{
+		Users: []*selUser{
+			ann,
+			{Name: "bob", Age: 25, Email: "bob@x", Boss: ann},
+			{Name: "cid", Age: 31, Email: "cid@x"},
+		},
+		Tags:   []string{"internal", "public", "beta"},
+		Scores: map[string]float64{"a": 1.5, "b": 3},
+	}
+	tests := []struct {
+		selector string
+		paths    []string
+	}{
+		{"Users[?Age>30].Email", []string{"Users[0].Email", "Users[2].Email"}},
+		{"Users[*].Name", []string{"Users[0].Name", "Users[1].Name", "Users[2].Name"}},
+		{`Users[?Boss.Name=="ann"].Name`, []string{"Users[1].Name"}},
+		{`Users[?Boss==nil && Age<40 || Name=='bob'].Age`, []string{"Users[1].Age", "Users[2].Age"}},
+		{"Users[?Boss].Name", []string{"Users[1].Name"}},
+		{`Tags[?@!="internal"]`, []string{"Tags[1]", "Tags[2]"}},
+		{"Scores[?@>=2]", []string{"Scores[b]"}},
+		{"Scores[a]", []string{"Scores[a]"}},
+		{"Users[1].Boss.Age", []string{"Users[1].Boss.Age"}},
+		{"Users[?Age>100].Email", nil},
+	}
+	for _, test := range tests {
+		pvs, err := Select(dir, test.selector)
+		if err != nil {
+			t.Fatalf("%s: %v", test.selector, err)
+		}
+		if len(pvs) != len(test.paths) {
+			t.Fatalf("%s: got %v", test.selector, pvs)
+		}
+		for i, pv := range pvs {
+			if pv.Path != test.paths[i] {
+				t.Fatalf("%s: got %v", test.selector, pvs)
+			}
+		}
+	}
+	pvs, err := Select(dir, "Users[?Age>30].Email")
+	if err != nil || pvs[1].Value != "cid@x" {
+		t.Fatalf("values: %v %v", pvs, err)
+	}
+	for _, bad := range []string{"Users[?Age>", "Users[?Age>x]", "Users[?]"} {
+		if _, err := ParseSelector(bad); err == nil {
+			t.Fatalf("%s should fail", bad)
+		}
+	}
+}
+
+func TestSelectNestedBrackets(t *testing.T) {
+	type host struct {
+		Name   string
+		Labels map[string]string
+		Ports  []int
+	}
+	obj := &struct {
+		Hosts []host
+		Envs  []map[string]string
+	}{
+		Hosts: []host{
+			{Name: "a", Labels: map[string]string{"env": "prod"}, Ports: []int{80}},
+			{Name: "b", Labels: map[string]string{"env": "dev"}, Ports: []int{22, 443}},
+		},
+		Envs: []map[string]string{{"env": "dev"}, {"env": "prod"}},
+	}
+	tests := []struct {
+		selector string
+		paths    []string
+	}{
+		{`Hosts[?Labels[env]=="prod"].Name`, []string{"Hosts[0].Name"}},
+		{`Hosts[?Ports[1]>=443 && Labels[env]!="prod"].Name`, []string{"Hosts[1].Name"}},
+		{`Envs[?@[env]=="prod"]`, []string{"Envs[1]"}},
+	}
+	for _, test := range tests {
+		pvs, err := Select(obj, test.selector)
+		if err != nil {
+			t.Fatalf("%s: %v", test.selector, err)
+		}
+		if len(pvs) != len(test.paths) {
+			t.Fatalf("%s: got %v", test.selector, pvs)
+		}
+		for i, pv := range pvs {
+			if pv.Path != test.paths[i] {
+				t.Fatalf("%s: got %v", test.selector, pvs)
+			}
+		}
+	}
+	for _, bad := range []string{`Hosts[?Labels[env]=="prod"`, `Hosts[?Name=="a"]Name`, `Hosts[0]x`} {
+		if _, err := ParseSelector(bad); err == nil {
+			t.Fatalf("%s should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const DefaultSlotTag = "rtlorder"
+
+type (
+	// SlotPropertier puts exported struct fields into fixed slots declared by tag (DefaultSlotTag if
+	// Tag is empty), e.g. `rtlorder:"3"`. Fields without slot number take the smallest free slots in
+	// declaration order, fields tagged with "-" are ignored. Slots not taken by any field are returned
+	// as placeholders (Property.Index == -1), so the size of a struct is the largest slot number + 1.
+	// Illegal or duplicated slot numbers cause panic, as there's no way to return an error from a
+	// StructPropertier.
+	SlotPropertier struct {
+		Tag string
+	}
+
+	slotCacheKey struct {
+		tag string
+		typ reflect.Type
+	}
+)
+
+var _slotCache sync.Map // slotCacheKey -> []Property
+
+func (p SlotPropertier) tag() string {
+	if p.Tag == "" {
+		return DefaultSlotTag
+	}
+	return p.Tag
+}
+
+func (p SlotPropertier) Properties(val reflect.Value) (int, []Property) {
+	if !val.IsValid() || val.Kind() != reflect.Struct {
+		return 0, nil
+	}
+	props := p.TypeProperties(val.Type())
+	return len(props), props
+}
+
+// TypeProperties returns all slots of the struct type typ, including placeholders
+func (p SlotPropertier) TypeProperties(typ reflect.Type) []Property {
+	key := slotCacheKey{tag: p.tag(), typ: typ}
+	if v, ok := _slotCache.Load(key); ok {
+		return v.([]Property)
+	}
+	props := p.parse(typ)
+	_slotCache.Store(key, props)
+	return props
+}
+
+func (p SlotPropertier) parse(typ reflect.Type) []Property {
+	tag := p.tag()
+	var untagged []Property
+	slots := make(map[int]Property)
+	max := -1
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tagStr := strings.TrimSpace(f.Tag.Get(tag))
+		if tagStr == "-" {
+			continue
+		}
+		if tagStr == "" {
+			untagged = append(untagged, Property{Index: i, Name: f.Name})
+			continue
+		}
+		slot, err := strconv.Atoi(tagStr)
+		if err != nil || slot < 0 {
+			panic(fmt.Errorf("illegal %s (%s) for field %s of type %s", tag, tagStr, f.Name, typ.Name()))
+		}
+		if exist, ok := slots[slot]; ok {
+			panic(fmt.Errorf("duplicated %s (%d) for field %s and %s of type %s", tag, slot, exist.Name, f.Name, typ.Name()))
+		}
+		slots[slot] = Property{Index: i, Name: f.Name, IndexForReal: slot}
+		if slot > max {
+			max = slot
+		}
+	}
+	free := 0
+	for _, prop := range untagged {
+		for ; ; free++ {
+			if _, ok := slots[free]; !ok {
+				break
+			}
+		}
+		prop.IndexForReal = free
+		slots[free] = prop
+		if free > max {
+			max = free
+		}
+	}
+	props := make([]Property, max+1)
+	for i := range props {
+		if prop, ok := slots[i]; ok {
+			props[i] = prop
+		} else {
+			props[i] = Property{Index: -1, IndexForReal: i}
+		}
+	}
+	return props
+}
@@ -0,0 +1,190 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// SparsityStat counts the pointers, slices, maps and interfaces at the same place
+	SparsityStat struct {
+		Total int // number of values
+		Nil   int // number of nil values
+		Empty int // number of non-nil slices and maps without elements
+	}
+
+	// SparsityReport aggregates how many pointers, slices, maps and interfaces are nil or empty,
+	// of one or more objects.
+	SparsityReport struct {
+		lock sync.Mutex
+		// by struct type and field name, e.g. "pkg.User.Addr"
+		ByField map[string]*SparsityStat
+		// by path pattern, with indexes and map keys replaced by *, e.g. "Users[*].Addr"
+		ByPath map[string]*SparsityStat
+	}
+
+	// sparsityCounter is the adapter of SparsityReport
+	sparsityCounter struct {
+		goInAll
+		r *SparsityReport
+	}
+)
+
+// Ratio returns the ratio of nil and empty values
+func (s *SparsityStat) Ratio() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Nil+s.Empty) / float64(s.Total)
+}
+
+func (s *SparsityStat) String() string {
+	return fmt.Sprintf("%d/%d nil, %d/%d empty", s.Nil, s.Total, s.Empty, s.Total)
+}
+
+func NewSparsityReport() *SparsityReport {
+	return &SparsityReport{
+		ByField: make(map[string]*SparsityStat),
+		ByPath:  make(map[string]*SparsityStat),
+	}
+}
+
+// pathPattern returns the path with indexes and map keys replaced by *
+func pathPattern(path Path) string {
+	var sb strings.Builder
+	for _, n := range path {
+		switch n.Kind {
+		case reflect.Struct:
+			sb.WriteString(".")
+			sb.WriteString(n.Name)
+		case reflect.Array, reflect.Slice:
+			sb.WriteString("[*]")
+		case reflect.Map:
+			if n.IsKey {
+				sb.WriteString("{*}")
+			} else {
+				sb.WriteString("[*]")
+			}
+		}
+	}
+	return strings.TrimPrefix(sb.String(), ".")
+}
+
+func (r *SparsityReport) count(node *NodeInfo, val reflect.Value) {
+	var isNil, isEmpty bool
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		isNil = val.IsNil()
+	case reflect.Slice, reflect.Map:
+		isNil = val.IsNil()
+		isEmpty = !isNil && val.Len() == 0
+	default:
+		return
+	}
+	add := func(stats map[string]*SparsityStat, key string) {
+		s, ok := stats[key]
+		if !ok {
+			s = &SparsityStat{}
+			stats[key] = s
+		}
+		s.Total++
+		if isNil {
+			s.Nil++
+		}
+		if isEmpty {
+			s.Empty++
+		}
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if node.Parent.IsValid() && node.Parent.Kind() == reflect.Struct {
+		add(r.ByField, node.Parent.Type().String()+"."+node.Name)
+	}
+	add(r.ByPath, pathPattern(node.Path))
+}
+
+func (c sparsityCounter) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	c.r.count(node, val)
+	return nil
+}
+
+func (c sparsityCounter) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	c.r.count(node, val)
+	return nil
+}
+
+func (c sparsityCounter) ForContainerMap(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	c.r.count(node, val)
+	return true, nil
+}
+
+func (c sparsityCounter) ForContainerPtr(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	c.r.count(node, val)
+	return true, nil
+}
+
+func (c sparsityCounter) ForContainerSlice(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	c.r.count(node, val)
+	return true, nil
+}
+
+// Analyze adds the pointers, slices, maps and interfaces of obj to the report, map keys are
+// included, and cycles are not traversed repeatedly.
+func (r *SparsityReport) Analyze(obj interface{}, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.DetectCycles = true
+	tr, err := NewTraveller(sparsityCounter{r: r}, c)
+	if err != nil {
+		return err
+	}
+	return tr.Traverse(NewContext(), obj)
+}
+
+// String returns the stats by path in the order of their paths
+func (r *SparsityReport) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	paths := make([]string, 0, len(r.ByPath))
+	for p := range r.ByPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var sb strings.Builder
+	for _, p := range paths {
+		if p == "" {
+			fmt.Fprintf(&sb, "(root): %s\n", r.ByPath[p])
+		} else {
+			fmt.Fprintf(&sb, "%s: %s\n", p, r.ByPath[p])
+		}
+	}
+	return sb.String()
+}
+
+// AnalyzeSparsity returns the report of nil and empty pointers, slices, maps and interfaces of obj
+func AnalyzeSparsity(obj interface{}, conf ...*TraverseConf) (*SparsityReport, error) {
+	r := NewSparsityReport()
+	if err := r.Analyze(obj, conf...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,66 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"testing"
+)
+
+type (
+	sparseAddr struct {
+		Lines []string
+	}
+
+	sparseUser struct {
+		Addr  *sparseAddr
+		Tags  map[string]string
+		Extra interface{}
+	}
+)
+
+func TestAnalyzeSparsity(t *testing.T) {
+	users := []sparseUser{
+		{Addr: &sparseAddr{Lines: []string{"a"}}, Tags: map[string]string{}},
+		{Addr: &sparseAddr{}},
+		{Extra: 1},
+	}
+	r, err := AnalyzeSparsity(users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(s *SparsityStat, total, nils, empty int) {
+		t.Helper()
+		if s == nil || s.Total != total || s.Nil != nils || s.Empty != empty {
+			t.Fatalf("stat: %v, want %d/%d/%d\n%s", s, total, nils, empty, r)
+		}
+	}
+	check(r.ByField["dfpt.sparseUser.Addr"], 3, 1, 0)
+	check(r.ByField["dfpt.sparseUser.Tags"], 3, 2, 1)
+	check(r.ByField["dfpt.sparseUser.Extra"], 3, 2, 0)
+	check(r.ByField["dfpt.sparseAddr.Lines"], 2, 1, 0)
+	check(r.ByPath["[*].Addr.Lines"], 2, 1, 0)
+	check(r.ByPath[""], 1, 0, 0)
+
+	if err = r.Analyze(&sparseUser{}); err != nil {
+		t.Fatal(err)
+	}
+	check(r.ByField["dfpt.sparseUser.Addr"], 4, 2, 0)
+	if ratio := r.ByPath["[*].Tags"].Ratio(); ratio != 1 {
+		t.Fatalf("ratio: %f", ratio)
+	}
+}
@@ -0,0 +1,96 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidUTF8   = errors.New("invalid UTF-8")
+	ErrNULByte       = errors.New("NUL byte")
+	ErrControlChar   = errors.New("control character")
+	ErrStringTooLong = errors.New("string too long")
+)
+
+// StringPolicy selects the checks of CheckStrings
+type StringPolicy struct {
+	// strings should be valid UTF-8
+	ValidUTF8 bool
+	// strings should not contain NUL bytes
+	NoNUL bool
+	// strings should not contain control characters (C0, DEL and C1) other than \t, \n and \r, NUL
+	// is reported by NoNUL
+	NoControl bool
+	// max length of strings in bytes, no limit if it's not positive
+	MaxLen int
+}
+
+// check returns the problems of string s
+func (p StringPolicy) check(s string) []error {
+	var errs []error
+	if p.ValidUTF8 && !utf8.ValidString(s) {
+		errs = append(errs, fmt.Errorf("%w at byte %d", ErrInvalidUTF8, invalidUTF8At(s)))
+	}
+	if p.NoNUL {
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			errs = append(errs, fmt.Errorf("%w at byte %d", ErrNULByte, i))
+		}
+	}
+	if p.NoControl {
+		for i, r := range s {
+			if r != 0 && r != '\t' && r != '\n' && r != '\r' && (r < 0x20 || r >= 0x7f && r < 0xa0) {
+				errs = append(errs, fmt.Errorf("%w %U at byte %d", ErrControlChar, r, i))
+				break
+			}
+		}
+	}
+	if p.MaxLen > 0 && len(s) > p.MaxLen {
+		errs = append(errs, fmt.Errorf("%w: %d > %d", ErrStringTooLong, len(s), p.MaxLen))
+	}
+	return errs
+}
+
+// invalidUTF8At returns the offset of the first invalid UTF-8 sequence in s
+func invalidUTF8At(s string) int {
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// CheckStrings returns the problems of all strings (including map keys and strings held by
+// interfaces) of obj against policy. Each Diagnostic has the path of the string and an error
+// wrapping ErrInvalidUTF8, ErrNULByte, ErrControlChar or ErrStringTooLong, a string may have more
+// than one problem.
+func CheckStrings(obj interface{}, policy StringPolicy, conf ...*TraverseConf) (Diagnostics, error) {
+	return checkLeaves(obj, func(_ *NodeInfo, val reflect.Value) []error {
+		if val.Kind() != reflect.String {
+			return nil
+		}
+		return policy.check(val.String())
+	}, conf...)
+}
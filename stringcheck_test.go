@@ -0,0 +1,65 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckStrings(t *testing.T) {
+	obj := struct {
+		Good  string
+		Bad   string
+		NUL   string
+		Ctrl  []string
+		Long  string
+		Attrs map[string]interface{}
+	}{
+		Good:  "héllo\tworld\n",
+		Bad:   "ok\xffno",
+		NUL:   "a\x00b",
+		Ctrl:  []string{"fine", "bell\a", "c1\u0085"},
+		Long:  "0123456789",
+		Attrs: map[string]interface{}{"k\x01": "v"},
+	}
+	issues, err := CheckStrings(obj, StringPolicy{ValidUTF8: true, NoNUL: true, NoControl: true, MaxLen: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		path string
+		err  error
+	}{
+		{"Good", ErrStringTooLong},
+		{"Bad", ErrInvalidUTF8},
+		{"NUL", ErrNULByte},
+		{"Ctrl[1]", ErrControlChar},
+		{"Ctrl[2]", ErrControlChar},
+		{"Long", ErrStringTooLong},
+		{"Attrs{k\x01}", ErrControlChar},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("issues: %v", issues)
+	}
+	for i, w := range want {
+		if issues[i].Path.String() != w.path || !errors.Is(issues[i].Err, w.err) {
+			t.Fatalf("issue %d: %v, want %s %v", i, issues[i], w.path, w.err)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type (
+	// SummaryOptions are the bounds of Summarize
+	SummaryOptions struct {
+		// config of the traversal, nil for default. Map keys are always sorted.
+		Conf *TraverseConf
+		// max length in bytes of the summary, 512 if <= 0
+		MaxLen int
+		// number of the first (and the last) elements shown in each array, slice or map, 2 if <= 0
+		Edge int
+		// max number of runes of each leaf value, 64 if <= 0
+		MaxLeaf int
+	}
+
+	// summaryFrame is a container being summarized
+	summaryFrame struct {
+		kind    reflect.Kind
+		entries int // number of elements (entries for maps)
+		seen    int // number of children visited
+		elided  bool
+	}
+
+	// summarizer writes the summary during traversal, pointers are transparent
+	summarizer struct {
+		opts   SummaryOptions
+		sb     strings.Builder
+		frames []*summaryFrame
+	}
+)
+
+// enter writes the separator and the label of the value of node, returns false if the value is
+// elided from its container.
+func (s *summarizer) enter(node *NodeInfo) bool {
+	if len(s.frames) == 0 {
+		return true
+	}
+	top := s.frames[len(s.frames)-1]
+	if top.kind == reflect.Ptr {
+		return true
+	}
+	pos := top.seen
+	top.seen++
+	entry, isValue := pos, false
+	if top.kind == reflect.Map {
+		entry, isValue = pos>>1, pos&1 == 1
+	}
+	if top.kind != reflect.Struct && entry >= s.opts.Edge && entry < top.entries-s.opts.Edge {
+		if !top.elided {
+			top.elided = true
+			s.sb.WriteString(", …(+" + strconv.Itoa(top.entries-2*s.opts.Edge) + ")")
+		}
+		return false
+	}
+	switch {
+	case isValue:
+		s.sb.WriteString(":")
+	case entry > 0:
+		s.sb.WriteString(", ")
+	}
+	if top.kind == reflect.Struct {
+		s.sb.WriteString(node.Name + ":")
+	}
+	return true
+}
+
+func (s *summarizer) write(str string) error {
+	s.sb.WriteString(str)
+	if s.sb.Len() > s.opts.MaxLen {
+		return ErrStopTraversal
+	}
+	return nil
+}
+
+func (s *summarizer) leaf(val reflect.Value) string {
+	if val.Kind() == reflect.Interface {
+		if val.IsNil() {
+			return "nil"
+		}
+		val = val.Elem()
+	}
+	var str string
+	switch {
+	case val.Kind() == reflect.String:
+		str = val.String()
+	case val.CanInterface():
+		str = fmt.Sprintf("%v", val.Interface())
+	default:
+		str = val.String()
+	}
+	if utf8.RuneCountInString(str) > s.opts.MaxLeaf {
+		str = string([]rune(str)[:s.opts.MaxLeaf]) + "…"
+	}
+	if val.Kind() == reflect.String {
+		return strconv.Quote(str)
+	}
+	return str
+}
+
+func (s *summarizer) ForNilPtr(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	if !s.enter(node) {
+		return nil
+	}
+	return s.write("nil")
+}
+
+func (s *summarizer) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if !s.enter(node) {
+		return nil
+	}
+	return s.write(s.leaf(val))
+}
+
+func (s *summarizer) ForCycle(_ *TravContext, node *NodeInfo, _ *NodeInfo, _ reflect.Value) error {
+	if !s.enter(node) {
+		return nil
+	}
+	return s.write("<cycle>")
+}
+
+// typeHistogram returns the counts of the dynamic types of the interface elements (values for
+// maps) of the container, sorted by counts, e.g. "<int:3 string:1>". It's empty if the elements
+// are not interfaces.
+func typeHistogram(val reflect.Value) string {
+	if val.Type().Elem().Kind() != reflect.Interface {
+		return ""
+	}
+	counts := make(map[string]int)
+	count := func(v reflect.Value) {
+		if v.IsNil() {
+			counts["nil"]++
+		} else {
+			counts[v.Elem().Type().String()]++
+		}
+	}
+	if val.Kind() == reflect.Map {
+		for iter := val.MapRange(); iter.Next(); {
+			count(iter.Value())
+		}
+	} else {
+		for i := 0; i < val.Len(); i++ {
+			count(val.Index(i))
+		}
+	}
+	if len(counts) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for i, name := range names {
+		names[i] = name + ":" + strconv.Itoa(counts[name])
+	}
+	return "<" + strings.Join(names, " ") + ">"
+}
+
+func (s *summarizer) container(node *NodeInfo, startOrEnd bool, val reflect.Value, open, close string) (bool, error) {
+	if !startOrEnd {
+		s.frames = s.frames[:len(s.frames)-1]
+		return false, s.write(close)
+	}
+	if !s.enter(node) {
+		return false, ErrSkipContainer
+	}
+	if (val.Kind() == reflect.Slice || val.Kind() == reflect.Map) && val.IsNil() {
+		return false, s.write("nil")
+	}
+	frame := &summaryFrame{kind: val.Kind()}
+	switch val.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map:
+		frame.entries = val.Len()
+		open = typeHistogram(val) + open
+	}
+	s.frames = append(s.frames, frame)
+	return true, s.write(open)
+}
+
+func (s *summarizer) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val, "[", "]")
+}
+
+func (s *summarizer) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val, "{", "}")
+}
+
+func (s *summarizer) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val, "", "")
+}
+
+func (s *summarizer) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val, "[", "]")
+}
+
+func (s *summarizer) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd, val, "{", "}")
+}
+
+// Summarize returns a summary of obj bounded by MaxLen for log lines: struct fields are shown with
+// their names, only the first and the last Edge elements of arrays, slices and maps are shown with
+// the number of the elided ones, e.g. `[1, 2, …(+996), 999, 1000]`, and containers of interfaces
+// are prefixed by the histograms of the dynamic types, e.g. `<int:3 string:1>[…]`. Long leaves are
+// cut to MaxLeaf runes, and the summary is cut with "…" if it's still longer than MaxLen.
+func Summarize(obj interface{}, opts ...*SummaryOptions) (string, error) {
+	o := SummaryOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	if o.MaxLen <= 0 {
+		o.MaxLen = 512
+	}
+	if o.Edge <= 0 {
+		o.Edge = 2
+	}
+	if o.MaxLeaf <= 0 {
+		o.MaxLeaf = 64
+	}
+	c := confOf(o.Conf)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	s := &summarizer{opts: o}
+	tr, err := NewTraveller(s, c)
+	if err != nil {
+		return "", err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return "", err
+	}
+	if str := s.sb.String(); len(str) > o.MaxLen {
+		cut := o.MaxLen
+		for cut > 0 && !utf8.RuneStart(str[cut]) {
+			cut--
+		}
+		return str[:cut] + "…", nil
+	}
+	return s.sb.String(), nil
+}
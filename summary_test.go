@@ -0,0 +1,60 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	type item struct {
+		Name  string
+		IDs   []int
+		Attrs map[string]interface{}
+		Next  *item
+		Tags  []string
+	}
+	ids := make([]int, 1000)
+	for i := range ids {
+		ids[i] = i
+	}
+	obj := &item{
+		Name:  "a",
+		IDs:   ids,
+		Attrs: map[string]interface{}{"a": 1, "b": "x", "c": 2, "d": nil},
+		Next:  &item{Name: strings.Repeat("n", 100)},
+	}
+	got, err := Summarize(obj, &SummaryOptions{MaxLeaf: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{Name:"a", IDs:[0, 1, …(+996), 998, 999], Attrs:<int:2 nil:1 string:1>{"a":1, "b":"x", "c":2, "d":nil}, ` +
+		`Next:{Name:"nnnn…", IDs:nil, Attrs:nil, Next:nil, Tags:nil}, Tags:nil}`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	got, err = Summarize(obj, &SummaryOptions{MaxLen: 20, Edge: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != `{Name:"a", IDs:[0, …` {
+		t.Fatalf("got %s", got)
+	}
+}
@@ -0,0 +1,306 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TagName      = "dfpt"  // tag key of the options for traversal, e.g. `dfpt:"codec=hex"`
+	LimitTagName = "limit" // tag key of the size limits checked by CheckLimits, e.g. `limit:"maxlen=256"`
+	// tag key of the roles which the field is visible to, e.g. `visibility:"admin,internal"`, see
+	// RolePropertier
+	VisibilityTagName = "visibility"
+	// tag key of the cross reference of a pointer field, the kind of the referenced objects followed
+	// by options, e.g. `ref:"User,id=OwnerID"`, see LinkReferences
+	RefTagName = "ref"
+
+	TagCodec = "codec" // codec=name: the field is processed by the codec registered with name
+	TagSince = "since" // since=N: the field exists since version N (inclusive)
+	TagUntil = "until" // until=N: the field exists until version N (inclusive)
+	TagOneOf = "oneof" // oneof=group: at most one field of the group is set, only the set one is traversed
+
+	TagUnsigned  = "unsigned"  // unsigned: the value of the signed number field should not be negative
+	TagNormalize = "normalize" // normalize: strings in the field are canonicalized by NormalizeStrings
+	TagGzip      = "gzip"      // gzip: the []byte field is compressed by CompressBytes and restored by DecompressBytes
+	TagSensitive = "sensitive" // sensitive: the field is blanked or masked by Redactor
+	TagID        = "id"        // id=kind: the field is the ID of the objects of kind (struct type name if omitted)
+	TagUnknown   = "unknown"   // unknown: the map[string]interface{} field keeps the inputs without fields, see UnknownSource
+
+	RefID = "id" // id=Field: the field holding the ID of the referenced object, <name of the pointer>ID by default
+
+	LimitMaxLen   = "maxlen"   // maxlen=N: max length in bytes of the string or []byte
+	LimitMaxItems = "maxitems" // maxitems=N: max number of elements of the slice, array or map
+)
+
+type (
+	// tagOptions are the comma separated options in the value of TagName, an option is either a name
+	// or a name=value pair.
+	tagOptions map[string]string
+
+	// structTypeInfo is the tag information of a struct type, slices are indexed by field index
+	structTypeInfo struct {
+		options  []tagOptions
+		limits   []tagOptions // options of LimitTagName
+		roles    []tagOptions // roles of VisibilityTagName, nil if the field is visible to all
+		codecs   []string
+		oneofs   []string // oneof group of the field
+		hasOneOf bool
+		unknown  int // index of the field tagged with TagUnknown, -1 if none
+	}
+
+	// VersionedPropertier filters the properties given by Propertier (exported fields in declaration
+	// order if nil) with the since/until options in field tags, e.g. `dfpt:"since=3,until=5"`.
+	// Properties not existing in Version are removed, or turned into placeholders if they have
+	// explicit IndexForReal (slot based Propertier), so that the layout of the struct is kept.
+	// Version 0 means no filtering.
+	VersionedPropertier struct {
+		Propertier StructPropertier
+		Version    int
+	}
+
+	// RolePropertier filters the properties given by Propertier (exported fields in declaration
+	// order if nil) with the visibility tags of the fields, e.g. `visibility:"admin,internal"`.
+	// Fields with visibility tags are removed (or turned into placeholders if they have explicit
+	// IndexForReal) unless any of Roles is listed in their tags, fields without the tags are
+	// visible to all.
+	RolePropertier struct {
+		Propertier StructPropertier
+		Roles      []string
+	}
+)
+
+var _structInfoCache sync.Map // reflect.Type -> *structTypeInfo
+
+func parseTagOptions(tag reflect.StructTag) tagOptions {
+	return parseTagOptionsOf(tag, TagName)
+}
+
+func parseTagOptionsOf(tag reflect.StructTag, key string) tagOptions {
+	str, ok := tag.Lookup(key)
+	if !ok {
+		return nil
+	}
+	return parseOptionString(str)
+}
+
+// parseOptionString parses the comma separated options in str
+func parseOptionString(str string) tagOptions {
+	opts := make(tagOptions)
+	for _, opt := range strings.Split(str, ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			opts[strings.TrimSpace(opt[:i])] = strings.TrimSpace(opt[i+1:])
+		} else {
+			opts[opt] = ""
+		}
+	}
+	return opts
+}
+
+func (o tagOptions) Has(name string) bool {
+	_, ok := o[name]
+	return ok
+}
+
+func (o tagOptions) Get(name string) (string, bool) {
+	v, ok := o[name]
+	return v, ok
+}
+
+func newStructTypeInfo(typ reflect.Type) *structTypeInfo {
+	info := &structTypeInfo{
+		options: make([]tagOptions, typ.NumField()),
+		limits:  make([]tagOptions, typ.NumField()),
+		roles:   make([]tagOptions, typ.NumField()),
+		codecs:  make([]string, typ.NumField()),
+		unknown: -1,
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		opts := parseTagOptions(typ.Field(i).Tag)
+		info.options[i] = opts
+		if opts.Has(TagUnknown) && info.unknown < 0 {
+			info.unknown = i
+		}
+		info.limits[i] = parseTagOptionsOf(typ.Field(i).Tag, LimitTagName)
+		info.roles[i] = parseTagOptionsOf(typ.Field(i).Tag, VisibilityTagName)
+		info.codecs[i], _ = opts.Get(TagCodec)
+		if group, ok := opts.Get(TagOneOf); ok && group != "" {
+			if info.oneofs == nil {
+				info.oneofs = make([]string, typ.NumField())
+			}
+			info.oneofs[i] = group
+			info.hasOneOf = true
+		}
+	}
+	return info
+}
+
+// selectOneOf returns the name of the set field of each oneof group in fields, and the indexes of
+// the other fields in the groups. It's an error if more than one field of a group is set.
+func (info *structTypeInfo) selectOneOf(val reflect.Value, fields []Property) (map[string]string, map[int]struct{}, error) {
+	if !info.hasOneOf {
+		return nil, nil, nil
+	}
+	selected := make(map[string]string)
+	skips := make(map[int]struct{})
+	for _, field := range fields {
+		if field.Index < 0 || info.oneofs[field.Index] == "" {
+			continue
+		}
+		group := info.oneofs[field.Index]
+		name, exist := selected[group]
+		if val.Field(field.Index).IsZero() {
+			if !exist {
+				selected[group] = ""
+			}
+			skips[field.Index] = struct{}{}
+			continue
+		}
+		if name != "" {
+			return nil, nil, fmt.Errorf("oneof %s of %s has more than one field set: %s, %s",
+				group, val.Type(), name, field.Name)
+		}
+		selected[group] = field.Name
+	}
+	return selected, skips, nil
+}
+
+// structInfo returns the cached tag information of the struct type
+func structInfo(typ reflect.Type) *structTypeInfo {
+	if v, ok := _structInfoCache.Load(typ); ok {
+		return v.(*structTypeInfo)
+	}
+	info := newStructTypeInfo(typ)
+	v, _ := _structInfoCache.LoadOrStore(typ, info)
+	return v.(*structTypeInfo)
+}
+
+// fieldIndex returns the index of the struct field of node
+func fieldIndex(node *NodeInfo) (int, bool) {
+	if !node.Parent.IsValid() || node.Parent.Kind() != reflect.Struct {
+		return 0, false
+	}
+	if n := len(node.Path); n > 0 && node.Path[n-1].Kind == reflect.Struct && node.Path[n-1].Index >= 0 {
+		// the name may be renamed by TraverseConf.FieldNames
+		return node.Path[n-1].Index, true
+	}
+	f, ok := node.Parent.Type().FieldByName(node.Name)
+	if !ok || len(f.Index) != 1 {
+		return 0, false
+	}
+	return f.Index[0], true
+}
+
+// fieldTagged returns whether the value of node is a struct field with the tag option
+func fieldTagged(node *NodeInfo, option string) bool {
+	index, ok := fieldIndex(node)
+	return ok && structInfo(node.Parent.Type()).options[index].Has(option)
+}
+
+// inVersion returns whether the field exists in version
+func (info *structTypeInfo) inVersion(index, version int) (bool, error) {
+	opts := info.options[index]
+	if since, ok := opts.Get(TagSince); ok {
+		v, err := strconv.Atoi(since)
+		if err != nil {
+			return false, fmt.Errorf("illegal %s=%s: %v", TagSince, since, err)
+		}
+		if version < v {
+			return false, nil
+		}
+	}
+	if until, ok := opts.Get(TagUntil); ok {
+		v, err := strconv.Atoi(until)
+		if err != nil {
+			return false, fmt.Errorf("illegal %s=%s: %v", TagUntil, until, err)
+		}
+		if version > v {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p VersionedPropertier) Properties(val reflect.Value) (int, []Property) {
+	size, props := structProperties(&TraverseConf{Propertier: p.Propertier}, val)
+	if p.Version == 0 || len(props) == 0 {
+		return size, props
+	}
+	info := structInfo(val.Type())
+	ret := make([]Property, 0, len(props))
+	for _, prop := range props {
+		if prop.Index >= 0 {
+			in, err := info.inVersion(prop.Index, p.Version)
+			if err != nil {
+				panic(fmt.Errorf("field %s of type %s: %v", prop.Name, val.Type(), err))
+			}
+			if !in {
+				if prop.IndexForReal < 0 {
+					size--
+					continue
+				}
+				prop = Property{Index: -1, IndexForReal: prop.IndexForReal}
+			}
+		}
+		ret = append(ret, prop)
+	}
+	return size, ret
+}
+
+// visible returns whether the field is visible to any of roles
+func (info *structTypeInfo) visible(index int, roles []string) bool {
+	allowed := info.roles[index]
+	if allowed == nil {
+		return true
+	}
+	for _, role := range roles {
+		if allowed.Has(role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p RolePropertier) Properties(val reflect.Value) (int, []Property) {
+	size, props := structProperties(&TraverseConf{Propertier: p.Propertier}, val)
+	if len(props) == 0 {
+		return size, props
+	}
+	info := structInfo(val.Type())
+	ret := make([]Property, 0, len(props))
+	for _, prop := range props {
+		if prop.Index >= 0 && !info.visible(prop.Index, p.Roles) {
+			if prop.IndexForReal < 0 {
+				size--
+				continue
+			}
+			prop = Property{Index: -1, IndexForReal: prop.IndexForReal}
+		}
+		ret = append(ret, prop)
+	}
+	return size, ret
+}
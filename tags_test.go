@@ -0,0 +1,222 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type versionedObj struct {
+	A int
+	B int `dfpt:"since=3"`
+	C int `dfpt:"until=4" rtlorder:"3"`
+	D int `dfpt:"since=3,until=5"`
+}
+
+func TestVersionedFields(t *testing.T) {
+	obj := &versionedObj{A: 1, B: 2, C: 3, D: 4}
+	expected := map[int]string{
+		0: "[{A 1} {B 2} {C 3} {D 4}]",
+		2: "[{A 1} {C 3}]",
+		3: "[{A 1} {B 2} {C 3} {D 4}]",
+		5: "[{A 1} {B 2} {D 4}]",
+		6: "[{A 1} {B 2}]",
+	}
+	for version, exp := range expected {
+		pairs, err := Flatten(obj, &TraverseConf{Version: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(pairs) != exp {
+			t.Fatalf("version %d: got %v, expecting %s", version, pairs, exp)
+		}
+	}
+
+	// slots are kept as placeholders
+	size, props := VersionedPropertier{Propertier: SlotPropertier{}, Version: 6}.Properties(reflect.ValueOf(*obj))
+	t.Log(size, props)
+	if size != 4 || props[1].Index != 1 || props[2].Index != -1 || props[3].Index != -1 {
+		t.Fatalf("size:%d props:%v", size, props)
+	}
+}
+
+type (
+	oneofObj struct {
+		Name   string
+		Circle *float64 `dfpt:"oneof=shape"`
+		Square *int     `dfpt:"oneof=shape"`
+		Text   string   `dfpt:"oneof=content"`
+		Bytes  []byte   `dfpt:"oneof=content"`
+	}
+
+	oneofRecorder struct {
+		groups *[]map[string]string
+	}
+)
+
+func (r oneofRecorder) ForContainerStruct(_ *TravContext, node *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	*r.groups = append(*r.groups, node.OneOf)
+	return true, nil
+}
+
+func (r oneofRecorder) ForContainerPtr(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (r oneofRecorder) ForAllKinds(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func TestOneOf(t *testing.T) {
+	f := 1.5
+	obj := &oneofObj{Name: "a", Circle: &f}
+	pairs, err := Flatten(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(pairs) != "[{Name a} {Circle 1.5}]" {
+		t.Fatalf("got %v", pairs)
+	}
+
+	var groups []map[string]string
+	tr, err := NewTraveller(oneofRecorder{groups: &groups})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0]["shape"] != "Circle" || groups[0]["content"] != "" {
+		t.Fatalf("groups: %v", groups)
+	}
+
+	i := 2
+	obj.Square = &i
+	if err = tr.Traverse(NewContext(), obj); err == nil {
+		t.Fatal("more than one field set in oneof should fail")
+	} else {
+		t.Log(err)
+	}
+}
+
+type visibleObj struct {
+	Name   string
+	Email  string `visibility:"admin,support"`
+	Salary int    `visibility:"admin" dfpt:"since=2"`
+	Notes  string `visibility:""`
+}
+
+func TestRoleVisibility(t *testing.T) {
+	obj := &visibleObj{Name: "a", Email: "e", Salary: 1, Notes: "n"}
+	tests := []struct {
+		conf     *TraverseConf
+		expected string
+	}{
+		{&TraverseConf{}, "[{Name a} {Email e} {Salary 1} {Notes n}]"},
+		{&TraverseConf{Roles: []string{}}, "[{Name a}]"},
+		{&TraverseConf{Roles: []string{"support"}}, "[{Name a} {Email e}]"},
+		{&TraverseConf{Roles: []string{"guest", "admin"}}, "[{Name a} {Email e} {Salary 1}]"},
+		{&TraverseConf{Roles: []string{"admin"}, Version: 1}, "[{Name a} {Email e}]"},
+	}
+	for _, test := range tests {
+		pairs, err := Flatten(obj, test.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(pairs) != test.expected {
+			t.Fatalf("roles %v: got %v, expecting %s", test.conf.Roles, pairs, test.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeYAML(&buf, obj, &TraverseConf{Roles: []string{"support"}}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "Name: a\nEmail: e\n" {
+		t.Fatalf("yaml: %q", buf.String())
+	}
+
+	// slots are kept as placeholders
+	size, props := RolePropertier{Propertier: SlotPropertier{}}.Properties(reflect.ValueOf(*obj))
+	if size != 4 || props[0].Index != 0 || props[1].Index != -1 || props[3].Index != -1 {
+		t.Fatalf("size:%d props:%v", size, props)
+	}
+}
+
+type secretMasker struct{}
+
+func (secretMasker) ForTagSecret(_ *TravContext, _ *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	return nil, !val.IsZero(), nil
+}
+
+func (secretMasker) ForKindString(*TravContext, *NodeInfo, reflect.Value) error {
+	return nil
+}
+
+func (secretMasker) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (secretMasker) ForContainerPtr(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestForTag(t *testing.T) {
+	type key struct {
+		ID string
+	}
+	type account struct {
+		Name  string
+		Token string `dfpt:"secret"`
+		PIN   int    `dfpt:"Secret,unsigned"`
+		Key   *key   `dfpt:"secret"`
+	}
+	tr, err := NewTraveller(secretMasker{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &account{Name: "a", Token: "t", PIN: 1234, Key: &key{ID: "k"}}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if *obj != (account{Name: "a"}) {
+		t.Fatalf("got %+v", obj)
+	}
+
+	var got []string
+	b := NewAdapterBuilder().
+		OnTag("secret", func(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+			got = append(got, node.Name)
+			return nil
+		}).
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, account{}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Token PIN Key]" {
+		t.Fatalf("got %v", got)
+	}
+}
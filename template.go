@@ -0,0 +1,182 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"text/template"
+)
+
+// TemplateNode is a value of the object passed to templates by RenderTemplate, containers keep the
+// order of their children (struct fields in the order given by the Propertier if any, map entries in
+// the order of sorted keys), and pointers are transparent.
+type TemplateNode struct {
+	Name     string          // name in the parent: field name, [index] or [key], empty for the root
+	Path     string          // path from the root
+	Type     string          // type of the value
+	Value    interface{}     // the value of leaf, nil for containers
+	Cycle    *TemplateNode   // the ancestor referenced by the value if it's a cycle
+	Children []*TemplateNode // children of container in order
+	Parent   *TemplateNode
+
+	value  reflect.Value
+	byName map[string]*TemplateNode
+	byPath map[string]*TemplateNode // all nodes by path, shared by the tree
+}
+
+// IsLeaf returns whether the node is not a container
+func (n *TemplateNode) IsLeaf() bool {
+	return n.byName == nil
+}
+
+// Get returns the child with name (field name, [index] or [key]), nil if not found
+func (n *TemplateNode) Get(name string) *TemplateNode {
+	return n.byName[name]
+}
+
+// Lookup returns the node at path (as the Path of TemplateNode) of the tree, nil if not found
+func (n *TemplateNode) Lookup(path string) *TemplateNode {
+	return n.byPath[path]
+}
+
+func (n *TemplateNode) String() string {
+	if n.IsLeaf() {
+		return fmt.Sprint(n.Value)
+	}
+	return n.Type
+}
+
+// templateBuilder builds the tree of TemplateNode
+type templateBuilder struct {
+	goInAll
+	stack  []*TemplateNode
+	root   *TemplateNode
+	byPath map[string]*TemplateNode
+}
+
+func (b *templateBuilder) add(node *NodeInfo, val reflect.Value) *TemplateNode {
+	n := &TemplateNode{
+		Name:   pathLabel(node.Path),
+		Path:   node.Path.String(),
+		Type:   val.Type().String(),
+		value:  val,
+		byPath: b.byPath,
+	}
+	b.byPath[n.Path] = n
+	if len(b.stack) == 0 {
+		b.root = n
+		return n
+	}
+	n.Parent = b.stack[len(b.stack)-1]
+	n.Parent.Children = append(n.Parent.Children, n)
+	n.Parent.byName[n.Name] = n
+	return n
+}
+
+func (b *templateBuilder) ForNilPtr(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if !isMapKey(node.Path) {
+		b.add(node, val)
+	}
+	return nil
+}
+
+func (b *templateBuilder) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if !isMapKey(node.Path) {
+		n := b.add(node, val)
+		if val.CanInterface() {
+			n.Value = val.Interface()
+		}
+	}
+	return nil
+}
+
+func (b *templateBuilder) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, val reflect.Value) error {
+	b.add(node, val).Cycle = b.byPath[ancestor.Path.String()]
+	return nil
+}
+
+func (b *templateBuilder) container(node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if isMapKey(node.Path) {
+		return false, nil
+	}
+	if !startOrEnd {
+		if len(b.stack) == 0 {
+			return false, errors.New("template: container stack is empty")
+		}
+		b.stack = b.stack[:len(b.stack)-1]
+		return false, nil
+	}
+	n := b.add(node, val)
+	n.byName = make(map[string]*TemplateNode)
+	b.stack = append(b.stack, n)
+	return true, nil
+}
+
+func (b *templateBuilder) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *templateBuilder) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *templateBuilder) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+func (b *templateBuilder) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val)
+}
+
+// NewTemplateNode traverses obj and returns its root TemplateNode. Cycles are recorded in the Cycle
+// of nodes instead of being traversed.
+func NewTemplateNode(obj interface{}, conf ...*TraverseConf) (*TemplateNode, error) {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	b := &templateBuilder{byPath: make(map[string]*TemplateNode)}
+	tr, err := NewTraveller(b, c)
+	if err != nil {
+		return nil, err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return nil, err
+	}
+	if b.root == nil {
+		return nil, errors.New("template: nothing traversed")
+	}
+	return b.root, nil
+}
+
+// RenderTemplate executes tmpl with the root TemplateNode of obj, so that the layout of the output
+// is controlled by the template, e.g.:
+//
+//	{{range .Children}}{{.Name}}: {{.}}{{end}}
+//	{{(.Lookup "Users[0].Email").Value}}
+func RenderTemplate(w io.Writer, tmpl *template.Template, obj interface{}, conf ...*TraverseConf) error {
+	root, err := NewTemplateNode(obj, conf...)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, root)
+}
@@ -0,0 +1,69 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+)
+
+type (
+	tmplUser struct {
+		Name  string
+		Email string
+	}
+
+	tmplTeam struct {
+		Title  string
+		Users  []*tmplUser
+		Scores map[string]int
+	}
+)
+
+func TestRenderTemplate(t *testing.T) {
+	team := &tmplTeam{
+		Title:  "core",
+		Users:  []*tmplUser{{Name: "ann", Email: "ann@x"}, {Name: "bob", Email: "bob@x"}},
+		Scores: map[string]int{"z": 1, "a": 2},
+	}
+	tmpl := template.Must(template.New("team").Parse(
+		`{{.Get "Title"}}:{{range (.Get "Users").Children}} {{.Get "Name"}}{{end}};` +
+			`{{range (.Get "Scores").Children}} {{.Name}}={{.Value}}{{end}};` +
+			` {{(.Lookup "Users[1].Email").Value}} {{(.Lookup "Users[1]").Type}}`))
+	var buf bytes.Buffer
+	if err := RenderTemplate(&buf, tmpl, team); err != nil {
+		t.Fatal(err)
+	}
+	if want := "core: ann bob; [a]=2 [z]=1; bob@x dfpt.tmplUser"; buf.String() != want {
+		t.Fatalf("got %q want %q", buf.String(), want)
+	}
+}
+
+func TestTemplateNodeCycle(t *testing.T) {
+	loop := &listNode{V: 1}
+	loop.Next = loop
+	root, err := NewTemplateNode(loop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := root.Get("Next")
+	if next == nil || !next.IsLeaf() || next.Cycle != root || root.Lookup("Next") != next {
+		t.Fatalf("next: %+v", next)
+	}
+}
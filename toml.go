@@ -0,0 +1,390 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A TOML encoder built on the traversal, the root value must be a struct or a map:
+//
+//	struct/map: table, keys are field names (in the order given by the Propertier if any) or
+//	sorted map keys (must be strings). Values which are not tables or arrays of tables are written
+//	before sub-tables.
+//	slice/array: array of tables if all the elements are tables, otherwise inline array
+//	pointer: the pointed value, nil pointer (and nil interface) is omitted
+//	interface: the scalar value held
+//
+// Cycles are reported as errors since TOML has no references, values shared by more than one place
+// are written repeatedly.
+
+type (
+	tomlKind int
+
+	tomlNode struct {
+		kind     tomlKind
+		text     string      // scalar
+		keys     []string    // keys of table
+		children []*tomlNode // values of table, elements of array, or the pointed node
+	}
+
+	// tomlBuilder builds the tree of tomlNode, which is written after traversal since the values of
+	// a table should be written before its sub-tables.
+	tomlBuilder struct {
+		stack []*tomlNode
+		root  *tomlNode
+		key   *string // the key of the current map entry
+	}
+
+	tomlWriter struct {
+		w       *bufio.Writer
+		written bool
+	}
+)
+
+const (
+	tomlNull tomlKind = iota
+	tomlScalar
+	tomlArray
+	tomlTable
+	tomlPointer
+)
+
+// add appends n to the current container
+func (b *tomlBuilder) add(node *NodeInfo, n *tomlNode) error {
+	if len(b.stack) == 0 {
+		b.root = n
+		return nil
+	}
+	top := b.stack[len(b.stack)-1]
+	switch {
+	case top.kind != tomlTable:
+		top.children = append(top.children, n)
+	case isMapKey(node.Path):
+		if node.Value.Kind() != reflect.String {
+			return fmt.Errorf("toml: map key should be a string, but %s at %s", node.Value.Type(), node.Path)
+		}
+		key := node.Value.String()
+		b.key = &key
+	case node.Parent.Kind() == reflect.Map:
+		if b.key == nil {
+			return fmt.Errorf("toml: missing map key at %s", node.Path)
+		}
+		top.keys = append(top.keys, *b.key)
+		top.children = append(top.children, n)
+		b.key = nil
+	default:
+		top.keys = append(top.keys, node.Name)
+		top.children = append(top.children, n)
+	}
+	return nil
+}
+
+func (b *tomlBuilder) container(node *NodeInfo, startOrEnd bool, kind tomlKind) (bool, error) {
+	if !startOrEnd {
+		if len(b.stack) == 0 {
+			return false, errors.New("toml: container stack is empty")
+		}
+		b.stack = b.stack[:len(b.stack)-1]
+		return false, nil
+	}
+	if isMapKey(node.Path) {
+		return false, fmt.Errorf("toml: map key should be a string, but %s at %s", node.Value.Type(), node.Path)
+	}
+	n := &tomlNode{kind: kind}
+	if err := b.add(node, n); err != nil {
+		return false, err
+	}
+	b.stack = append(b.stack, n)
+	return true, nil
+}
+
+func (b *tomlBuilder) ForNilPtr(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	return b.add(node, &tomlNode{kind: tomlNull})
+}
+
+func (b *tomlBuilder) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	n, err := tomlScalarOf(val)
+	if err != nil {
+		return fmt.Errorf("%v at %s", err, node.Path)
+	}
+	return b.add(node, n)
+}
+
+func (b *tomlBuilder) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, _ reflect.Value) error {
+	return fmt.Errorf("toml: cycle found at %s referencing %s", node.Path, ancestor.Path)
+}
+
+func (b *tomlBuilder) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, tomlArray)
+}
+
+func (b *tomlBuilder) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, tomlTable)
+}
+
+func (b *tomlBuilder) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, tomlPointer)
+}
+
+func (b *tomlBuilder) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, tomlArray)
+}
+
+func (b *tomlBuilder) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, tomlTable)
+}
+
+func tomlScalarOf(val reflect.Value) (*tomlNode, error) {
+	switch val.Kind() {
+	case reflect.Bool:
+		return &tomlNode{kind: tomlScalar, text: strconv.FormatBool(val.Bool())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &tomlNode{kind: tomlScalar, text: strconv.FormatInt(val.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if val.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("toml: integer %d overflows", val.Uint())
+		}
+		return &tomlNode{kind: tomlScalar, text: strconv.FormatUint(val.Uint(), 10)}, nil
+	case reflect.Float32:
+		return &tomlNode{kind: tomlScalar, text: tomlFloat(val.Float(), 32)}, nil
+	case reflect.Float64:
+		return &tomlNode{kind: tomlScalar, text: tomlFloat(val.Float(), 64)}, nil
+	case reflect.String:
+		return &tomlNode{kind: tomlScalar, text: tomlQuote(val.String())}, nil
+	case reflect.Interface:
+		if val.IsNil() {
+			return &tomlNode{kind: tomlNull}, nil
+		}
+		return tomlScalarOf(val.Elem())
+	default:
+		return nil, fmt.Errorf("toml: unsupported type %s", val.Type())
+	}
+}
+
+func tomlFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// tomlQuote returns the basic string of s
+func tomlQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// tomlKey returns the bare key if possible, otherwise the quoted one
+func tomlKey(key string) string {
+	if key == "" {
+		return `""`
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return tomlQuote(key)
+		}
+	}
+	return key
+}
+
+// resolve returns the node pointed by n through pointers
+func (n *tomlNode) resolve() *tomlNode {
+	for n.kind == tomlPointer {
+		n = n.children[0]
+	}
+	return n
+}
+
+// isTables returns whether n is a non-empty array of tables
+func (n *tomlNode) isTables() bool {
+	if n.kind != tomlArray || len(n.children) == 0 {
+		return false
+	}
+	for _, c := range n.children {
+		if c.resolve().kind != tomlTable {
+			return false
+		}
+	}
+	return true
+}
+
+// inline returns the inline form of value n
+func (n *tomlNode) inline() (string, error) {
+	switch n = n.resolve(); n.kind {
+	case tomlScalar:
+		return n.text, nil
+	case tomlArray:
+		items := make([]string, 0, len(n.children))
+		for _, c := range n.children {
+			if c.resolve().kind == tomlNull {
+				return "", errors.New("toml: null in array")
+			}
+			s, err := c.inline()
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case tomlTable:
+		var items []string
+		for i, c := range n.children {
+			if c.resolve().kind == tomlNull {
+				continue
+			}
+			s, err := c.inline()
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(n.keys[i])+" = "+s)
+		}
+		if len(items) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("toml: unexpected node kind %d", n.kind)
+	}
+}
+
+func (tw *tomlWriter) line(s string) {
+	tw.w.WriteString(s)
+	tw.w.WriteString("\n")
+	tw.written = true
+}
+
+// table writes the table n with header of path (none for the root), values first, and then the
+// sub-tables and arrays of tables.
+func (tw *tomlWriter) table(path []string, n *tomlNode, array bool) error {
+	if len(path) > 0 {
+		if tw.written {
+			tw.w.WriteString("\n")
+		}
+		keys := make([]string, len(path))
+		for i, p := range path {
+			keys[i] = tomlKey(p)
+		}
+		if array {
+			tw.line("[[" + strings.Join(keys, ".") + "]]")
+		} else {
+			tw.line("[" + strings.Join(keys, ".") + "]")
+		}
+	}
+	var subs []int
+	for i, c := range n.children {
+		c = c.resolve()
+		if c.kind == tomlNull {
+			continue
+		}
+		if c.kind == tomlTable || c.isTables() {
+			subs = append(subs, i)
+			continue
+		}
+		s, err := c.inline()
+		if err != nil {
+			return fmt.Errorf("%v at %s", err, strings.Join(append(path, n.keys[i]), "."))
+		}
+		tw.line(tomlKey(n.keys[i]) + " = " + s)
+	}
+	for _, i := range subs {
+		sub := append(append([]string(nil), path...), n.keys[i])
+		c := n.children[i].resolve()
+		if c.kind == tomlTable {
+			if err := tw.table(sub, c, false); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, elem := range c.children {
+			if err := tw.table(sub, elem.resolve(), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// EncodeTOML writes obj (a struct or a map, or a pointer to it) to w as a TOML document. Struct
+// fields are selected by conf (Propertier, Version) if given, map entries are in the order of sorted
+// keys.
+func EncodeTOML(w io.Writer, obj interface{}, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.DetectCycles = true
+	c.AsyncLeaves = 0
+	builder := &tomlBuilder{}
+	tr, err := NewTraveller(builder, c)
+	if err != nil {
+		return err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return err
+	}
+	if builder.root == nil || builder.root.resolve().kind != tomlTable {
+		return errors.New("toml: root should be a struct or a map")
+	}
+	tw := &tomlWriter{w: bufio.NewWriter(w)}
+	if err = tw.table(nil, builder.root.resolve(), false); err != nil {
+		return err
+	}
+	return tw.w.Flush()
+}
@@ -0,0 +1,95 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"testing"
+)
+
+type (
+	tomlServer struct {
+		Host  string
+		Ports []int
+	}
+
+	tomlConf struct {
+		Title   string
+		Owner   *tomlServer
+		Servers []tomlServer
+		Backup  *tomlServer
+		Labels  map[string]string
+		Ratio   float64
+		Matrix  [][]int
+	}
+)
+
+func TestEncodeTOML(t *testing.T) {
+	c := tomlConf{
+		Title:   "say \"hi\"\n",
+		Owner:   &tomlServer{Host: "localhost"},
+		Servers: []tomlServer{{Host: "a", Ports: []int{80, 443}}, {Host: "b"}},
+		Labels:  map[string]string{"z": "last", "a.b": "dotted"},
+		Ratio:   2,
+		Matrix:  [][]int{{1}, {2, 3}},
+	}
+	var buf bytes.Buffer
+	if err := EncodeTOML(&buf, &c); err != nil {
+		t.Fatal(err)
+	}
+	want := `Title = "say \"hi\"\n"
+Ratio = 2.0
+Matrix = [[1], [2, 3]]
+
+[Owner]
+Host = "localhost"
+Ports = []
+
+[[Servers]]
+Host = "a"
+Ports = [80, 443]
+
+[[Servers]]
+Host = "b"
+Ports = []
+
+[Labels]
+"a.b" = "dotted"
+z = "last"
+`
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestEncodeTOMLErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeTOML(&buf, []int{1}); err == nil {
+		t.Fatal("root should be a table")
+	}
+	n := &listNode{V: 1}
+	n.Next = n
+	if err := EncodeTOML(&buf, n); err == nil {
+		t.Fatal("cycle should fail")
+	} else {
+		t.Log(err)
+	}
+	if err := EncodeTOML(&buf, map[int]int{1: 1}); err == nil {
+		t.Fatal("non-string key should fail")
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+)
+
+// Transform returns a modified copy of obj, which is traversed in place of obj, so that the
+// replacements returned by the leaf bindings with signature (newVal interface{}, changed bool, err
+// error) are written into the copy, and obj is left untouched. The copy shares nothing with obj
+// except unexported fields, map keys, and values not copyable (e.g. channels and functions).
+func (t *Traveller) Transform(obj interface{}) (interface{}, error) {
+	val := reflect.ValueOf(obj)
+	if !val.IsValid() {
+		return nil, nil
+	}
+	// an addressable copy, so that the values in it are settable
+	cp := reflect.New(val.Type()).Elem()
+	cp.Set(deepCopy(val, make(map[refKey]reflect.Value)))
+	if err := t.traverseValue(NewContext(), cp); err != nil {
+		return nil, err
+	}
+	return cp.Interface(), nil
+}
+
+// deepCopy returns a copy of val sharing nothing with it except unexported fields, map keys and
+// values not copyable (e.g. channels and functions), seen are the copies of pointers, maps and
+// slices copied before.
+func deepCopy(val reflect.Value, seen map[refKey]reflect.Value) reflect.Value {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if val.IsNil() {
+			return val
+		}
+		key, ok := referenceOf(val)
+		if ok {
+			if c, exist := seen[key]; exist {
+				return c
+			}
+		}
+		var ret reflect.Value
+		switch val.Kind() {
+		case reflect.Ptr:
+			ret = reflect.New(val.Type().Elem())
+			if ok {
+				seen[key] = ret
+			}
+			ret.Elem().Set(deepCopy(val.Elem(), seen))
+		case reflect.Map:
+			ret = reflect.MakeMapWithSize(val.Type(), val.Len())
+			if ok {
+				seen[key] = ret
+			}
+			iter := val.MapRange()
+			for iter.Next() {
+				ret.SetMapIndex(iter.Key(), deepCopy(iter.Value(), seen))
+			}
+		default:
+			ret = reflect.MakeSlice(val.Type(), val.Len(), val.Len())
+			if ok {
+				seen[key] = ret
+			}
+			for i := 0; i < val.Len(); i++ {
+				ret.Index(i).Set(deepCopy(val.Index(i), seen))
+			}
+		}
+		return ret
+	case reflect.Interface:
+		if val.IsNil() {
+			return val
+		}
+		ret := reflect.New(val.Type()).Elem()
+		ret.Set(deepCopy(val.Elem(), seen))
+		return ret
+	case reflect.Array:
+		ret := reflect.New(val.Type()).Elem()
+		for i := 0; i < val.Len(); i++ {
+			ret.Index(i).Set(deepCopy(val.Index(i), seen))
+		}
+		return ret
+	case reflect.Struct:
+		ret := reflect.New(val.Type()).Elem()
+		ret.Set(val)
+		for i := 0; i < val.NumField(); i++ {
+			if f := ret.Field(i); f.CanSet() {
+				f.Set(deepCopy(val.Field(i), seen))
+			}
+		}
+		return ret
+	default:
+		return val
+	}
+}
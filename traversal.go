@@ -18,48 +18,100 @@
 package dfpt
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
 )
 
 type Traveller struct {
-	adapter         reflect.Value
-	conf            *TraverseConf
-	prefixes        ItemTypes                      // group bindings run before all individually bindings
-	suffixes        ItemTypes                      // group bindings run after all individually bindings
-	shortcuts       map[ItemType]reflect.Value     // group bindings(ForNilPtr/ForIntX/ForUintX/ForAllKinds) -> binding methods
-	typeMethods     map[reflect.Type]reflect.Value // type -> method
-	kindMethods     map[reflect.Kind]reflect.Value // kind -> method
-	typeOrder       orderItems                     // all type list in order (tag order or declare order)
-	structTypeCache sync.Map
+	adapter     reflect.Value
+	conf        *TraverseConf
+	prefixes    ItemTypes                    // group bindings run before all individually bindings
+	suffixes    ItemTypes                    // group bindings run after all individually bindings
+	shortcuts   map[ItemType]boundMethod     // group bindings(ForNilPtr/ForNilSlice/ForNilMap/ForNilInterface/ForZero/ForIntX/ForUintX/ForFloatX/ForComplexX/ForNumber/ForAllKinds/ForReference/ForCycle/ForDefault/ForBytes) -> binding methods
+	typeMethods map[reflect.Type]boundMethod // type -> method
+	kindMethods map[reflect.Kind]boundMethod // kind -> method
+	typeOrder   orderItems                   // all type list in order (tag order or declare order)
+	guards      []guardedBinding             // predicate guarded bindings in the order of registrations
+	tagMethods  map[string]boundMethod       // lower-cased tag option -> ForTag binding
+	codecs      *sync.Map                    // codec name -> FieldCodec, shared by the copies of WithConf
+	leafStats   *leafStats                   // for the estimates of TraverseConf.AsyncMinLeaves
+
+	mapKeyMethods   map[reflect.Kind]boundMethod // kind -> ForMapKeyYYYY binding
+	mapValueMethods map[reflect.Kind]boundMethod // kind -> ForMapValueYYYY binding
+	namedMethods    map[reflect.Kind]boundMethod // kind -> ForNamedYYYY binding
+}
+
+// guardedBinding is a leaf binding called for the values its predicate returns true
+type guardedBinding struct {
+	when    func(reflect.Value) bool
+	match   NodePredicate
+	binding boundMethod
 }
 
+// FieldCodec processes a struct field tagged with `dfpt:"codec=name"` instead of the bindings of
+// the adapter, the field will not be traversed further.
+type FieldCodec func(ctx *TravContext, node *NodeInfo, val reflect.Value) error
+
 func NewTraveller(adapter interface{}, config ...*TraverseConf) (*Traveller, error) {
 	aptVal := reflect.ValueOf(adapter)
 	if !aptVal.IsValid() {
 		return nil, ErrInvalidAdapter
 	}
-	aptType := aptVal.Type()
+	methods, err := adapterMethods(aptVal)
+	if err != nil {
+		return nil, err
+	}
 	var items orderItems
-	shortcuts := make(map[ItemType]reflect.Value)
-	typeMethods := make(map[reflect.Type]reflect.Value)
-	kindMethods := make(map[reflect.Kind]reflect.Value)
-	for i := 0; i < aptType.NumMethod(); i++ {
-		m := aptType.Method(i)
+	shortcuts := make(map[ItemType]boundMethod)
+	typeMethods := make(map[reflect.Type]boundMethod)
+	kindMethods := make(map[reflect.Kind]boundMethod)
+	var guards []guardedBinding
+	tagMethods := make(map[string]boundMethod)
+	groupMethods := map[ItemType]map[reflect.Kind]boundMethod{
+		ForMapKey:   make(map[reflect.Kind]boundMethod),
+		ForMapValue: make(map[reflect.Kind]boundMethod),
+		ForNamed:    make(map[reflect.Kind]boundMethod),
+	}
+	for i, m := range methods {
 		itype, inKind, ok := Unknown.Which(m.Name)
 		if !ok {
 			continue
 		}
-		if !itype.IsValidWithReceiver(m) {
+		valid, v2 := m.checked, false
+		if !valid {
+			valid, v2 = itype.Signature(m.Method)
+		}
+		if !valid {
+			if m.strict {
+				return nil, fmt.Errorf("invalid signature %s of binding %s", m.fn.Type(), m.Name)
+			}
 			continue
 		}
 		fType := m.Func.Type()
+		bound := boundMethod{fn: m.fn, itype: itype, v2: v2, writeBack: v2 && isWriteBack(fType)}
+		if !v2 {
+			ptype := fType.In(itype.PropertyIndex(false))
+			var accept bool
+			if bound.property, accept = itype.acceptProperty(inKind, ptype); !accept {
+				if m.strict {
+					return nil, fmt.Errorf("binding %s can not accept the values with Property of %s", m.Name, ptype)
+				}
+				continue
+			}
+		}
 		switch itype {
 		case ForImpl, ForAssign:
-			inType := fType.In(itype.ParamLength())
+			inType := fType.In(itype.PropertyIndex(v2))
+			if m.want != nil && inType != m.want {
+				return nil, fmt.Errorf("binding %s expects %s, but %s", m.Name, m.want, inType)
+			}
 			if _, exist := typeMethods[inType]; exist {
 				return nil, fmt.Errorf("duplicated binding function %s found for Type:%s", m.Name, inType.Name())
 			}
@@ -71,7 +123,7 @@ func NewTraveller(adapter interface{}, config ...*TraverseConf) (*Traveller, err
 				c: false, // there's no possibility of further in-depth analysis with explicit type binding
 				k: reflect.Invalid,
 			})
-			typeMethods[inType] = aptVal.Method(i)
+			typeMethods[inType] = bound
 		case ForKind, ForContainer:
 			if _, exist := kindMethods[inKind]; exist {
 				return nil, fmt.Errorf("duplicated binding function %s found for Kind:%s", m.Name, inKind.String())
@@ -84,17 +136,43 @@ func NewTraveller(adapter interface{}, config ...*TraverseConf) (*Traveller, err
 				c: itype == ForContainer,
 				k: inKind,
 			})
-			kindMethods[inKind] = aptVal.Method(i)
-		case ForNilPtr, ForIntX, ForUintX, ForAllKinds:
+			kindMethods[inKind] = bound
+		case ForTag:
+			if !v2 {
+				continue
+			}
+			option := strings.ToLower(m.Name[len(TagPrefix):])
+			if _, exist := tagMethods[option]; exist {
+				return nil, fmt.Errorf("duplicated binding function %s found for tag option %s", m.Name, option)
+			}
+			tagMethods[option] = bound
+		case ForMapKey, ForMapValue, ForNamed:
+			if _, exist := groupMethods[itype][inKind]; exist {
+				return nil, fmt.Errorf("duplicated binding function %s found for Kind:%s", m.Name, inKind.String())
+			}
+			groupMethods[itype][inKind] = bound
+		case ForNilPtr, ForNilSlice, ForNilMap, ForNilInterface, ForZero,
+			ForIntX, ForUintX, ForFloatX, ForComplexX, ForNumber, ForAllKinds, ForReference, ForCycle, ForDefault,
+			ForBytes, ForMapEntry, ForPlaceholder:
+			if m.when != nil || m.match != nil {
+				guards = append(guards, guardedBinding{when: m.when, match: m.match, binding: bound})
+				continue
+			}
 			if _, exist := shortcuts[itype]; exist {
 				return nil, fmt.Errorf("duplicated binding function %s found", m.Name)
 			}
-			shortcuts[itype] = aptVal.Method(i)
+			shortcuts[itype] = bound
 		}
 	}
-	if len(items) == 0 && len(shortcuts) == 0 {
+	if len(items) == 0 && len(shortcuts) == 0 && len(guards) == 0 && len(tagMethods) == 0 &&
+		len(groupMethods[ForMapKey]) == 0 && len(groupMethods[ForMapValue]) == 0 && len(groupMethods[ForNamed]) == 0 {
 		return nil, errors.New("no available binding function found")
 	}
+	if orderer, ok := adapter.(BindingOrderer); ok {
+		if err = items.applyOrder(orderer.Order()); err != nil {
+			return nil, err
+		}
+	}
 	sort.Sort(items)
 	var conf *TraverseConf
 	if len(config) > 0 && config[0] != nil {
@@ -121,6 +199,14 @@ func NewTraveller(adapter interface{}, config ...*TraverseConf) (*Traveller, err
 		typeMethods: typeMethods,
 		kindMethods: kindMethods,
 		typeOrder:   items,
+		guards:      guards,
+		tagMethods:  tagMethods,
+		codecs:      new(sync.Map),
+		leafStats:   newLeafStats(),
+
+		mapKeyMethods:   groupMethods[ForMapKey],
+		mapValueMethods: groupMethods[ForMapValue],
+		namedMethods:    groupMethods[ForNamed],
 	}, nil
 }
 
@@ -145,36 +231,85 @@ func (t *Traveller) _call(ctx *TravContext, parent *parentInfo, val reflect.Valu
 		return false, false, nil, reflect.Value{}, errors.New("invalid value")
 	}
 
+	// bindings of the tag options of struct fields
+	if m, ok := t._tagBinding(parent); ok {
+		err = t._callLeaf(ctx, parent, m, val)
+		return false, false, nil, reflect.Value{}, err
+	}
+
 	// prefix shortcuts
 	for _, itype := range t.prefixes {
 		if itype.MatchValue(val) {
-			method := t.shortcuts[itype]
-			outs := method.Call(parent.callIns(ctx, val))
-			_, err = itype.parseReturns(outs)
+			err = t._callLeaf(ctx, parent, t.shortcuts[itype], val)
 			return false, false, nil, reflect.Value{}, err
 		}
 	}
 
-	for i, item := range t.typeOrder {
-		itype, typ, kind, match := item.match(val)
-		if !match {
+	// predicate guarded bindings
+	var node *NodeInfo
+	for _, g := range t.guards {
+		if g.when != nil && !g.when(val) {
 			continue
 		}
-		var outs []reflect.Value
+		if g.match != nil {
+			if node == nil {
+				node = parent.nodeInfo(val, 0, false)
+			}
+			if !g.match(node) {
+				continue
+			}
+		}
+		err = t._callLeaf(ctx, parent, g.binding, val)
+		return false, false, nil, reflect.Value{}, err
+	}
+
+	// bindings of the keys and values of map entries
+	if m, ok := t._mapBinding(parent, val); ok {
+		err = t._callLeaf(ctx, parent, m, val)
+		return false, false, nil, reflect.Value{}, err
+	}
+
+	// byte slices and arrays as leaves, unless their types are bound explicitly
+	if m, ok := t.shortcuts[ForBytes]; ok && t._isBytes(val) {
+		if _, typed := t.typeMethods[val.Type()]; !typed {
+			err = t._callLeaf(ctx, parent, m, val)
+			return false, false, nil, reflect.Value{}, err
+		}
+	}
+
+	// bindings of named types, unless the types are bound explicitly
+	if m, ok := t.namedMethods[val.Kind()]; ok && isNamedType(val.Type()) {
+		if _, typed := t.typeMethods[val.Type()]; !typed {
+			err = t._callLeaf(ctx, parent, m, val)
+			return false, false, nil, reflect.Value{}, err
+		}
+	}
+
+	canAddr := t.conf != nil && t.conf.Addressable && val.CanAddr()
+	if i, item, typ, kind, byAddr, match := t._match(val, canAddr); match {
 		if typ != nil {
 			fVal, ok := t.typeMethods[typ]
-			if !ok || !fVal.IsValid() {
+			if !ok || !fVal.fn.IsValid() {
 				panic(fmt.Errorf("matching %d item %s, but function not found by Type:%s", i, item, typ.Name()))
 			}
-			outs = fVal.Call(parent.callIns(ctx, val))
+			if byAddr {
+				err = t._callLeaf(ctx, parent, fVal, val.Addr())
+			} else if typ.Kind() != reflect.Interface && !val.Type().AssignableTo(typ) {
+				err = t._callConverted(ctx, parent, fVal, val, typ)
+			} else {
+				err = t._callLeaf(ctx, parent, fVal, val)
+			}
 		} else if kind != reflect.Invalid {
 			fVal, ok := t.kindMethods[kind]
-			if !ok || !fVal.IsValid() {
+			if !ok || !fVal.fn.IsValid() {
 				panic(fmt.Errorf("matching %d item %s, but function not found by Kind:%s", i, item, kind.String()))
 			}
 			if _, isContainer := _containers[kind]; isContainer {
 				var size int
 				var fields []Property
+				var oneofs map[string]string
+				var skips map[int]struct{}
+				_, byEntry := t.shortcuts[ForMapEntry]
 				switch kind {
 				case reflect.Array:
 					size = val.Len()
@@ -184,14 +319,23 @@ func (t *Traveller) _call(ctx *TravContext, parent *parentInfo, val reflect.Valu
 					}
 				case reflect.Map:
 					if !val.IsNil() {
-						size = val.Len() << 1
+						size = val.Len()
+						if !byEntry {
+							size <<= 1
+						}
 					}
 				case reflect.Struct:
 					size, fields = t._structProperties(val)
-				case reflect.Ptr:
+					oneofs, skips, err = structInfo(val.Type()).selectOneOf(val, fields)
+					if err != nil {
+						return false, false, nil, reflect.Value{}, err
+					}
+				case reflect.Ptr, reflect.Interface:
 					if !val.IsNil() {
 						size = 1
 					}
+				case reflect.Chan:
+					size = t._chanSize(val)
 				}
 				info = &parentInfo{
 					depth:        parent.nextDepth(),
@@ -200,15 +344,24 @@ func (t *Traveller) _call(ctx *TravContext, parent *parentInfo, val reflect.Valu
 					offset:       -1,
 					structFields: fields,
 					binding:      fVal,
+					oneofs:       oneofs,
+					oneofSkips:   skips,
+					byEntry:      byEntry && kind == reflect.Map,
+				}
+				info.path = parent.childPath()
+				info.trail = parent.childTrail()
+				info.seq = ctx.seq()
+				info.samples = t._sample(info)
+				goin, err = fVal.callContainer(ctx, parent, info, true, val)
+				if errors.Is(err, ErrSkipContainer) {
+					goin, err = false, nil
 				}
-				outs = fVal.Call(parent.startContainerIns(ctx, info, val))
 			} else {
-				outs = fVal.Call(parent.callIns(ctx, val))
+				err = t._callLeaf(ctx, parent, fVal, val)
 			}
 		} else {
 			panic(fmt.Errorf("SHOULD NOT BE HERE!! matching %d item %s, Kind:%s", i, item, kind.String()))
 		}
-		goin, err = itype.parseReturns(outs)
 		if err != nil {
 			return false, false, nil, reflect.Value{}, err
 		}
@@ -226,15 +379,36 @@ func (t *Traveller) _call(ctx *TravContext, parent *parentInfo, val reflect.Valu
 			}
 		}
 	}
+	if t.conf != nil && t.conf.InterfaceAutoGoIn && val.Kind() == reflect.Interface {
+		// no callback for Interface
+		if val.IsNil() {
+			return false, false, parent, reflect.Value{}, nil
+		}
+		return false, true, parent, val.Elem(), nil
+	}
+	if t.conf != nil && t.conf.LazyAutoGoIn && isLazyFunc(val.Type()) {
+		// no callback for lazy value provider
+		if val.IsNil() {
+			return false, false, parent, reflect.Value{}, nil
+		}
+		outs := val.Call(nil)
+		if len(outs) == 2 && !outs[1].IsNil() {
+			return false, false, nil, reflect.Value{}, outs[1].Interface().(error)
+		}
+		return false, true, parent, outs[0], nil
+	}
 	// suffix shortcuts
 	for _, itype := range t.suffixes {
 		if itype.MatchValue(val) {
-			method := t.shortcuts[itype]
-			outs := method.Call(parent.callIns(ctx, val))
-			_, err = itype.parseReturns(outs)
+			err = t._callLeaf(ctx, parent, t.shortcuts[itype], val)
 			return false, false, nil, reflect.Value{}, err
 		}
 	}
+	// catch-all binding
+	if m, ok := t.shortcuts[ForDefault]; ok {
+		err = t._callLeaf(ctx, parent, m, val)
+		return false, false, nil, reflect.Value{}, err
+	}
 	// emit error if there's no flag for ignoring
 	if t.conf == nil || !t.conf.IgnoreMissedBinding {
 		return false, false, nil, reflect.Value{},
@@ -243,12 +417,140 @@ func (t *Traveller) _call(ctx *TravContext, parent *parentInfo, val reflect.Valu
 	return false, false, nil, reflect.Value{}, nil
 }
 
+// _isBytes returns whether val is a byte slice, or a byte array with TraverseConf.BytesArrays
+func (t *Traveller) _isBytes(val reflect.Value) bool {
+	if ForBytes.MatchValue(val) {
+		return true
+	}
+	return t.conf != nil && t.conf.BytesArrays && val.Kind() == reflect.Array && val.Type().Elem().Kind() == reflect.Uint8
+}
+
+// _mapBinding returns the ForMapKeyYYYY or ForMapValueYYYY binding of val if it's the key or the
+// value of a map entry
+func (t *Traveller) _mapBinding(parent *parentInfo, val reflect.Value) (boundMethod, bool) {
+	if (len(t.mapKeyMethods) == 0 && len(t.mapValueMethods) == 0) || !parent.isValid() ||
+		parent.value.Kind() != reflect.Map {
+		return boundMethod{}, false
+	}
+	methods := t.mapValueMethods
+	if parent.offset%2 == 0 {
+		methods = t.mapKeyMethods
+	}
+	m, ok := methods[val.Kind()]
+	return m, ok
+}
+
+// _tagBinding returns the ForTag binding of the tag options of the current field of parent, the
+// one of the least option name if more than one are bound.
+func (t *Traveller) _tagBinding(parent *parentInfo) (boundMethod, bool) {
+	if len(t.tagMethods) == 0 || !parent.isValid() || parent.value.Kind() != reflect.Struct ||
+		parent.offset < 0 || parent.offset >= len(parent.structFields) {
+		return boundMethod{}, false
+	}
+	index := parent.structFields[parent.offset].Index
+	if index < 0 {
+		return boundMethod{}, false
+	}
+	var found boundMethod
+	name := ""
+	for option := range structInfo(parent.value.Type()).options[index] {
+		option = strings.ToLower(option)
+		if m, ok := t.tagMethods[option]; ok && (name == "" || option < name) {
+			found, name = m, option
+		}
+	}
+	return found, name != ""
+}
+
+// _match returns the first item of typeOrder matching val (or its address if canAddr), or the most
+// specific one with MatchMostSpecific policy.
+func (t *Traveller) _match(val reflect.Value, canAddr bool) (index int, item orderItem, typ reflect.Type,
+	kind reflect.Kind, byAddr, match bool) {
+	mostSpecific := t.conf != nil && t.conf.MatchPolicy == MatchMostSpecific
+	best := -1
+	for i, it := range t.typeOrder {
+		_, ityp, ikind, ok := it.match(val)
+		addr := false
+		if !ok && canAddr && it.t != nil {
+			_, ityp, ikind, ok = it.match(val.Addr())
+			addr = ok
+		}
+		if !ok && t.conf != nil && t.conf.ConvertibleAssign && it.convertible(val) {
+			ityp, ikind, ok = it.t, reflect.Invalid, true
+		}
+		if !ok {
+			continue
+		}
+		if !mostSpecific {
+			return i, it, ityp, ikind, addr, true
+		}
+		target := val.Type()
+		if addr {
+			target = val.Addr().Type()
+		}
+		if rank := it.specificity(target); best < 0 || rank < best {
+			best = rank
+			index, item, typ, kind, byAddr, match = i, it, ityp, ikind, addr, true
+		}
+	}
+	return
+}
+
 func (t *Traveller) _structProperties(val reflect.Value) (int, []Property) {
+	return structProperties(t.conf, val)
+}
+
+// structProperties returns the properties of the struct val, without the field tagged with
+// `dfpt:"unknown"`, which is filled by Populate from UnknownSource instead of a slot
+func structProperties(conf *TraverseConf, val reflect.Value) (int, []Property) {
+	size, props := filteredProperties(conf, val)
+	if val.IsValid() {
+		if unknown := structInfo(val.Type()).unknown; unknown >= 0 {
+			size, props = withoutField(size, props, unknown)
+		}
+	}
+	if conf != nil && len(conf.FieldNames) > 0 && len(props) > 0 {
+		props = conf.FieldNames.rename(val.Type(), props)
+	}
+	return size, props
+}
+
+// withoutField returns the properties without the one of the field index, it's replaced by a
+// placeholder if it takes a fixed slot (e.g. given by SlotPropertier), so the other slots are kept.
+func withoutField(size int, props []Property, index int) (int, []Property) {
+	for i, prop := range props {
+		if prop.Index != index {
+			continue
+		}
+		ret := make([]Property, 0, len(props))
+		ret = append(ret, props[:i]...)
+		if prop.IndexForReal >= 0 {
+			ret = append(ret, Property{Index: -1, IndexForReal: prop.IndexForReal})
+		} else {
+			size--
+		}
+		return size, append(ret, props[i+1:]...)
+	}
+	return size, props
+}
+
+// filteredProperties returns the properties of the struct val filtered by versions and roles
+func filteredProperties(conf *TraverseConf, val reflect.Value) (int, []Property) {
 	if !val.IsValid() {
 		return 0, nil
 	}
-	if t.conf != nil && t.conf.Propertier != nil {
-		return t.conf.Propertier.Properties(val)
+	if conf != nil && conf.Roles != nil {
+		propertier := conf.Propertier
+		if conf.Version != 0 {
+			propertier = VersionedPropertier{Propertier: conf.Propertier, Version: conf.Version}
+		}
+		return RolePropertier{Propertier: propertier, Roles: conf.Roles}.Properties(val)
+	}
+	if conf != nil && conf.Version != 0 {
+		return VersionedPropertier{Propertier: conf.Propertier, Version: conf.Version}.Properties(val)
+	}
+	if conf != nil && conf.Propertier != nil {
+		return conf.Propertier.Properties(val)
 	}
 	var ps []Property
 	typ := val.Type()
@@ -264,10 +566,76 @@ func (t *Traveller) _structProperties(val reflect.Value) (int, []Property) {
 	return len(ps), ps
 }
 
-func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.Value) error {
+func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.Value) (err error) {
+	if t.conf == nil || !t.conf.BestEffort {
+		return t._traverseNode(ctx, parent, val)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		err = t._tolerate(ctx, parent, err)
+	}()
+	return t._traverseNode(ctx, parent, val)
+}
+
+// _tolerate records the non-fatal error of the current child of parent and returns nil in
+// BestEffort mode
+func (t *Traveller) _tolerate(ctx *TravContext, parent *parentInfo, err error) error {
+	if err == nil || t.conf == nil || !t.conf.BestEffort || isFatal(err) || errors.Is(err, ErrSkipContainer) {
+		return err
+	}
+	ctx.diagnose(parent.childPath(), err)
+	return nil
+}
+
+// _tolerateLeaf is _tolerate for the asynchronous leaf binding of path, a panic of which is
+// recovered as its error
+func (t *Traveller) _tolerateLeaf(ctx *TravContext, path Path, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic: %v", r)
+	}
+	if *err != nil && !isFatal(*err) && !errors.Is(*err, ErrSkipContainer) {
+		ctx.diagnose(path, *err)
+		*err = nil
+	}
+}
+
+// isFatal returns whether the error should stop the traversal even in BestEffort mode
+func isFatal(err error) bool {
+	return errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrDeadlineExceeded) || errors.Is(err, ErrStopTraversal) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (t *Traveller) _traverseNode(ctx *TravContext, parent *parentInfo, val reflect.Value) error {
 	if !val.IsValid() {
 		return fmt.Errorf("invalid value in _traverse(parent:%s, val:%s)", parent, val.String())
 	}
+	if ctx.resume != nil && ctx.skipTo(parent.childTrail()) {
+		return nil
+	}
+	if t.conf != nil && len(t.conf.TypeBudgets) > 0 {
+		if budget, ok := t.conf.TypeBudgets[val.Type()]; ok && !isNilValue(val) && !ctx.countType(val.Type(), budget) {
+			return nil
+		}
+	}
+	if err := ctx.visit(parent.currentDepth()); err != nil {
+		return ctx.stopped(err, parent)
+	}
+	if t.conf != nil && t.conf.DetectCycles {
+		key, ancestor, ok := ctx.enter(val, func() *NodeInfo { return parent.nodeInfo(val, 0, false) })
+		if ancestor != nil {
+			return t._callCycle(ctx, parent, ancestor, val)
+		}
+		if ok {
+			defer ctx.leave(key)
+		}
+	}
+	if t.conf != nil && t.conf.TrackReferences {
+		if target, exist := ctx.reference(val, parent.childPath); exist {
+			return t._callReference(ctx, parent, target, val)
+		}
+	}
 	var next *parentInfo
 	var goin, reEnter bool
 	var err error
@@ -276,6 +644,7 @@ func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.
 	for {
 		goin, reEnter, next, newVal, err = t._call(ctx, parent, oldVal)
 		if err != nil {
+			locateViolation(err, parent)
 			return err
 		}
 		if reEnter {
@@ -293,8 +662,58 @@ func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.
 		}
 		break
 	}
+	estimating := ctx.workers != nil && t.conf.AsyncMinLeaves > 0
+	if ctx.workers != nil && (!estimating || t.leafStats.estimate(next) >= t.conf.AsyncMinLeaves) {
+		next.leaves = &leafGroup{}
+	}
+	err = t._children(ctx, next, oldVal)
+	if estimating && err == nil {
+		t.leafStats.record(oldVal.Type(), next.size, next.leafCalls)
+	}
+	if errors.Is(err, ErrSkipContainer) {
+		// remaining children skipped
+		err = nil
+	}
+	if next.leaves != nil {
+		// join asynchronous leaf bindings before the end of the container
+		if werr := next.leaves.wait(); err == nil {
+			err = werr
+		}
+	}
+	if len(next.entries) > 0 {
+		next.storeEntries()
+	}
+	if err != nil {
+		return err
+	}
+	ctx.setDepth(parent.currentDepth())
+	if t.conf != nil && t.conf.ContainerEnd {
+		_, err = next.binding.callContainer(ctx, parent, next, false, oldVal)
+		locateViolation(err, parent)
+		if errors.Is(err, ErrStopTraversal) {
+			return err
+		}
+		if err != nil && !errors.Is(err, ErrSkipContainer) {
+			return fmt.Errorf("call container end failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// _children traverses all children of the container value
+func (t *Traveller) _children(ctx *TravContext, next *parentInfo, oldVal reflect.Value) error {
+	var err error
 	switch oldVal.Kind() {
 	case reflect.Array, reflect.Slice:
+		if next.samples != nil {
+			for _, i := range next.samples {
+				next.offset = i
+				if err = t._traverse(ctx, next, oldVal.Index(i)); err != nil {
+					return err
+				}
+			}
+			break
+		}
 		for i := 0; i < next.size; i++ {
 			child := oldVal.Index(i)
 			next.offset = i
@@ -305,16 +724,34 @@ func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.
 	case reflect.Map:
 		if next.size > 0 {
 			keys := oldVal.MapKeys()
+			if t.conf != nil && t.conf.SortMapKeys {
+				sortValues(keys)
+			}
+			if next.byEntry {
+				return t._mapEntries(ctx, next, oldVal, keys)
+			}
 			if len(keys)<<1 != next.size {
 				panic(fmt.Errorf("next:%s but len(keys)==%d", next, len(keys)))
 			}
+			if next.samples != nil {
+				sampled := make([]reflect.Value, len(next.samples))
+				for j, i := range next.samples {
+					sampled[j] = keys[i]
+				}
+				keys = sampled
+			}
 			for i := 0; i < len(keys); i++ {
 				// stack value for map: idx%2==0 is the key of map, idx%2==1 is the value of map
 				next.offset = i << 1
+				next.mapKey = keys[i]
 				if err = t._traverse(ctx, next, keys[i]); err != nil {
 					return err
 				}
 				value := oldVal.MapIndex(keys[i])
+				if t.conf != nil && t.conf.Addressable {
+					value = addressable(value)
+					next.entries = append(next.entries, keys[i], value)
+				}
 				next.offset = i<<1 + 1
 				if err = t._traverse(ctx, next, value); err != nil {
 					return err
@@ -322,18 +759,40 @@ func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.
 			}
 		}
 	case reflect.Struct:
+		sinfo := structInfo(oldVal.Type())
 		for i := 0; i < len(next.structFields); i++ {
 			field := next.structFields[i]
 			if field.Index < 0 {
+				if m, ok := t.shortcuts[ForPlaceholder]; ok {
+					next.offset = i
+					if err = t._tolerate(ctx, next, t._callPlaceholder(ctx, next, m)); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if _, skip := next.oneofSkips[field.Index]; skip {
 				continue
 			}
 			fieldVal := oldVal.Field(field.Index)
+			if t.conf != nil && t.conf.SkipZeroValues && isEmptyValue(fieldVal) {
+				continue
+			}
 			next.offset = i
-			if err = t._traverse(ctx, next, fieldVal); err != nil {
+			if name := sinfo.codecs[field.Index]; name != "" {
+				err = t._tolerate(ctx, next, t._callCodec(ctx, next, name, fieldVal))
+			} else {
+				err = t._traverse(ctx, next, fieldVal)
+			}
+			if err != nil {
 				return err
 			}
 		}
-	case reflect.Ptr:
+	case reflect.Chan:
+		if next.size > 0 {
+			return t._drain(ctx, next, oldVal)
+		}
+	case reflect.Ptr, reflect.Interface:
 		if next.size > 0 {
 			elem := oldVal.Elem()
 			next.offset = 0
@@ -344,20 +803,463 @@ func (t *Traveller) _traverse(ctx *TravContext, parent *parentInfo, val reflect.
 	default:
 		panic("unknown status")
 	}
-	if t.conf != nil && t.conf.ContainerEnd {
-		outs := next.binding.Call(parent.endContainerIns(ctx, next, oldVal))
-		_, err = ForContainer.parseReturns(outs)
-		if err != nil {
-			return fmt.Errorf("call container end failed: %v", err)
+	return nil
+}
+
+// _mapEntries calls the ForMapEntry binding with each entry of the map oldVal in the order of keys
+func (t *Traveller) _mapEntries(ctx *TravContext, next *parentInfo, oldVal reflect.Value, keys []reflect.Value) error {
+	if len(keys) != next.size {
+		panic(fmt.Errorf("next:%s but len(keys)==%d", next, len(keys)))
+	}
+	m := t.shortcuts[ForMapEntry]
+	indexes := next.samples
+	if indexes == nil {
+		indexes = make([]int, len(keys))
+		for i := range indexes {
+			indexes[i] = i
+		}
+	}
+	for _, i := range indexes {
+		next.offset = i
+		next.mapKey = keys[i]
+		value := oldVal.MapIndex(keys[i])
+		if t.conf != nil && t.conf.Addressable {
+			value = addressable(value)
+			next.entries = append(next.entries, keys[i], value)
+		}
+		if err := t._tolerate(ctx, next, t._callMapEntry(ctx, next, m, keys[i], value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// _callPlaceholder calls the ForPlaceholder binding m with the current placeholder of the struct
+func (t *Traveller) _callPlaceholder(ctx *TravContext, parent *parentInfo, m boundMethod) error {
+	var ins []reflect.Value
+	if m.v2 {
+		node := parent.nodeInfo(reflect.Value{}, 0, false)
+		node.Seq = ctx.seq()
+		ins = []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node)}
+	} else {
+		index, _ := parent.leafPosition()
+		ins = []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(parent.currentDepth()), reflect.ValueOf(index)}
+	}
+	_, err := ForPlaceholder.parseReturns(m.fn.Call(ins))
+	locateViolation(err, parent)
+	return err
+}
+
+// _callMapEntry calls the ForMapEntry binding m with the current entry of the map
+func (t *Traveller) _callMapEntry(ctx *TravContext, parent *parentInfo, m boundMethod, key, value reflect.Value) error {
+	if ctx.resume != nil && ctx.skipTo(parent.childTrail()) {
+		return nil
+	}
+	if err := ctx.visit(parent.currentDepth()); err != nil {
+		return ctx.stopped(err, parent)
+	}
+	var ins []reflect.Value
+	if m.v2 {
+		node := parent.nodeInfo(value, 0, false)
+		node.Seq = ctx.seq()
+		ins = []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node), reflect.ValueOf(key), reflect.ValueOf(value)}
+	} else {
+		ins = []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(parent.currentDepth()),
+			reflect.ValueOf(parent.offset), reflect.ValueOf(""), key, value}
+	}
+	_, err := ForMapEntry.parseReturns(m.fn.Call(ins))
+	locateViolation(err, parent)
+	return err
+}
+
+// _chanSize returns the number of the elements of the channel val to be traversed
+func (t *Traveller) _chanSize(val reflect.Value) int {
+	if t.conf == nil || t.conf.ChanLimit <= 0 || val.IsNil() || val.Type().ChanDir() != reflect.BothDir {
+		return 0
+	}
+	if n := val.Len(); n < t.conf.ChanLimit {
+		return n
+	}
+	return t.conf.ChanLimit
+}
+
+// _drain receives the buffered elements of the channel, traverses the first next.size of them, and
+// sends them back in order, ErrChanNotRestored is returned if some of them can't be sent back.
+func (t *Traveller) _drain(ctx *TravContext, next *parentInfo, ch reflect.Value) (err error) {
+	n := ch.Len()
+	elems := make([]reflect.Value, 0, n)
+	for len(elems) < n {
+		elem, ok := ch.TryRecv()
+		if !ok {
+			break
+		}
+		elems = append(elems, elem)
+	}
+	defer func() {
+		if serr := sendBack(ch, elems); serr != nil && err == nil {
+			err = serr
+		}
+	}()
+	for i := 0; i < next.size && i < len(elems); i++ {
+		next.offset = i
+		if err = t._traverse(ctx, next, elems[i]); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (t *Traveller) Traverse(ctx *TravContext, obj interface{}) error {
-	val := reflect.ValueOf(obj)
+// sendBack sends elems to the channel ch in order without blocking, and returns ErrChanNotRestored
+// if the channel is full or closed (sending to which panics) before all of them are sent.
+func sendBack(ch reflect.Value, elems []reflect.Value) (err error) {
+	sent := 0
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %d of %d elements of %s lost: %v", ErrChanNotRestored, len(elems)-sent,
+				len(elems), ch.Type(), r)
+		}
+	}()
+	for ; sent < len(elems); sent++ {
+		if !ch.TrySend(elems[sent]) {
+			return fmt.Errorf("%w: %d of %d elements of %s lost: channel is full", ErrChanNotRestored,
+				len(elems)-sent, len(elems), ch.Type())
+		}
+	}
+	return nil
+}
+
+// _sample returns the sorted indexes of the elements (entries for maps) of the array, slice or map
+// container to be visited, nil if all of them should be visited.
+func (t *Traveller) _sample(info *parentInfo) []int {
+	if t.conf == nil || t.conf.SampleSize <= 0 {
+		return nil
+	}
+	n := info.size
+	switch info.value.Kind() {
+	case reflect.Array, reflect.Slice:
+	case reflect.Map:
+		if !info.byEntry {
+			n >>= 1
+		}
+	default:
+		return nil
+	}
+	if n <= t.conf.SampleSize {
+		return nil
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(info.path.String()))
+	rnd := rand.New(rand.NewSource(t.conf.SampleSeed ^ int64(h.Sum64())))
+	return sampleIndexes(rnd, n, t.conf.SampleSize)
+}
+
+// sampleIndexes returns k distinct indexes in [0, n) chosen by rnd in ascending order, with Floyd's
+// algorithm which costs O(k) no matter how large n is.
+func sampleIndexes(rnd *rand.Rand, n, k int) []int {
+	chosen := make(map[int]struct{}, k)
+	ret := make([]int, 0, k)
+	for j := n - k; j < n; j++ {
+		i := rnd.Intn(j + 1)
+		if _, ok := chosen[i]; ok {
+			i = j
+		}
+		chosen[i] = struct{}{}
+		ret = append(ret, i)
+	}
+	sort.Ints(ret)
+	return ret
+}
+
+// _callReference calls the ForReference binding if bound
+func (t *Traveller) _callReference(ctx *TravContext, parent *parentInfo, target Path, val reflect.Value) error {
+	m, ok := t.shortcuts[ForReference]
+	if !ok {
+		return nil
+	}
+	node := parent.nodeInfo(val, 0, false)
+	node.Seq = ctx.seq()
+	outs := m.fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node), reflect.ValueOf(target), reflect.ValueOf(val)})
+	_, err := ForReference.parseReturns(outs)
+	return err
+}
+
+// _callCycle calls the ForCycle binding if bound
+func (t *Traveller) _callCycle(ctx *TravContext, parent *parentInfo, ancestor *NodeInfo, val reflect.Value) error {
+	m, ok := t.shortcuts[ForCycle]
+	if !ok {
+		return nil
+	}
+	node := parent.nodeInfo(val, 0, false)
+	node.Seq = ctx.seq()
+	outs := m.fn.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node), reflect.ValueOf(ancestor), reflect.ValueOf(val)})
+	_, err := ForCycle.parseReturns(outs)
+	return err
+}
+
+// _callLeaf calls the leaf binding m, asynchronously if the parent container is joining leaves
+func (t *Traveller) _callLeaf(ctx *TravContext, parent *parentInfo, m boundMethod, val reflect.Value) error {
+	var set func(reflect.Value) error
+	if m.writeBack {
+		set = parent.setter(val)
+	}
+	return t._callLeafIns(ctx, parent, m, parent.callIns(ctx, m, val), set)
+}
+
+// _callConverted calls the ForAssign binding m of typ with val converted to typ, a replacement
+// returned by the binding is converted back to the type of val by the setter
+func (t *Traveller) _callConverted(ctx *TravContext, parent *parentInfo, m boundMethod, val reflect.Value, typ reflect.Type) error {
+	var set func(reflect.Value) error
+	if m.writeBack {
+		set = parent.setter(val)
+	}
+	ins := parent.callIns(ctx, m, val.Convert(typ))
+	if m.v2 {
+		ins[1].Interface().(*NodeInfo).ConvertedFrom = val.Type()
+	}
+	return t._callLeafIns(ctx, parent, m, ins, set)
+}
+
+func (t *Traveller) _callLeafIns(ctx *TravContext, parent *parentInfo, m boundMethod, ins []reflect.Value,
+	set func(reflect.Value) error) error {
+	if parent != nil {
+		parent.leafCalls++
+	}
+	// writing back into a map can't be concurrent with the traversal of it
+	if parent == nil || parent.leaves == nil || (m.writeBack && parent.value.Kind() == reflect.Map) {
+		return m.callLeaf(ins, set)
+	}
+	collector, seq := ctx.collector, ctx.seq()
+	if collector != nil {
+		collector.begin(seq)
+	}
+	var path Path
+	if t.conf.BestEffort {
+		// the diagnostics of asynchronous leaves are recorded in their goroutines
+		path = parent.childPath()
+	}
+	return parent.leaves.submit(ctx.workers, func() (err error) {
+		if collector != nil {
+			defer collector.end(seq)
+		}
+		if path != nil {
+			defer t._tolerateLeaf(ctx, path, &err)
+		}
+		return m.callLeaf(ins, set)
+	})
+}
+
+// RegisterCodec registers codec with name for fields tagged with `dfpt:"codec=name"`, it should be
+// called before traversals.
+func (t *Traveller) RegisterCodec(name string, codec FieldCodec) error {
+	if name == "" || codec == nil {
+		return errors.New("codec name and function should not be empty")
+	}
+	if _, loaded := t.codecs.LoadOrStore(name, codec); loaded {
+		return fmt.Errorf("duplicated codec %s", name)
+	}
+	return nil
+}
+
+func (t *Traveller) _callCodec(ctx *TravContext, parent *parentInfo, name string, val reflect.Value) error {
+	if ctx.resume != nil && ctx.skipTo(parent.childTrail()) {
+		return nil
+	}
+	if err := ctx.visit(parent.currentDepth()); err != nil {
+		return ctx.stopped(err, parent)
+	}
+	v, ok := t.codecs.Load(name)
+	if !ok {
+		return fmt.Errorf("codec %s not found for %s", name, parent.childPath())
+	}
+	node := parent.nodeInfo(val, 0, false)
+	node.Seq = ctx.seq()
+	return v.(FieldCodec)(ctx, node, val)
+}
+
+// Traverse traverses obj with the adapter of the Traveller, the statistics in ctx are reset at the
+// beginning. A new context is used if ctx is nil. In BestEffort mode, Diagnostics is returned if
+// there's any failed value. Options override the configuration of the Traveller for this traversal
+// only, the bindings of the adapter are not rebuilt.
+func (t *Traveller) Traverse(ctx *TravContext, obj interface{}, opts ...TraverseOption) error {
+	if len(opts) > 0 {
+		conf := t.conf.Clone()
+		if conf == nil {
+			conf = &TraverseConf{}
+		}
+		for _, opt := range opts {
+			if opt != nil {
+				opt(conf)
+			}
+		}
+		t = t.withConf(conf)
+	}
+	return t.traverseValue(ctx, reflect.ValueOf(obj))
+}
+
+// WithConf returns a shallow copy of t configured by (a clone of) conf, nil for the defaults. The
+// copy shares the bindings built from the adapter and the registered codecs with t, so it's much
+// cheaper than NewTraveller with the same adapter.
+func (t *Traveller) WithConf(conf *TraverseConf) *Traveller {
+	return t.withConf(conf.Clone())
+}
+
+// withConf returns a shallow copy of t with conf, sharing the bindings and codecs of t
+func (t *Traveller) withConf(conf *TraverseConf) *Traveller {
+	c := *t
+	c.conf = conf
+	return &c
+}
+
+func (t *Traveller) traverseValue(ctx *TravContext, val reflect.Value) error {
+	return t.traverseFrom(ctx, val, nil)
+}
+
+// traverseFrom traverses val from the cursor, nil for the beginning
+func (t *Traveller) traverseFrom(ctx *TravContext, val reflect.Value, cursor Cursor) error {
 	if !val.IsValid() {
 		return nil
 	}
-	return t._traverse(ctx, nil, val)
+	if ctx == nil {
+		ctx = NewContext()
+	}
+	maxNodes := 0
+	if t.conf != nil {
+		maxNodes = t.conf.MaxNodes
+	}
+	ctx.reset(maxNodes)
+	ctx.resume = cursor
+	ctx.root = val
+	ctx.names = nil
+	if t.conf != nil {
+		ctx.names = t.conf.FieldNames
+	}
+	if t.conf != nil && t.conf.Deadline > 0 {
+		ctx.deadline = ctx.started.Add(t.conf.Deadline)
+	}
+	if t.conf != nil && t.conf.AsyncLeaves > 0 {
+		ctx.workers = make(chan struct{}, t.conf.AsyncLeaves)
+	}
+	if err := t._traverse(ctx, nil, val); err != nil && !errors.Is(err, ErrSkipContainer) &&
+		!errors.Is(err, ErrStopTraversal) {
+		return err
+	}
+	if skipped := ctx.skippedTypes(); len(skipped) > 0 && t.conf.OnTypeBudgetExceeded != nil {
+		if err := t.conf.OnTypeBudgetExceeded(ctx, skipped); err != nil {
+			return err
+		}
+	}
+	if diags := ctx.Diagnostics(); len(diags) > 0 {
+		return diags
+	}
+	return nil
+}
+
+// sortValues sorts values of the same type: numbers and strings by their values, false before true,
+// others by their fmt representations.
+func sortValues(vals []reflect.Value) {
+	sort.SliceStable(vals, func(i, j int) bool {
+		return compareValue(vals[i], vals[j]) < 0
+	})
+}
+
+func compareValue(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float())
+	case reflect.String:
+		return compareOrdered(a.String() < b.String(), a.String() > b.String())
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool())
+	case reflect.Ptr, reflect.Chan, reflect.UnsafePointer:
+		return compareOrdered(a.Pointer() < b.Pointer(), a.Pointer() > b.Pointer())
+	case reflect.Array, reflect.Struct:
+		var n int
+		if a.Kind() == reflect.Array {
+			n = a.Len()
+		} else {
+			n = a.NumField()
+		}
+		for i := 0; i < n; i++ {
+			var c int
+			if a.Kind() == reflect.Array {
+				c = compareValue(a.Index(i), b.Index(i))
+			} else {
+				c = compareValue(a.Field(i), b.Field(i))
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return compareOrdered(a.IsNil() && !b.IsNil(), !a.IsNil() && b.IsNil())
+		}
+		ea, eb := a.Elem(), b.Elem()
+		if ea.Type() != eb.Type() {
+			return compareOrdered(ea.Type().String() < eb.Type().String(), ea.Type().String() > eb.Type().String())
+		}
+		return compareValue(ea, eb)
+	default:
+		sa, sb := fmt.Sprint(a), fmt.Sprint(b)
+		return compareOrdered(sa < sb, sa > sb)
+	}
+}
+
+func compareOrdered(less, greater bool) int {
+	if less {
+		return -1
+	}
+	if greater {
+		return 1
+	}
+	return 0
+}
+
+// isNamedType returns whether typ is a defined type, but not a predeclared one like string
+func isNamedType(typ reflect.Type) bool {
+	return typ.Name() != "" && typ.PkgPath() != ""
+}
+
+// isLazyFunc returns whether typ is a lazy value provider: func() T or func() (T, error)
+func isLazyFunc(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Func || typ.NumIn() != 0 {
+		return false
+	}
+	switch typ.NumOut() {
+	case 1:
+		return true
+	case 2:
+		return typ.Out(1) == _typeOfError
+	default:
+		return false
+	}
+}
+
+// isEmptyValue returns whether val is a zero leaf or an empty container, structs are never empty
+// since their fields are checked one by one.
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return val.IsNil()
+	case reflect.Array, reflect.Slice, reflect.Map:
+		return val.Len() == 0
+	case reflect.Struct:
+		return false
+	default:
+		return val.IsZero()
+	}
+}
+
+func isNilValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return val.IsNil()
+	default:
+		return false
+	}
 }
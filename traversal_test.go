@@ -19,12 +19,18 @@ package dfpt
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"math/rand"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type Inner0 struct {
@@ -368,3 +374,2131 @@ func TestIntAssign(t *testing.T) {
 		reflect.TypeOf(int16th(0)).AssignableTo(typeOfint64),
 	)
 }
+
+type (
+	v2Inner struct {
+		S string
+		M map[string]int
+	}
+	v2Outer struct {
+		A  int
+		In v2Inner
+		L  []*v2Inner
+	}
+	v2Parser struct {
+		paths *[]string
+	}
+)
+
+func (p v2Parser) ForKindString(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*p.paths = append(*p.paths, node.Path.String())
+	if val.CanSet() {
+		val.SetString(val.String() + "!")
+	}
+	return nil
+}
+
+func (p v2Parser) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*p.paths = append(*p.paths, node.Path.String())
+	return nil
+}
+
+func (p v2Parser) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	fmt.Printf("ForContainerStruct(%s start:%t)\n", node, startOrEnd)
+	return true, nil
+}
+
+func (p v2Parser) ForContainerMap(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (p v2Parser) ForContainerSlice(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestV2Signature(t *testing.T) {
+	var paths []string
+	tr, err := NewTraveller(v2Parser{paths: &paths}, &TraverseConf{PtrAutoGoIn: true, ContainerEnd: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(tr)
+	obj := &v2Outer{
+		A:  1,
+		In: v2Inner{S: "in", M: map[string]int{"x": 2}},
+		L:  []*v2Inner{{S: "l0"}},
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"A", "In.S", "In.M{x}", "In.M[x]", "L[0].S"}
+	if fmt.Sprint(paths) != fmt.Sprint(expected) {
+		t.Fatalf("paths: %v, expecting: %v", paths, expected)
+	}
+	if obj.In.S != "in!" || obj.L[0].S != "l0!" {
+		t.Fatalf("values not set: %+v", obj)
+	}
+	t.Log(paths)
+}
+
+type (
+	codecObj struct {
+		ID   []byte `dfpt:"codec=hex"`
+		Blob []byte `dfpt:" codec = b64 "`
+		N    int
+	}
+
+	lineWriter struct {
+		lines *[]string
+	}
+)
+
+func (w lineWriter) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*w.lines = append(*w.lines, fmt.Sprintf("%s=%v", node.Path, val.Interface()))
+	return nil
+}
+
+func (w lineWriter) ForContainerStruct(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestFieldCodec(t *testing.T) {
+	var lines []string
+	tr, err := NewTraveller(lineWriter{lines: &lines})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.RegisterCodec("hex", func(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+		lines = append(lines, fmt.Sprintf("%s=%x", node.Path, val.Bytes()))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	obj := &codecObj{ID: []byte{0xab, 0xcd}, Blob: []byte("blob"), N: 1}
+	if err = tr.Traverse(NewContext(), *obj); err == nil {
+		t.Fatal("missing codec b64 should fail")
+	} else {
+		t.Log(err)
+	}
+	if err = tr.RegisterCodec("b64", func(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+		lines = append(lines, fmt.Sprintf("%s=%s", node.Path, base64.StdEncoding.EncodeToString(val.Bytes())))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.RegisterCodec("hex", func(*TravContext, *NodeInfo, reflect.Value) error { return nil }); err == nil {
+		t.Fatal("duplicated codec should fail")
+	}
+	lines = nil
+	if err = tr.Traverse(NewContext(), *obj); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[ID=abcd Blob=YmxvYg== N=1]"; fmt.Sprint(lines) != expected {
+		t.Fatalf("got %v, expecting %s", lines, expected)
+	}
+}
+
+type lazyObj struct {
+	Name  string
+	Inner func() *flatObj
+	Count func() (int, error)
+	Nil   func() string
+}
+
+func TestLazyAutoGoIn(t *testing.T) {
+	called := 0
+	obj := &lazyObj{
+		Name: "lazy",
+		Inner: func() *flatObj {
+			called++
+			return &flatObj{Name: "inner"}
+		},
+		Count: func() (int, error) { return 3, nil },
+	}
+	pairs, err := Flatten(obj, &TraverseConf{LazyAutoGoIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[{Name lazy} {Inner.Name inner} {Inner.Next <nil>} {Count 3}]"; fmt.Sprint(pairs) != expected {
+		t.Fatalf("got %v, expecting %s", pairs, expected)
+	}
+	if called != 1 {
+		t.Fatalf("called %d times", called)
+	}
+
+	failed := errors.New("failed")
+	obj.Count = func() (int, error) { return 0, failed }
+	if _, err = Flatten(obj, &TraverseConf{LazyAutoGoIn: true}); err != failed {
+		t.Fatalf("expecting %v, got %v", failed, err)
+	}
+}
+
+type statsRecorder struct {
+	lines *[]string
+}
+
+func (r statsRecorder) ForAllKinds(ctx *TravContext, node *NodeInfo, _ reflect.Value) error {
+	remaining, limited := ctx.Budget()
+	*r.lines = append(*r.lines, fmt.Sprintf("%s:%d/%d/%d/%t", node.Path, ctx.Depth(), ctx.Visited(), remaining, limited))
+	return nil
+}
+
+func (r statsRecorder) ForContainerStruct(ctx *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	*r.lines = append(*r.lines, fmt.Sprintf("%s(%t):%d/%d", node.Path, startOrEnd, ctx.Depth(), ctx.Visited()))
+	return true, nil
+}
+
+func TestContextStats(t *testing.T) {
+	var lines []string
+	type inner struct{ X, Y int }
+	type outer struct {
+		A  int
+		In inner
+		B  int
+	}
+	tr, err := NewTraveller(statsRecorder{lines: &lines}, &TraverseConf{ContainerEnd: true, MaxNodes: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext()
+	err = tr.Traverse(ctx, outer{})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expecting ErrBudgetExceeded, got %v", err)
+	}
+	t.Log(err)
+	var partial *PartialError
+	if !errors.As(err, &partial) || partial.Path.String() != "B" || partial.Visited != 5 {
+		t.Fatalf("expecting partial result at B, got %#v", err)
+	}
+	expected := "[(true):0/1 A:1/2/3/true In(true):1/3 In.X:2/4/1/true In.Y:2/5/0/true In(false):1/5]"
+	if fmt.Sprint(lines) != expected {
+		t.Fatalf("got %v, expecting %s", lines, expected)
+	}
+
+	lines = nil
+	tr, _ = NewTraveller(statsRecorder{lines: &lines}, &TraverseConf{ContainerEnd: true})
+	if err = tr.Traverse(ctx, outer{}); err != nil {
+		t.Fatal(err)
+	}
+	expected = "[(true):0/1 A:1/2/0/false In(true):1/3 In.X:2/4/0/false In.Y:2/5/0/false In(false):1/5 B:1/6/0/false (false):0/6]"
+	if fmt.Sprint(lines) != expected {
+		t.Fatalf("got %v, expecting %s", lines, expected)
+	}
+}
+
+type tenantChecker struct{}
+
+func (tenantChecker) ForKindString(ctx *TravContext, _ *NodeInfo, val reflect.Value) error {
+	tenant, _ := ctx.GetLocal("tenant")
+	if val.String() != tenant {
+		return fmt.Errorf("tenant %s, expecting %v", val.String(), tenant)
+	}
+	return nil
+}
+
+func TestContextWithValues(t *testing.T) {
+	tr, err := NewTraveller(tenantChecker{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(NewContextWithValues(map[interface{}]interface{}{"tenant": "t1"}), "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(NewContextWithValues(map[interface{}]interface{}{"tenant": "t2"}), "t1"); err == nil {
+		t.Fatal("should fail with another tenant")
+	}
+}
+
+type ctxKey string
+
+func TestContextFrom(t *testing.T) {
+	std, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("tenant"), "t1"))
+	ctx := NewContextFrom(std)
+	if v, ok := ctx.GetLocal(ctxKey("tenant")); !ok || v != "t1" {
+		t.Fatalf("value from std: %v %t", v, ok)
+	}
+	ctx.PutLocal(ctxKey("tenant"), "t2")
+	if v, _ := ctx.GetLocal(ctxKey("tenant")); v != "t2" {
+		t.Fatalf("local value should override std: %v", v)
+	}
+	if ctx.Std() != std || NewContext().Std() == nil {
+		t.Fatal("wrong std context")
+	}
+
+	visited := 0
+	tr, err := NewTraveller(cancelAfter{visited: &visited, cancel: cancel})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(ctx, []int{1, 2, 3}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expecting context.Canceled, got %v", err)
+	}
+	if visited != 1 {
+		t.Fatalf("visited %d after canceled", visited)
+	}
+}
+
+type cancelAfter struct {
+	visited *int
+	cancel  context.CancelFunc
+}
+
+func (c cancelAfter) ForKindInt(*TravContext, *NodeInfo, reflect.Value) error {
+	*c.visited++
+	c.cancel()
+	return nil
+}
+
+func (c cancelAfter) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+type asyncRecorder struct {
+	lock     *sync.Mutex
+	inflight *int
+	max      *int
+	done     map[string]bool
+	failAt   string
+}
+
+func (r asyncRecorder) ForKindInt(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	r.lock.Lock()
+	*r.inflight++
+	if *r.inflight > *r.max {
+		*r.max = *r.inflight
+	}
+	r.lock.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	*r.inflight--
+	r.done[node.Path.String()] = true
+	if node.Path.String() == r.failAt {
+		return errors.New("failed at " + r.failAt)
+	}
+	return nil
+}
+
+func (r asyncRecorder) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		for i := 0; i < val.Len(); i++ {
+			if !r.done[fmt.Sprintf("%s[%d]", node.Path, i)] {
+				return false, fmt.Errorf("%s[%d] not joined before end", node.Path, i)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (r asyncRecorder) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestAsyncLeaves(t *testing.T) {
+	type obj struct {
+		A []int
+		B []int
+	}
+	var inflight, max int
+	r := asyncRecorder{lock: new(sync.Mutex), inflight: &inflight, max: &max, done: make(map[string]bool)}
+	tr, err := NewTraveller(r, &TraverseConf{AsyncLeaves: 3, ContainerEnd: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := obj{A: make([]int, 10), B: make([]int, 5)}
+	if err = tr.Traverse(NewContext(), o); err != nil {
+		t.Fatal(err)
+	}
+	if max < 2 || max > 3 {
+		t.Fatalf("max concurrency %d", max)
+	}
+	if len(r.done) != 15 {
+		t.Fatalf("%d leaves done", len(r.done))
+	}
+
+	r.failAt = "A[2]"
+	r.done = make(map[string]bool)
+	tr, _ = NewTraveller(r, &TraverseConf{AsyncLeaves: 3, ContainerEnd: true})
+	if err = tr.Traverse(NewContext(), o); err == nil || err.Error() != "failed at A[2]" {
+		t.Fatalf("expecting failure at A[2], got %v", err)
+	}
+}
+
+func TestAsyncLeafPanic(t *testing.T) {
+	var visited []string
+	obj := [][]int{{13, 1}}
+	tr, err := NewTraveller(pickyParser{visited: &visited}, &TraverseConf{AsyncLeaves: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(NewContext(), obj); err == nil || !strings.Contains(err.Error(), "panic: unlucky") {
+		t.Fatalf("expecting the recovered panic, got %v", err)
+	}
+
+	visited = nil
+	tr, _ = NewTraveller(pickyParser{visited: &visited}, &TraverseConf{AsyncLeaves: 1, BestEffort: true})
+	err = tr.Traverse(NewContext(), obj)
+	diags, ok := err.(Diagnostics)
+	if !ok || len(diags) != 1 || diags[0].Path.String() != "[0][0]" || diags[0].Err.Error() != "panic: unlucky" {
+		t.Fatalf("expecting the panic diagnosed at [0][0], got %v", err)
+	}
+	if fmt.Sprint(visited) != "[[0][1]]" {
+		t.Fatalf("visited: %v", visited)
+	}
+}
+
+type pickyParser struct {
+	visited *[]string
+}
+
+func (p pickyParser) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	if val.Int() < 0 {
+		return fmt.Errorf("negative %d", val.Int())
+	}
+	if val.Int() == 13 {
+		panic("unlucky")
+	}
+	*p.visited = append(*p.visited, node.Path.String())
+	return nil
+}
+
+func (p pickyParser) ForContainerSlice(_ *TravContext, _ *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd && val.Len() > 3 {
+		return false, errors.New("too long")
+	}
+	return true, nil
+}
+
+func TestBestEffort(t *testing.T) {
+	var visited []string
+	tr, err := NewTraveller(pickyParser{visited: &visited}, &TraverseConf{BestEffort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := [][]int{{1, -2, 3}, {4, 5, 6, 7}, {13, 8}}
+	err = tr.Traverse(NewContext(), obj)
+	diags, ok := err.(Diagnostics)
+	if !ok {
+		t.Fatalf("expecting Diagnostics, got %v", err)
+	}
+	t.Log(diags)
+	if len(diags) != 3 || diags[0].Path.String() != "[0][1]" || diags[1].Path.String() != "[1]" ||
+		diags[2].Path.String() != "[2][0]" {
+		t.Fatalf("diagnostics: %v", diags)
+	}
+	if fmt.Sprint(visited) != "[[0][0] [0][2] [2][1]]" {
+		t.Fatalf("visited: %v", visited)
+	}
+
+	tr, _ = NewTraveller(pickyParser{visited: &visited}, &TraverseConf{BestEffort: true, MaxNodes: 3})
+	if err = tr.Traverse(NewContext(), obj); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("budget exceeded should not be tolerated: %v", err)
+	}
+}
+
+type listNode struct {
+	V    int
+	Next *listNode
+}
+
+func TestTypeBudgets(t *testing.T) {
+	nodes := make([]*listNode, 10)
+	for i := range nodes {
+		nodes[i] = &listNode{V: i}
+	}
+	nodes[0].Next = &listNode{V: 100}
+	var skipped map[reflect.Type]int
+	conf := &TraverseConf{
+		PtrAutoGoIn: true,
+		TypeBudgets: map[reflect.Type]int{reflect.TypeOf(&listNode{}): 4},
+		OnTypeBudgetExceeded: func(_ *TravContext, s map[reflect.Type]int) error {
+			skipped = s
+			return nil
+		},
+	}
+	pairs, err := Flatten(nodes, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(pairs)
+	// nodes[0], nodes[0].Next, nodes[1], nodes[2] are visited
+	if len(pairs) != 7 || pairs[6].Path != "[2].Next" {
+		t.Fatalf("pairs: %v", pairs)
+	}
+	if skipped[reflect.TypeOf(&listNode{})] != 7 {
+		t.Fatalf("skipped: %v", skipped)
+	}
+}
+
+type refRecorder struct {
+	flattener
+	refs []string
+}
+
+func (r *refRecorder) ForReference(_ *TravContext, node *NodeInfo, target Path, _ reflect.Value) error {
+	r.refs = append(r.refs, fmt.Sprintf("%s->%s", node.Path, target))
+	return nil
+}
+
+func TestTrackReferences(t *testing.T) {
+	a := &listNode{V: 1}
+	b := &listNode{V: 2, Next: a}
+	a.Next = b
+	shared := []int{7, 8}
+	obj := struct {
+		Head  *listNode
+		Nums  []int
+		Alias []int
+	}{Head: a, Nums: shared, Alias: shared}
+
+	var pairs []PathValue
+	r := &refRecorder{flattener: flattener{callback: func(path string, val interface{}) error {
+		pairs = append(pairs, PathValue{Path: path, Value: val})
+		return nil
+	}}}
+	tr, err := NewTraveller(r, &TraverseConf{PtrAutoGoIn: true, TrackReferences: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 4 {
+		t.Fatalf("pairs: %v", pairs)
+	}
+	if len(r.refs) != 2 || r.refs[0] != "Head.Next.Next->Head" || r.refs[1] != "Alias->Nums" {
+		t.Fatalf("refs: %v", r.refs)
+	}
+}
+
+type cycleRecorder struct {
+	flattener
+	cycles []string
+}
+
+func (r *cycleRecorder) ForCycle(_ *TravContext, node *NodeInfo, ancestor *NodeInfo, _ reflect.Value) error {
+	r.cycles = append(r.cycles, fmt.Sprintf("%s->%s@%d", node.Path, ancestor.Path, ancestor.Depth))
+	return nil
+}
+
+func TestDetectCycles(t *testing.T) {
+	a := &listNode{V: 1}
+	b := &listNode{V: 2, Next: a}
+	a.Next = b
+	shared := &listNode{V: 3}
+	obj := struct {
+		Head  *listNode
+		One   *listNode
+		Other *listNode
+	}{Head: a, One: shared, Other: shared}
+
+	var paths []string
+	r := &cycleRecorder{flattener: flattener{callback: func(path string, _ interface{}) error {
+		paths = append(paths, path)
+		return nil
+	}}}
+	tr, err := NewTraveller(r, &TraverseConf{PtrAutoGoIn: true, DetectCycles: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	// shared values which are not cyclic are traversed repeatedly
+	if len(paths) != 6 || paths[2] != "One.V" || paths[4] != "Other.V" {
+		t.Fatalf("paths: %v", paths)
+	}
+	if len(r.cycles) != 1 || r.cycles[0] != "Head.Next.Next->Head@1" {
+		t.Fatalf("cycles: %v", r.cycles)
+	}
+}
+
+type skipper struct {
+	flattener
+}
+
+func (s skipper) ForContainerMap(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, ErrSkipContainer
+}
+
+func (s skipper) ForImplerror(_ *TravContext, _ *NodeInfo, _ error) error {
+	return ErrSkipContainer
+}
+
+func TestErrSkipContainer(t *testing.T) {
+	var paths []string
+	s := skipper{flattener: flattener{callback: func(path string, _ interface{}) error {
+		paths = append(paths, path)
+		return nil
+	}}}
+	obj := struct {
+		A   int
+		M   map[string]int
+		L   []interface{}
+		Err error
+		Z   int
+	}{A: 1, M: map[string]int{"x": 1}, L: []interface{}{1, 2}, Z: 4}
+	tr, err := NewTraveller(s, &TraverseConf{BestEffort: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	// the map is skipped, and so are the siblings after Err
+	if fmt.Sprint(paths) != "[A L[0] L[1]]" {
+		t.Fatalf("paths: %v", paths)
+	}
+}
+
+func TestErrStopTraversal(t *testing.T) {
+	var paths []string
+	f := flattener{callback: func(path string, v interface{}) error {
+		paths = append(paths, path)
+		if v == 2 {
+			return ErrStopTraversal
+		}
+		return nil
+	}}
+	for _, conf := range []*TraverseConf{nil, {BestEffort: true}, {AsyncLeaves: 1}} {
+		paths = nil
+		tr, err := NewTraveller(f, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tr.Traverse(nil, [][]int{{1, 2, 3}, {4}}); err != nil {
+			t.Fatal(err)
+		}
+		got := fmt.Sprint(paths)
+		if conf != nil && conf.AsyncLeaves > 0 {
+			// leaves submitted before the stop may be called
+			if strings.Contains(got, "[1][0]") {
+				t.Fatalf("async paths: %v", paths)
+			}
+		} else if got != "[[0][0] [0][1]]" {
+			t.Fatalf("paths: %v", paths)
+		}
+	}
+}
+
+type trimmer struct{}
+
+func (trimmer) ForKindString(_ *TravContext, _ *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	s := strings.TrimSpace(val.String())
+	return s, s != val.String(), nil
+}
+
+func (trimmer) ForNilPtr(_ *TravContext, _ *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	if val.Type() != reflect.TypeOf((*int)(nil)) {
+		return nil, false, nil
+	}
+	zero := 0
+	return &zero, true, nil
+}
+
+func (trimmer) ForContainerPtr(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (trimmer) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (trimmer) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (trimmer) ForContainerMap(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestWriteBack(t *testing.T) {
+	type request struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+		Limit *int
+	}
+	for _, conf := range []*TraverseConf{nil, {AsyncLeaves: 2}} {
+		tr, err := NewTraveller(trimmer{}, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &request{Name: " a ", Tags: []string{"b ", " c"}, Attrs: map[string]string{"k": " v "}}
+		if err = tr.Traverse(nil, req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Name != "a" || fmt.Sprint(req.Tags) != "[b c]" || req.Attrs["k"] != "v" ||
+			req.Limit == nil || *req.Limit != 0 {
+			t.Fatalf("not written back: %+v", req)
+		}
+
+		err = tr.Traverse(nil, request{Name: " a "})
+		if err == nil || !strings.Contains(err.Error(), "not settable") {
+			t.Fatalf("expecting not settable error, got %v", err)
+		}
+	}
+}
+
+type upperName struct{ Name string }
+
+func (u *upperName) upper() { u.Name = strings.ToUpper(u.Name) }
+
+type upperer interface{ upper() }
+
+type mutator struct{}
+
+func (mutator) ForAssignStringPtr(_ *TravContext, _ *NodeInfo, s *string) error {
+	*s = strings.ToUpper(*s)
+	return nil
+}
+
+func (mutator) ForImplUpperer(_ *TravContext, _ *NodeInfo, u upperer) error {
+	u.upper()
+	return nil
+}
+
+func (mutator) ForContainerPtr(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (mutator) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (mutator) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (mutator) ForContainerMap(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestAddressable(t *testing.T) {
+	type request struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+		Users map[string]upperName
+	}
+	// map keys are not addressable
+	for _, conf := range []*TraverseConf{
+		{Addressable: true, IgnoreMissedBinding: true},
+		{Addressable: true, IgnoreMissedBinding: true, AsyncLeaves: 2},
+	} {
+		tr, err := NewTraveller(mutator{}, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := &request{
+			Name:  "a",
+			Tags:  []string{"b", "c"},
+			Attrs: map[string]string{"k": "v"},
+			Users: map[string]upperName{"u": {Name: "x"}},
+		}
+		if err = tr.Traverse(nil, req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Name != "A" || fmt.Sprint(req.Tags) != "[B C]" || fmt.Sprint(req.Attrs) != "map[k:V]" ||
+			req.Users["u"].Name != "X" {
+			t.Fatalf("not mutated: %+v", req)
+		}
+	}
+}
+
+func TestTransform(t *testing.T) {
+	type request struct {
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+		Limit *int
+	}
+	tr, err := NewTraveller(trimmer{}, &TraverseConf{Addressable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range []interface{}{
+		request{Name: " a ", Tags: []string{"b ", " c"}, Attrs: map[string]string{"k": " v "}},
+		&request{Name: " a ", Tags: []string{"b ", " c"}, Attrs: map[string]string{"k": " v "}},
+	} {
+		before := fmt.Sprintf("%+v", obj)
+		ret, err := tr.Transform(obj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprintf("%+v", obj) != before {
+			t.Fatalf("original modified: %+v", obj)
+		}
+		req, ok := ret.(request)
+		if !ok {
+			req = *ret.(*request)
+		}
+		if req.Name != "a" || fmt.Sprint(req.Tags) != "[b c]" || req.Attrs["k"] != "v" ||
+			req.Limit == nil || *req.Limit != 0 {
+			t.Fatalf("not transformed: %+v", req)
+		}
+	}
+}
+
+func TestSkipZeroValues(t *testing.T) {
+	type inner struct {
+		A int
+		B string
+	}
+	obj := &struct {
+		I     int
+		S     string
+		P     *int
+		L     []int
+		M     map[string]int
+		E     []string
+		In    inner
+		Any   interface{}
+		Elems []int
+		Zeros map[string]int
+	}{
+		S:     "s",
+		L:     []int{1},
+		E:     []string{},
+		In:    inner{B: "b"},
+		Elems: []int{0, 1},
+		Zeros: map[string]int{"": 0},
+	}
+	pairs, err := Flatten(obj, &TraverseConf{SkipZeroValues: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(pairs); got != "[{S s} {L[0] 1} {In.B b} {Elems[0] 0} {Elems[1] 1} {Zeros[] 0}]" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+type sampler struct {
+	sizes  map[string][2]int
+	leaves []string
+}
+
+func (s *sampler) container(node *NodeInfo, startOrEnd bool) (bool, error) {
+	if startOrEnd {
+		s.sizes[node.Path.String()] = [2]int{node.Size, node.Sampled}
+	}
+	return true, nil
+}
+
+func (s *sampler) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	s.leaves = append(s.leaves, fmt.Sprintf("%s=%d", node.Path, val.Int()))
+	return nil
+}
+
+func (s *sampler) ForKindString(*TravContext, *NodeInfo, reflect.Value) error {
+	return nil
+}
+
+func (s *sampler) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd)
+}
+
+func (s *sampler) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd)
+}
+
+func (s *sampler) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+	return s.container(node, startOrEnd)
+}
+
+func TestSampling(t *testing.T) {
+	obj := struct {
+		Big   []int
+		Small []int
+		M     map[string]int
+	}{Big: make([]int, 100), Small: []int{1, 2}, M: make(map[string]int)}
+	for i := range obj.Big {
+		obj.Big[i] = i
+	}
+	for i := 0; i < 10; i++ {
+		obj.M[fmt.Sprint("k", i)] = i
+	}
+	run := func(seed int64) *sampler {
+		s := &sampler{sizes: make(map[string][2]int)}
+		tr, err := NewTraveller(s, &TraverseConf{SampleSize: 5, SampleSeed: seed, SortMapKeys: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tr.Traverse(nil, obj); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	s := run(1)
+	if got := fmt.Sprint(s.sizes); got != "map[:[3 0] Big:[100 5] M:[20 10] Small:[2 0]]" {
+		t.Fatalf("sizes: %s", got)
+	}
+	if len(s.leaves) != 5+2+5 {
+		t.Fatalf("leaves: %v", s.leaves)
+	}
+	if again := run(1); fmt.Sprint(again.leaves) != fmt.Sprint(s.leaves) {
+		t.Fatalf("not reproducible: %v vs %v", s.leaves, again.leaves)
+	}
+	if other := run(2); fmt.Sprint(other.leaves) == fmt.Sprint(s.leaves) {
+		t.Fatalf("same samples with different seeds: %v", s.leaves)
+	}
+}
+
+func TestSampleIndexes(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	for _, c := range [][2]int{{10, 10}, {1000000, 3}, {20, 19}} {
+		idx := sampleIndexes(rnd, c[0], c[1])
+		if len(idx) != c[1] {
+			t.Fatalf("%v: got %v", c, idx)
+		}
+		for i, v := range idx {
+			if v < 0 || v >= c[0] || (i > 0 && v <= idx[i-1]) {
+				t.Fatalf("%v: got %v", c, idx)
+			}
+		}
+	}
+}
+
+type (
+	matchInt   int
+	matchBytes []byte
+)
+
+func (m matchInt) String() string {
+	return "matchInt"
+}
+
+func TestMatchPolicy(t *testing.T) {
+	var got []string
+	bind := func(name string) func(*TravContext, *NodeInfo, reflect.Value) error {
+		return func(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+			got = append(got, node.Name+":"+name)
+			return nil
+		}
+	}
+	b := NewAdapterBuilder().
+		OnType((*fmt.Stringer)(nil), func(_ *TravContext, node *NodeInfo, _ fmt.Stringer) error {
+			got = append(got, node.Name+":impl")
+			return nil
+		}).
+		OnType([]byte(nil), func(_ *TravContext, node *NodeInfo, _ []byte) error {
+			got = append(got, node.Name+":bytes")
+			return nil
+		}).
+		OnKind(reflect.Int, bind("kind")).
+		OnType(matchInt(0), func(_ *TravContext, node *NodeInfo, _ matchInt) error {
+			got = append(got, node.Name+":exact")
+			return nil
+		}).
+		OnType(matchBytes(nil), func(_ *TravContext, node *NodeInfo, _ matchBytes) error {
+			got = append(got, node.Name+":raw")
+			return nil
+		}).
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		})
+	obj := struct {
+		M matchInt
+		I int
+		R matchBytes
+		B []byte
+	}{}
+	for policy, want := range map[MatchPolicy]string{
+		MatchInOrder:      "[M:impl I:kind R:bytes B:bytes]",
+		MatchMostSpecific: "[M:exact I:kind R:raw B:bytes]",
+	} {
+		got = nil
+		tr, err := NewTraveller(b, &TraverseConf{MatchPolicy: policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tr.Traverse(nil, obj); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != want {
+			t.Fatalf("policy %d: got %v, want %s", policy, got, want)
+		}
+	}
+}
+
+type bytesBinder struct {
+	got   *[]string
+	order map[string]int
+}
+
+func (b bytesBinder) ForAssignBytes(_ *TravContext, node *NodeInfo, _ []byte) error {
+	*b.got = append(*b.got, node.Name+":bytes")
+	return nil
+}
+
+func (b bytesBinder) ForAssignMatchBytes(_ *TravContext, node *NodeInfo, _ matchBytes) error {
+	*b.got = append(*b.got, node.Name+":matchBytes")
+	return nil
+}
+
+func (b bytesBinder) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (b bytesBinder) Order() map[string]int {
+	return b.order
+}
+
+func TestBindingOrder(t *testing.T) {
+	obj := struct {
+		M matchBytes
+		B []byte
+	}{}
+	for _, c := range []struct {
+		order map[string]int
+		want  string
+	}{
+		{nil, "[M:bytes B:bytes]"},
+		// []byte is also assignable to matchBytes
+		{map[string]int{"ForAssignMatchBytes": -1}, "[M:matchBytes B:matchBytes]"},
+		{map[string]int{"ForAssignBytes": 2, "ForAssignMatchBytes": 1}, "[M:matchBytes B:matchBytes]"},
+		{map[string]int{"ForAssignBytes": 1, "ForAssignMatchBytes": 2}, "[M:bytes B:bytes]"},
+	} {
+		var got []string
+		tr, err := NewTraveller(bytesBinder{got: &got, order: c.order})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tr.Traverse(nil, obj); err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(got) != c.want {
+			t.Fatalf("order %v: got %v, want %s", c.order, got, c.want)
+		}
+	}
+	if _, err := NewTraveller(bytesBinder{order: map[string]int{"ForAssignTypo": 1}}); err == nil {
+		t.Fatal("expecting error of unknown binding")
+	}
+}
+
+type defaultCollector struct {
+	got *[]string
+}
+
+func (d defaultCollector) ForKindString(_ *TravContext, _, _ int, name string, _ string) error {
+	*d.got = append(*d.got, name+":string")
+	return nil
+}
+
+func (d defaultCollector) ForDefault(_ *TravContext, _, _ int, name string, val interface{}) error {
+	*d.got = append(*d.got, fmt.Sprintf("%s:%v", name, val))
+	return nil
+}
+
+func (d defaultCollector) ForContainerStruct(*TravContext, int, int, int, bool, string, interface{}) (bool, error) {
+	return true, nil
+}
+
+func TestForDefault(t *testing.T) {
+	var got []string
+	tr, err := NewTraveller(defaultCollector{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct {
+		S string
+		I int
+		M map[string]int
+		P *int
+	}{S: "s", I: 1, M: map[string]int{"a": 1}}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[S:string I:1 M:map[a:1] P:<nil>]" {
+		t.Fatalf("got %v", got)
+	}
+
+	got = nil
+	b := NewAdapterBuilder().OnDefault(func(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+		got = append(got, node.Path.String()+":"+val.Kind().String())
+		return nil
+	})
+	if tr, err = NewTraveller(b); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[:struct]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type sleeper struct {
+	d time.Duration
+}
+
+func (s sleeper) ForKindInt(*TravContext, *NodeInfo, reflect.Value) error {
+	time.Sleep(s.d)
+	return nil
+}
+
+func (s sleeper) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestDeadline(t *testing.T) {
+	tr, err := NewTraveller(sleeper{d: time.Millisecond}, &TraverseConf{Deadline: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Traverse(nil, make([]int, 1000))
+	var partial *PartialError
+	if !errors.Is(err, ErrDeadlineExceeded) || !errors.As(err, &partial) {
+		t.Fatalf("expecting partial result, got %v", err)
+	}
+	if partial.Visited < 2 || partial.Visited > 1000 || partial.Elapsed < 10*time.Millisecond ||
+		partial.Path.String() != fmt.Sprintf("[%d]", partial.Visited-1) {
+		t.Fatalf("got %#v", partial)
+	}
+
+	// deadline of each traversal
+	if err = tr.Traverse(nil, make([]int, 2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type numberGrouper struct {
+	got *[]string
+}
+
+func (g numberGrouper) ForFloatX(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*g.got = append(*g.got, fmt.Sprintf("float:%s=%v", node.Name, val.Float()))
+	return nil
+}
+
+func (g numberGrouper) ForComplexX(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*g.got = append(*g.got, fmt.Sprintf("complex:%s=%v", node.Name, val.Complex()))
+	return nil
+}
+
+func (g numberGrouper) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*g.got = append(*g.got, fmt.Sprintf("%s:%s", val.Kind(), node.Name))
+	return nil
+}
+
+func (g numberGrouper) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestFloatXComplexX(t *testing.T) {
+	var got []string
+	tr, err := NewTraveller(numberGrouper{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct {
+		F32 float32
+		F64 float64
+		C64 complex64
+		C   complex128
+		I   int
+	}{F32: 1.5, F64: 2.5, C64: 1 + 2i, C: 3 - 4i, I: 5}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[float:F32=1.5 float:F64=2.5 complex:C64=(1+2i) complex:C=(3-4i) int:I]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type numberStats struct {
+	got *[]string
+}
+
+func (s numberStats) ForKindInt8(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*s.got = append(*s.got, fmt.Sprintf("int8:%s=%d", node.Name, val.Int()))
+	return nil
+}
+
+func (s numberStats) ForNumber(_ *TravContext, node *NodeInfo, kind NumberKind, val reflect.Value) error {
+	*s.got = append(*s.got, fmt.Sprintf("%s:%s=%v", kind, node.Name, val.Interface()))
+	return nil
+}
+
+func (s numberStats) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*s.got = append(*s.got, fmt.Sprintf("%s:%s", val.Kind(), node.Name))
+	return nil
+}
+
+func (s numberStats) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestForNumber(t *testing.T) {
+	obj := struct {
+		I  int
+		U  uint16
+		F  float32
+		I8 int8
+		S  string
+	}{I: -1, U: 2, F: 0.5, I8: 3, S: "s"}
+	var got []string
+	tr, err := NewTraveller(numberStats{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[int:I=-1 uint:U=2 float:F=0.5 int8:I8=3 string:S]" {
+		t.Fatalf("got %v", got)
+	}
+
+	got = nil
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnNumber(func(_ *TravContext, _, _ int, name string, kind NumberKind, num interface{}) error {
+			got = append(got, fmt.Sprintf("%s:%s=%v", kind, name, num))
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[int:I=-1 uint:U=2 float:F=0.5 int:I8=3]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type nilEncoder struct {
+	got *[]string
+}
+
+func (e nilEncoder) ForNilSlice(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	*e.got = append(*e.got, node.Name+":nil slice")
+	return nil
+}
+
+func (e nilEncoder) ForNilMap(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	*e.got = append(*e.got, node.Name+":nil map")
+	return nil
+}
+
+func (e nilEncoder) ForNilInterface(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*e.got = append(*e.got, node.Name+":nil "+val.Type().String())
+	return nil
+}
+
+func (e nilEncoder) ForContainerSlice(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	if start {
+		*e.got = append(*e.got, fmt.Sprintf("%s:slice(%d)", node.Name, node.Size))
+	}
+	return true, nil
+}
+
+func (e nilEncoder) ForContainerMap(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	if start {
+		*e.got = append(*e.got, fmt.Sprintf("%s:map(%d)", node.Name, node.Size))
+	}
+	return true, nil
+}
+
+func (e nilEncoder) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestForNilContainers(t *testing.T) {
+	var got []string
+	tr, err := NewTraveller(nilEncoder{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct {
+		A []int
+		B []int
+		M map[string]int
+		N map[string]int
+		E error
+	}{B: []int{}, N: map[string]int{}}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[A:nil slice B:slice(0) M:nil map N:map(0) E:nil error]" {
+		t.Fatalf("got %v", got)
+	}
+
+	got = nil
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnNil(reflect.Slice, func(_ *TravContext, _, _ int, name string, _ interface{}) error {
+			got = append(got, name)
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[A]" {
+		t.Fatalf("got %v", got)
+	}
+	if err = NewAdapterBuilder().OnNil(reflect.Int, func() {}).err; err == nil {
+		t.Fatal("expecting error of OnNil(Int)")
+	}
+}
+
+type omitZero struct {
+	got *[]string
+}
+
+func (o omitZero) ForZero(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*o.got = append(*o.got, "zero:"+node.Path.String())
+	return nil
+}
+
+func (o omitZero) ForNilMap(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*o.got = append(*o.got, "nil map:"+node.Path.String())
+	return nil
+}
+
+func (o omitZero) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*o.got = append(*o.got, fmt.Sprintf("%s=%d", node.Path, val.Int()))
+	return nil
+}
+
+func (o omitZero) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestForZero(t *testing.T) {
+	type inner struct {
+		A int
+	}
+	obj := struct {
+		S     string
+		I     int
+		J     int
+		Inner inner
+		Outer inner
+		P     *int
+		M     map[string]int
+	}{J: 1, Outer: inner{A: 2}}
+	var got []string
+	tr, err := NewTraveller(omitZero{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[zero:S zero:I J=1 zero:Inner Outer.A=2 zero:P nil map:M]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type blobID []byte
+
+type blobEncoder struct {
+	got *[]string
+}
+
+func (e blobEncoder) ForBytes(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*e.got = append(*e.got, fmt.Sprintf("%s:bytes(%d)", node.Path, val.Len()))
+	return nil
+}
+
+func (e blobEncoder) ForAssignBlobID(_ *TravContext, node *NodeInfo, id blobID) error {
+	*e.got = append(*e.got, fmt.Sprintf("%s:id(%x)", node.Path, []byte(id)))
+	return nil
+}
+
+func (e blobEncoder) ForKindUint8(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	*e.got = append(*e.got, node.Path.String())
+	return nil
+}
+
+func (e blobEncoder) ForContainerArray(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (e blobEncoder) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func TestForBytes(t *testing.T) {
+	obj := struct {
+		Data []byte
+		ID   blobID
+		Hash [2]byte
+	}{Data: make([]byte, 1<<20), ID: blobID{0xab}, Hash: [2]byte{1, 2}}
+	var got []string
+	tr, err := NewTraveller(blobEncoder{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Data:bytes(1048576) ID:id(ab) Hash[0] Hash[1]]" {
+		t.Fatalf("got %v", got)
+	}
+
+	got = nil
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnBytes(func(_ *TravContext, _, _ int, name string, bs []byte) error {
+			got = append(got, fmt.Sprintf("%s=%x", name, bs[:1]))
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{BytesArrays: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Data=00 ID=ab Hash=01]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type mapEntryFormatter struct {
+	got *[]string
+}
+
+func (f mapEntryFormatter) ForContainerMap(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (f mapEntryFormatter) ForMapKeyString(_ *TravContext, _ *NodeInfo, val reflect.Value) error {
+	*f.got = append(*f.got, val.String()+"=")
+	return nil
+}
+
+func (f mapEntryFormatter) ForMapValueInt(_ *TravContext, _ *NodeInfo, val reflect.Value) error {
+	(*f.got)[len(*f.got)-1] += strconv.FormatInt(val.Int(), 10)
+	return nil
+}
+
+func (f mapEntryFormatter) ForKindString(_ *TravContext, _ *NodeInfo, val reflect.Value) error {
+	*f.got = append(*f.got, "string:"+val.String())
+	return nil
+}
+
+func TestForMapKeyValue(t *testing.T) {
+	var got []string
+	tr, err := NewTraveller(mapEntryFormatter{got: &got}, &TraverseConf{SortMapKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, map[string]int{"b": 2, "a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[a=1 b=2]" {
+		t.Fatalf("got %v", got)
+	}
+
+	got = nil
+	if err = tr.Traverse(nil, map[string]string{"k": "v"}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[k= string:v]" {
+		t.Fatalf("got %v", got)
+	}
+
+	var keys []string
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Map, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnMapKey(reflect.Int, func(_ *TravContext, _, _ int, _ string, key interface{}) error {
+			keys = append(keys, fmt.Sprint(key))
+			return nil
+		}).
+		OnMapValue(reflect.Int, func(*TravContext, int, int, string, interface{}) error {
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{SortMapKeys: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, map[int]int{3: 30, 1: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(keys) != "[1 3]" {
+		t.Fatalf("got %v", keys)
+	}
+	if _, err = NewTraveller(NewAdapterBuilder().OnMapKey(reflect.Map, func() {})); err == nil {
+		t.Fatal("container kind should not be bound as map key")
+	}
+}
+
+type entryCollector struct {
+	sizes   []int
+	entries []string
+}
+
+func (c *entryCollector) ForContainerMap(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	if start {
+		c.sizes = append(c.sizes, node.Size)
+	}
+	return true, nil
+}
+
+func (c *entryCollector) ForMapEntry(_ *TravContext, node *NodeInfo, key, value reflect.Value) error {
+	c.entries = append(c.entries, fmt.Sprintf("%d:%v=%v(%s)", node.Index, key, value, node.Path))
+	return nil
+}
+
+func TestForMapEntry(t *testing.T) {
+	c := &entryCollector{}
+	tr, err := NewTraveller(c, &TraverseConf{SortMapKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, map[string]int{"b": 2, "a": 1, "c": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(c.sizes) != "[3]" {
+		t.Fatalf("sizes %v", c.sizes)
+	}
+	if fmt.Sprint(c.entries) != "[0:a=1([a]) 1:b=2([b]) 2:c=3([c])]" {
+		t.Fatalf("got %v", c.entries)
+	}
+
+	var got []string
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Map, func(_ *TravContext, _, _, size int, start bool, _ string, _ interface{}) (bool, error) {
+			if start {
+				got = append(got, fmt.Sprintf("size=%d", size))
+			}
+			return true, nil
+		}).
+		OnMapEntry(func(_ *TravContext, _, index int, _ string, key, value interface{}) error {
+			got = append(got, fmt.Sprintf("%d:%v=%v", index, key, value))
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{SortMapKeys: true, ContainerEnd: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, map[int]bool{2: false, 1: true}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[size=2 0:1=true 1:2=false]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type ifaceHolder struct {
+	Shape interface{}
+	None  interface{}
+}
+
+type ifaceVisitor struct {
+	got *[]string
+}
+
+func (v ifaceVisitor) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (v ifaceVisitor) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	*v.got = append(*v.got, fmt.Sprintf("%s=%d", node.Path, val.Int()))
+	return nil
+}
+
+type ifaceContainerVisitor struct {
+	ifaceVisitor
+}
+
+func (v ifaceContainerVisitor) ForContainerInterface(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	*v.got = append(*v.got, fmt.Sprintf("iface %s size:%d", node.Name, node.Size))
+	return true, nil
+}
+
+func TestInterfaceContainer(t *testing.T) {
+	obj := ifaceHolder{Shape: struct{ W, H int }{3, 4}}
+	var got []string
+	tr, err := NewTraveller(ifaceVisitor{got: &got})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err == nil || !strings.Contains(err.Error(), "binding is missing") {
+		t.Fatalf("missing binding expected, but %v", err)
+	}
+
+	if tr, err = NewTraveller(ifaceVisitor{got: &got}, &TraverseConf{InterfaceAutoGoIn: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Shape.W=3 Shape.H=4]" {
+		t.Fatalf("got %v", got)
+	}
+
+	got = nil
+	if tr, err = NewTraveller(ifaceContainerVisitor{ifaceVisitor{got: &got}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[iface Shape size:1 Shape.W=3 Shape.H=4 iface None size:0]" {
+		t.Fatalf("got %v", got)
+	}
+	if _, _, ok := Unknown.Which(KindPrefix + "Interface"); ok {
+		t.Fatal("interface should be bound as a container")
+	}
+}
+
+func TestWhichKinds(t *testing.T) {
+	for kind := reflect.Bool; kind <= reflect.UnsafePointer; kind++ {
+		name, ok := kindName(kind)
+		if !ok {
+			t.Fatalf("kind %s has no name in binding names", kind)
+		}
+		want, prefix := ForKind, KindPrefix
+		if _, isContainer := _containers[kind]; isContainer {
+			want, prefix = ForContainer, ContainerPrefix
+		}
+		itype, k, ok := Unknown.Which(prefix + name)
+		if !ok || itype != want || k != kind {
+			t.Fatalf("Which(%s%s) = %s, %s, %t", prefix, name, itype, k, ok)
+		}
+		if want == ForKind {
+			if _, _, ok = Unknown.Which(ContainerPrefix + name); ok {
+				t.Fatalf("%s%s should be illegal", ContainerPrefix, name)
+			}
+		} else if _, _, ok = Unknown.Which(KindPrefix + name); ok {
+			t.Fatalf("%s%s should be illegal", KindPrefix, name)
+		}
+	}
+	for _, name := range []string{KindPrefix + "Invalid", KindPrefix + "Int128", KindPrefix, ContainerPrefix} {
+		if _, _, ok := Unknown.Which(name); ok {
+			t.Fatalf("%s should be illegal", name)
+		}
+	}
+	if !ForIntX.MatchValue(reflect.ValueOf(int32(1))) || ForIntX.MatchValue(reflect.ValueOf(uint32(1))) {
+		t.Fatal("ForIntX should match int32 but not uint32")
+	}
+}
+
+type int32Visitor struct {
+	got []int32
+}
+
+func (v *int32Visitor) ForKindInt32(_ *TravContext, _, _ int, _ string, val interface{}) error {
+	v.got = append(v.got, val.(int32))
+	return nil
+}
+
+func (v *int32Visitor) ForContainerSlice(*TravContext, int, int, int, bool, string, interface{}) (bool, error) {
+	return true, nil
+}
+
+func TestForKindInt32(t *testing.T) {
+	v := &int32Visitor{}
+	tr, err := NewTraveller(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, []int32{1, -2}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.got) != "[1 -2]" {
+		t.Fatalf("got %v", v.got)
+	}
+}
+
+type chanVisitor struct {
+	got   []string
+	onInt func()
+}
+
+func (v *chanVisitor) ForContainerChan(_ *TravContext, node *NodeInfo, start bool, _ reflect.Value) (bool, error) {
+	if start {
+		v.got = append(v.got, fmt.Sprintf("chan %s size:%d", node.Path, node.Size))
+	} else {
+		v.got = append(v.got, "end")
+	}
+	return true, nil
+}
+
+func (v *chanVisitor) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (v *chanVisitor) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	v.got = append(v.got, fmt.Sprintf("%s=%d", node.Path, val.Int()))
+	if v.onInt != nil {
+		v.onInt()
+	}
+	return nil
+}
+
+func TestForContainerChan(t *testing.T) {
+	ch := make(chan int, 4)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	obj := struct {
+		C    chan int
+		Recv <-chan int
+		Nil  chan int
+	}{C: ch, Recv: ch}
+
+	v := &chanVisitor{}
+	tr, err := NewTraveller(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.got) != "[chan C size:0 chan Recv size:0 chan Nil size:0]" {
+		t.Fatalf("got %v", v.got)
+	}
+
+	v.got = nil
+	if tr, err = NewTraveller(v, &TraverseConf{ChanLimit: 2, ContainerEnd: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.got) != "[chan C size:2 C[0]=1 C[1]=2 end chan Recv size:0 end chan Nil size:0 end]" {
+		t.Fatalf("got %v", v.got)
+	}
+	// the elements are sent back in order
+	if len(ch) != 3 || <-ch != 1 || <-ch != 2 || <-ch != 3 {
+		t.Fatal("channel changed")
+	}
+
+	// the channel is filled by another sender during the traversal
+	ch <- 5
+	v.got, v.onInt = nil, func() {
+		for len(ch) < cap(ch) {
+			ch <- 6
+		}
+	}
+	if err = tr.Traverse(nil, obj); !errors.Is(err, ErrChanNotRestored) {
+		t.Fatalf("expecting ErrChanNotRestored, got %v", err)
+	}
+	if len(ch) != 4 || <-ch != 6 {
+		t.Fatal("channel changed")
+	}
+	v.onInt = nil
+	for len(ch) > 0 {
+		<-ch
+	}
+
+	// the elements of closed channels are consumed
+	ch <- 4
+	close(ch)
+	v.got = nil
+	if err = tr.Traverse(nil, obj); !errors.Is(err, ErrChanNotRestored) {
+		t.Fatalf("expecting ErrChanNotRestored, got %v", err)
+	}
+	if fmt.Sprint(v.got) != "[chan C size:1 C[0]=4]" {
+		t.Fatalf("got %v", v.got)
+	}
+}
+
+type convUserID int64
+
+type convVisitor struct {
+	got []string
+}
+
+func (v *convVisitor) ForAssignInt64(_ *TravContext, node *NodeInfo, id int64) (interface{}, bool, error) {
+	v.got = append(v.got, fmt.Sprintf("%s:int64(%d) from %v", node.Name, id, node.ConvertedFrom))
+	return id + 100, true, nil
+}
+
+func (v *convVisitor) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (v *convVisitor) ForKindInt64(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	v.got = append(v.got, fmt.Sprintf("%s:kind(%d)", node.Name, val.Int()))
+	return nil
+}
+
+func (v *convVisitor) ForKindString(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	v.got = append(v.got, fmt.Sprintf("%s:%s", node.Name, val.String()))
+	return nil
+}
+
+func TestConvertibleAssign(t *testing.T) {
+	type record struct {
+		ID   convUserID
+		Raw  int64
+		Name string
+	}
+	v := &convVisitor{}
+	tr, err := NewTraveller(v, &TraverseConf{MatchPolicy: MatchMostSpecific, PtrAutoGoIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &record{ID: 7, Raw: 8, Name: "x"}
+	if err = tr.Traverse(nil, rec); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.got) != "[ID:kind(7) Raw:int64(8) from <nil> Name:x]" || rec.Raw != 108 {
+		t.Fatalf("got %v %+v", v.got, rec)
+	}
+
+	v.got = nil
+	if tr, err = NewTraveller(v, &TraverseConf{MatchPolicy: MatchMostSpecific, PtrAutoGoIn: true, ConvertibleAssign: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, rec); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.got) != "[ID:int64(7) from dfpt.convUserID Raw:int64(108) from <nil> Name:x]" {
+		t.Fatalf("got %v", v.got)
+	}
+	// the replacement is converted back
+	if rec.ID != 107 || rec.Raw != 208 {
+		t.Fatalf("got %+v", rec)
+	}
+}
+
+type funcReporter struct {
+	got []string
+}
+
+func (r *funcReporter) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (r *funcReporter) ForKindFunc(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	r.got = append(r.got, fmt.Sprintf("%s:%s nil:%t", node.Name, val.Type(), val.IsNil()))
+	return nil
+}
+
+func (r *funcReporter) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	r.got = append(r.got, fmt.Sprintf("%s=%d", node.Name, val.Int()))
+	return nil
+}
+
+func TestForKindFunc(t *testing.T) {
+	called := false
+	obj := struct {
+		OnDone func(int) error
+		Lazy   func() int
+		N      int
+	}{Lazy: func() int { called = true; return 1 }, N: 2}
+	r := &funcReporter{}
+	tr, err := NewTraveller(r, &TraverseConf{LazyAutoGoIn: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if called || fmt.Sprint(r.got) != "[OnDone:func(int) error nil:true Lazy:func() int nil:false N=2]" {
+		t.Fatalf("called:%t got %v", called, r.got)
+	}
+}
+
+type namedEmail string
+
+type namedLevel int
+
+type namedVisitor struct {
+	got []string
+}
+
+func (v *namedVisitor) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (v *namedVisitor) ForNamedString(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	v.got = append(v.got, fmt.Sprintf("%s:named %s(%s)", node.Name, val.Type(), val.String()))
+	return nil
+}
+
+func (v *namedVisitor) ForKindString(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	v.got = append(v.got, fmt.Sprintf("%s:%s", node.Name, val.String()))
+	return nil
+}
+
+func (v *namedVisitor) ForAssignNamedLevel(_ *TravContext, node *NodeInfo, level namedLevel) error {
+	v.got = append(v.got, fmt.Sprintf("%s:level %d", node.Name, level))
+	return nil
+}
+
+func TestForNamed(t *testing.T) {
+	obj := struct {
+		Email namedEmail
+		Name  string
+		Level namedLevel
+	}{Email: "a@b.c", Name: "ann", Level: 3}
+	v := &namedVisitor{}
+	tr, err := NewTraveller(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(v.got) != "[Email:named dfpt.namedEmail(a@b.c) Name:ann Level:level 3]" {
+		t.Fatalf("got %v", v.got)
+	}
+
+	var got []string
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnNamed(reflect.Int, func(_ *TravContext, _, _ int, name string, val interface{}) error {
+			got = append(got, fmt.Sprintf("%s:%T", name, val))
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{IgnoreMissedBinding: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[Level:dfpt.namedLevel]" {
+		t.Fatalf("got %v", got)
+	}
+	if _, err = NewTraveller(NewAdapterBuilder().OnNamed(reflect.Struct, func() {})); err == nil {
+		t.Fatal("container kind should not be bound by ForNamed")
+	}
+}
+
+type resolveDoc struct {
+	Users  []resolveUser
+	Orders []resolveOrder
+	Owner  interface{}
+}
+
+type resolveUser struct {
+	Name string
+}
+
+type resolveOrder struct {
+	User int
+}
+
+type resolveChecker struct {
+	got []string
+}
+
+func (c *resolveChecker) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (c *resolveChecker) ForContainerSlice(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (c *resolveChecker) ForContainerPtr(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (c *resolveChecker) ForContainerInterface(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return false, nil
+}
+
+func (c *resolveChecker) ForKindString(*TravContext, *NodeInfo, reflect.Value) error {
+	return nil
+}
+
+func (c *resolveChecker) ForKindInt(ctx *TravContext, node *NodeInfo, val reflect.Value) error {
+	user, err := ctx.Resolve(Path{{Kind: reflect.Struct, Name: "Users"}, {Kind: reflect.Slice, Index: int(val.Int())}})
+	if err != nil {
+		c.got = append(c.got, err.Error())
+		return nil
+	}
+	c.got = append(c.got, fmt.Sprintf("%s:%s", node.Path, user.Field(0)))
+	return nil
+}
+
+func TestResolve(t *testing.T) {
+	doc := &resolveDoc{
+		Users:  []resolveUser{{Name: "ann"}, {Name: "bob"}},
+		Orders: []resolveOrder{{User: 1}, {User: 2}},
+		Owner:  &resolveUser{Name: "cid"},
+	}
+	c := &resolveChecker{}
+	tr, err := NewTraveller(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := NewContext()
+	if _, err = ctx.Resolve(nil); err == nil {
+		t.Fatal("resolving without traversal should fail")
+	}
+	if err = tr.Traverse(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(c.got) != "[Orders[0].User:bob no value at Users[2] in *dfpt.resolveDoc]" {
+		t.Fatalf("got %v", c.got)
+	}
+	// pointers and interfaces are dereferenced, and the values can be set
+	owner, err := ctx.Resolve(Path{{Kind: reflect.Struct, Name: "Owner"}, {Kind: reflect.Struct, Name: "Name"}})
+	if err != nil || owner.String() != "cid" {
+		t.Fatalf("%v %v", owner, err)
+	}
+	name, err := ctx.Resolve(Path{{Kind: reflect.Ptr}, {Kind: reflect.Struct, Index: 0}, {Kind: reflect.Slice, Index: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name.Field(0).SetString("amy")
+	if doc.Users[0].Name != "amy" {
+		t.Fatalf("got %+v", doc.Users[0])
+	}
+}
+
+// slotPropertier lays out the fields A and B of placeholderRecord at slots 0 and 2
+type slotPropertier struct{}
+
+func (slotPropertier) Properties(reflect.Value) (int, []Property) {
+	return 3, []Property{
+		{Index: 0, Name: "A", IndexForReal: 0},
+		{Index: -1, IndexForReal: 1},
+		{Index: 1, Name: "B", IndexForReal: 2},
+	}
+}
+
+type placeholderRecord struct {
+	A int
+	B int
+}
+
+type slotWriter struct {
+	slots []string
+}
+
+func (w *slotWriter) ForContainerStruct(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (w *slotWriter) ForKindInt(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	w.slots = append(w.slots, fmt.Sprintf("%d:%d", node.Index, val.Int()))
+	return nil
+}
+
+func (w *slotWriter) ForPlaceholder(_ *TravContext, node *NodeInfo) error {
+	w.slots = append(w.slots, fmt.Sprintf("%d:absent(%t)", node.Index, node.Value.IsValid()))
+	return nil
+}
+
+func TestForPlaceholder(t *testing.T) {
+	w := &slotWriter{}
+	tr, err := NewTraveller(w, &TraverseConf{Propertier: slotPropertier{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, placeholderRecord{A: 1, B: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(w.slots) != "[0:1 1:absent(false) 2:2]" {
+		t.Fatalf("got %v", w.slots)
+	}
+
+	var got []string
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(*TravContext, int, int, int, bool, string, interface{}) (bool, error) {
+			return true, nil
+		}).
+		OnKind(reflect.Int, func(*TravContext, int, int, string, interface{}) error {
+			return nil
+		}).
+		OnPlaceholder(func(_ *TravContext, depth, index int) error {
+			got = append(got, fmt.Sprintf("depth:%d index:%d", depth, index))
+			return nil
+		})
+	if tr, err = NewTraveller(b, &TraverseConf{Propertier: slotPropertier{}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Traverse(nil, placeholderRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != "[depth:1 index:1]" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestTraverseOptions(t *testing.T) {
+	var ends, leaves int
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Struct, func(_ *TravContext, _ *NodeInfo, startOrEnd bool, _ reflect.Value) (bool, error) {
+			if !startOrEnd {
+				ends++
+			}
+			return true, nil
+		}).
+		OnKind(reflect.Int, func(*TravContext, *NodeInfo, reflect.Value) error {
+			leaves++
+			return nil
+		})
+	tr, err := NewTraveller(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := struct{ A, B, C int }{1, 2, 3}
+	if err = tr.Traverse(nil, obj, WithContainerEnd(true)); err != nil || ends != 1 || leaves != 3 {
+		t.Fatalf("with ContainerEnd: ends=%d leaves=%d err=%v", ends, leaves, err)
+	}
+	// the configuration of the Traveller is kept
+	if err = tr.Traverse(nil, obj); err != nil || ends != 1 || leaves != 6 {
+		t.Fatalf("without options: ends=%d leaves=%d err=%v", ends, leaves, err)
+	}
+	err = tr.Traverse(nil, obj, WithMaxNodes(2))
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expecting ErrBudgetExceeded, got %v", err)
+	}
+	if tr.conf != nil {
+		t.Fatalf("conf of the Traveller changed: %s", tr.conf)
+	}
+}
+
+func TestWithConf(t *testing.T) {
+	var paths []string
+	b := NewAdapterBuilder().
+		OnContainer(reflect.Map, func(*TravContext, *NodeInfo, bool, reflect.Value) (bool, error) {
+			return true, nil
+		}).
+		OnKind(reflect.Int, func(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+			paths = append(paths, node.Path.String())
+			return nil
+		})
+	tr, err := NewTraveller(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := &TraverseConf{SortMapKeys: true, IgnoreMissedBinding: true}
+	sorted := tr.WithConf(conf)
+	conf.SortMapKeys = false
+	if sorted.typeOrder == nil || len(sorted.kindMethods) != len(tr.kindMethods) || sorted.conf == conf {
+		t.Fatal("bindings should be shared and conf should be cloned")
+	}
+	obj := map[string]int{"c": 3, "a": 1, "b": 2}
+	if err = sorted.Traverse(nil, obj); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(paths) != "[[a] [b] [c]]" {
+		t.Fatalf("got %v", paths)
+	}
+	if tr.conf != nil {
+		t.Fatalf("conf of the original Traveller changed: %s", tr.conf)
+	}
+	codec := func(*TravContext, *NodeInfo, reflect.Value) error { return nil }
+	if err = tr.RegisterCodec("noop", codec); err != nil {
+		t.Fatal(err)
+	}
+	if err = sorted.RegisterCodec("noop", codec); err == nil {
+		t.Fatal("codecs should be shared")
+	}
+}
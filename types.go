@@ -18,22 +18,43 @@
 package dfpt
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
 	ErrInvalidAdapter = errors.New("invalid adapter")
 	ErrWant2Returns   = errors.New("expecting returns (goin bool, err error)")
 	ErrWant1Return    = errors.New("expecting returns (err error)")
+	ErrWant3Returns   = errors.New("expecting returns (newVal interface{}, changed bool, err error)")
+	ErrBudgetExceeded = errors.New("traversal budget exceeded")
+	// ErrDeadlineExceeded is wrapped in the PartialError if the traversal takes longer than
+	// TraverseConf.Deadline
+	ErrDeadlineExceeded = errors.New("traversal deadline exceeded")
+	// ErrSkipContainer can be returned by bindings to skip a container without failing the
+	// traversal, like filepath.SkipDir: the children of the container are not traversed if it's
+	// returned by the ForContainerXxxx binding at the start, the remaining siblings of the value are
+	// skipped if it's returned by other bindings (ignored if they are called asynchronously).
+	ErrSkipContainer = errors.New("skip this container")
+	// ErrStopTraversal can be returned by bindings to stop the whole traversal, and Traverse returns
+	// nil as if the traversal is over. Asynchronous leaf bindings submitted before may still be called.
+	ErrStopTraversal = errors.New("stop traversal")
+	// ErrChanNotRestored is returned if the elements received from a channel for TraverseConf.ChanLimit
+	// can't be sent back, because the channel is closed or filled by other senders meanwhile.
+	ErrChanNotRestored = errors.New("channel elements not restored")
 
 	_kindMap = map[string]reflect.Kind{
 		"Bool":          reflect.Bool,
 		"Int":           reflect.Int,
 		"Int8":          reflect.Int8,
 		"Int16":         reflect.Int16,
+		"Int32":         reflect.Int32,
 		"Int64":         reflect.Int64,
 		"Uint":          reflect.Uint,
 		"Uint8":         reflect.Uint8,
@@ -59,19 +80,26 @@ var (
 	}
 
 	_containers = map[reflect.Kind]struct{}{
-		reflect.Array:  {},
-		reflect.Map:    {},
-		reflect.Ptr:    {},
-		reflect.Slice:  {},
-		reflect.Struct: {},
-	}
-
-	_typeOfString     = reflect.TypeOf((*string)(nil)).Elem()
-	_typeOfBool       = reflect.TypeOf(true)
-	_typeOfInt        = reflect.TypeOf(int(0))
-	_typeOfError      = reflect.TypeOf((*error)(nil)).Elem()
-	_typeOfInterface  = reflect.TypeOf((*interface{})(nil)).Elem()
-	_typeOfTravCtxPtr = reflect.TypeOf((*TravContext)(nil))
+		reflect.Array:     {},
+		reflect.Chan:      {},
+		reflect.Interface: {},
+		reflect.Map:       {},
+		reflect.Ptr:       {},
+		reflect.Slice:     {},
+		reflect.Struct:    {},
+	}
+
+	_typeOfString      = reflect.TypeOf((*string)(nil)).Elem()
+	_typeOfBool        = reflect.TypeOf(true)
+	_typeOfInt         = reflect.TypeOf(int(0))
+	_typeOfError       = reflect.TypeOf((*error)(nil)).Elem()
+	_typeOfInterface   = reflect.TypeOf((*interface{})(nil)).Elem()
+	_typeOfTravCtxPtr  = reflect.TypeOf((*TravContext)(nil))
+	_typeOfNodeInfoPtr = reflect.TypeOf((*NodeInfo)(nil))
+	_typeOfValue       = reflect.TypeOf(reflect.Value{})
+	_typeOfPath        = reflect.TypeOf(Path(nil))
+	_typeOfNumberKind  = reflect.TypeOf(NumberKind(0))
+	_typeOfBytes       = reflect.TypeOf([]byte(nil))
 )
 
 const (
@@ -80,20 +108,83 @@ const (
 	ForKind      ItemType = 2
 	ForContainer ItemType = 3
 	ForNilPtr    ItemType = 4
-	ForIntX      ItemType = 5 // for int/int8/int16/int32/int64
-	ForUintX     ItemType = 6 // for uint/uint8/uint16/uint32/uint64
-	ForAllKinds  ItemType = 7 // process all unintercepted values at the end
-	Unknown      ItemType = 0xff
+	ForIntX      ItemType = 5  // for int/int8/int16/int32/int64
+	ForUintX     ItemType = 6  // for uint/uint8/uint16/uint32/uint64
+	ForAllKinds  ItemType = 7  // process all unintercepted values at the end
+	ForReference ItemType = 8  // for values referencing a visited one, with TraverseConf.TrackReferences
+	ForCycle     ItemType = 9  // for values referencing one of their ancestors, with TraverseConf.DetectCycles
+	ForTag       ItemType = 10 // for struct fields with the tag option, e.g. ForTagSecret for `dfpt:"secret"`
+	ForDefault   ItemType = 11 // for values no other binding matches, instead of erroring or ignoring them
+	ForFloatX    ItemType = 12 // for float32/float64
+	ForComplexX  ItemType = 13 // for complex64/complex128
+	ForNumber    ItemType = 14 // for all integers, unsigned integers and floats, with their NumberKind
+	ForNilSlice  ItemType = 15 // for nil slices, instead of ForContainerSlice with size 0
+	ForNilMap    ItemType = 16 // for nil maps, instead of ForContainerMap with size 0
+	// for nil values of interface types, e.g. a nil error field
+	ForNilInterface ItemType = 17
+	// for zero values (IsZero) of all types and kinds, before the other bindings except ForNilXxxx
+	ForZero ItemType = 18
+	// for byte slices (and byte arrays with TraverseConf.BytesArrays) as leaves, instead of traversing
+	// them element by element
+	ForBytes ItemType = 19
+	// for keys of map entries of the kind, e.g. ForMapKeyString, before ForKindYYYY
+	ForMapKey ItemType = 20
+	// for values of map entries of the kind, e.g. ForMapValueInt, before ForKindYYYY
+	ForMapValue ItemType = 21
+	// for the entries of maps with both keys and values, instead of traversing them one by one
+	ForMapEntry ItemType = 22
+	// for values of named types of the kind, e.g. ForNamedString for `type Email string`, before
+	// ForKindYYYY
+	ForNamed ItemType = 23
+	// for placeholders (Property.Index < 0) given by StructPropertier, which have no values
+	ForPlaceholder ItemType = 24
+	Unknown        ItemType = 0xff
 
 	ImplPrefix       = "ForImpl"
 	AssignPrefix     = "ForAssign"
 	KindPrefix       = "ForKind"
 	ContainerPrefix  = "ForContainer"
 	NilPtrName       = "ForNilPtr"
+	NilSliceName     = "ForNilSlice"
+	NilMapName       = "ForNilMap"
+	NilInterfaceName = "ForNilInterface"
+	ZeroName         = "ForZero"
+	BytesName        = "ForBytes"
+	MapKeyPrefix     = "ForMapKey"
+	MapValuePrefix   = "ForMapValue"
+	MapEntryName     = "ForMapEntry"
+	NamedPrefix      = "ForNamed"
+	PlaceholderName  = "ForPlaceholder"
 	IntXName         = "ForIntX"
 	UintXName        = "ForUintX"
+	FloatXName       = "ForFloatX"
+	ComplexXName     = "ForComplexX"
+	NumberName       = "ForNumber"
 	AllKindsName     = "ForAllKinds"
+	ReferenceName    = "ForReference"
+	CycleName        = "ForCycle"
+	TagPrefix        = "ForTag"
+	DefaultName      = "ForDefault"
 	_minPrefixLength = 7
+
+	_rootIndex = -1
+)
+
+const (
+	// MatchInOrder chooses the first matching binding in the order of the methods of the adapter
+	// (sorted by names), or of the registrations for AdapterBuilder
+	MatchInOrder MatchPolicy = iota
+	// MatchMostSpecific chooses the most specific matching binding: the exact type, then a type the
+	// value is assignable to, then an interface it implements, then a type it is convertible to (with
+	// TraverseConf.ConvertibleAssign), and its kind at last. Bindings of the same specificity are
+	// chosen like MatchInOrder.
+	MatchMostSpecific
+)
+
+const (
+	NumberInt   NumberKind = iota // int/int8/int16/int32/int64
+	NumberUint                    // uint/uint8/uint16/uint32/uint64/uintptr
+	NumberFloat                   // float32/float64
 )
 
 // Traveller 将一个对象中所有公开属性进行依次深度优先遍历，即当对象中包含另一个对象时，则先对子对象的公开属
@@ -115,10 +206,24 @@ type (
 	ItemType  uint8
 	ItemTypes []ItemType
 
+	// MatchPolicy is how a binding is chosen when more than one matches a value
+	MatchPolicy int
+
+	// NumberKind is the discriminator of the numbers passed to ForNumber
+	NumberKind int
+
+	// BindingOrderer can be implemented by adapters to order their ForImpl/ForAssign/ForKind/
+	// ForContainer bindings explicitly, since the first matching one is chosen (MatchInOrder).
+	// Order returns the orders of the bindings by their method names, bindings are matched in
+	// ascending orders, unlisted ones have order 0, and ties are in the order of the method names.
+	BindingOrderer interface {
+		Order() map[string]int
+	}
+
 	orderItem struct {
 		i int          // index of the method list of adapter
 		n string       // name of the method
-		o int          // order, not in use
+		o int          // order given by BindingOrderer, 0 by default
 		t reflect.Type // type of property bound by the method
 		c bool         // if the property is a container
 		k reflect.Kind // kind of property bound by the method, only one of t!=nil or k!=0
@@ -127,7 +232,7 @@ type (
 	orderItems []orderItem
 
 	Property struct {
-		Index        int    // index for reflect.Value.Field(), if -1,placeholder, return zero value, no corresponding property in the struct
+		Index        int    // index for reflect.Value.Field(), if -1,placeholder, return zero value, no corresponding property in the struct, see ForPlaceholder
 		Name         string // field name
 		IndexForReal int    // index for Traveller, -1: use Index instead
 	}
@@ -147,15 +252,172 @@ type (
 		// When val.IsNil==true, val is directly ignored;
 		// when val.IsNil==false, the object pointed to by the pointer will be automatically called back.
 		PtrAutoGoIn bool
+		// When no binding matches a value of interface type (e.g. ForContainerInterface is not bound),
+		// auto is true and will be valid: nil interface is ignored, and the dynamic value of others is
+		// traversed like PtrAutoGoIn.
+		InterfaceAutoGoIn bool
+		// max number of the buffered elements of channels traversed as the children of
+		// ForContainerChan, 0 for none. To keep the channel intact, all its buffered elements are
+		// received without blocking and sent back in order after the traversal of the channel, which
+		// is not atomic if the channel is used concurrently. The traversal fails with
+		// ErrChanNotRestored if some elements can't be sent back, e.g. the elements of closed
+		// channels are consumed. Only bidirectional channels are drained.
+		ChanLimit int
+		// When no binding matches a lazy value provider (func() T or func() (T, error)), auto is true
+		// and will be valid: nil func is ignored, others are called and their results are traversed
+		// in place of them. So the providers are only called if the traversal goes in there.
+		// Providers are not called if ForKindFunc is bound.
+		LazyAutoGoIn bool
+		// traverse map entries in the order of sorted keys instead of the random order of Go maps
+		SortMapKeys bool
+		// if not 0, struct fields are filtered with their since/until tag options, see VersionedPropertier
+		Version int
+		// max number of values could be visited in a traversal, 0 for unlimited. A PartialError
+		// wrapping ErrBudgetExceeded would be returned if exceeded.
+		MaxNodes int
+		// max duration of a traversal, 0 for unlimited. A PartialError wrapping ErrDeadlineExceeded
+		// would be returned if exceeded, the bindings called before are not rolled back.
+		Deadline time.Duration
+		// if > 0, leaf bindings (not ForContainerXxxx) are called asynchronously by at most AsyncLeaves
+		// goroutines, and are joined before the end of their container (and its ContainerEnd binding).
+		// Bindings should be safe for concurrent use, and the statistics in TravContext are not
+		// meaningful for asynchronous calls.
+		AsyncLeaves int
+		// with AsyncLeaves, only containers with at least AsyncMinLeaves leaf bindings estimated to
+		// be called directly under them call them asynchronously, so that small containers and
+		// containers of containers avoid the overhead of goroutines. The estimate of a container is
+		// its size times the leaf bindings per child of the containers of the same type traversed
+		// before by the Traveller (running statistics), or of a sample of its children if its type
+		// hasn't been seen. 0 for all containers.
+		AsyncMinLeaves int
+		// if true, failures (errors and panics) of a value are recorded as Diagnostics and the value is
+		// skipped, the traversal continues with the next value. Traverse returns the Diagnostics at
+		// the end. ErrBudgetExceeded and errors of the wrapped context.Context stop the traversal as
+		// usual.
+		BestEffort bool
+		// max number of non-nil values of each type could be visited in a traversal, values exceeding the
+		// budget of their types are skipped (not counted by MaxNodes), and reported by
+		// OnTypeBudgetExceeded at the end of the traversal.
+		TypeBudgets map[reflect.Type]int
+		// receives the number of skipped values of each type exceeding TypeBudgets, if any.
+		OnTypeBudgetExceeded func(ctx *TravContext, skipped map[reflect.Type]int) error
+		// if true, non-nil pointers, maps and non-empty slices are tracked by their identities, a value
+		// identical to one visited before is not traversed again, but passed to the ForReference binding
+		// (ignored if not bound) with the path of the first one. So shared values and cycles are
+		// reported as back references instead of being traversed repeatedly or infinitely.
+		TrackReferences bool
+		// if true, the pointers, maps and slices being traversed are tracked, a value identical to one
+		// of its ancestors is not traversed again, but passed to the ForCycle binding (ignored if not
+		// bound) with the NodeInfo of the ancestor. It's checked before TrackReferences.
+		DetectCycles bool
+		// if true, addressability of the values is kept in the traversal (the root should be given by
+		// pointer): map values are traversed as addressable copies which are stored back into the map
+		// before the end of it, and ForAssign/ForImpl bindings of pointer types (e.g. *string) match
+		// addressable values with their addresses, so that adapters can mutate the values in place.
+		Addressable bool
+		// if not nil, struct fields are filtered with their visibility tags, only the fields visible
+		// to any of the roles (of the caller) are traversed, see RolePropertier
+		Roles []string
+		// if not nil, properties of the struct types in it are renamed to their wire names, e.g. to
+		// encode the same structs in different vocabularies. NodeInfo.Name and PathNode.Name are the
+		// wire names, see NameMapping
+		FieldNames NameMapping
+		// if true, struct fields with zero leaves (reflect.Value.IsZero), nil pointers and interfaces,
+		// or empty arrays, slices and maps are skipped like omitempty, they are neither dispatched
+		// to bindings nor traversed. Elements of arrays, slices and maps are not skipped, and the
+		// Size of the struct still counts the skipped fields.
+		SkipZeroValues bool
+		// if > 0, arrays, slices and maps with more than SampleSize elements (entries for maps) are
+		// sampled: only a pseudo-random subset of SampleSize elements are visited in their order.
+		// The subset is determined by SampleSeed and the path of the container, so it is
+		// reproducible (maps should be traversed with SortMapKeys). NodeInfo.Sampled of the
+		// container bindings reports the number of children visited.
+		SampleSize int
+		SampleSeed int64
+		// implementations of interface types, for populating interface values from TypedSource
+		Types *TypeRegistry
+		// how the binding of a value is chosen among the matching ForImpl/ForAssign/ForKind/ForContainer
+		// bindings, MatchInOrder by default
+		MatchPolicy MatchPolicy
+		// if true, a value matches the ForAssign binding of a type of the same kind it is convertible
+		// to, e.g. a value of `type UserID int64` matches ForAssign of int64, and is converted to the
+		// type when passed to the binding. NodeInfo.ConvertedFrom is the original type.
+		ConvertibleAssign bool
+		// if true, byte arrays ([N]byte) are passed to the ForBytes binding like byte slices
+		BytesArrays bool
 	}
 
 	parentInfo struct {
 		depth        int
-		value        reflect.Value // container value
-		size         int           // container size: Array/Slice.Len(), len(Map.MapKeys())*2, len([]Property)
-		offset       int           // current calling child value index [0, size)
-		structFields []Property    // properties if value is a struct
-		binding      reflect.Value // container binding start/end function
+		value        reflect.Value     // container value
+		size         int               // container size: Array/Slice.Len(), len(Map.MapKeys())*2 (*1 if byEntry), len([]Property)
+		offset       int               // current calling child value index [0, size)
+		structFields []Property        // properties if value is a struct
+		binding      boundMethod       // container binding start/end function
+		path         Path              // path of the container value
+		trail        Cursor            // offsets of the container value and its ancestors in their parents
+		mapKey       reflect.Value     // current key if value is a map
+		oneofs       map[string]string // oneof group -> name of the field set in the group if value is a struct
+		oneofSkips   map[int]struct{}  // indexes of unset fields in oneof groups
+		leaves       *leafGroup        // asynchronous leaf bindings of the children
+		leafCalls    int               // number of leaf bindings called for the children
+		entries      []reflect.Value   // (key, addressable copy of value) pairs of the map in Addressable mode
+		samples      []int             // sorted indexes of the sampled elements (entries for maps), nil if not sampled
+		seq          int               // sequence number of the container value in the traversal
+		byEntry      bool              // the map is traversed by entries with ForMapEntry
+	}
+
+	// leafGroup joins the asynchronous leaf bindings of a container
+	leafGroup struct {
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		err  error
+	}
+
+	// boundMethod is an adapter method bound to a property, v2 is true if the method uses
+	// the NodeInfo based signature, writeBack is true if the leaf method returns a replacement
+	// of the value, property is the predeclared type the Property of a v1 method is converted to.
+	boundMethod struct {
+		fn        reflect.Value
+		itype     ItemType
+		v2        bool
+		writeBack bool
+		property  reflect.Type
+	}
+
+	// PathNode is one step from a container to one of its children.
+	PathNode struct {
+		Kind  reflect.Kind  // kind of the container
+		Index int           // element index of Array/Slice, field index of Struct, offset of Map
+		Name  string        // field name if the container is a struct
+		Key   reflect.Value // map key if the container is a map
+		IsKey bool          // true if the node is the key of a map entry, otherwise the value
+	}
+
+	// Path locates a value from the root object of a traversal, the root itself has an empty path.
+	Path []PathNode
+
+	// NodeInfo is the position information of the property passed to v2 binding functions.
+	NodeInfo struct {
+		Depth  int           // same as the Depth parameter of v1 bindings
+		Index  int           // same as the IndexInParent parameter of v1 bindings, -1 for the root
+		Name   string        // field name if the parent is a struct
+		Size   int           // container size, only for ForContainerXxxx bindings
+		Path   Path          // path from the root object
+		Parent reflect.Value // the container value, invalid for the root
+		Value  reflect.Value // the property, it can be set if the root was passed by pointer
+		// sequence number of the value in the traversal, starts from 0 (the root), stable no matter
+		// whether the binding is called asynchronously
+		Seq int
+		// discriminators of the oneof groups of a struct: group -> name of the field set ("" if none),
+		// only for ForContainerStruct bindings
+		OneOf map[string]string
+		// number of children visited if the container is sampled (see TraverseConf.SampleSize), in
+		// the same unit as Size, 0 if not sampled. Only for ForContainerXxxx bindings
+		Sampled int
+		// original type of the value if it was converted to the type of the ForAssign binding (see
+		// TraverseConf.ConvertibleAssign), nil if not converted
+		ConvertedFrom reflect.Type
 	}
 )
 
@@ -166,12 +428,38 @@ func (ItemType) Which(name string) (ItemType, reflect.Kind, bool) {
 	switch name {
 	case NilPtrName:
 		return ForNilPtr, reflect.Invalid, true
+	case NilSliceName:
+		return ForNilSlice, reflect.Invalid, true
+	case NilMapName:
+		return ForNilMap, reflect.Invalid, true
+	case NilInterfaceName:
+		return ForNilInterface, reflect.Invalid, true
+	case ZeroName:
+		return ForZero, reflect.Invalid, true
+	case BytesName:
+		return ForBytes, reflect.Invalid, true
+	case MapEntryName:
+		return ForMapEntry, reflect.Invalid, true
+	case PlaceholderName:
+		return ForPlaceholder, reflect.Invalid, true
 	case IntXName:
 		return ForIntX, reflect.Invalid, true
 	case UintXName:
 		return ForUintX, reflect.Invalid, true
+	case FloatXName:
+		return ForFloatX, reflect.Invalid, true
+	case ComplexXName:
+		return ForComplexX, reflect.Invalid, true
+	case NumberName:
+		return ForNumber, reflect.Invalid, true
 	case AllKindsName:
 		return ForAllKinds, reflect.Invalid, true
+	case ReferenceName:
+		return ForReference, reflect.Invalid, true
+	case CycleName:
+		return ForCycle, reflect.Invalid, true
+	case DefaultName:
+		return ForDefault, reflect.Invalid, true
 	default:
 		if name[:len(ImplPrefix)] == ImplPrefix {
 			return ForImpl, reflect.Invalid, true
@@ -187,7 +475,25 @@ func (ItemType) Which(name string) (ItemType, reflect.Kind, bool) {
 				return Unknown, reflect.Invalid, false
 			}
 			return ForKind, kind, true
-		} else if name[:len(ContainerPrefix)] == ContainerPrefix {
+		} else if name[:len(TagPrefix)] == TagPrefix {
+			return ForTag, reflect.Invalid, true
+		} else if strings.HasPrefix(name, MapKeyPrefix) || strings.HasPrefix(name, MapValuePrefix) ||
+			strings.HasPrefix(name, NamedPrefix) {
+			itype, suffix := ForMapKey, name[len(MapKeyPrefix):]
+			if strings.HasPrefix(name, MapValuePrefix) {
+				itype, suffix = ForMapValue, name[len(MapValuePrefix):]
+			} else if strings.HasPrefix(name, NamedPrefix) {
+				itype, suffix = ForNamed, name[len(NamedPrefix):]
+			}
+			kind, ok := _kindMap[suffix]
+			if !ok {
+				return Unknown, reflect.Invalid, false
+			}
+			if _, ok = _containers[kind]; ok {
+				return Unknown, reflect.Invalid, false
+			}
+			return itype, kind, true
+		} else if strings.HasPrefix(name, ContainerPrefix) {
 			suffix := name[len(ContainerPrefix):]
 			kind, ok := _kindMap[suffix]
 			if !ok {
@@ -207,9 +513,19 @@ func (i ItemType) MatchValue(val reflect.Value) bool {
 	switch i {
 	case ForNilPtr:
 		return val.Type().Kind() == reflect.Ptr && val.IsNil()
+	case ForNilSlice:
+		return val.Type().Kind() == reflect.Slice && val.IsNil()
+	case ForNilMap:
+		return val.Type().Kind() == reflect.Map && val.IsNil()
+	case ForNilInterface:
+		return val.Type().Kind() == reflect.Interface && val.IsNil()
+	case ForZero:
+		return val.IsZero()
+	case ForBytes:
+		return val.Type().Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8
 	case ForIntX:
 		switch val.Type().Kind() {
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint32, reflect.Int64:
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 			return true
 		}
 		return false
@@ -219,6 +535,15 @@ func (i ItemType) MatchValue(val reflect.Value) bool {
 			return true
 		}
 		return false
+	case ForFloatX:
+		kind := val.Type().Kind()
+		return kind == reflect.Float32 || kind == reflect.Float64
+	case ForComplexX:
+		kind := val.Type().Kind()
+		return kind == reflect.Complex64 || kind == reflect.Complex128
+	case ForNumber:
+		_, ok := numberKindOf(val.Type().Kind())
+		return ok
 	case ForAllKinds:
 		return true
 	default:
@@ -231,13 +556,37 @@ func (i ItemType) MatchValue(val reflect.Value) bool {
 // ForImplxxxx(*TravContext, Depth, IndexInParent, PropertyName, Property) error
 // ForAssignxxxx(*TravContext, Depth, IndexInParent, PropertyName, Property) error
 // ForNilPtr(*TravContext, Depth, IndexInParent, PropertyName, Property) error
+// ForNilSlice/ForNilMap/ForNilInterface(*TravContext, Depth, IndexInParent, PropertyName, interface{}) error
+// ForZero(*TravContext, Depth, IndexInParent, PropertyName, interface{}) error, zero containers are not
+// traversed
 // ForIntX(*TravContext, Depth, IndexInParent, PropertyName, Property) error
 // ForUintX(*TravContext, Depth, IndexInParent, PropertyName, Property) error
+// ForFloatX(*TravContext, Depth, IndexInParent, PropertyName, Property) error
+// ForComplexX(*TravContext, Depth, IndexInParent, PropertyName, Property) error
+// ForNumber(*TravContext, Depth, IndexInParent, PropertyName, NumberKind, interface{}) error, for all
+// the numbers not bound by more specific bindings
 // ForAllKinds(*TravContext, Depth, IndexInParent, PropertyName, Property) error
+// ForPlaceholder(*TravContext, Depth, IndexInParent) error, for the placeholders of structs given by
+// StructPropertier, IndexInParent is the IndexForReal of the placeholder
+// ForMapEntry(*TravContext, Depth, IndexInParent, PropertyName, Key interface{}, Value interface{}) error,
+// for each entry of the maps, whose ForContainerMap is called with the number of entries as the size
+// ForBytes(*TravContext, Depth, IndexInParent, PropertyName, []byte) error, byte arrays are passed as
+// slices of copies
+// ForDefault(*TravContext, Depth, IndexInParent, PropertyName, interface{}) error, for values no other
+// binding matches (including containers without ForContainerYYYY), they are not traversed further
 // ForKind:
 //
 //	normal kinds: ForKindYYYY(*TravContext, Depth, IndexInParent, PropertyName, Property) error,
 //		YYYY must be a key in _kindMap, and the Kind must not be a container.
+//		Property is interface{} or the predeclared type of the Kind (values of the named types are
+//		converted), other types are rejected by NewTraveller as are the typed Property of ForIntX,
+//		ForUintX, ForFloatX, ForComplexX and ForAllKinds.
+//		ForKindFunc binds function-typed values (including nil ones) as leaves, they are never called,
+//		and it takes precedence over TraverseConf.LazyAutoGoIn.
+//	map entries: ForMapKeyYYYY/ForMapValueYYYY have the same signature as ForKindYYYY, for the keys
+//		and the values of the kind in maps.
+//	named types: ForNamedYYYY has the same signature as ForKindYYYY, for the values of the types
+//		defined with the kind (e.g. `type Email string`), but not the predeclared ones.
 //	container kinds:
 //		ForContainerYYYY(*TravContext, Depth, IndexInParent, Size, StartOrEnd, PropertyName, Property) (goin bool, err error),
 //		YYYY must be a key in _containers
@@ -251,7 +600,9 @@ func (i ItemType) IsValidWithReceiver(method reflect.Method) bool {
 		return false
 	}
 	switch i {
-	case ForImpl, ForAssign, ForKind, ForNilPtr, ForIntX, ForUintX, ForAllKinds:
+	case ForImpl, ForAssign, ForKind, ForNilPtr, ForNilSlice, ForNilMap, ForNilInterface, ForZero,
+		ForIntX, ForUintX, ForFloatX, ForComplexX, ForAllKinds, ForDefault, ForBytes, ForMapKey, ForMapValue,
+		ForNamed:
 		if ftype.In(1) != _typeOfTravCtxPtr || ftype.In(2) != _typeOfInt ||
 			ftype.In(3) != _typeOfInt || ftype.In(4) != _typeOfString {
 			return false
@@ -259,10 +610,30 @@ func (i ItemType) IsValidWithReceiver(method reflect.Method) bool {
 		if ftype.NumOut() != 1 || ftype.Out(0) != _typeOfError {
 			return false
 		}
-		if i == ForNilPtr && ftype.In(5) != _typeOfInterface {
+		if (i.Prefix() || i == ForDefault) && ftype.In(5) != _typeOfInterface {
+			return false
+		}
+		if i == ForBytes && ftype.In(5) != _typeOfBytes {
 			return false
 		}
 		return true
+	case ForNumber:
+		if ftype.In(1) != _typeOfTravCtxPtr || ftype.In(2) != _typeOfInt || ftype.In(3) != _typeOfInt ||
+			ftype.In(4) != _typeOfString || ftype.In(5) != _typeOfNumberKind || ftype.In(6) != _typeOfInterface {
+			return false
+		}
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
+	case ForPlaceholder:
+		if ftype.In(1) != _typeOfTravCtxPtr || ftype.In(2) != _typeOfInt || ftype.In(3) != _typeOfInt {
+			return false
+		}
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
+	case ForMapEntry:
+		if ftype.In(1) != _typeOfTravCtxPtr || ftype.In(2) != _typeOfInt || ftype.In(3) != _typeOfInt ||
+			ftype.In(4) != _typeOfString || ftype.In(5) != _typeOfInterface || ftype.In(6) != _typeOfInterface {
+			return false
+		}
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
 	case ForContainer:
 		if ftype.In(1) != _typeOfTravCtxPtr || ftype.In(2) != _typeOfInt ||
 			ftype.In(3) != _typeOfInt || ftype.In(4) != _typeOfInt ||
@@ -278,9 +649,155 @@ func (i ItemType) IsValidWithReceiver(method reflect.Method) bool {
 	}
 }
 
+// IsValidV2WithReceiver with receiver object in the first place
+// v2 binding function signatures:
+// ForImplxxxx(*TravContext, *NodeInfo, Property) error
+// ForAssignxxxx(*TravContext, *NodeInfo, Property) error
+// ForNilPtr/ForNilSlice/ForNilMap/ForNilInterface/ForZero/ForIntX/ForUintX/ForFloatX/ForComplexX/ForAllKinds/ForDefault/ForBytes/ForKindYYYY/
+// ForMapKeyYYYY/ForMapValueYYYY/ForNamedYYYY(
+// *TravContext, *NodeInfo, reflect.Value) error
+// ForContainerYYYY(*TravContext, *NodeInfo, StartOrEnd, reflect.Value) (goin bool, err error)
+// ForNumber(*TravContext, *NodeInfo, NumberKind, reflect.Value) error
+// ForMapEntry(*TravContext, *NodeInfo, Key reflect.Value, Value reflect.Value) error, NodeInfo is of the value
+// ForPlaceholder(*TravContext, *NodeInfo) error, NodeInfo.Value is invalid
+// ForReference(*TravContext, *NodeInfo, Path, reflect.Value) error, only in v2, Path is the path of the
+// referenced value visited before
+// ForCycle(*TravContext, *NodeInfo, *NodeInfo, reflect.Value) error, only in v2, the second NodeInfo
+// is the ancestor (with its depth and path) referenced by the value
+// ForTagYYYY(*TravContext, *NodeInfo, reflect.Value) error, only in v2, for the struct fields with the tag
+// option YYYY (case-insensitive), e.g. ForTagSecret for `dfpt:"secret"`, regardless of their types
+// Leaf bindings (ForImpl/ForAssign/ForNilPtr/ForNilSlice/ForNilMap/ForNilInterface/ForZero/ForIntX/ForUintX/ForFloatX/ForComplexX/ForNumber/ForAllKinds/ForDefault/ForBytes/ForKind/ForTag/ForMapKey/ForMapValue/ForNamed) in v2
+// can also
+// return (newVal interface{}, changed bool, err error) to write newVal back in place of the value
+// if changed, see isWriteBack.
+func (i ItemType) IsValidV2WithReceiver(method reflect.Method) bool {
+	if !method.Func.IsValid() {
+		return false
+	}
+	ftype := method.Func.Type()
+	if ftype.NumIn() != i.ParamLengthV2()+1 {
+		return false
+	}
+	if ftype.In(1) != _typeOfTravCtxPtr || ftype.In(2) != _typeOfNodeInfoPtr {
+		return false
+	}
+	switch i {
+	case ForImpl, ForAssign:
+		return (ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError) || isWriteBack(ftype)
+	case ForKind, ForNilPtr, ForNilSlice, ForNilMap, ForNilInterface, ForZero,
+		ForIntX, ForUintX, ForFloatX, ForComplexX, ForAllKinds, ForTag, ForDefault, ForBytes, ForMapKey, ForMapValue,
+		ForNamed:
+		if ftype.In(3) != _typeOfValue {
+			return false
+		}
+		return (ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError) || isWriteBack(ftype)
+	case ForNumber:
+		if ftype.In(3) != _typeOfNumberKind || ftype.In(4) != _typeOfValue {
+			return false
+		}
+		return (ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError) || isWriteBack(ftype)
+	case ForMapEntry:
+		if ftype.In(3) != _typeOfValue || ftype.In(4) != _typeOfValue {
+			return false
+		}
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
+	case ForPlaceholder:
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
+	case ForContainer:
+		if ftype.In(3) != _typeOfBool || ftype.In(4) != _typeOfValue {
+			return false
+		}
+		return ftype.NumOut() == 2 && ftype.Out(0) == _typeOfBool && ftype.Out(1) == _typeOfError
+	case ForReference:
+		if ftype.In(3) != _typeOfPath || ftype.In(4) != _typeOfValue {
+			return false
+		}
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
+	case ForCycle:
+		if ftype.In(3) != _typeOfNodeInfoPtr || ftype.In(4) != _typeOfValue {
+			return false
+		}
+		return ftype.NumOut() == 1 && ftype.Out(0) == _typeOfError
+	default:
+		return false
+	}
+}
+
+// bytesOf returns the content of the byte slice or array val
+func bytesOf(val reflect.Value) []byte {
+	if val.Kind() == reflect.Slice {
+		return val.Convert(_typeOfBytes).Interface().([]byte)
+	}
+	bs := make([]byte, val.Len())
+	reflect.Copy(reflect.ValueOf(bs), val)
+	return bs
+}
+
+// numberKindOf returns the NumberKind of the numbers of kind
+func numberKindOf(kind reflect.Kind) (NumberKind, bool) {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NumberInt, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return NumberUint, true
+	case reflect.Float32, reflect.Float64:
+		return NumberFloat, true
+	default:
+		return 0, false
+	}
+}
+
+func (k NumberKind) String() string {
+	switch k {
+	case NumberInt:
+		return "int"
+	case NumberUint:
+		return "uint"
+	case NumberFloat:
+		return "float"
+	default:
+		return fmt.Sprintf("NumberKind(%d)", int(k))
+	}
+}
+
+// isWriteBack returns whether the binding function returns (newVal interface{}, changed bool, err error)
+func isWriteBack(ftype reflect.Type) bool {
+	return ftype.NumOut() == 3 && ftype.Out(0) == _typeOfInterface && ftype.Out(1) == _typeOfBool &&
+		ftype.Out(2) == _typeOfError
+}
+
+// Signature returns whether the method is a valid binding function, and whether it uses v2 signature.
+func (i ItemType) Signature(method reflect.Method) (valid bool, v2 bool) {
+	if i.IsValidWithReceiver(method) {
+		return true, false
+	}
+	if i.IsValidV2WithReceiver(method) {
+		return true, true
+	}
+	return false, false
+}
+
+// acceptProperty returns whether the Property parameter ptype of a v1 binding of kind accepts
+// all the values it will be called with, and the predeclared type the values should be converted
+// to if they may be of the named types of the kind.
+func (i ItemType) acceptProperty(kind reflect.Kind, ptype reflect.Type) (convert reflect.Type, ok bool) {
+	switch i {
+	case ForKind, ForMapKey, ForMapValue, ForNamed, ForContainer:
+		if ptype.Kind() != reflect.Interface && ptype.Kind() == kind && ptype.PkgPath() == "" && ptype.Name() != "" {
+			return ptype, true
+		}
+	case ForIntX, ForUintX, ForFloatX, ForComplexX, ForAllKinds:
+	default:
+		return nil, true
+	}
+	return nil, ptype == _typeOfInterface
+}
+
 func (i ItemType) parseReturns(outs []reflect.Value) (goin bool, err error) {
 	switch i {
-	case ForImpl, ForAssign, ForKind, ForNilPtr, ForIntX, ForUintX, ForAllKinds:
+	case ForImpl, ForAssign, ForKind, ForNilPtr, ForNilSlice, ForNilMap, ForNilInterface, ForZero,
+		ForIntX, ForUintX, ForFloatX, ForComplexX, ForAllKinds, ForReference, ForCycle, ForTag,
+		ForDefault, ForNumber, ForBytes, ForMapKey, ForMapValue, ForMapEntry, ForNamed, ForPlaceholder:
 		if len(outs) != 1 {
 			return false, ErrWant1Return
 		}
@@ -307,10 +824,29 @@ func (i ItemType) parseReturns(outs []reflect.Value) (goin bool, err error) {
 	}
 }
 
+// parseWriteBack parses the returns of a write-back leaf binding, a nil newVal stands for the
+// zero value.
+func parseWriteBack(outs []reflect.Value) (newVal reflect.Value, changed bool, err error) {
+	if len(outs) != 3 || outs[0].Kind() != reflect.Interface || outs[1].Kind() != reflect.Bool ||
+		!outs[2].Type().Implements(_typeOfError) {
+		return reflect.Value{}, false, ErrWant3Returns
+	}
+	if !outs[2].IsZero() {
+		err = outs[2].Interface().(error)
+	}
+	return outs[0].Elem(), outs[1].Bool(), err
+}
+
 func (i ItemType) ParamLength() int {
 	switch i {
-	case ForImpl, ForAssign, ForKind, ForNilPtr, ForIntX, ForUintX, ForAllKinds:
+	case ForImpl, ForAssign, ForKind, ForNilPtr, ForNilSlice, ForNilMap, ForNilInterface, ForZero,
+		ForIntX, ForUintX, ForFloatX, ForComplexX, ForAllKinds, ForDefault, ForBytes, ForMapKey, ForMapValue,
+		ForNamed:
 		return 5
+	case ForNumber, ForMapEntry:
+		return 6
+	case ForPlaceholder:
+		return 3
 	case ForContainer:
 		return 7
 	default:
@@ -318,12 +854,35 @@ func (i ItemType) ParamLength() int {
 	}
 }
 
+func (i ItemType) ParamLengthV2() int {
+	switch i {
+	case ForImpl, ForAssign, ForKind, ForNilPtr, ForNilSlice, ForNilMap, ForNilInterface, ForZero,
+		ForIntX, ForUintX, ForFloatX, ForComplexX, ForAllKinds, ForTag, ForDefault, ForBytes, ForMapKey, ForMapValue,
+		ForNamed:
+		return 3
+	case ForContainer, ForReference, ForCycle, ForNumber, ForMapEntry:
+		return 4
+	case ForPlaceholder:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// PropertyIndex returns the index of the property in the parameters of the method with receiver
+func (i ItemType) PropertyIndex(v2 bool) int {
+	if v2 {
+		return i.ParamLengthV2()
+	}
+	return i.ParamLength()
+}
+
 func (i ItemType) Prefix() bool {
-	return i == ForNilPtr
+	return i == ForNilPtr || i == ForNilSlice || i == ForNilMap || i == ForNilInterface || i == ForZero
 }
 
 func (i ItemType) Suffix() bool {
-	return i == ForIntX || i == ForUintX || i == ForAllKinds
+	return i == ForIntX || i == ForUintX || i == ForFloatX || i == ForComplexX || i == ForNumber || i == ForAllKinds
 }
 
 func (i ItemType) String() string {
@@ -338,12 +897,46 @@ func (i ItemType) String() string {
 		return ContainerPrefix
 	case ForNilPtr:
 		return NilPtrName
+	case ForNilSlice:
+		return NilSliceName
+	case ForNilMap:
+		return NilMapName
+	case ForNilInterface:
+		return NilInterfaceName
+	case ForZero:
+		return ZeroName
+	case ForBytes:
+		return BytesName
+	case ForMapKey:
+		return MapKeyPrefix
+	case ForMapValue:
+		return MapValuePrefix
+	case ForMapEntry:
+		return MapEntryName
+	case ForNamed:
+		return NamedPrefix
+	case ForPlaceholder:
+		return PlaceholderName
 	case ForIntX:
 		return IntXName
 	case ForUintX:
 		return UintXName
+	case ForFloatX:
+		return FloatXName
+	case ForComplexX:
+		return ComplexXName
+	case ForNumber:
+		return NumberName
 	case ForAllKinds:
 		return AllKindsName
+	case ForReference:
+		return ReferenceName
+	case ForCycle:
+		return CycleName
+	case ForTag:
+		return TagPrefix
+	case ForDefault:
+		return DefaultName
 	case Unknown:
 		return "Unknown"
 	default:
@@ -360,7 +953,15 @@ func (is ItemTypes) Swap(i, j int) {
 }
 
 func (is ItemTypes) Less(i, j int) bool {
-	return int(is[i]) < int(is[j])
+	return is[i].rank() < is[j].rank()
+}
+
+// rank is the order of the group bindings, ForAllKinds is the last one since it matches all
+func (i ItemType) rank() int {
+	if i == ForAllKinds {
+		return int(Unknown)
+	}
+	return int(i)
 }
 
 func (is ItemTypes) String() string {
@@ -420,6 +1021,30 @@ func (i orderItem) match(val reflect.Value) (ItemType, reflect.Type, reflect.Kin
 	}
 }
 
+// specificity returns the rank of the item matching a value of typ with MatchMostSpecific policy,
+// the smaller the more specific: exact type, assignable type, interface, convertible type, kind.
+func (i orderItem) specificity(typ reflect.Type) int {
+	switch {
+	case i.t == nil:
+		return 4
+	case i.t == typ:
+		return 0
+	case i.t.Kind() == reflect.Interface:
+		return 2
+	case !typ.AssignableTo(i.t):
+		return 3
+	default:
+		return 1
+	}
+}
+
+// convertible returns whether val can be converted to the type of the ForAssign item, the kinds
+// must be the same, so that e.g. an integer is not converted to a string
+func (i orderItem) convertible(val reflect.Value) bool {
+	return i.t != nil && i.t.Kind() != reflect.Interface && val.IsValid() && val.Kind() == i.t.Kind() &&
+		val.Type().ConvertibleTo(i.t)
+}
+
 func (i orderItem) String() string {
 	typ, _ := i.Type()
 	str := fmt.Sprintf("Idx:%d Order:%d Name:%s", i.i, i.o, i.n)
@@ -439,6 +1064,24 @@ func (i orderItem) String() string {
 	}
 }
 
+// applyOrder sets the orders of the items by their method names, it's an error if a name is not
+// one of the items.
+func (is orderItems) applyOrder(orders map[string]int) error {
+	for name, order := range orders {
+		found := false
+		for i := range is {
+			if is[i].n == name {
+				is[i].o = order
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("ordered binding %s not found", name)
+		}
+	}
+	return nil
+}
+
 func (is orderItems) Len() int {
 	return len(is)
 }
@@ -475,18 +1118,75 @@ func (c *TraverseConf) String() string {
 	return fmt.Sprintf("Conf{IgnoreMissedBinding:%t%s}", c.IgnoreMissedBinding, propertier)
 }
 
+// confOf returns a copy of the optional conf given to the helpers of this package, or an empty one,
+// so that they can set the options they depend on.
+func confOf(conf ...*TraverseConf) *TraverseConf {
+	if len(conf) > 0 && conf[0] != nil {
+		return conf[0].Clone()
+	}
+	return &TraverseConf{}
+}
+
 func (c *TraverseConf) Clone() *TraverseConf {
 	if c == nil {
 		return nil
 	}
 	return &TraverseConf{
-		IgnoreMissedBinding: c.IgnoreMissedBinding,
-		Propertier:          c.Propertier,
-		ContainerEnd:        c.ContainerEnd,
-		PtrAutoGoIn:         c.PtrAutoGoIn,
+		IgnoreMissedBinding:  c.IgnoreMissedBinding,
+		Propertier:           c.Propertier,
+		ContainerEnd:         c.ContainerEnd,
+		PtrAutoGoIn:          c.PtrAutoGoIn,
+		InterfaceAutoGoIn:    c.InterfaceAutoGoIn,
+		ChanLimit:            c.ChanLimit,
+		LazyAutoGoIn:         c.LazyAutoGoIn,
+		SortMapKeys:          c.SortMapKeys,
+		Version:              c.Version,
+		MaxNodes:             c.MaxNodes,
+		Deadline:             c.Deadline,
+		AsyncLeaves:          c.AsyncLeaves,
+		AsyncMinLeaves:       c.AsyncMinLeaves,
+		BestEffort:           c.BestEffort,
+		TypeBudgets:          c.TypeBudgets,
+		TrackReferences:      c.TrackReferences,
+		DetectCycles:         c.DetectCycles,
+		Addressable:          c.Addressable,
+		Roles:                c.Roles,
+		FieldNames:           c.FieldNames,
+		SkipZeroValues:       c.SkipZeroValues,
+		SampleSize:           c.SampleSize,
+		SampleSeed:           c.SampleSeed,
+		OnTypeBudgetExceeded: c.OnTypeBudgetExceeded,
+		Types:                c.Types,
+		MatchPolicy:          c.MatchPolicy,
+		ConvertibleAssign:    c.ConvertibleAssign,
+		BytesArrays:          c.BytesArrays,
 	}
 }
 
+// TraverseOption overrides the configuration of a Traveller for a single traversal, see
+// Traveller.Traverse
+type TraverseOption func(conf *TraverseConf)
+
+// WithContainerEnd overrides TraverseConf.ContainerEnd
+func WithContainerEnd(end bool) TraverseOption {
+	return func(conf *TraverseConf) { conf.ContainerEnd = end }
+}
+
+// WithSortMapKeys overrides TraverseConf.SortMapKeys
+func WithSortMapKeys(sorted bool) TraverseOption {
+	return func(conf *TraverseConf) { conf.SortMapKeys = sorted }
+}
+
+// WithMaxNodes overrides TraverseConf.MaxNodes
+func WithMaxNodes(max int) TraverseOption {
+	return func(conf *TraverseConf) { conf.MaxNodes = max }
+}
+
+// WithDeadline overrides TraverseConf.Deadline
+func WithDeadline(d time.Duration) TraverseOption {
+	return func(conf *TraverseConf) { conf.Deadline = d }
+}
+
 func (p *parentInfo) String() string {
 	if p == nil {
 		return "<nil>"
@@ -496,69 +1196,220 @@ func (p *parentInfo) String() string {
 	}
 	if p.value.Type().Kind() == reflect.Struct {
 		return fmt.Sprintf("{%s size:%d offset:%d fields:%s binding:%t}",
-			p.value.Type().Name(), p.size, p.offset, p.structFields, p.binding.IsValid())
+			p.value.Type().Name(), p.size, p.offset, p.structFields, p.binding.fn.IsValid())
 	}
 	return fmt.Sprintf("{%s size:%d offset:%d binding:%t}",
-		p.value.Type().Name(), p.size, p.offset, p.binding.IsValid())
+		p.value.Type().Name(), p.size, p.offset, p.binding.fn.IsValid())
+}
+
+// sampled returns the number of children to be visited if the container is sampled, 0 if not
+func (p *parentInfo) sampled() int {
+	if p.samples == nil {
+		return 0
+	}
+	if p.value.Kind() == reflect.Map && !p.byEntry {
+		return len(p.samples) << 1
+	}
+	return len(p.samples)
 }
 
 func (p *parentInfo) isValid() bool {
 	return p != nil && p.value.IsValid()
 }
 
-func (p *parentInfo) callIns(ctx *TravContext, val reflect.Value) []reflect.Value {
-	ret := make([]reflect.Value, 5)
-	ret[0] = reflect.ValueOf(ctx)
-	if p != nil && p.value.IsValid() {
-		ret[1] = reflect.ValueOf(p.depth)
-		if len(p.structFields) > 0 && p.offset >= 0 && p.offset < len(p.structFields) {
-			if p.structFields[p.offset].IndexForReal >= 0 {
-				ret[2] = reflect.ValueOf(p.structFields[p.offset].IndexForReal)
-			} else {
-				ret[2] = reflect.ValueOf(p.structFields[p.offset].Index)
-			}
-			ret[3] = reflect.ValueOf(p.structFields[p.offset].Name)
-		} else {
-			ret[2] = reflect.ValueOf(p.offset)
-			ret[3] = reflect.ValueOf("")
+// leafPosition returns (IndexInParent, PropertyName) of the current child for leaf bindings
+func (p *parentInfo) leafPosition() (int, string) {
+	if !p.isValid() {
+		return _rootIndex, ""
+	}
+	if len(p.structFields) > 0 && p.offset >= 0 && p.offset < len(p.structFields) {
+		if p.structFields[p.offset].IndexForReal >= 0 {
+			return p.structFields[p.offset].IndexForReal, p.structFields[p.offset].Name
 		}
-	} else {
-		ret[1] = reflect.ValueOf(0)
-		ret[2] = reflect.ValueOf(int(-1))
-		ret[3] = reflect.ValueOf("")
+		return p.structFields[p.offset].Index, p.structFields[p.offset].Name
+	}
+	return p.offset, ""
+}
+
+// containerPosition returns (IndexInParent, PropertyName) of the current child for container bindings
+func (p *parentInfo) containerPosition() (int, string) {
+	if !p.isValid() {
+		return _rootIndex, ""
+	}
+	if len(p.structFields) > 0 && p.offset >= 0 && p.offset < len(p.structFields) {
+		return p.offset, p.structFields[p.offset].Name
 	}
-	ret[4] = val
+	return p.offset, ""
+}
+
+func (p *parentInfo) currentDepth() int {
+	if !p.isValid() {
+		return 0
+	}
+	return p.depth
+}
+
+// childTrail returns the offsets of the current child and its ancestors in their parents
+func (p *parentInfo) childTrail() Cursor {
+	if !p.isValid() {
+		return Cursor{}
+	}
+	trail := make(Cursor, len(p.trail), len(p.trail)+1)
+	copy(trail, p.trail)
+	return append(trail, p.offset)
+}
+
+// childPath returns the path of the current child
+func (p *parentInfo) childPath() Path {
+	if !p.isValid() {
+		return nil
+	}
+	node := PathNode{Kind: p.value.Kind(), Index: p.offset}
+	switch node.Kind {
+	case reflect.Struct:
+		if p.offset >= 0 && p.offset < len(p.structFields) {
+			node.Index = p.structFields[p.offset].Index
+			node.Name = p.structFields[p.offset].Name
+		}
+	case reflect.Map:
+		node.Key = p.mapKey
+		node.IsKey = !p.byEntry && p.offset%2 == 0
+	}
+	path := make(Path, len(p.path), len(p.path)+1)
+	copy(path, p.path)
+	return append(path, node)
+}
+
+// storeEntries stores the addressable copies of the map values back into the map
+func (p *parentInfo) storeEntries() {
+	for i := 0; i+1 < len(p.entries); i += 2 {
+		p.value.SetMapIndex(p.entries[i], p.entries[i+1])
+	}
+	p.entries = nil
+}
+
+// addressable returns an addressable copy of val
+func addressable(val reflect.Value) reflect.Value {
+	if val.CanAddr() {
+		return val
+	}
+	ret := reflect.New(val.Type()).Elem()
+	ret.Set(val)
 	return ret
 }
 
-func (p *parentInfo) _containerIns(ctx *TravContext, info *parentInfo, startOrEnd bool, val reflect.Value) []reflect.Value {
-	ret := make([]reflect.Value, 7)
-	ret[0] = reflect.ValueOf(ctx)
-	if p != nil && p.value.IsValid() {
-		ret[1] = reflect.ValueOf(p.depth)
-		ret[2] = reflect.ValueOf(p.offset)
-		if len(p.structFields) > 0 && p.offset >= 0 && p.offset < len(p.structFields) {
-			ret[5] = reflect.ValueOf(p.structFields[p.offset].Name)
-		} else {
-			ret[5] = reflect.ValueOf("")
+// setter returns the function writing a replacement of the current child val back to its place:
+// the settable value itself, or the entry of the current key if the container is a map.
+func (p *parentInfo) setter(val reflect.Value) func(reflect.Value) error {
+	var set func(reflect.Value)
+	path := p.childPath()
+	switch {
+	case !val.IsValid():
+	case val.CanSet():
+		set = val.Set
+	case p.isValid() && p.value.Kind() == reflect.Map && p.offset%2 == 1:
+		m, key := p.value, p.mapKey
+		set = func(v reflect.Value) { m.SetMapIndex(key, v) }
+	}
+	if set == nil {
+		err := fmt.Errorf("value at %q is not settable, the root should be given by pointer", path)
+		if p.isValid() && p.value.Kind() == reflect.Map {
+			err = fmt.Errorf("map key at %q can not be replaced", path)
+		}
+		return func(reflect.Value) error { return err }
+	}
+	typ := val.Type()
+	return func(v reflect.Value) error {
+		switch {
+		case !v.IsValid():
+			v = reflect.Zero(typ)
+		case v.Type().AssignableTo(typ):
+		case v.Kind() == typ.Kind() && v.Type().ConvertibleTo(typ):
+			v = v.Convert(typ)
+		default:
+			return fmt.Errorf("replacement of type %s can not be assigned to %q of type %s", v.Type(), path, typ)
 		}
+		set(v)
+		return nil
+	}
+}
+
+func (p *parentInfo) nodeInfo(val reflect.Value, size int, forContainer bool) *NodeInfo {
+	node := &NodeInfo{
+		Depth: p.currentDepth(),
+		Size:  size,
+		Path:  p.childPath(),
+		Value: val,
+	}
+	if forContainer {
+		node.Index, node.Name = p.containerPosition()
 	} else {
-		ret[1] = reflect.ValueOf(0)
-		ret[2] = reflect.ValueOf(int(-1))
-		ret[5] = reflect.ValueOf("")
+		node.Index, node.Name = p.leafPosition()
+	}
+	if p.isValid() {
+		node.Parent = p.value
+	}
+	return node
+}
+
+func (p *parentInfo) callIns(ctx *TravContext, m boundMethod, val reflect.Value) []reflect.Value {
+	if m.v2 {
+		property := val
+		if m.itype != ForImpl && m.itype != ForAssign {
+			property = reflect.ValueOf(val)
+		}
+		node := p.nodeInfo(val, 0, false)
+		node.Seq = ctx.seq()
+		if m.itype == ForNumber {
+			nk, _ := numberKindOf(val.Kind())
+			return []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node), reflect.ValueOf(nk), property}
+		}
+		return []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node), property}
+	}
+	index, name := p.leafPosition()
+	ret := make([]reflect.Value, 5, 6)
+	ret[0] = reflect.ValueOf(ctx)
+	ret[1] = reflect.ValueOf(p.currentDepth())
+	ret[2] = reflect.ValueOf(index)
+	ret[3] = reflect.ValueOf(name)
+	ret[4] = m.propertyOf(val)
+	if m.itype == ForBytes {
+		ret[4] = reflect.ValueOf(bytesOf(val))
+	}
+	if m.itype == ForNumber {
+		nk, _ := numberKindOf(val.Kind())
+		ret = append(ret[:4], reflect.ValueOf(nk), val)
 	}
-	ret[3] = reflect.ValueOf(info.size)
-	ret[4] = reflect.ValueOf(startOrEnd)
-	ret[6] = val
 	return ret
 }
 
-func (p *parentInfo) startContainerIns(ctx *TravContext, info *parentInfo, val reflect.Value) []reflect.Value {
-	return p._containerIns(ctx, info, true, val)
+func (p *parentInfo) containerIns(ctx *TravContext, m boundMethod, info *parentInfo, startOrEnd bool, val reflect.Value) []reflect.Value {
+	if m.v2 {
+		node := p.nodeInfo(val, info.size, true)
+		node.OneOf = info.oneofs
+		node.Seq = info.seq
+		node.Sampled = info.sampled()
+		return []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(node), reflect.ValueOf(startOrEnd), reflect.ValueOf(val)}
+	}
+	index, name := p.containerPosition()
+	ret := make([]reflect.Value, 7)
+	ret[0] = reflect.ValueOf(ctx)
+	ret[1] = reflect.ValueOf(p.currentDepth())
+	ret[2] = reflect.ValueOf(index)
+	ret[3] = reflect.ValueOf(info.size)
+	ret[4] = reflect.ValueOf(startOrEnd)
+	ret[5] = reflect.ValueOf(name)
+	ret[6] = m.propertyOf(val)
+	return ret
 }
 
-func (p *parentInfo) endContainerIns(ctx *TravContext, info *parentInfo, val reflect.Value) []reflect.Value {
-	return p._containerIns(ctx, info, false, val)
+// propertyOf returns val as the Property argument of the v1 method, e.g. a named string
+// converted to string for ForKindString(..., property string).
+func (m boundMethod) propertyOf(val reflect.Value) reflect.Value {
+	if m.property == nil || val.Type() == m.property {
+		return val
+	}
+	return val.Convert(m.property)
 }
 
 func (p *parentInfo) nextDepth() int {
@@ -568,19 +1419,471 @@ func (p *parentInfo) nextDepth() int {
 	return p.depth + 1
 }
 
+// callLeaf calls the leaf binding with ins, and writes the replacement back with set if the
+// binding returns one.
+func (m boundMethod) callLeaf(ins []reflect.Value, set func(reflect.Value) error) error {
+	outs := m.fn.Call(ins)
+	if !m.writeBack {
+		_, err := m.itype.parseReturns(outs)
+		return err
+	}
+	newVal, changed, err := parseWriteBack(outs)
+	if changed && (err == nil || errors.Is(err, ErrSkipContainer) || errors.Is(err, ErrStopTraversal)) {
+		if werr := set(newVal); werr != nil {
+			return werr
+		}
+	}
+	return err
+}
+
+func (m boundMethod) callContainer(ctx *TravContext, parent, info *parentInfo, startOrEnd bool,
+	val reflect.Value) (goin bool, err error) {
+	outs := m.fn.Call(parent.containerIns(ctx, m, info, startOrEnd, val))
+	return ForContainer.parseReturns(outs)
+}
+
+func (n PathNode) String() string {
+	switch n.Kind {
+	case reflect.Struct:
+		return "." + n.Name
+	case reflect.Array, reflect.Slice, reflect.Chan:
+		return fmt.Sprintf("[%d]", n.Index)
+	case reflect.Map:
+		key := "<invalid>"
+		if n.Key.IsValid() && n.Key.CanInterface() {
+			key = fmt.Sprintf("%v", n.Key.Interface())
+		}
+		if n.IsKey {
+			return "{" + key + "}"
+		}
+		return "[" + key + "]"
+	default:
+		return ""
+	}
+}
+
+// String returns the path like A.B[1][key].C, pointers are transparent, map keys are surrounded by {}
+func (p Path) String() string {
+	var buf strings.Builder
+	for _, n := range p {
+		buf.WriteString(n.String())
+	}
+	return strings.TrimPrefix(buf.String(), ".")
+}
+
+func (n *NodeInfo) String() string {
+	if n == nil {
+		return "Node<nil>"
+	}
+	return fmt.Sprintf("Node{Depth:%d Index:%d Name:%s Size:%d Path:%s}", n.Depth, n.Index, n.Name, n.Size, n.Path)
+}
+
+// PartialError is returned if the traversal stopped by TraverseConf.MaxNodes or Deadline, the
+// values before Path were visited, so that the results of the bindings are partial.
+type PartialError struct {
+	Err     error         // ErrBudgetExceeded or ErrDeadlineExceeded
+	Path    Path          // path of the value where the traversal stopped, it's not visited
+	Cursor  Cursor        // position of the value, the traversal can be continued by Traveller.TraverseFrom
+	Visited int           // number of values visited
+	Depth   int           // depth of the last visited value
+	Elapsed time.Duration // duration of the traversal
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%v at %s, %d values visited in %s", e.Err, e.Path, e.Visited, e.Elapsed)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
 type TravContext struct {
+	std    context.Context
 	locals sync.Map
+	// statistics of the current traversal, updated atomically
+	depth     int64
+	visited   int64
+	budget    int64     // max nodes, 0 for unlimited
+	started   time.Time // start time of the current traversal
+	deadline  time.Time // zero for unlimited
+	resume    Cursor    // values before it are skipped, nil if reached or not resuming
+	workers   chan struct{}
+	collector *OrderedCollector
+	root      reflect.Value // root object of the current traversal, for Resolve
+	names     NameMapping   // TraverseConf.FieldNames of the current traversal, for Resolve
+
+	diagLock    sync.Mutex
+	diagnostics Diagnostics
+
+	typeLock   sync.Mutex
+	typeCounts map[reflect.Type]int // visited values of types with budget
+	typeSkips  map[reflect.Type]int // skipped values of types exceeding budget
+
+	refLock sync.Mutex
+	refs    map[refKey]Path      // identities of visited references -> their paths
+	actives map[refKey]*NodeInfo // identities of references being traversed -> their nodes
+}
+
+// refKey is the identity of a reference value: pointer, map or slice
+type refKey struct {
+	typ reflect.Type
+	ptr uintptr
+	len int
+}
+
+// Diagnostic is a failure of a value in BestEffort traversal
+type Diagnostic struct {
+	Path Path
+	Err  error
+}
+
+// Diagnostics are all failures of a BestEffort traversal
+type Diagnostics []Diagnostic
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %v", d.Path, d.Err)
+}
+
+func (d Diagnostic) Unwrap() error {
+	return d.Err
+}
+
+func (ds Diagnostics) Error() string {
+	strs := make([]string, 0, len(ds))
+	for _, d := range ds {
+		strs = append(strs, d.String())
+	}
+	return fmt.Sprintf("%d failures: %s", len(ds), strings.Join(strs, "; "))
 }
 
 func NewContext() *TravContext {
 	return &TravContext{locals: sync.Map{}}
 }
 
+// NewContextWithValues returns a new context with request-scoped values, which are available for
+// bindings by GetLocal
+func NewContextWithValues(values map[interface{}]interface{}) *TravContext {
+	c := NewContext()
+	for k, v := range values {
+		c.locals.Store(k, v)
+	}
+	return c
+}
+
+// NewContextFrom returns a new context wrapping std, values of std are available by GetLocal if
+// they are not overridden by PutLocal, and the traversal stops with std.Err() once std is done.
+func NewContextFrom(std context.Context) *TravContext {
+	c := NewContext()
+	c.std = std
+	return c
+}
+
+// Std returns the wrapped context.Context, context.Background() if there's none
+func (c *TravContext) Std() context.Context {
+	if c.std == nil {
+		return context.Background()
+	}
+	return c.std
+}
+
 func (c *TravContext) GetLocal(key interface{}) (interface{}, bool) {
-	return c.locals.Load(key)
+	if v, ok := c.locals.Load(key); ok {
+		return v, true
+	}
+	if c.std != nil {
+		if v := c.std.Value(key); v != nil {
+			return v, true
+		}
+	}
+	return nil, false
 }
 
 func (c *TravContext) PutLocal(key, val interface{}) *TravContext {
 	c.locals.Store(key, val)
 	return c
 }
+
+// Depth returns the depth of the value being visited, 0 for the root object
+func (c *TravContext) Depth() int {
+	return int(atomic.LoadInt64(&c.depth))
+}
+
+// Visited returns the number of values visited so far in the current traversal, including the
+// value being visited
+func (c *TravContext) Visited() int {
+	return int(atomic.LoadInt64(&c.visited))
+}
+
+// Budget returns the number of values could be visited after the current one, limited is false if
+// TraverseConf.MaxNodes is not set.
+func (c *TravContext) Budget() (remaining int, limited bool) {
+	budget := atomic.LoadInt64(&c.budget)
+	if budget <= 0 {
+		return 0, false
+	}
+	remaining = int(budget - atomic.LoadInt64(&c.visited))
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+func (g *leafGroup) failed() error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.err
+}
+
+// submit runs fn in a new goroutine once there's an idle worker, returns the first error of the
+// group without running fn if any. A panic of fn is recovered as the error of it.
+func (g *leafGroup) submit(workers chan struct{}, fn func() error) error {
+	if err := g.failed(); err != nil {
+		return err
+	}
+	workers <- struct{}{}
+	g.wg.Add(1)
+	go func() {
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+			if err != nil && !errors.Is(err, ErrSkipContainer) {
+				g.lock.Lock()
+				if g.err == nil {
+					g.err = err
+				}
+				g.lock.Unlock()
+			}
+			<-workers
+			g.wg.Done()
+		}()
+		err = fn()
+	}()
+	return nil
+}
+
+func (g *leafGroup) wait() error {
+	g.wg.Wait()
+	return g.failed()
+}
+
+// Diagnostics returns the failures recorded so far in the BestEffort traversal
+func (c *TravContext) Diagnostics() Diagnostics {
+	c.diagLock.Lock()
+	defer c.diagLock.Unlock()
+	return append(Diagnostics(nil), c.diagnostics...)
+}
+
+func (c *TravContext) diagnose(path Path, err error) {
+	c.diagLock.Lock()
+	defer c.diagLock.Unlock()
+	c.diagnostics = append(c.diagnostics, Diagnostic{Path: path, Err: err})
+}
+
+// countType counts a value of typ, returns false if it exceeds budget and should be skipped
+func (c *TravContext) countType(typ reflect.Type, budget int) bool {
+	c.typeLock.Lock()
+	defer c.typeLock.Unlock()
+	if c.typeCounts == nil {
+		c.typeCounts = make(map[reflect.Type]int)
+		c.typeSkips = make(map[reflect.Type]int)
+	}
+	if c.typeCounts[typ] >= budget {
+		c.typeSkips[typ]++
+		return false
+	}
+	c.typeCounts[typ]++
+	return true
+}
+
+func (c *TravContext) skippedTypes() map[reflect.Type]int {
+	c.typeLock.Lock()
+	defer c.typeLock.Unlock()
+	return c.typeSkips
+}
+
+// referenceOf returns the identity of val if it's a non-nil pointer, map or a non-empty slice, and
+// the pointed memory is not of zero size (which may be shared by different values).
+func referenceOf(val reflect.Value) (refKey, bool) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() || val.Type().Elem().Size() == 0 {
+			return refKey{}, false
+		}
+		return refKey{typ: val.Type(), ptr: val.Pointer()}, true
+	case reflect.Map:
+		if val.IsNil() {
+			return refKey{}, false
+		}
+		return refKey{typ: val.Type(), ptr: val.Pointer()}, true
+	case reflect.Slice:
+		if val.Len() == 0 || val.Type().Elem().Size() == 0 {
+			return refKey{}, false
+		}
+		return refKey{typ: val.Type(), ptr: val.Pointer(), len: val.Len()}, true
+	default:
+		return refKey{}, false
+	}
+}
+
+// reference returns the path of the value identical to val visited before if any, otherwise
+// records val with path.
+func (c *TravContext) reference(val reflect.Value, path func() Path) (Path, bool) {
+	key, ok := referenceOf(val)
+	if !ok {
+		return nil, false
+	}
+	c.refLock.Lock()
+	defer c.refLock.Unlock()
+	if c.refs == nil {
+		c.refs = make(map[refKey]Path)
+	}
+	if p, exist := c.refs[key]; exist {
+		return p, true
+	}
+	c.refs[key] = path()
+	return nil, false
+}
+
+// enter records val as being traversed, returns the node of the ancestor identical to val if any.
+// ok is false if val is not a reference.
+func (c *TravContext) enter(val reflect.Value, node func() *NodeInfo) (key refKey, ancestor *NodeInfo, ok bool) {
+	if key, ok = referenceOf(val); !ok {
+		return key, nil, false
+	}
+	c.refLock.Lock()
+	defer c.refLock.Unlock()
+	if c.actives == nil {
+		c.actives = make(map[refKey]*NodeInfo)
+	}
+	if ancestor, exist := c.actives[key]; exist {
+		return key, ancestor, true
+	}
+	c.actives[key] = node()
+	return key, nil, true
+}
+
+// leave removes the reference from the values being traversed
+func (c *TravContext) leave(key refKey) {
+	c.refLock.Lock()
+	delete(c.actives, key)
+	c.refLock.Unlock()
+}
+
+func (c *TravContext) reset(maxNodes int) {
+	atomic.StoreInt64(&c.depth, 0)
+	atomic.StoreInt64(&c.visited, 0)
+	atomic.StoreInt64(&c.budget, int64(maxNodes))
+	c.started, c.deadline = time.Now(), time.Time{}
+	c.resume = nil
+	c.workers = nil
+	c.diagLock.Lock()
+	c.diagnostics = nil
+	c.diagLock.Unlock()
+	c.typeLock.Lock()
+	c.typeCounts, c.typeSkips = nil, nil
+	c.typeLock.Unlock()
+	c.refLock.Lock()
+	c.refs, c.actives = nil, nil
+	c.refLock.Unlock()
+}
+
+// Resolve returns the value at path in the root object of the current traversal, so that a binding
+// can look at another location (e.g. a sibling referenced by ID) without traversing again. Pointers
+// and interfaces are dereferenced if the path doesn't step into them. Struct fields are located by
+// Index if it's the field of Name (a wire name of TraverseConf.FieldNames is mapped back to its Go
+// name), which is always true for the paths of the traversal, otherwise by Name for hand-written
+// paths, or by Index if Name is empty. The value can be set if the root was passed by pointer.
+func (c *TravContext) Resolve(path Path) (reflect.Value, error) {
+	val := c.root
+	if !val.IsValid() {
+		return reflect.Value{}, errors.New("no root object to resolve the path in")
+	}
+	for i, n := range path {
+		for val.Kind() != n.Kind && (val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface) && !val.IsNil() {
+			val = val.Elem()
+		}
+		var next reflect.Value
+		if n.Kind == reflect.Struct && n.Name != "" && val.Kind() == reflect.Struct {
+			next = c.structField(val, n)
+		} else {
+			next = stepValue(val, n)
+		}
+		if !next.IsValid() {
+			return reflect.Value{}, fmt.Errorf("no value at %s in %s", path[:i+1], c.root.Type())
+		}
+		val = next
+	}
+	return val, nil
+}
+
+// structField returns the field of struct val at n, which has a Name
+func (c *TravContext) structField(val reflect.Value, n PathNode) reflect.Value {
+	name := n.Name
+	if c.names != nil {
+		if field, ok := c.names.FieldName(val.Type(), name); ok {
+			name = field
+		}
+	}
+	if n.Index >= 0 && n.Index < val.NumField() && val.Type().Field(n.Index).Name == name {
+		return val.Field(n.Index)
+	}
+	return val.FieldByName(name)
+}
+
+// SetCollector sets the collector of the outputs of bindings, which should be set before traversal
+// if it's used by asynchronous bindings.
+func (c *TravContext) SetCollector(collector *OrderedCollector) *TravContext {
+	c.collector = collector
+	return c
+}
+
+func (c *TravContext) Collector() *OrderedCollector {
+	return c.collector
+}
+
+// seq returns the sequence number of the value being visited
+func (c *TravContext) seq() int {
+	return int(atomic.LoadInt64(&c.visited)) - 1
+}
+
+func (c *TravContext) setDepth(depth int) {
+	atomic.StoreInt64(&c.depth, int64(depth))
+}
+
+// stopped returns the error of visit at the current child of parent, a PartialError for ErrBudgetExceeded and
+// ErrDeadlineExceeded
+func (c *TravContext) stopped(err error, parent *parentInfo) error {
+	path := parent.childPath()
+	if errors.Is(err, ErrBudgetExceeded) || errors.Is(err, ErrDeadlineExceeded) {
+		return &PartialError{
+			Err:     err,
+			Path:    path,
+			Cursor:  parent.childTrail(),
+			Visited: c.Visited() - 1,
+			Depth:   c.Depth(),
+			Elapsed: time.Since(c.started),
+		}
+	}
+	return fmt.Errorf("%w at %s", err, path)
+}
+
+// visit counts a value at depth, returns ErrBudgetExceeded if there's no budget for it,
+// ErrDeadlineExceeded if the deadline passed, or the error of the wrapped context if it's done
+func (c *TravContext) visit(depth int) error {
+	if c.std != nil {
+		if err := c.std.Err(); err != nil {
+			return err
+		}
+	}
+	visited := atomic.AddInt64(&c.visited, 1)
+	if budget := atomic.LoadInt64(&c.budget); budget > 0 && visited > budget {
+		return ErrBudgetExceeded
+	}
+	if !c.deadline.IsZero() && time.Now().After(c.deadline) {
+		return ErrDeadlineExceeded
+	}
+	c.setDepth(depth)
+	return nil
+}
@@ -0,0 +1,117 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"strings"
+)
+
+// viewer is the adapter zeroing the values of a copy which are not on the allowed paths
+type viewer struct {
+	allows []string
+}
+
+// isPathPrefix returns whether path is prefix of (or the same as) the path q
+func isPathPrefix(path, q string) bool {
+	if !strings.HasPrefix(q, path) {
+		return false
+	}
+	if len(q) == len(path) || path == "" {
+		return true
+	}
+	switch q[len(path)] {
+	case '.', '[', '{':
+		return true
+	}
+	return false
+}
+
+// match returns whether the value of node is allowed (with its descendants), and whether it's on
+// the way to an allowed value
+func (v viewer) match(node *NodeInfo) (allowed, onTheWay bool) {
+	path, pattern := node.Path.String(), pathPattern(node.Path)
+	for _, q := range v.allows {
+		if q == path || q == pattern {
+			return true, true
+		}
+		if isPathPrefix(path, q) || isPathPrefix(pattern, q) {
+			onTheWay = true
+		}
+	}
+	return false, onTheWay
+}
+
+func (v viewer) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) (interface{}, bool, error) {
+	if isMapKey(node.Path) {
+		return nil, false, nil
+	}
+	if allowed, _ := v.match(node); allowed {
+		return nil, false, nil
+	}
+	return nil, !val.IsZero(), nil
+}
+
+func (v viewer) container(node *NodeInfo, val reflect.Value) (bool, error) {
+	if isMapKey(node.Path) {
+		return false, nil
+	}
+	allowed, onTheWay := v.match(node)
+	if allowed {
+		return false, nil
+	}
+	if onTheWay {
+		return true, nil
+	}
+	if val.CanSet() {
+		val.Set(reflect.Zero(val.Type()))
+	}
+	return false, nil
+}
+
+func (v viewer) ForContainerArray(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return v.container(node, val)
+}
+
+func (v viewer) ForContainerMap(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return v.container(node, val)
+}
+
+func (v viewer) ForContainerPtr(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return v.container(node, val)
+}
+
+func (v viewer) ForContainerSlice(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return v.container(node, val)
+}
+
+func (v viewer) ForContainerStruct(_ *TravContext, node *NodeInfo, _ bool, val reflect.Value) (bool, error) {
+	return v.container(node, val)
+}
+
+// View returns a copy of obj in which only the values at allowPaths (with their descendants) are
+// kept, others are zeroed (map entries are kept with zero values). A path is either exact (e.g.
+// "Users[0].Name") or a pattern matching any index and map value with [*] (e.g. "Users[*].Name").
+// obj itself is left untouched.
+func View(obj interface{}, allowPaths ...string) (interface{}, error) {
+	tr, err := NewTraveller(viewer{allows: allowPaths}, &TraverseConf{Addressable: true, DetectCycles: true})
+	if err != nil {
+		return nil, err
+	}
+	return tr.Transform(obj)
+}
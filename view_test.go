@@ -0,0 +1,67 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	type user struct {
+		Name  string
+		Email string
+		Roles []string
+	}
+	type response struct {
+		Total  int
+		Users  []user
+		Owner  *user
+		Extras map[string]user
+	}
+	resp := &response{
+		Total:  2,
+		Users:  []user{{Name: "a", Email: "a@x", Roles: []string{"admin"}}, {Name: "b", Email: "b@x"}},
+		Owner:  &user{Name: "o", Email: "o@x"},
+		Extras: map[string]user{"k": {Name: "e", Email: "e@x"}},
+	}
+	ret, err := View(resp, "Total", "Users[*].Name", "Users[0].Roles", "Owner", "Extras[*].Email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &response{
+		Total:  2,
+		Users:  []user{{Name: "a", Roles: []string{"admin"}}, {Name: "b"}},
+		Owner:  &user{Name: "o", Email: "o@x"},
+		Extras: map[string]user{"k": {Email: "e@x"}},
+	}
+	if !reflect.DeepEqual(ret, expect) {
+		t.Fatalf("view: %+v, expecting %+v", ret, expect)
+	}
+	if resp.Users[0].Email != "a@x" || resp.Extras["k"].Name != "e" {
+		t.Fatalf("original modified: %+v", resp)
+	}
+
+	ret, err = View(*resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ret, response{}) {
+		t.Fatalf("empty view: %+v", ret)
+	}
+}
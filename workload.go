@@ -0,0 +1,128 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"reflect"
+	"sync"
+)
+
+// maxLeafSample is the max number of children sampled to estimate the leaves of a container of a
+// type never seen before
+const maxLeafSample = 8
+
+type (
+	// leafStat is the running statistics of the containers of a type: their children and the leaf
+	// bindings called directly under them
+	leafStat struct {
+		children int64
+		leaves   int64
+	}
+
+	// leafStats estimates the leaf bindings under containers by the statistics of their types, for
+	// TraverseConf.AsyncMinLeaves. It's shared by the copies of WithConf.
+	leafStats struct {
+		lock  sync.Mutex
+		types map[reflect.Type]*leafStat
+	}
+)
+
+func newLeafStats() *leafStats {
+	return &leafStats{types: make(map[reflect.Type]*leafStat)}
+}
+
+// record adds the number of children and leaf bindings of a container of typ to the statistics
+func (s *leafStats) record(typ reflect.Type, children, leaves int) {
+	if children <= 0 {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	stat, ok := s.types[typ]
+	if !ok {
+		stat = &leafStat{}
+		s.types[typ] = stat
+	}
+	stat.children += int64(children)
+	stat.leaves += int64(leaves)
+}
+
+// estimate returns the estimated number of leaf bindings to be called directly under the container
+// of info: its size times the leaves per child of the containers of the same type seen before, or
+// of a sample of its children if none has been seen.
+func (s *leafStats) estimate(info *parentInfo) int {
+	s.lock.Lock()
+	stat, ok := s.types[info.value.Type()]
+	var children, leaves int64
+	if ok {
+		children, leaves = stat.children, stat.leaves
+	}
+	s.lock.Unlock()
+	if ok {
+		return int(int64(info.size) * leaves / children)
+	}
+	return sampleLeaves(info)
+}
+
+// sampleLeaves estimates the leaves under the container of info by the leaf children in a sample
+// of at most maxLeafSample children, without going into them.
+func sampleLeaves(info *parentInfo) int {
+	val := info.value
+	sampled, leaves := 0, 0
+	count := func(v reflect.Value) {
+		sampled++
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+		}
+		if _, container := _containers[v.Kind()]; !container {
+			leaves++
+		}
+	}
+	switch val.Kind() {
+	case reflect.Array, reflect.Slice:
+		step := 1
+		if n := val.Len(); n > maxLeafSample {
+			step = n / maxLeafSample
+		}
+		for i := 0; i < val.Len() && sampled < maxLeafSample; i += step {
+			count(val.Index(i))
+		}
+	case reflect.Map:
+		for it := val.MapRange(); sampled < maxLeafSample && it.Next(); {
+			count(it.Key())
+			count(it.Value())
+		}
+	case reflect.Struct:
+		for _, field := range info.structFields {
+			if sampled >= maxLeafSample {
+				break
+			}
+			if field.Index >= 0 {
+				count(val.Field(field.Index))
+			}
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !val.IsNil() {
+			count(val.Elem())
+		}
+	}
+	if sampled == 0 {
+		return 0
+	}
+	return info.size * leaves / sampled
+}
@@ -0,0 +1,495 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A YAML encoder built on the traversal, values are written in block style:
+//
+//	struct: mapping of field names in the order given by the Propertier (if any)
+//	map: mapping in the order of sorted keys, keys must be scalars
+//	slice/array: sequence, []byte is written as a !!binary scalar
+//	pointer: the pointed value, nil pointer is null
+//	string: plain or double-quoted scalar, multi-line strings as literal block scalars
+//	interface: null or the value held, tagged with the name registered in TraverseConf.Types if any
+//
+// Pointers, maps and slices shared by more than one place (including cycles) are written once with
+// an anchor, and referenced by aliases in other places.
+
+type (
+	yamlKind int
+
+	yamlNode struct {
+		kind     yamlKind
+		tag      string
+		text     string      // plain or quoted scalar
+		literal  bool        // whether the scalar is written as a literal block scalar of raw
+		raw      string      // raw string of the scalar
+		children []*yamlNode // items of a sequence, keys and values of a mapping, or the pointed node
+		shared   bool        // referenced by aliases
+		anchor   string
+		target   *yamlNode // of alias
+	}
+
+	// yamlScanner finds the values referenced more than once
+	yamlScanner struct {
+		goInAll
+		shared map[refKey]struct{}
+	}
+
+	// yamlBuilder builds the tree of yamlNode, which is written after traversal since the anchor of a
+	// pointer can only be placed on the node it points to.
+	yamlBuilder struct {
+		conf    *TraverseConf
+		shared  map[refKey]struct{}
+		targets map[refKey]*yamlNode
+		stack   []*yamlNode
+		root    *yamlNode
+	}
+
+	yamlWriter struct {
+		w       *bufio.Writer
+		anchors int
+	}
+)
+
+const (
+	yamlScalar yamlKind = iota
+	yamlSequence
+	yamlMapping
+	yamlStruct
+	yamlPointer
+	yamlInterface // the value held by an interface, whose tag is given to the value
+	yamlAlias
+)
+
+func (s yamlScanner) ForNilPtr(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (s yamlScanner) ForAllKinds(_ *TravContext, _ *NodeInfo, _ reflect.Value) error {
+	return nil
+}
+
+func (s yamlScanner) ForContainerInterface(_ *TravContext, _ *NodeInfo, _ bool, _ reflect.Value) (bool, error) {
+	return true, nil
+}
+
+func (s yamlScanner) ForReference(_ *TravContext, _ *NodeInfo, _ Path, val reflect.Value) error {
+	if key, ok := referenceOf(val); ok {
+		s.shared[key] = struct{}{}
+	}
+	return nil
+}
+
+// add appends n to the current container
+func (b *yamlBuilder) add(node *NodeInfo, n *yamlNode) error {
+	if len(b.stack) == 0 {
+		b.root = n
+		return nil
+	}
+	top := b.stack[len(b.stack)-1]
+	if top.kind == yamlStruct {
+		top.children = append(top.children, yamlString(node.Name))
+	} else if top.kind == yamlInterface {
+		n.tag = top.tag
+	} else if top.kind == yamlMapping && len(top.children)%2 == 0 && n.kind != yamlScalar && n.kind != yamlInterface {
+		return fmt.Errorf("yaml: unsupported map key %s at %s", node.Value.Type(), node.Path)
+	}
+	top.children = append(top.children, n)
+	return nil
+}
+
+// start adds the container node n, and records it if it's referenced by others
+func (b *yamlBuilder) start(node *NodeInfo, val reflect.Value, n *yamlNode, goin bool) (bool, error) {
+	if key, ok := referenceOf(val); ok {
+		if _, shared := b.shared[key]; shared {
+			n.shared = true
+			b.targets[key] = n
+		}
+	}
+	if err := b.add(node, n); err != nil {
+		return false, err
+	}
+	if goin {
+		b.stack = append(b.stack, n)
+	}
+	return goin, nil
+}
+
+func (b *yamlBuilder) end() (bool, error) {
+	if len(b.stack) == 0 {
+		return false, errors.New("yaml: container stack is empty")
+	}
+	b.stack = b.stack[:len(b.stack)-1]
+	return false, nil
+}
+
+func (b *yamlBuilder) container(node *NodeInfo, startOrEnd bool, val reflect.Value, kind yamlKind) (bool, error) {
+	if !startOrEnd {
+		return b.end()
+	}
+	return b.start(node, val, &yamlNode{kind: kind}, true)
+}
+
+func (b *yamlBuilder) ForNilPtr(_ *TravContext, node *NodeInfo, _ reflect.Value) error {
+	return b.add(node, &yamlNode{kind: yamlScalar, text: "null"})
+}
+
+func (b *yamlBuilder) ForAllKinds(_ *TravContext, node *NodeInfo, val reflect.Value) error {
+	n, err := b.scalar(val)
+	if err != nil {
+		return fmt.Errorf("%v at %s", err, node.Path)
+	}
+	return b.add(node, n)
+}
+
+func (b *yamlBuilder) ForReference(_ *TravContext, node *NodeInfo, target Path, val reflect.Value) error {
+	key, _ := referenceOf(val)
+	n, ok := b.targets[key]
+	if !ok {
+		return fmt.Errorf("yaml: anchor of %s not found for %s", target, node.Path)
+	}
+	return b.add(node, &yamlNode{kind: yamlAlias, target: n})
+}
+
+func (b *yamlBuilder) ForContainerArray(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val, yamlSequence)
+}
+
+// ForContainerInterface goes into the value held by the interface, which is tagged with the name
+// registered in TraverseConf.Types if any
+func (b *yamlBuilder) ForContainerInterface(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if !startOrEnd {
+		return b.end()
+	}
+	if val.IsNil() {
+		return false, b.add(node, &yamlNode{kind: yamlScalar, text: "null"})
+	}
+	n := &yamlNode{kind: yamlInterface}
+	if b.conf.Types != nil {
+		if name, ok := b.conf.Types.NameOf(val.Type(), val); ok {
+			n.tag = "!" + name
+		}
+	}
+	return b.start(node, val, n, true)
+}
+
+func (b *yamlBuilder) ForContainerMap(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val, yamlMapping)
+}
+
+func (b *yamlBuilder) ForContainerPtr(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val, yamlPointer)
+}
+
+func (b *yamlBuilder) ForContainerSlice(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	if startOrEnd && val.Type().Elem().Kind() == reflect.Uint8 {
+		n := &yamlNode{kind: yamlScalar, tag: "!!binary", text: base64.StdEncoding.EncodeToString(val.Bytes())}
+		return b.start(node, val, n, false)
+	}
+	return b.container(node, startOrEnd, val, yamlSequence)
+}
+
+func (b *yamlBuilder) ForContainerStruct(_ *TravContext, node *NodeInfo, startOrEnd bool, val reflect.Value) (bool, error) {
+	return b.container(node, startOrEnd, val, yamlStruct)
+}
+
+func (b *yamlBuilder) scalar(val reflect.Value) (*yamlNode, error) {
+	switch val.Kind() {
+	case reflect.Bool:
+		return &yamlNode{kind: yamlScalar, text: strconv.FormatBool(val.Bool())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &yamlNode{kind: yamlScalar, text: strconv.FormatInt(val.Int(), 10)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &yamlNode{kind: yamlScalar, text: strconv.FormatUint(val.Uint(), 10)}, nil
+	case reflect.Float32:
+		return &yamlNode{kind: yamlScalar, text: yamlFloat(val.Float(), 32)}, nil
+	case reflect.Float64:
+		return &yamlNode{kind: yamlScalar, text: yamlFloat(val.Float(), 64)}, nil
+	case reflect.Complex64:
+		return yamlString(yamlComplex(val.Complex(), 32)), nil
+	case reflect.Complex128:
+		return yamlString(yamlComplex(val.Complex(), 64)), nil
+	case reflect.String:
+		return yamlString(val.String()), nil
+	default:
+		return nil, fmt.Errorf("yaml: unsupported type %s", val.Type())
+	}
+}
+
+func yamlFloat(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return ".nan"
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".eEn") {
+		// keep it a float when it's read back
+		s += ".0"
+	}
+	return s
+}
+
+// yamlComplex formats c like strconv.FormatComplex, which is not available before go1.15
+func yamlComplex(c complex128, bits int) string {
+	im := strconv.FormatFloat(imag(c), 'g', -1, bits)
+	if im[0] != '+' && im[0] != '-' {
+		im = "+" + im
+	}
+	return "(" + strconv.FormatFloat(real(c), 'g', -1, bits) + im + "i)"
+}
+
+var _yamlReserved = map[string]struct{}{
+	"~": {}, "null": {}, "true": {}, "false": {}, "yes": {}, "no": {}, "on": {}, "off": {},
+	"y": {}, "n": {}, ".inf": {}, "-.inf": {}, "+.inf": {}, ".nan": {},
+}
+
+// _yamlTimestamp matches the strings read as timestamps by YAML 1.1, e.g. 2001-12-14
+var _yamlTimestamp = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt]|[ \t]+|$)`)
+
+// yamlString returns the scalar node of string s, which is plain if it can not be read as another
+// type, otherwise double-quoted.
+func yamlString(s string) *yamlNode {
+	n := &yamlNode{kind: yamlScalar, raw: s}
+	if yamlPlain(s) {
+		n.text = s
+		return n
+	}
+	n.text = strconv.Quote(s)
+	if strings.Contains(s, "\n") && !strings.HasPrefix(strings.TrimLeft(s, "\n"), " ") &&
+		strings.TrimLeft(s, "\n") != "" && strings.IndexFunc(s, func(r rune) bool {
+		return r != '\n' && r != '\t' && !unicode.IsPrint(r)
+	}) < 0 {
+		n.literal = true
+	}
+	return n
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return false
+	}
+	if _, reserved := _yamlReserved[strings.ToLower(s)]; reserved {
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseInt(s, 0, 64); err == nil {
+		return false
+	}
+	if _yamlTimestamp.MatchString(s) {
+		return false
+	}
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(s[0])) {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	return strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) }) < 0
+}
+
+// resolve returns the node pointed by n through pointers, and whether it should be anchored
+func (n *yamlNode) resolve() (*yamlNode, bool) {
+	shared := n.shared
+	for n.kind == yamlPointer || n.kind == yamlInterface {
+		n = n.children[0]
+		shared = shared || n.shared
+	}
+	return n, shared
+}
+
+func (y *yamlWriter) indent(depth int) {
+	y.w.WriteString("\n")
+	y.w.WriteString(strings.Repeat(" ", depth))
+}
+
+// properties returns the anchor and tag of node n
+func (y *yamlWriter) properties(n *yamlNode, shared bool) string {
+	var props []string
+	if shared {
+		if n.anchor == "" {
+			y.anchors++
+			n.anchor = "a" + strconv.Itoa(y.anchors)
+		}
+		props = append(props, "&"+n.anchor)
+	}
+	if n.tag != "" {
+		props = append(props, n.tag)
+	}
+	return strings.Join(props, " ")
+}
+
+// value writes node n after a mapping key or a sequence indicator (prefix), or at the beginning of
+// the document. Entries of collections are written at depth, the first one is written in the current
+// line if inline.
+func (y *yamlWriter) value(n *yamlNode, depth int, prefix, inline bool) error {
+	n, shared := n.resolve()
+	if n.kind == yamlAlias {
+		target, _ := n.target.resolve()
+		if target.anchor == "" {
+			return errors.New("yaml: alias before its anchor")
+		}
+		y.space(prefix)
+		y.w.WriteString("*" + target.anchor)
+		return nil
+	}
+	if props := y.properties(n, shared); props != "" {
+		y.space(prefix)
+		y.w.WriteString(props)
+		prefix, inline = true, false
+	}
+	switch n.kind {
+	case yamlScalar:
+		y.space(prefix)
+		if !n.literal {
+			y.w.WriteString(n.text)
+			return nil
+		}
+		raw := n.raw
+		switch {
+		case !strings.HasSuffix(raw, "\n"):
+			y.w.WriteString("|-")
+		case strings.HasSuffix(raw, "\n\n"):
+			y.w.WriteString("|+")
+			raw = raw[:len(raw)-1]
+		default:
+			y.w.WriteString("|")
+			raw = raw[:len(raw)-1]
+		}
+		for _, line := range strings.Split(raw, "\n") {
+			if line == "" {
+				y.w.WriteString("\n")
+			} else {
+				y.indent(depth)
+				y.w.WriteString(line)
+			}
+		}
+		return nil
+	case yamlSequence:
+		if len(n.children) == 0 {
+			y.space(prefix)
+			y.w.WriteString("[]")
+			return nil
+		}
+		for i, item := range n.children {
+			y.entry(depth, i == 0 && inline, prefix)
+			y.w.WriteString("-")
+			if err := y.value(item, depth+2, true, y.compact(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case yamlMapping, yamlStruct:
+		if len(n.children) == 0 {
+			y.space(prefix)
+			y.w.WriteString("{}")
+			return nil
+		}
+		for i := 0; i < len(n.children); i += 2 {
+			y.entry(depth, i == 0 && inline, prefix)
+			key, _ := n.children[i].resolve()
+			if key.kind != yamlScalar || key.tag != "" {
+				return errors.New("yaml: map key should be an untagged scalar")
+			}
+			y.w.WriteString(key.text)
+			y.w.WriteString(":")
+			if err := y.value(n.children[i+1], depth+2, true, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("yaml: unknown node kind %d", n.kind)
+	}
+}
+
+// space separates the value from the preceding indicator
+func (y *yamlWriter) space(prefix bool) {
+	if prefix {
+		y.w.WriteString(" ")
+	}
+}
+
+// entry starts an entry of a collection, in the current line or a new line
+func (y *yamlWriter) entry(depth int, inline, prefix bool) {
+	if inline {
+		y.space(prefix)
+	} else {
+		y.indent(depth)
+	}
+}
+
+// compact returns whether the sequence item n can start in the line of its indicator
+func (y *yamlWriter) compact(n *yamlNode) bool {
+	n, shared := n.resolve()
+	return !shared && n.tag == "" && (n.kind == yamlSequence || n.kind == yamlMapping ||
+		n.kind == yamlStruct) && len(n.children) > 0
+}
+
+// EncodeYAML writes obj to w as a YAML document. Struct fields are selected by conf (Propertier,
+// Version) if given, map entries are in the order of sorted keys.
+func EncodeYAML(w io.Writer, obj interface{}, conf ...*TraverseConf) error {
+	c := confOf(conf...)
+	c.ContainerEnd = true
+	c.SortMapKeys = true
+	c.TrackReferences = true
+	c.AsyncLeaves = 0
+	scanner := yamlScanner{shared: make(map[refKey]struct{})}
+	tr, err := NewTraveller(scanner, c)
+	if err != nil {
+		return err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return err
+	}
+	builder := &yamlBuilder{conf: c, shared: scanner.shared, targets: make(map[refKey]*yamlNode)}
+	if tr, err = NewTraveller(builder, c); err != nil {
+		return err
+	}
+	if err = tr.Traverse(NewContext(), obj); err != nil {
+		return err
+	}
+	if builder.root == nil {
+		return errors.New("yaml: nothing to encode")
+	}
+	y := &yamlWriter{w: bufio.NewWriter(w)}
+	if err = y.value(builder.root, 0, false, true); err != nil {
+		return err
+	}
+	y.w.WriteString("\n")
+	return y.w.Flush()
+}
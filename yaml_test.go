@@ -0,0 +1,115 @@
+/*
+ *    Copyright 2023 Stephen Guo
+ *
+ *    Licensed under the Apache License, Version 2.0 (the "License");
+ *    you may not use this file except in compliance with the License.
+ *    You may obtain a copy of the License at
+ *
+ *        http://www.apache.org/licenses/LICENSE-2.0
+ *
+ *    Unless required by applicable law or agreed to in writing, software
+ *    distributed under the License is distributed on an "AS IS" BASIS,
+ *    WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ *    See the License for the specific language governing permissions and
+ *    limitations under the License.
+ *
+ */
+
+package dfpt
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+type celsius float64
+
+func (c celsius) String() string { return fmt.Sprintf("%g°C", float64(c)) }
+
+type yamlDoc struct {
+	Title   string
+	Notes   string
+	Owner   *listNode
+	Backup  *listNode
+	Counts  map[string]int
+	Items   [][]int
+	Data    []byte
+	Reading fmt.Stringer
+	Missing *int
+}
+
+func TestEncodeYAML(t *testing.T) {
+	owner := &listNode{V: 1}
+	owner.Next = owner
+	doc := yamlDoc{
+		Title:   "true",
+		Notes:   "first\nsecond\n",
+		Owner:   owner,
+		Backup:  owner,
+		Counts:  map[string]int{"b": 2, "a": 1},
+		Items:   [][]int{{1, 2}, {}},
+		Data:    []byte("hi"),
+		Reading: celsius(21.5),
+	}
+	types := NewTypeRegistry()
+	if err := types.Register((*fmt.Stringer)(nil), "celsius", celsius(0)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := EncodeYAML(&buf, &doc, &TraverseConf{Types: types}); err != nil {
+		t.Fatal(err)
+	}
+	want := `Title: "true"
+Notes: |
+  first
+  second
+Owner: &a1
+  V: 1
+  Next: *a1
+Backup: *a1
+Counts:
+  a: 1
+  b: 2
+Items:
+  - - 1
+    - 2
+  - []
+Data: !!binary aGk=
+Reading: !celsius 21.5
+Missing: null
+`
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestEncodeYAMLMapKey(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeYAML(&buf, map[[2]int]string{{1, 2}: "x"}); err == nil {
+		t.Fatal("non-scalar map key should fail")
+	}
+}
+
+func TestEncodeYAMLInterface(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": []int{1, 2},
+		"b": "2001-12-14",
+		"c": map[interface{}]interface{}{"d": nil, 1: "2001-12-14 21:59:43.10 -5"},
+	}
+	var buf bytes.Buffer
+	if err := EncodeYAML(&buf, doc); err != nil {
+		t.Fatal(err)
+	}
+	want := `a:
+  - 1
+  - 2
+b: "2001-12-14"
+c:
+  1: "2001-12-14 21:59:43.10 -5"
+  d: null
+`
+	if buf.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}